@@ -0,0 +1,21 @@
+CREATE TABLE exercise_set_new (
+    id            integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    exercise_id   integer NOT NULL,
+    workout_id    integer NOT NULL,
+    created_utc_s integer NOT NULL,
+    repetitions   integer NOT NULL,
+    weight        integer,
+    added_weight  integer,
+    note          text,
+
+    FOREIGN KEY (exercise_id) REFERENCES exercise (id),
+    FOREIGN KEY (workout_id) REFERENCES workout (id) ON DELETE CASCADE
+);
+
+INSERT INTO exercise_set_new (id, exercise_id, workout_id, created_utc_s, repetitions, weight, note)
+SELECT id, exercise_id, workout_id, created_utc_s, repetitions, weight, note
+FROM exercise_set;
+
+DROP TABLE exercise_set;
+
+ALTER TABLE exercise_set_new RENAME TO exercise_set;
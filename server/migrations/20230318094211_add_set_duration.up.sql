@@ -0,0 +1 @@
+ALTER TABLE exercise_set ADD COLUMN duration_s integer DEFAULT NULL;
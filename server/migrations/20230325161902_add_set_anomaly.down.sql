@@ -0,0 +1 @@
+DROP TABLE set_anomaly;
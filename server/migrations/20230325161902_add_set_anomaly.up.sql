@@ -0,0 +1,9 @@
+CREATE TABLE set_anomaly (
+    id              integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    exercise_set_id integer NOT NULL,
+    code            text    NOT NULL,
+    message         text    NOT NULL,
+    created_utc_s   integer NOT NULL,
+
+    FOREIGN KEY (exercise_set_id) REFERENCES exercise_set (id) ON DELETE CASCADE
+);
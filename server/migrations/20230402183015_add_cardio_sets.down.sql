@@ -0,0 +1,3 @@
+ALTER TABLE exercise_set DROP COLUMN distance_m;
+
+ALTER TABLE exercise DROP COLUMN modality;
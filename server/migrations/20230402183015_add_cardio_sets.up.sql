@@ -0,0 +1,3 @@
+ALTER TABLE exercise ADD COLUMN modality text NOT NULL DEFAULT 'strength';
+
+ALTER TABLE exercise_set ADD COLUMN distance_m integer DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE exercise_set ADD COLUMN set_type text NOT NULL DEFAULT 'working';
@@ -0,0 +1,3 @@
+DROP INDEX exercise_set_workout_id_position;
+
+ALTER TABLE exercise_set DROP COLUMN position;
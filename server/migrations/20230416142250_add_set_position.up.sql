@@ -0,0 +1,12 @@
+ALTER TABLE exercise_set ADD COLUMN position integer NOT NULL DEFAULT 0;
+
+UPDATE exercise_set
+SET position = (
+    SELECT COUNT(*)
+    FROM exercise_set es
+    WHERE es.workout_id = exercise_set.workout_id
+        AND (es.created_utc_s < exercise_set.created_utc_s
+            OR (es.created_utc_s = exercise_set.created_utc_s AND es.id < exercise_set.id))
+);
+
+CREATE INDEX exercise_set_workout_id_position ON exercise_set (workout_id, position);
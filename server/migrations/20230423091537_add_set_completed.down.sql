@@ -0,0 +1 @@
+ALTER TABLE exercise_set DROP COLUMN completed;
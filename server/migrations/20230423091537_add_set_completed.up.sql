@@ -0,0 +1 @@
+ALTER TABLE exercise_set ADD COLUMN completed boolean NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+ALTER TABLE exercise_set DROP COLUMN target_weight;
+
+ALTER TABLE exercise_set DROP COLUMN target_repetitions;
@@ -0,0 +1,3 @@
+ALTER TABLE exercise_set ADD COLUMN target_repetitions integer DEFAULT NULL;
+
+ALTER TABLE exercise_set ADD COLUMN target_weight integer DEFAULT NULL;
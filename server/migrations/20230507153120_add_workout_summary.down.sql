@@ -0,0 +1 @@
+DROP TABLE workout_summary;
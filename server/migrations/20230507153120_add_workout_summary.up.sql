@@ -0,0 +1,12 @@
+CREATE TABLE workout_summary (
+    workout_id           integer NOT NULL PRIMARY KEY,
+    end_utc_s            integer NOT NULL,
+    total_sets           integer NOT NULL,
+    repetition_sets      integer NOT NULL,
+    total_repetitions    integer NOT NULL,
+    total_volume         integer NOT NULL,
+    total_set_duration_s integer NOT NULL,
+    archived_utc_s       integer NOT NULL,
+
+    FOREIGN KEY (workout_id) REFERENCES workout (id) ON DELETE CASCADE
+);
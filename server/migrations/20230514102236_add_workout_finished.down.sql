@@ -0,0 +1,3 @@
+DROP TABLE workout_audit;
+
+ALTER TABLE workout DROP COLUMN finished_utc_s;
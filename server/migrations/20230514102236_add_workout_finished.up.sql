@@ -0,0 +1,10 @@
+ALTER TABLE workout ADD COLUMN finished_utc_s integer DEFAULT NULL;
+
+CREATE TABLE workout_audit (
+    id            integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    workout_id    integer NOT NULL,
+    action        text    NOT NULL,
+    created_utc_s integer NOT NULL,
+
+    FOREIGN KEY (workout_id) REFERENCES workout (id) ON DELETE CASCADE
+);
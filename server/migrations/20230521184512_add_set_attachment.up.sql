@@ -0,0 +1,13 @@
+CREATE TABLE set_attachment (
+    id              integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    exercise_set_id integer NOT NULL,
+    content_type    text,
+    file_name       text,
+    url             text,
+    data            blob,
+    created_utc_s   integer NOT NULL,
+
+    FOREIGN KEY (exercise_set_id) REFERENCES exercise_set (id) ON DELETE CASCADE
+);
+
+CREATE INDEX set_attachment_exercise_set_id ON set_attachment (exercise_set_id);
@@ -0,0 +1,3 @@
+DROP INDEX exercise_set_client_id;
+
+ALTER TABLE exercise_set DROP COLUMN client_id;
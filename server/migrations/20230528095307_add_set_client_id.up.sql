@@ -0,0 +1,3 @@
+ALTER TABLE exercise_set ADD COLUMN client_id text DEFAULT NULL;
+
+CREATE UNIQUE INDEX exercise_set_client_id ON exercise_set (client_id) WHERE client_id IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE exercise_set ADD COLUMN plates text DEFAULT NULL;
@@ -0,0 +1,3 @@
+DROP TABLE correction_set;
+
+DROP TABLE correction;
@@ -0,0 +1,21 @@
+CREATE TABLE correction (
+    id            integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    description   text    NOT NULL,
+    affected_sets integer NOT NULL,
+    created_utc_s integer NOT NULL
+);
+
+CREATE TABLE correction_set (
+    correction_id     integer NOT NULL,
+    exercise_set_id   integer NOT NULL,
+    old_weight        integer,
+    new_weight        integer,
+    old_added_weight  integer,
+    new_added_weight  integer,
+    old_target_weight integer,
+    new_target_weight integer,
+
+    PRIMARY KEY (correction_id, exercise_set_id),
+    FOREIGN KEY (correction_id) REFERENCES correction (id) ON DELETE CASCADE,
+    FOREIGN KEY (exercise_set_id) REFERENCES exercise_set (id) ON DELETE CASCADE
+);
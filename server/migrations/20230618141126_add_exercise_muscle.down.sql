@@ -0,0 +1 @@
+DROP TABLE exercise_muscle;
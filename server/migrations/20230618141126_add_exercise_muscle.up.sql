@@ -0,0 +1,10 @@
+CREATE TABLE exercise_muscle (
+    exercise_id integer NOT NULL,
+    muscle      text    NOT NULL,
+    role        text    NOT NULL,
+
+    PRIMARY KEY (exercise_id, muscle),
+    FOREIGN KEY (exercise_id) REFERENCES exercise (id) ON DELETE CASCADE
+);
+
+CREATE INDEX exercise_muscle_muscle ON exercise_muscle (muscle);
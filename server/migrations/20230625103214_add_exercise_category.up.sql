@@ -0,0 +1 @@
+ALTER TABLE exercise ADD COLUMN category text;
@@ -0,0 +1,3 @@
+ALTER TABLE exercise DROP COLUMN video_url;
+ALTER TABLE exercise DROP COLUMN instructions;
+ALTER TABLE exercise DROP COLUMN description;
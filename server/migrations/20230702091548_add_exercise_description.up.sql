@@ -0,0 +1,3 @@
+ALTER TABLE exercise ADD COLUMN description text;
+ALTER TABLE exercise ADD COLUMN instructions text;
+ALTER TABLE exercise ADD COLUMN video_url text;
@@ -0,0 +1,3 @@
+DROP TABLE gym_equipment;
+DROP TABLE gym;
+DROP TABLE exercise_equipment;
@@ -0,0 +1,20 @@
+CREATE TABLE exercise_equipment (
+    exercise_id integer NOT NULL,
+    equipment   text    NOT NULL,
+
+    PRIMARY KEY (exercise_id, equipment),
+    FOREIGN KEY (exercise_id) REFERENCES exercise (id) ON DELETE CASCADE
+);
+
+CREATE TABLE gym (
+    id   integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name text    NOT NULL
+);
+
+CREATE TABLE gym_equipment (
+    gym_id    integer NOT NULL,
+    equipment text    NOT NULL,
+
+    PRIMARY KEY (gym_id, equipment),
+    FOREIGN KEY (gym_id) REFERENCES gym (id) ON DELETE CASCADE
+);
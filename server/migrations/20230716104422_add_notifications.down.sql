@@ -0,0 +1,2 @@
+DROP TABLE notification_rule;
+DROP TABLE notification_channel;
@@ -0,0 +1,15 @@
+CREATE TABLE notification_channel (
+    id            integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name          text    NOT NULL,
+    transport     text    NOT NULL,
+    created_utc_s integer NOT NULL
+);
+
+CREATE TABLE notification_rule (
+    id         integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    event      text    NOT NULL,
+    channel_id integer NOT NULL,
+
+    UNIQUE (event, channel_id),
+    FOREIGN KEY (channel_id) REFERENCES notification_channel (id) ON DELETE CASCADE
+);
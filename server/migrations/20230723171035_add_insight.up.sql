@@ -0,0 +1,12 @@
+CREATE TABLE insight (
+    id                 integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    rule               text    NOT NULL,
+    subject            text    NOT NULL,
+    title              text    NOT NULL,
+    message            text    NOT NULL,
+    created_utc_s      integer NOT NULL,
+    acknowledged_utc_s integer,
+    dismissed_utc_s    integer,
+
+    UNIQUE (rule, subject)
+);
@@ -0,0 +1 @@
+ALTER TABLE exercise DROP COLUMN favorite;
@@ -0,0 +1 @@
+ALTER TABLE exercise ADD COLUMN favorite boolean NOT NULL DEFAULT 0;
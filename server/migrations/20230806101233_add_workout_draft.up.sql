@@ -0,0 +1,7 @@
+CREATE TABLE workout_draft (
+    workout_id    integer NOT NULL PRIMARY KEY,
+    data          text    NOT NULL,
+    updated_utc_s integer NOT NULL,
+
+    FOREIGN KEY (workout_id) REFERENCES workout (id) ON DELETE CASCADE
+);
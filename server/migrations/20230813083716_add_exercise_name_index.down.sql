@@ -0,0 +1 @@
+DROP INDEX exercise_name;
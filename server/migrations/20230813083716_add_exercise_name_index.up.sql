@@ -0,0 +1,12 @@
+-- Exercise names were not checked for duplicates before. Names that only
+-- differ in case from the name of an older exercise are renamed by appending
+-- the id, e.g. "bench press" becomes "bench press (12)", so the oldest
+-- exercise keeps its name. Sets stay with the renamed exercise, which can be
+-- renamed again or merged by hand. See CHANGELOG.md.
+UPDATE exercise
+SET name = name || ' (' || id || ')'
+WHERE EXISTS (
+    SELECT 1 FROM exercise e WHERE e.name = exercise.name COLLATE NOCASE AND e.id < exercise.id
+);
+
+CREATE UNIQUE INDEX exercise_name ON exercise (name COLLATE NOCASE);
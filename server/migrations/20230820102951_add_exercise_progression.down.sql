@@ -0,0 +1,3 @@
+ALTER TABLE exercise DROP COLUMN max_repetitions;
+ALTER TABLE exercise DROP COLUMN min_repetitions;
+ALTER TABLE exercise DROP COLUMN default_increment;
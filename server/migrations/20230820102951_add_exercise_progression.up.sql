@@ -0,0 +1,3 @@
+ALTER TABLE exercise ADD COLUMN default_increment integer;
+ALTER TABLE exercise ADD COLUMN min_repetitions integer;
+ALTER TABLE exercise ADD COLUMN max_repetitions integer;
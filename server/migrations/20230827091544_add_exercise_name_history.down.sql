@@ -0,0 +1,2 @@
+DROP INDEX exercise_name_history_exercise_id;
+DROP TABLE exercise_name_history;
@@ -0,0 +1,10 @@
+CREATE TABLE exercise_name_history (
+    id            integer NOT NULL PRIMARY KEY,
+    exercise_id   integer NOT NULL,
+    name          text    NOT NULL,
+    renamed_utc_s integer NOT NULL,
+
+    FOREIGN KEY (exercise_id) REFERENCES exercise (id) ON DELETE CASCADE
+);
+
+CREATE INDEX exercise_name_history_exercise_id ON exercise_name_history (exercise_id);
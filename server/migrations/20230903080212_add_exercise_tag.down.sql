@@ -0,0 +1,2 @@
+DROP INDEX exercise_tag_tag;
+DROP TABLE exercise_tag;
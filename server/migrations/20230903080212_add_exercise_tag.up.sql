@@ -0,0 +1,9 @@
+CREATE TABLE exercise_tag (
+    exercise_id integer NOT NULL,
+    tag         text    NOT NULL,
+
+    PRIMARY KEY (exercise_id, tag),
+    FOREIGN KEY (exercise_id) REFERENCES exercise (id) ON DELETE CASCADE
+);
+
+CREATE INDEX exercise_tag_tag ON exercise_tag (tag);
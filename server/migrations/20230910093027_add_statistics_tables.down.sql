@@ -0,0 +1,6 @@
+DROP TRIGGER exercise_set_delete_statistics;
+DROP TRIGGER exercise_set_update_statistics;
+DROP TRIGGER exercise_set_insert_statistics;
+DROP INDEX exercise_set_exercise_id;
+DROP TABLE exercise_statistics;
+DROP TABLE workout_statistics;
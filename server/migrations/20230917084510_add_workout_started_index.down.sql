@@ -0,0 +1 @@
+DROP INDEX workout_started_utc_s;
@@ -0,0 +1 @@
+CREATE INDEX workout_started_utc_s ON workout (started_utc_s);
@@ -0,0 +1,2 @@
+DROP INDEX pr_event_created_utc_s;
+DROP TABLE pr_event;
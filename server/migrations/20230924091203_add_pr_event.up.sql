@@ -0,0 +1,13 @@
+CREATE TABLE pr_event (
+    id              integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    exercise_set_id integer NOT NULL,
+    kind            text    NOT NULL,
+    value           real    NOT NULL,
+    previous_value  real    NOT NULL,
+    created_utc_s   integer NOT NULL,
+
+    UNIQUE (exercise_set_id, kind),
+    FOREIGN KEY (exercise_set_id) REFERENCES exercise_set (id) ON DELETE CASCADE
+);
+
+CREATE INDEX pr_event_created_utc_s ON pr_event (created_utc_s);
@@ -0,0 +1 @@
+DROP TABLE rest_timer;
@@ -0,0 +1,7 @@
+CREATE TABLE rest_timer (
+    workout_id    integer NOT NULL PRIMARY KEY,
+    started_utc_s integer NOT NULL,
+    duration_s    integer NOT NULL,
+
+    FOREIGN KEY (workout_id) REFERENCES workout (id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+CREATE TABLE goal (
+    id             integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    kind           text    NOT NULL,
+    exercise_id    integer,
+    target         integer NOT NULL,
+    deadline_utc_s integer,
+    created_utc_s  integer NOT NULL,
+
+    FOREIGN KEY (exercise_id) REFERENCES exercise (id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP TABLE body_weight;
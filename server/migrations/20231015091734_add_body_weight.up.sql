@@ -0,0 +1,7 @@
+CREATE TABLE body_weight (
+    id             integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    weight         integer NOT NULL,
+    measured_utc_s integer NOT NULL
+);
+
+CREATE INDEX body_weight_measured_utc_s ON body_weight (measured_utc_s);
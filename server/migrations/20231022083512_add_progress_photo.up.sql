@@ -0,0 +1,9 @@
+CREATE TABLE progress_photo (
+    id            integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+    content_type  text    NOT NULL,
+    size          integer NOT NULL,
+    taken_utc_s   integer NOT NULL,
+    created_utc_s integer NOT NULL
+);
+
+CREATE INDEX progress_photo_taken_utc_s ON progress_photo (taken_utc_s);
@@ -0,0 +1 @@
+DROP TABLE google_fit_session;
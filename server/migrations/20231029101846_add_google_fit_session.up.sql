@@ -0,0 +1,7 @@
+CREATE TABLE google_fit_session (
+    workout_id     integer NOT NULL PRIMARY KEY,
+    finished_utc_s integer NOT NULL,
+    synced_utc_s   integer NOT NULL,
+
+    FOREIGN KEY (workout_id) REFERENCES workout (id) ON DELETE CASCADE
+);
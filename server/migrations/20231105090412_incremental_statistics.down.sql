@@ -0,0 +1,177 @@
+DROP TRIGGER exercise_set_update_statistics;
+DROP TRIGGER exercise_set_delete_statistics;
+DROP TRIGGER exercise_set_insert_statistics;
+DROP TABLE workout_exercise_statistics;
+
+CREATE TABLE workout_statistics (
+    workout_id  integer NOT NULL PRIMARY KEY,
+    set_count   integer NOT NULL,
+    repetitions integer NOT NULL,
+    tonnage     integer NOT NULL
+);
+
+INSERT INTO workout_statistics (workout_id, set_count, repetitions, tonnage)
+SELECT
+    workout_id,
+    COUNT(*) FILTER (WHERE completed AND set_type != 'warmup'),
+    COALESCE(SUM(repetitions) FILTER (WHERE completed AND set_type != 'warmup'), 0),
+    COALESCE(
+        SUM(repetitions * weight) FILTER (
+            WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+        ),
+        0
+    )
+FROM exercise_set
+GROUP BY workout_id;
+
+CREATE TRIGGER exercise_set_insert_statistics AFTER INSERT ON exercise_set
+BEGIN
+    DELETE FROM workout_statistics WHERE workout_id = NEW.workout_id;
+    INSERT INTO workout_statistics (workout_id, set_count, repetitions, tonnage)
+    SELECT
+        NEW.workout_id,
+        COUNT(*) FILTER (WHERE completed AND set_type != 'warmup'),
+        COALESCE(SUM(repetitions) FILTER (WHERE completed AND set_type != 'warmup'), 0),
+        COALESCE(
+            SUM(repetitions * weight) FILTER (
+                WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+            ),
+            0
+        )
+    FROM exercise_set
+    WHERE workout_id = NEW.workout_id
+    HAVING COUNT(*) > 0;
+
+    DELETE FROM exercise_statistics WHERE exercise_id = NEW.exercise_id;
+    INSERT INTO exercise_statistics (
+        exercise_id, last_used_utc_s, usage_count, best_weight, best_one_rep_max
+    )
+    SELECT
+        NEW.exercise_id,
+        MAX(created_utc_s),
+        COUNT(DISTINCT workout_id),
+        MAX(weight) FILTER (WHERE completed AND set_type != 'warmup' AND duration_s IS NULL),
+        MAX(
+            CASE WHEN repetitions = 1 THEN weight ELSE weight * (1 + repetitions / 30.0) END
+        ) FILTER (
+            WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+                AND weight > 0 AND repetitions > 0
+        )
+    FROM exercise_set
+    WHERE exercise_id = NEW.exercise_id
+    HAVING COUNT(*) > 0;
+END;
+
+CREATE TRIGGER exercise_set_update_statistics
+AFTER UPDATE OF
+    workout_id, exercise_id, repetitions, weight, duration_s, set_type, completed, created_utc_s
+ON exercise_set
+BEGIN
+    DELETE FROM workout_statistics WHERE workout_id = OLD.workout_id;
+    INSERT INTO workout_statistics (workout_id, set_count, repetitions, tonnage)
+    SELECT
+        OLD.workout_id,
+        COUNT(*) FILTER (WHERE completed AND set_type != 'warmup'),
+        COALESCE(SUM(repetitions) FILTER (WHERE completed AND set_type != 'warmup'), 0),
+        COALESCE(
+            SUM(repetitions * weight) FILTER (
+                WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+            ),
+            0
+        )
+    FROM exercise_set
+    WHERE workout_id = OLD.workout_id
+    HAVING COUNT(*) > 0;
+
+    DELETE FROM workout_statistics WHERE workout_id = NEW.workout_id;
+    INSERT INTO workout_statistics (workout_id, set_count, repetitions, tonnage)
+    SELECT
+        NEW.workout_id,
+        COUNT(*) FILTER (WHERE completed AND set_type != 'warmup'),
+        COALESCE(SUM(repetitions) FILTER (WHERE completed AND set_type != 'warmup'), 0),
+        COALESCE(
+            SUM(repetitions * weight) FILTER (
+                WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+            ),
+            0
+        )
+    FROM exercise_set
+    WHERE workout_id = NEW.workout_id
+    HAVING COUNT(*) > 0;
+
+    DELETE FROM exercise_statistics WHERE exercise_id = OLD.exercise_id;
+    INSERT INTO exercise_statistics (
+        exercise_id, last_used_utc_s, usage_count, best_weight, best_one_rep_max
+    )
+    SELECT
+        OLD.exercise_id,
+        MAX(created_utc_s),
+        COUNT(DISTINCT workout_id),
+        MAX(weight) FILTER (WHERE completed AND set_type != 'warmup' AND duration_s IS NULL),
+        MAX(
+            CASE WHEN repetitions = 1 THEN weight ELSE weight * (1 + repetitions / 30.0) END
+        ) FILTER (
+            WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+                AND weight > 0 AND repetitions > 0
+        )
+    FROM exercise_set
+    WHERE exercise_id = OLD.exercise_id
+    HAVING COUNT(*) > 0;
+
+    DELETE FROM exercise_statistics WHERE exercise_id = NEW.exercise_id;
+    INSERT INTO exercise_statistics (
+        exercise_id, last_used_utc_s, usage_count, best_weight, best_one_rep_max
+    )
+    SELECT
+        NEW.exercise_id,
+        MAX(created_utc_s),
+        COUNT(DISTINCT workout_id),
+        MAX(weight) FILTER (WHERE completed AND set_type != 'warmup' AND duration_s IS NULL),
+        MAX(
+            CASE WHEN repetitions = 1 THEN weight ELSE weight * (1 + repetitions / 30.0) END
+        ) FILTER (
+            WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+                AND weight > 0 AND repetitions > 0
+        )
+    FROM exercise_set
+    WHERE exercise_id = NEW.exercise_id
+    HAVING COUNT(*) > 0;
+END;
+
+CREATE TRIGGER exercise_set_delete_statistics AFTER DELETE ON exercise_set
+BEGIN
+    DELETE FROM workout_statistics WHERE workout_id = OLD.workout_id;
+    INSERT INTO workout_statistics (workout_id, set_count, repetitions, tonnage)
+    SELECT
+        OLD.workout_id,
+        COUNT(*) FILTER (WHERE completed AND set_type != 'warmup'),
+        COALESCE(SUM(repetitions) FILTER (WHERE completed AND set_type != 'warmup'), 0),
+        COALESCE(
+            SUM(repetitions * weight) FILTER (
+                WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+            ),
+            0
+        )
+    FROM exercise_set
+    WHERE workout_id = OLD.workout_id
+    HAVING COUNT(*) > 0;
+
+    DELETE FROM exercise_statistics WHERE exercise_id = OLD.exercise_id;
+    INSERT INTO exercise_statistics (
+        exercise_id, last_used_utc_s, usage_count, best_weight, best_one_rep_max
+    )
+    SELECT
+        OLD.exercise_id,
+        MAX(created_utc_s),
+        COUNT(DISTINCT workout_id),
+        MAX(weight) FILTER (WHERE completed AND set_type != 'warmup' AND duration_s IS NULL),
+        MAX(
+            CASE WHEN repetitions = 1 THEN weight ELSE weight * (1 + repetitions / 30.0) END
+        ) FILTER (
+            WHERE completed AND set_type != 'warmup' AND duration_s IS NULL
+                AND weight > 0 AND repetitions > 0
+        )
+    FROM exercise_set
+    WHERE exercise_id = OLD.exercise_id
+    HAVING COUNT(*) > 0;
+END;
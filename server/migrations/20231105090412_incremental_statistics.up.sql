@@ -0,0 +1,361 @@
+DROP TRIGGER exercise_set_delete_statistics;
+DROP TRIGGER exercise_set_update_statistics;
+DROP TRIGGER exercise_set_insert_statistics;
+DROP TABLE workout_statistics;
+
+-- Totals of the completed sets of an exercise within a workout, rows without
+-- completed sets are removed. Every column except end_utc_s is a sum, so
+-- the triggers add or subtract the contribution of a single set.
+CREATE TABLE workout_exercise_statistics (
+    workout_id             integer NOT NULL,
+    exercise_id            integer NOT NULL,
+    end_utc_s              integer NOT NULL,
+    set_count              integer NOT NULL,
+    working_set_count      integer NOT NULL,
+    repetition_set_count   integer NOT NULL,
+    repetitions            integer NOT NULL,
+    tonnage                integer NOT NULL,
+    bodyweight_repetitions integer NOT NULL,
+    added_tonnage          integer NOT NULL,
+    set_duration_s         integer NOT NULL,
+    distance_m             integer NOT NULL,
+
+    PRIMARY KEY (workout_id, exercise_id)
+);
+
+INSERT INTO workout_exercise_statistics
+SELECT
+    workout_id,
+    exercise_id,
+    MAX(created_utc_s),
+    COUNT(*),
+    COUNT(*) FILTER (WHERE set_type != 'warmup'),
+    COUNT(*) FILTER (WHERE duration_s IS NULL),
+    COALESCE(SUM(repetitions) FILTER (WHERE duration_s IS NULL), 0),
+    COALESCE(
+        SUM(repetitions * weight) FILTER (WHERE set_type != 'warmup' AND duration_s IS NULL),
+        0
+    ),
+    COALESCE(
+        SUM(repetitions) FILTER (
+            WHERE set_type != 'warmup' AND duration_s IS NULL AND weight IS NULL
+        ),
+        0
+    ),
+    COALESCE(
+        SUM(repetitions * added_weight) FILTER (
+            WHERE set_type != 'warmup' AND duration_s IS NULL AND weight IS NULL
+        ),
+        0
+    ),
+    COALESCE(SUM(duration_s), 0),
+    COALESCE(SUM(distance_m), 0)
+FROM exercise_set
+WHERE completed
+GROUP BY workout_id, exercise_id;
+
+-- Adds the contribution of a set. Maxima of exercise_statistics only grow,
+-- the usage count grows if the set is the first of its exercise in the
+-- workout.
+CREATE TRIGGER exercise_set_insert_statistics AFTER INSERT ON exercise_set
+BEGIN
+    INSERT INTO workout_exercise_statistics
+    SELECT
+        NEW.workout_id,
+        NEW.exercise_id,
+        NEW.created_utc_s,
+        1,
+        NEW.set_type != 'warmup',
+        NEW.duration_s IS NULL,
+        CASE WHEN NEW.duration_s IS NULL THEN NEW.repetitions ELSE 0 END,
+        CASE WHEN NEW.set_type != 'warmup' AND NEW.duration_s IS NULL
+            THEN COALESCE(NEW.repetitions * NEW.weight, 0) ELSE 0
+        END,
+        CASE WHEN NEW.set_type != 'warmup' AND NEW.duration_s IS NULL AND NEW.weight IS NULL
+            THEN NEW.repetitions ELSE 0
+        END,
+        CASE WHEN NEW.set_type != 'warmup' AND NEW.duration_s IS NULL AND NEW.weight IS NULL
+            THEN NEW.repetitions * COALESCE(NEW.added_weight, 0) ELSE 0
+        END,
+        COALESCE(NEW.duration_s, 0),
+        COALESCE(NEW.distance_m, 0)
+    WHERE NEW.completed
+    ON CONFLICT (workout_id, exercise_id) DO UPDATE SET
+        end_utc_s = MAX(end_utc_s, excluded.end_utc_s),
+        set_count = set_count + excluded.set_count,
+        working_set_count = working_set_count + excluded.working_set_count,
+        repetition_set_count = repetition_set_count + excluded.repetition_set_count,
+        repetitions = repetitions + excluded.repetitions,
+        tonnage = tonnage + excluded.tonnage,
+        bodyweight_repetitions = bodyweight_repetitions + excluded.bodyweight_repetitions,
+        added_tonnage = added_tonnage + excluded.added_tonnage,
+        set_duration_s = set_duration_s + excluded.set_duration_s,
+        distance_m = distance_m + excluded.distance_m;
+
+    INSERT INTO exercise_statistics (
+        exercise_id, last_used_utc_s, usage_count, best_weight, best_one_rep_max
+    )
+    SELECT
+        NEW.exercise_id,
+        NEW.created_utc_s,
+        1,
+        CASE WHEN NEW.completed AND NEW.set_type != 'warmup' AND NEW.duration_s IS NULL
+            THEN NEW.weight
+        END,
+        CASE WHEN NEW.completed AND NEW.set_type != 'warmup' AND NEW.duration_s IS NULL
+                AND NEW.weight > 0 AND NEW.repetitions > 0
+            THEN CASE WHEN NEW.repetitions = 1
+                THEN NEW.weight ELSE NEW.weight * (1 + NEW.repetitions / 30.0)
+            END
+        END
+    WHERE true
+    ON CONFLICT (exercise_id) DO UPDATE SET
+        last_used_utc_s = MAX(COALESCE(last_used_utc_s, 0), excluded.last_used_utc_s),
+        usage_count = usage_count + NOT EXISTS (
+            SELECT 1 FROM exercise_set
+            WHERE exercise_id = NEW.exercise_id AND workout_id = NEW.workout_id
+                AND id != NEW.id
+        ),
+        best_weight = MAX(
+            COALESCE(best_weight, excluded.best_weight),
+            COALESCE(excluded.best_weight, best_weight)
+        ),
+        best_one_rep_max = MAX(
+            COALESCE(best_one_rep_max, excluded.best_one_rep_max),
+            COALESCE(excluded.best_one_rep_max, best_one_rep_max)
+        );
+END;
+
+-- Subtracts the contribution of a set. Maxima are only recomputed from the
+-- remaining sets of the same workout or exercise if the set held them.
+CREATE TRIGGER exercise_set_delete_statistics AFTER DELETE ON exercise_set
+BEGIN
+    UPDATE workout_exercise_statistics SET
+        end_utc_s = CASE WHEN end_utc_s = OLD.created_utc_s
+            THEN COALESCE(
+                (
+                    SELECT MAX(created_utc_s) FROM exercise_set
+                    WHERE workout_id = OLD.workout_id AND exercise_id = OLD.exercise_id
+                        AND completed
+                ),
+                end_utc_s
+            )
+            ELSE end_utc_s
+        END,
+        set_count = set_count - 1,
+        working_set_count = working_set_count - (OLD.set_type != 'warmup'),
+        repetition_set_count = repetition_set_count - (OLD.duration_s IS NULL),
+        repetitions = repetitions
+            - CASE WHEN OLD.duration_s IS NULL THEN OLD.repetitions ELSE 0 END,
+        tonnage = tonnage
+            - CASE WHEN OLD.set_type != 'warmup' AND OLD.duration_s IS NULL
+                THEN COALESCE(OLD.repetitions * OLD.weight, 0) ELSE 0
+            END,
+        bodyweight_repetitions = bodyweight_repetitions
+            - CASE WHEN OLD.set_type != 'warmup' AND OLD.duration_s IS NULL
+                    AND OLD.weight IS NULL
+                THEN OLD.repetitions ELSE 0
+            END,
+        added_tonnage = added_tonnage
+            - CASE WHEN OLD.set_type != 'warmup' AND OLD.duration_s IS NULL
+                    AND OLD.weight IS NULL
+                THEN OLD.repetitions * COALESCE(OLD.added_weight, 0) ELSE 0
+            END,
+        set_duration_s = set_duration_s - COALESCE(OLD.duration_s, 0),
+        distance_m = distance_m - COALESCE(OLD.distance_m, 0)
+    WHERE OLD.completed AND workout_id = OLD.workout_id AND exercise_id = OLD.exercise_id;
+
+    DELETE FROM workout_exercise_statistics
+    WHERE workout_id = OLD.workout_id AND exercise_id = OLD.exercise_id AND set_count = 0;
+
+    UPDATE exercise_statistics SET
+        last_used_utc_s = CASE WHEN last_used_utc_s = OLD.created_utc_s
+            THEN (SELECT MAX(created_utc_s) FROM exercise_set WHERE exercise_id = OLD.exercise_id)
+            ELSE last_used_utc_s
+        END,
+        usage_count = usage_count - NOT EXISTS (
+            SELECT 1 FROM exercise_set
+            WHERE exercise_id = OLD.exercise_id AND workout_id = OLD.workout_id
+                AND id != OLD.id
+        ),
+        best_weight = CASE WHEN best_weight = OLD.weight
+            THEN (
+                SELECT MAX(weight) FROM exercise_set
+                WHERE exercise_id = OLD.exercise_id
+                    AND completed AND set_type != 'warmup' AND duration_s IS NULL
+            )
+            ELSE best_weight
+        END,
+        best_one_rep_max = CASE WHEN OLD.weight > 0 AND OLD.repetitions > 0
+                AND best_one_rep_max = CASE WHEN OLD.repetitions = 1
+                    THEN OLD.weight ELSE OLD.weight * (1 + OLD.repetitions / 30.0)
+                END
+            THEN (
+                SELECT MAX(
+                    CASE WHEN repetitions = 1
+                        THEN weight ELSE weight * (1 + repetitions / 30.0)
+                    END
+                )
+                FROM exercise_set
+                WHERE exercise_id = OLD.exercise_id
+                    AND completed AND set_type != 'warmup' AND duration_s IS NULL
+                    AND weight > 0 AND repetitions > 0
+            )
+            ELSE best_one_rep_max
+        END
+    WHERE exercise_id = OLD.exercise_id;
+
+    DELETE FROM exercise_statistics WHERE exercise_id = OLD.exercise_id AND usage_count = 0;
+END;
+
+-- An update subtracts the contribution of the old set and adds the one of the
+-- new set, like a delete followed by an insert.
+CREATE TRIGGER exercise_set_update_statistics
+AFTER UPDATE OF
+    workout_id, exercise_id, repetitions, weight, added_weight, duration_s, distance_m,
+    set_type, completed, created_utc_s
+ON exercise_set
+BEGIN
+    UPDATE workout_exercise_statistics SET
+        end_utc_s = CASE WHEN end_utc_s = OLD.created_utc_s
+            THEN COALESCE(
+                (
+                    SELECT MAX(created_utc_s) FROM exercise_set
+                    WHERE workout_id = OLD.workout_id AND exercise_id = OLD.exercise_id
+                        AND completed AND id != OLD.id
+                ),
+                end_utc_s
+            )
+            ELSE end_utc_s
+        END,
+        set_count = set_count - 1,
+        working_set_count = working_set_count - (OLD.set_type != 'warmup'),
+        repetition_set_count = repetition_set_count - (OLD.duration_s IS NULL),
+        repetitions = repetitions
+            - CASE WHEN OLD.duration_s IS NULL THEN OLD.repetitions ELSE 0 END,
+        tonnage = tonnage
+            - CASE WHEN OLD.set_type != 'warmup' AND OLD.duration_s IS NULL
+                THEN COALESCE(OLD.repetitions * OLD.weight, 0) ELSE 0
+            END,
+        bodyweight_repetitions = bodyweight_repetitions
+            - CASE WHEN OLD.set_type != 'warmup' AND OLD.duration_s IS NULL
+                    AND OLD.weight IS NULL
+                THEN OLD.repetitions ELSE 0
+            END,
+        added_tonnage = added_tonnage
+            - CASE WHEN OLD.set_type != 'warmup' AND OLD.duration_s IS NULL
+                    AND OLD.weight IS NULL
+                THEN OLD.repetitions * COALESCE(OLD.added_weight, 0) ELSE 0
+            END,
+        set_duration_s = set_duration_s - COALESCE(OLD.duration_s, 0),
+        distance_m = distance_m - COALESCE(OLD.distance_m, 0)
+    WHERE OLD.completed AND workout_id = OLD.workout_id AND exercise_id = OLD.exercise_id;
+
+    DELETE FROM workout_exercise_statistics
+    WHERE workout_id = OLD.workout_id AND exercise_id = OLD.exercise_id AND set_count = 0;
+
+    INSERT INTO workout_exercise_statistics
+    SELECT
+        NEW.workout_id,
+        NEW.exercise_id,
+        NEW.created_utc_s,
+        1,
+        NEW.set_type != 'warmup',
+        NEW.duration_s IS NULL,
+        CASE WHEN NEW.duration_s IS NULL THEN NEW.repetitions ELSE 0 END,
+        CASE WHEN NEW.set_type != 'warmup' AND NEW.duration_s IS NULL
+            THEN COALESCE(NEW.repetitions * NEW.weight, 0) ELSE 0
+        END,
+        CASE WHEN NEW.set_type != 'warmup' AND NEW.duration_s IS NULL AND NEW.weight IS NULL
+            THEN NEW.repetitions ELSE 0
+        END,
+        CASE WHEN NEW.set_type != 'warmup' AND NEW.duration_s IS NULL AND NEW.weight IS NULL
+            THEN NEW.repetitions * COALESCE(NEW.added_weight, 0) ELSE 0
+        END,
+        COALESCE(NEW.duration_s, 0),
+        COALESCE(NEW.distance_m, 0)
+    WHERE NEW.completed
+    ON CONFLICT (workout_id, exercise_id) DO UPDATE SET
+        end_utc_s = MAX(end_utc_s, excluded.end_utc_s),
+        set_count = set_count + excluded.set_count,
+        working_set_count = working_set_count + excluded.working_set_count,
+        repetition_set_count = repetition_set_count + excluded.repetition_set_count,
+        repetitions = repetitions + excluded.repetitions,
+        tonnage = tonnage + excluded.tonnage,
+        bodyweight_repetitions = bodyweight_repetitions + excluded.bodyweight_repetitions,
+        added_tonnage = added_tonnage + excluded.added_tonnage,
+        set_duration_s = set_duration_s + excluded.set_duration_s,
+        distance_m = distance_m + excluded.distance_m;
+
+    UPDATE exercise_statistics SET
+        last_used_utc_s = CASE WHEN last_used_utc_s = OLD.created_utc_s
+            THEN (SELECT MAX(created_utc_s) FROM exercise_set WHERE exercise_id = OLD.exercise_id)
+            ELSE last_used_utc_s
+        END,
+        usage_count = usage_count - NOT EXISTS (
+            SELECT 1 FROM exercise_set
+            WHERE exercise_id = OLD.exercise_id AND workout_id = OLD.workout_id
+                AND id != OLD.id
+        ),
+        best_weight = CASE WHEN best_weight = OLD.weight
+            THEN (
+                SELECT MAX(weight) FROM exercise_set
+                WHERE exercise_id = OLD.exercise_id
+                    AND completed AND set_type != 'warmup' AND duration_s IS NULL
+            )
+            ELSE best_weight
+        END,
+        best_one_rep_max = CASE WHEN OLD.weight > 0 AND OLD.repetitions > 0
+                AND best_one_rep_max = CASE WHEN OLD.repetitions = 1
+                    THEN OLD.weight ELSE OLD.weight * (1 + OLD.repetitions / 30.0)
+                END
+            THEN (
+                SELECT MAX(
+                    CASE WHEN repetitions = 1
+                        THEN weight ELSE weight * (1 + repetitions / 30.0)
+                    END
+                )
+                FROM exercise_set
+                WHERE exercise_id = OLD.exercise_id
+                    AND completed AND set_type != 'warmup' AND duration_s IS NULL
+                    AND weight > 0 AND repetitions > 0
+            )
+            ELSE best_one_rep_max
+        END
+    WHERE exercise_id = OLD.exercise_id;
+
+    DELETE FROM exercise_statistics WHERE exercise_id = OLD.exercise_id AND usage_count = 0;
+
+    INSERT INTO exercise_statistics (
+        exercise_id, last_used_utc_s, usage_count, best_weight, best_one_rep_max
+    )
+    SELECT
+        NEW.exercise_id,
+        NEW.created_utc_s,
+        1,
+        CASE WHEN NEW.completed AND NEW.set_type != 'warmup' AND NEW.duration_s IS NULL
+            THEN NEW.weight
+        END,
+        CASE WHEN NEW.completed AND NEW.set_type != 'warmup' AND NEW.duration_s IS NULL
+                AND NEW.weight > 0 AND NEW.repetitions > 0
+            THEN CASE WHEN NEW.repetitions = 1
+                THEN NEW.weight ELSE NEW.weight * (1 + NEW.repetitions / 30.0)
+            END
+        END
+    WHERE true
+    ON CONFLICT (exercise_id) DO UPDATE SET
+        last_used_utc_s = MAX(COALESCE(last_used_utc_s, 0), excluded.last_used_utc_s),
+        usage_count = usage_count + NOT EXISTS (
+            SELECT 1 FROM exercise_set
+            WHERE exercise_id = NEW.exercise_id AND workout_id = NEW.workout_id
+                AND id != NEW.id
+        ),
+        best_weight = MAX(
+            COALESCE(best_weight, excluded.best_weight),
+            COALESCE(excluded.best_weight, best_weight)
+        ),
+        best_one_rep_max = MAX(
+            COALESCE(best_one_rep_max, excluded.best_one_rep_max),
+            COALESCE(excluded.best_one_rep_max, best_one_rep_max)
+        );
+END;
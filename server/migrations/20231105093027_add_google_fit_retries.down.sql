@@ -0,0 +1,2 @@
+DROP TABLE google_fit_deletion;
+DROP TABLE google_fit_failure;
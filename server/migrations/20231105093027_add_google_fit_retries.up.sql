@@ -0,0 +1,14 @@
+CREATE TABLE google_fit_failure (
+    workout_id     integer NOT NULL PRIMARY KEY,
+    finished_utc_s integer NOT NULL,
+    attempts       integer NOT NULL,
+    retry_utc_s    integer NOT NULL,
+
+    FOREIGN KEY (workout_id) REFERENCES workout (id) ON DELETE CASCADE
+);
+
+CREATE TABLE google_fit_deletion (
+    workout_id  integer NOT NULL PRIMARY KEY,
+    attempts    integer NOT NULL DEFAULT 0,
+    retry_utc_s integer NOT NULL DEFAULT 0
+);
@@ -0,0 +1,2 @@
+DROP TABLE gym_plate;
+ALTER TABLE gym DROP COLUMN bar_weight;
@@ -0,0 +1,10 @@
+ALTER TABLE gym ADD COLUMN bar_weight real DEFAULT NULL;
+
+CREATE TABLE gym_plate (
+    gym_id integer NOT NULL,
+    weight real    NOT NULL,
+    count  integer NOT NULL,
+
+    PRIMARY KEY (gym_id, weight),
+    FOREIGN KEY (gym_id) REFERENCES gym (id) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+DROP TRIGGER workout_insert_statistics_version;
+DROP TRIGGER workout_update_statistics_version;
+DROP TRIGGER workout_delete_statistics_version;
+DROP TRIGGER exercise_insert_statistics_version;
+DROP TRIGGER exercise_update_statistics_version;
+DROP TRIGGER exercise_delete_statistics_version;
+DROP TRIGGER exercise_set_insert_statistics_version;
+DROP TRIGGER exercise_set_update_statistics_version;
+DROP TRIGGER exercise_set_delete_statistics_version;
+DROP TRIGGER exercise_tag_insert_statistics_version;
+DROP TRIGGER exercise_tag_update_statistics_version;
+DROP TRIGGER exercise_tag_delete_statistics_version;
+DROP TRIGGER workout_summary_insert_statistics_version;
+DROP TRIGGER workout_summary_update_statistics_version;
+DROP TRIGGER workout_summary_delete_statistics_version;
+DROP TRIGGER body_weight_insert_statistics_version;
+DROP TRIGGER body_weight_update_statistics_version;
+DROP TRIGGER body_weight_delete_statistics_version;
+DROP TABLE statistics_version;
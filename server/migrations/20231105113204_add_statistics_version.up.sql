@@ -0,0 +1,98 @@
+-- Counter of writes to the data statistics are computed from, which makes
+-- cached statistics stale regardless of the process or code path that wrote.
+CREATE TABLE statistics_version (
+    id      integer NOT NULL PRIMARY KEY CHECK (id = 1),
+    version integer NOT NULL
+);
+
+INSERT INTO statistics_version (id, version) VALUES (1, 0);
+
+CREATE TRIGGER workout_insert_statistics_version AFTER INSERT ON workout
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER workout_update_statistics_version AFTER UPDATE ON workout
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER workout_delete_statistics_version AFTER DELETE ON workout
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_insert_statistics_version AFTER INSERT ON exercise
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_update_statistics_version AFTER UPDATE ON exercise
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_delete_statistics_version AFTER DELETE ON exercise
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_set_insert_statistics_version AFTER INSERT ON exercise_set
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_set_update_statistics_version AFTER UPDATE ON exercise_set
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_set_delete_statistics_version AFTER DELETE ON exercise_set
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_tag_insert_statistics_version AFTER INSERT ON exercise_tag
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_tag_update_statistics_version AFTER UPDATE ON exercise_tag
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER exercise_tag_delete_statistics_version AFTER DELETE ON exercise_tag
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER workout_summary_insert_statistics_version AFTER INSERT ON workout_summary
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER workout_summary_update_statistics_version AFTER UPDATE ON workout_summary
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER workout_summary_delete_statistics_version AFTER DELETE ON workout_summary
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER body_weight_insert_statistics_version AFTER INSERT ON body_weight
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER body_weight_update_statistics_version AFTER UPDATE ON body_weight
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
+
+CREATE TRIGGER body_weight_delete_statistics_version AFTER DELETE ON body_weight
+BEGIN
+    UPDATE statistics_version SET version = version + 1;
+END;
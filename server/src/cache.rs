@@ -0,0 +1,89 @@
+use std::{
+    any::Any,
+    collections::HashMap,
+    sync::{Arc, Mutex, MutexGuard},
+    time::{Duration, Instant},
+};
+
+/// Entries expire after this time even without writes, as some statistics
+/// depend on the current date.
+const MAX_AGE: Duration = Duration::from_secs(60);
+
+/// Upper bound of cached responses, queries are part of the key.
+const MAX_ENTRIES: usize = 256;
+
+/// Responses of expensive statistics kept in memory. Entries are stored with
+/// the statistics version of the database, see
+/// `dal::get_statistics_version`, and are only returned for that version.
+#[derive(Debug, Clone, Default)]
+pub struct StatisticsCache {
+    inner: Arc<Mutex<Inner>>,
+}
+
+#[derive(Debug, Default)]
+struct Inner {
+    generation: i64,
+    entries: HashMap<String, Entry>,
+}
+
+#[derive(Debug)]
+struct Entry {
+    generation: i64,
+    created: Instant,
+    value: Box<dyn Any + Send>,
+}
+
+impl StatisticsCache {
+    /// The generation must be read before the data of a response, so writes
+    /// during the read make its entry stale.
+    pub fn get<T>(&self, key: &str, generation: i64) -> Option<T>
+    where
+        T: Clone + 'static,
+    {
+        let inner = self.lock();
+        inner
+            .entries
+            .get(key)
+            .filter(|entry| entry.generation == generation && entry.created.elapsed() < MAX_AGE)
+            .and_then(|entry| entry.value.downcast_ref::<T>())
+            .cloned()
+    }
+
+    /// Values of an outdated generation are dropped, a newer generation
+    /// drops all entries of older ones.
+    pub fn insert<T>(&self, key: String, generation: i64, value: T)
+    where
+        T: Send + 'static,
+    {
+        let mut inner = self.lock();
+        if generation < inner.generation {
+            return;
+        }
+        if generation > inner.generation {
+            inner.generation = generation;
+            inner.entries.clear();
+        }
+
+        if inner.entries.len() >= MAX_ENTRIES {
+            inner
+                .entries
+                .retain(|_, entry| entry.created.elapsed() < MAX_AGE);
+        }
+        if inner.entries.len() >= MAX_ENTRIES {
+            inner.entries.clear();
+        }
+
+        inner.entries.insert(
+            key,
+            Entry {
+                generation,
+                created: Instant::now(),
+                value: Box::new(value),
+            },
+        );
+    }
+
+    fn lock(&self) -> MutexGuard<'_, Inner> {
+        self.inner.lock().expect("Cache must not be poisoned")
+    }
+}
@@ -0,0 +1,85 @@
+use serde::Deserialize;
+use serde_json::{json, Value};
+
+use crate::{
+    dal::{ExerciseEntity, ProgressionEntity, WeeklyCountEntity},
+    units::WeightConversion,
+};
+
+const SCHEMA: &str = "https://vega.github.io/schema/vega-lite/v5.json";
+
+#[derive(Debug, Clone, Copy, Default, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ProgressionMetric {
+    #[default]
+    E1rm,
+    Volume,
+}
+
+/// Builds a Vega-Lite line chart of the weekly progression of one or more
+/// exercises, with the data inlined.
+pub fn progression(
+    progression: &ProgressionEntity,
+    exercises: &[ExerciseEntity],
+    metric: ProgressionMetric,
+    conversion: WeightConversion,
+) -> Value {
+    let mut values = Vec::new();
+
+    for (series, exercise) in progression.series.iter().zip(exercises) {
+        for (i, week) in progression.weeks.iter().enumerate() {
+            let value = match metric {
+                ProgressionMetric::E1rm => series.estimated_one_rep_max[i]
+                    .map(|estimate| Value::from(conversion.value(estimate))),
+                ProgressionMetric::Volume => {
+                    series.volume[i].map(|volume| Value::from(conversion.weight(volume)))
+                }
+            };
+
+            if let Some(value) = value {
+                values.push(json!({
+                    "week": week,
+                    "exercise": exercise.name,
+                    "value": value,
+                }));
+            }
+        }
+    }
+
+    let (title, axis) = match metric {
+        ProgressionMetric::E1rm => ("Estimated 1RM per week", "Estimated 1RM"),
+        ProgressionMetric::Volume => ("Volume per week", "Volume"),
+    };
+
+    json!({
+        "$schema": SCHEMA,
+        "title": title,
+        "data": { "values": values },
+        "mark": { "type": "line", "point": true },
+        "encoding": {
+            "x": { "field": "week", "type": "temporal", "title": "Week" },
+            "y": { "field": "value", "type": "quantitative", "title": axis },
+            "color": { "field": "exercise", "type": "nominal", "title": "Exercise" },
+        },
+    })
+}
+
+/// Builds a Vega-Lite bar chart of the number of workouts per week, with the
+/// data inlined.
+pub fn workouts_per_week(weeks: &[WeeklyCountEntity]) -> Value {
+    let values = weeks
+        .iter()
+        .map(|week| json!({ "week": week.week, "count": week.count }))
+        .collect::<Vec<_>>();
+
+    json!({
+        "$schema": SCHEMA,
+        "title": "Workouts per week",
+        "data": { "values": values },
+        "mark": "bar",
+        "encoding": {
+            "x": { "field": "week", "type": "temporal", "timeUnit": "yearweek", "title": "Week" },
+            "y": { "field": "count", "type": "quantitative", "title": "Workouts" },
+        },
+    })
+}
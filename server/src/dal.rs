@@ -1,528 +1,4306 @@
+use std::collections::{BTreeSet, HashMap};
+
 use anyhow::{Context, Result};
 use chrono::{DateTime, Utc};
-use sqlx::{FromRow, SqliteExecutor};
+use serde::{Deserialize, Serialize};
+use sqlx::{FromRow, Pool, Sqlite, SqliteExecutor, Transaction};
+
+use crate::{one_rep_max, plates::PlateStock};
+
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "lowercase")]
+#[serde(rename_all = "lowercase")]
+pub enum ExerciseModality {
+    #[default]
+    Strength,
+    Cardio,
+}
+
+/// The equipment an exercise is performed with.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "lowercase")]
+#[serde(rename_all = "lowercase")]
+pub enum ExerciseCategory {
+    Barbell,
+    Dumbbell,
+    Machine,
+    Bodyweight,
+    Cable,
+}
+
+/// Warm-up sets are excluded from personal records and volume statistics.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "lowercase")]
+#[serde(rename_all = "lowercase")]
+pub enum SetType {
+    Warmup,
+    #[default]
+    Working,
+    Drop,
+    Failure,
+}
+
+/// Records a set can beat: the heaviest weight, the best estimated
+/// one-repetition maximum or the most repetitions at its weight.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "snake_case")]
+#[serde(rename_all = "snake_case")]
+pub enum PrKind {
+    Weight,
+    OneRepMax,
+    Repetitions,
+}
+
+/// What a goal targets: the estimated one-repetition maximum of an exercise,
+/// the number of workouts per week or the body weight.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "snake_case")]
+#[serde(rename_all = "snake_case")]
+pub enum GoalKind {
+    OneRepMax,
+    WeeklyWorkouts,
+    BodyWeight,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "lowercase")]
+#[serde(rename_all = "lowercase")]
+pub enum MuscleRole {
+    Primary,
+    Secondary,
+}
+
+#[derive(Debug, FromRow)]
+pub struct ExerciseEntity {
+    pub id: i64,
+    pub name: String,
+    pub modality: ExerciseModality,
+    pub category: Option<ExerciseCategory>,
+    pub description: Option<String>,
+    pub instructions: Option<String>,
+    pub video_url: Option<String>,
+    /// Favorites are listed first when sorted by name.
+    pub favorite: bool,
+    /// Weight added once the top of the repetition range is reached.
+    pub default_increment: Option<i64>,
+    pub min_repetitions: Option<i64>,
+    pub max_repetitions: Option<i64>,
+    /// Comma separated muscle groups the exercise trains.
+    pub primary_muscles: Option<String>,
+    pub secondary_muscles: Option<String>,
+    /// Comma separated equipment the exercise needs.
+    pub equipment: Option<String>,
+    /// Comma separated free-form labels, e.g. `rehab`.
+    pub tags: Option<String>,
+    /// Creation time of the most recent set of the exercise.
+    #[sqlx(rename = "last_used_utc_s")]
+    pub last_used: Option<DateTime<Utc>>,
+    /// Number of workouts the exercise was done in.
+    pub usage_count: i64,
+    /// Heaviest completed working set.
+    pub best_weight: Option<i64>,
+    /// Best estimated one-repetition maximum of the completed working sets.
+    pub best_one_rep_max: Option<f64>,
+}
+
+impl ExerciseEntity {
+    pub fn primary_muscles(&self) -> Vec<String> {
+        split_list(&self.primary_muscles)
+    }
+
+    pub fn secondary_muscles(&self) -> Vec<String> {
+        split_list(&self.secondary_muscles)
+    }
+
+    pub fn equipment(&self) -> Vec<String> {
+        split_list(&self.equipment)
+    }
+
+    pub fn tags(&self) -> Vec<String> {
+        split_list(&self.tags)
+    }
+}
+
+/// Extended result codes of SQLite for violated constraints.
+const SQLITE_CONSTRAINT_UNIQUE: &str = "2067";
+const SQLITE_CONSTRAINT_FOREIGNKEY: &str = "787";
+
+/// Whether a write failed because of a unique constraint, e.g. an exercise
+/// name that is taken already.
+pub fn is_unique_violation(err: &anyhow::Error) -> bool {
+    has_error_code(err, SQLITE_CONSTRAINT_UNIQUE)
+}
+
+/// Whether a write failed because of a foreign key, e.g. deleting an
+/// exercise that still has sets.
+pub fn is_foreign_key_violation(err: &anyhow::Error) -> bool {
+    has_error_code(err, SQLITE_CONSTRAINT_FOREIGNKEY)
+}
+
+fn has_error_code(err: &anyhow::Error, code: &str) -> bool {
+    err.downcast_ref::<sqlx::Error>()
+        .and_then(|err| err.as_database_error())
+        .and_then(|err| err.code())
+        .map_or(false, |actual| actual == code)
+}
+
+/// Splits a list aggregated with `GROUP_CONCAT`, sorted as the aggregation
+/// order is undefined.
+fn split_list(list: &Option<String>) -> Vec<String> {
+    let mut items = list
+        .as_deref()
+        .unwrap_or_default()
+        .split(',')
+        .filter(|item| !item.is_empty())
+        .map(str::to_string)
+        .collect::<Vec<_>>();
+    items.sort();
+    items
+}
+
+/// Values of an exercise that can be written by clients, fields set to
+/// `None` keep their current value on updates and empty texts clear them.
+/// Muscle groups and equipment are expected in lowercase.
+#[derive(Debug)]
+pub struct ExerciseInput {
+    pub name: String,
+    pub modality: Option<ExerciseModality>,
+    pub category: Option<ExerciseCategory>,
+    pub description: Option<String>,
+    pub instructions: Option<String>,
+    pub video_url: Option<String>,
+    /// Zero clears the increment and the repetition range.
+    pub default_increment: Option<i64>,
+    pub min_repetitions: Option<i64>,
+    pub max_repetitions: Option<i64>,
+    pub primary_muscles: Option<Vec<String>>,
+    pub secondary_muscles: Option<Vec<String>>,
+    pub equipment: Option<Vec<String>>,
+    pub tags: Option<Vec<String>>,
+}
+
+#[derive(Debug, Default)]
+pub struct ExerciseFilterInput {
+    /// Only exercises training the muscle group, either primarily or
+    /// secondarily.
+    pub muscle: Option<String>,
+    pub category: Option<ExerciseCategory>,
+    /// Only exercises that can be done with the equipment of the gym.
+    pub gym_id: Option<i64>,
+    pub tag: Option<String>,
+    pub sort: ExerciseSort,
+}
+
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum VolumeGroup {
+    /// Weeks starting on Monday.
+    #[default]
+    Week,
+    Month,
+}
+
+/// Slot of the local time a workout started in.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum TimeSlot {
+    /// Day of the week from 0 (Sunday) to 6.
+    Weekday,
+    /// Hour from 0 to 23.
+    Hour,
+}
+
+#[derive(Debug, Default)]
+pub struct VolumeFilterInput {
+    pub group: VolumeGroup,
+    /// Only exercises training the muscle group, either primarily or
+    /// secondarily.
+    pub muscle: Option<String>,
+    pub exercise_id: Option<i64>,
+}
+
+/// Limits statistics to a part of the sets.
+#[derive(Debug, Default)]
+pub struct StatisticsFilterInput {
+    /// Only sets of exercises with the tag.
+    pub tag: Option<String>,
+    /// Only workouts started within `from` (inclusive) and `to` (exclusive)
+    /// in UTC seconds.
+    pub from: Option<i64>,
+    pub to: Option<i64>,
+}
+
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ExerciseSort {
+    /// Favorite exercises first, then by name.
+    #[default]
+    Name,
+    /// Most recently done exercises first.
+    Recent,
+    /// Exercises done in the most workouts first.
+    Usage,
+}
+
+/// A place to train at with the equipment that is available there. Weights
+/// of the bar and plates are in the unit of the instance.
+#[derive(Debug, FromRow)]
+pub struct GymEntity {
+    pub id: i64,
+    pub name: String,
+    /// Comma separated equipment.
+    pub equipment: Option<String>,
+    pub bar_weight: Option<f64>,
+    /// Comma separated plates as `weight:count`.
+    pub plates: Option<String>,
+}
+
+impl GymEntity {
+    pub fn equipment(&self) -> Vec<String> {
+        split_list(&self.equipment)
+    }
+
+    /// Plates available at the gym, heaviest first.
+    pub fn plates(&self) -> Vec<PlateStock> {
+        let mut plates = split_list(&self.plates)
+            .iter()
+            .filter_map(|plate| {
+                let (weight, count) = plate.split_once(':')?;
+                Some(PlateStock {
+                    weight: weight.parse().ok()?,
+                    count: count.parse().ok()?,
+                })
+            })
+            .collect::<Vec<_>>();
+        plates.sort_by(|a, b| b.weight.total_cmp(&a.weight));
+        plates
+    }
+}
+
+/// A measured body weight in the unit of the instance.
+#[derive(Debug, FromRow)]
+pub struct BodyWeightEntity {
+    pub id: i64,
+    pub weight: i64,
+    #[sqlx(rename = "measured_utc_s")]
+    pub measured: DateTime<Utc>,
+}
+
+/// Body weights are measured now by default.
+#[derive(Debug)]
+pub struct BodyWeightInput {
+    pub weight: i64,
+    pub measured_utc_s: Option<i64>,
+}
+
+/// A progress photo, its file is kept in the photo storage of the instance.
+#[derive(Debug, FromRow)]
+pub struct ProgressPhotoEntity {
+    pub id: i64,
+    pub content_type: String,
+    pub size: i64,
+    #[sqlx(rename = "taken_utc_s")]
+    pub taken: DateTime<Utc>,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+/// Photos are taken now by default.
+#[derive(Debug)]
+pub struct ProgressPhotoInput {
+    pub content_type: String,
+    pub size: i64,
+    pub taken_utc_s: Option<i64>,
+}
+
+/// A finished workout that isn't in Google Fit as it is now, with the number
+/// of its completed sets and their exercises.
+#[derive(Debug, FromRow)]
+pub struct GoogleFitWorkoutEntity {
+    pub id: i64,
+    #[sqlx(rename = "started_utc_s")]
+    pub started: DateTime<Utc>,
+    #[sqlx(rename = "finished_utc_s")]
+    pub finished: DateTime<Utc>,
+    pub note: Option<String>,
+    pub exercises: i64,
+    pub sets: i64,
+}
+
+/// Weights of targets are in the unit of the instance.
+#[derive(Debug, FromRow)]
+pub struct GoalEntity {
+    pub id: i64,
+    pub kind: GoalKind,
+    pub exercise_id: Option<i64>,
+    pub exercise_name: Option<String>,
+    pub target: i64,
+    #[sqlx(rename = "deadline_utc_s")]
+    pub deadline: Option<DateTime<Utc>>,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+/// Only goals for the one-repetition maximum have an exercise.
+#[derive(Debug)]
+pub struct GoalInput {
+    pub kind: GoalKind,
+    pub exercise_id: Option<i64>,
+    pub target: i64,
+    pub deadline_utc_s: Option<i64>,
+}
+
+/// Equipment is expected in lowercase.
+#[derive(Debug)]
+pub struct GymInput {
+    pub name: String,
+    pub equipment: Vec<String>,
+    pub bar_weight: Option<f64>,
+    pub plates: Vec<PlateStock>,
+}
+
+#[derive(Debug, FromRow)]
+pub struct WorkoutEntity {
+    pub id: i64,
+    #[sqlx(rename = "started_utc_s")]
+    pub started: chrono::DateTime<chrono::Utc>,
+    /// Sets of a finished workout can't be changed until the workout is reopened.
+    #[sqlx(rename = "finished_utc_s")]
+    pub finished: Option<chrono::DateTime<chrono::Utc>>,
+    pub note: Option<String>,
+}
+
+#[derive(Debug, FromRow)]
+pub struct SetSuggestionEntity {
+    pub exercise_id: i64,
+    pub repetitions: i64,
+    pub weight: Option<i64>,
+    pub added_weight: Option<i64>,
+    pub duration_s: Option<i64>,
+}
+
+/// A set of a recent session of an exercise, used to recommend its next set.
+#[derive(Debug, FromRow)]
+pub struct HistorySetEntity {
+    pub workout_id: i64,
+    pub repetitions: i64,
+    pub weight: Option<i64>,
+    pub added_weight: Option<i64>,
+    pub duration_s: Option<i64>,
+    pub target_repetitions: Option<i64>,
+    pub target_weight: Option<i64>,
+}
+
+#[derive(Debug, FromRow)]
+pub struct ExerciseSetEntity {
+    pub id: i64,
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub workout_id: i64,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+    pub repetitions: i64,
+    pub weight: Option<i64>,
+    pub added_weight: Option<i64>,
+    pub duration_s: Option<i64>,
+    pub distance_m: Option<i64>,
+    pub set_type: SetType,
+    pub position: i64,
+    pub completed: bool,
+    pub target_repetitions: Option<i64>,
+    pub target_weight: Option<i64>,
+    pub note: Option<String>,
+    /// Plate configuration the weight was entered with, encoded as JSON.
+    pub plates: Option<String>,
+    pub client_id: Option<String>,
+}
+
+/// Values of an exercise set that can be written by clients.
+///
+/// A `weight` of `None` marks a bodyweight set, in which case `added_weight`
+/// holds any additional load like a weight belt. Timed sets like planks carry
+/// their duration in `duration_s`, cardio sets additionally their distance in
+/// `distance_m`. A `set_type` of `None` keeps the current type on updates and
+/// defaults to a working set otherwise. The same applies to `created_utc_s`,
+/// which defaults to the current time, so offline recorded sets keep their
+/// real completion time. Sets are completed unless created as planned sets
+/// with `completed` set to false. Planned values go into the `target_*`
+/// fields, which also keep their current value on updates when `None`.
+/// A `client_id` generated by the client identifies retries of the same set
+/// creation and is ignored on updates. Sets entered as plates keep their
+/// configuration in `plates` next to the resolved `weight`.
+#[derive(Debug)]
+pub struct ExerciseSetInput {
+    pub workout_id: i64,
+    pub exercise_id: i64,
+    pub repetitions: i64,
+    pub weight: Option<i64>,
+    pub added_weight: Option<i64>,
+    pub duration_s: Option<i64>,
+    pub distance_m: Option<i64>,
+    pub set_type: Option<SetType>,
+    pub created_utc_s: Option<i64>,
+    pub completed: Option<bool>,
+    pub target_repetitions: Option<i64>,
+    pub target_weight: Option<i64>,
+    pub note: String,
+    pub plates: Option<String>,
+    pub client_id: Option<String>,
+}
+
+/// Cursor based pagination of a list, `after` is the id of the last item of
+/// the previous page.
+#[derive(Debug, Default, Clone, Copy)]
+pub struct PageInput {
+    pub after: Option<i64>,
+    pub limit: Option<i64>,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "lowercase")]
+#[serde(rename_all = "lowercase")]
+pub enum WorkoutAuditAction {
+    Finish,
+    Reopen,
+}
+
+#[derive(Debug, FromRow)]
+pub struct WorkoutAuditEntity {
+    pub id: i64,
+    pub workout_id: i64,
+    pub action: WorkoutAuditAction,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+/// Values of a partially filled set form, kept as JSON the server doesn't
+/// interpret.
+#[derive(Debug, FromRow)]
+pub struct WorkoutDraftEntity {
+    pub workout_id: i64,
+    pub data: String,
+    #[sqlx(rename = "updated_utc_s")]
+    pub updated: DateTime<Utc>,
+}
+
+/// A rest countdown of a workout, shared by all devices.
+#[derive(Debug, FromRow)]
+pub struct RestTimerEntity {
+    pub workout_id: i64,
+    #[sqlx(rename = "started_utc_s")]
+    pub started: DateTime<Utc>,
+    pub duration_s: i64,
+}
+
+impl RestTimerEntity {
+    /// Seconds left at the given time, zero once the rest is over.
+    pub fn remaining_s(&self, now: DateTime<Utc>) -> i64 {
+        (self.duration_s - (now - self.started).num_seconds()).clamp(0, self.duration_s)
+    }
+}
+
+/// A name an exercise had before it was renamed.
+#[derive(Debug, FromRow)]
+pub struct ExerciseNameEntity {
+    pub name: String,
+    #[sqlx(rename = "renamed_utc_s")]
+    pub renamed: DateTime<Utc>,
+}
+
+#[derive(Debug, FromRow)]
+pub struct SetAnomalyEntity {
+    pub id: i64,
+    pub exercise_set_id: i64,
+    pub workout_id: i64,
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub code: String,
+    pub message: String,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+/// A set that beat a previous personal record of its exercise.
+#[derive(Debug, FromRow)]
+pub struct PrEventEntity {
+    pub id: i64,
+    pub exercise_set_id: i64,
+    pub workout_id: i64,
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub kind: PrKind,
+    pub weight: i64,
+    pub repetitions: i64,
+    pub value: f64,
+    pub previous_value: f64,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+#[derive(Debug)]
+pub struct PrEventInput {
+    pub kind: PrKind,
+    pub value: f64,
+    pub previous_value: f64,
+}
+
+/// Bests of the completed, weighted working sets of an exercise.
+#[derive(Debug, Default, FromRow)]
+pub struct ExerciseBestsEntity {
+    pub weight: Option<i64>,
+    pub one_rep_max: Option<f64>,
+    pub repetitions_at_weight: Option<i64>,
+}
+
+/// A finding of an insight rule. Insights stay until the rule no longer finds
+/// their subject, dismissed ones are hidden but not recreated.
+#[derive(Debug, FromRow)]
+pub struct InsightEntity {
+    pub id: i64,
+    pub rule: String,
+    /// Identifies what the insight is about within its rule, e.g. an exercise.
+    pub subject: String,
+    pub title: String,
+    pub message: String,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+    #[sqlx(rename = "acknowledged_utc_s")]
+    pub acknowledged: Option<DateTime<Utc>>,
+    #[sqlx(rename = "dismissed_utc_s")]
+    pub dismissed: Option<DateTime<Utc>>,
+}
+
+#[derive(Debug)]
+pub struct InsightInput {
+    pub subject: String,
+    pub title: String,
+    pub message: String,
+}
+
+/// A photo or video of a set, either uploaded and stored in the database or
+/// linked by its URL.
+#[derive(Debug, FromRow)]
+pub struct AttachmentEntity {
+    pub id: i64,
+    pub exercise_set_id: i64,
+    pub content_type: Option<String>,
+    pub file_name: Option<String>,
+    pub url: Option<String>,
+    pub size: i64,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+/// Content of an attachment, uploaded attachments carry their `data`, linked
+/// ones their `url`.
+#[derive(Debug, FromRow)]
+pub struct AttachmentContentEntity {
+    pub content_type: Option<String>,
+    pub url: Option<String>,
+    pub data: Option<Vec<u8>>,
+}
+
+#[derive(Debug)]
+pub struct AttachmentInput {
+    pub content_type: Option<String>,
+    pub file_name: Option<String>,
+    pub url: Option<String>,
+    pub data: Option<Vec<u8>>,
+}
+
+/// Occurrences notifications can be routed to channels for.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, sqlx::Type, Serialize, Deserialize)]
+#[sqlx(rename_all = "snake_case")]
+#[serde(rename_all = "snake_case")]
+pub enum NotificationEvent {
+    WorkoutFinished,
+    /// A lift stopped improving.
+    Stall,
+    /// A set beat a personal record.
+    PersonalRecord,
+    /// Sent on demand to check a channel, can't be routed.
+    Test,
+}
+
+/// A destination of notifications, `transport` holds the JSON encoded
+/// settings of the transport that delivers them.
+#[derive(Debug, FromRow)]
+pub struct NotificationChannelEntity {
+    pub id: i64,
+    pub name: String,
+    pub transport: String,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+#[derive(Debug)]
+pub struct NotificationChannelInput {
+    pub name: String,
+    pub transport: String,
+}
+
+/// Routes notifications of an event to a channel.
+#[derive(Debug, FromRow)]
+pub struct NotificationRuleEntity {
+    pub id: i64,
+    pub event: NotificationEvent,
+    pub channel_id: i64,
+}
+
+#[derive(Debug, FromRow)]
+pub struct ExerciseCountEntity {
+    pub count: i64,
+}
+
+/// Where an exercise was used, e.g. to see when it was dropped from a
+/// routine.
+#[derive(Debug)]
+pub struct ExerciseUsageEntity {
+    pub set_count: i64,
+    /// Oldest month first, months without the exercise are missing.
+    pub months: Vec<MonthlyUsageEntity>,
+    /// Most recent workout first.
+    pub workouts: Vec<WorkoutUsageEntity>,
+}
+
+#[derive(Debug, FromRow)]
+pub struct MonthlyUsageEntity {
+    /// Formatted as `YYYY-MM`.
+    pub month: String,
+    pub workout_count: i64,
+    pub set_count: i64,
+}
+
+#[derive(Debug, FromRow)]
+pub struct WorkoutUsageEntity {
+    pub workout_id: i64,
+    #[sqlx(rename = "started_utc_s")]
+    pub started: DateTime<Utc>,
+    pub set_count: i64,
+}
+
+/// Lifetime statistics of an exercise, only completed sets count.
+#[derive(Debug)]
+pub struct ExerciseStatsEntity {
+    pub set_count: i64,
+    /// Start of the most recent workout containing the exercise.
+    pub last_performed: Option<DateTime<Utc>>,
+    /// Best estimated one-repetition maximum of all working sets.
+    pub estimated_one_rep_max: Option<f64>,
+    pub best_set: Option<ExerciseSetEntity>,
+}
+
+#[derive(Debug, Default, FromRow)]
+pub struct StatisticsOverviewEntity {
+    pub total_workouts: i64,
+    pub total_duration_s: i64,
+    pub avg_duration_s: i64,
+    pub total_sets: i64,
+    pub total_repetitions: i64,
+    pub avg_repetitions_per_set: i64,
+    /// Sum of the repetitions times the weight of all time, the current week
+    /// and the current month.
+    pub total_volume: i64,
+    pub week_volume: i64,
+    pub month_volume: i64,
+    pub total_set_duration_s: i64,
+    pub total_cardio_sets: i64,
+    pub total_cardio_distance_m: i64,
+    pub total_cardio_duration_s: i64,
+}
+
+#[derive(Debug)]
+pub struct ProgressionEntity {
+    /// First day (Monday) of every week that has sets of any of the exercises.
+    pub weeks: Vec<String>,
+    pub series: Vec<ProgressionSeriesEntity>,
+}
+
+/// Weekly values of a single exercise, aligned to `ProgressionEntity::weeks`.
+#[derive(Debug)]
+pub struct ProgressionSeriesEntity {
+    pub exercise_id: i64,
+    pub estimated_one_rep_max: Vec<Option<f64>>,
+    pub volume: Vec<Option<i64>>,
+}
+
+/// A weighted working set together with the session it was done in.
+#[derive(Debug, FromRow)]
+pub struct SessionSetEntity {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub workout_id: i64,
+    #[sqlx(rename = "started_utc_s")]
+    pub started: DateTime<Utc>,
+    pub weight: i64,
+    pub repetitions: i64,
+}
+
+#[derive(Debug, FromRow)]
+pub struct WeeklyCountEntity {
+    pub week: String,
+    pub count: i64,
+}
+
+/// Training volume of a week or month.
+#[derive(Debug, FromRow)]
+pub struct VolumeEntity {
+    /// First day of the period, formatted as `YYYY-MM-DD`.
+    pub period: String,
+    pub set_count: i64,
+    /// Sum of the repetitions times the weight.
+    pub tonnage: i64,
+}
+
+/// Sets of a week that trained a muscle group.
+#[derive(Debug, FromRow)]
+pub struct MuscleVolumeEntity {
+    /// First day (Monday) of the week.
+    pub week: String,
+    pub muscle: String,
+    pub primary_sets: i64,
+    pub secondary_sets: i64,
+}
+
+/// Averages of the workouts started in a time slot.
+#[derive(Debug, FromRow)]
+pub struct TimeSlotVolumeEntity {
+    pub slot: i64,
+    pub workout_count: i64,
+    pub average_set_count: f64,
+    pub average_tonnage: f64,
+}
+
+/// How well planned sets, those with a target, were followed.
+#[derive(Debug, Default, FromRow)]
+pub struct AdherenceEntity {
+    pub planned_sets: i64,
+    pub completed_sets: i64,
+    /// Completed sets that reached their target repetitions and weight.
+    pub targets_met: i64,
+}
+
+#[derive(Debug, FromRow)]
+pub struct CorrectionEntity {
+    pub id: i64,
+    pub description: String,
+    pub affected_sets: i64,
+    #[sqlx(rename = "created_utc_s")]
+    pub created: DateTime<Utc>,
+}
+
+/// Bulk correction of the weights of an exercise, e.g. to fix sets that were
+/// logged in the wrong unit. All weights are multiplied by `factor`.
+#[derive(Debug)]
+pub struct WeightCorrectionInput {
+    pub exercise_id: i64,
+    pub created_before_utc_s: Option<i64>,
+    pub factor: f64,
+    pub description: String,
+}
+
+#[derive(Debug, Default)]
+pub struct ArchiveResultEntity {
+    pub archived_workouts: u64,
+    pub deleted_sets: u64,
+}
+
+#[derive(Debug, Default)]
+pub struct UpsertResultEntity {
+    pub created: usize,
+    pub updated: usize,
+}
+
+pub async fn get_exercise_count<'local, E>(conn: E, id: i64) -> Result<ExerciseCountEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as("SELECT COUNT(*) AS count FROM exercise_set WHERE exercise_id = ?")
+        .bind(id)
+        .fetch_one(conn)
+        .await
+        .with_context(|| format!("Failed to get exercise count for exercise with id {id}"))
+}
+
+pub async fn get_exercise_usage<'local, E>(conn: E, id: i64) -> Result<ExerciseUsageEntity>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    let workouts: Vec<WorkoutUsageEntity> = sqlx::query_as(
+        "
+        SELECT w.id AS workout_id, w.started_utc_s, COUNT(*) AS set_count
+        FROM exercise_set es
+        JOIN workout w ON w.id = es.workout_id
+        WHERE es.exercise_id = ?
+        GROUP BY w.id
+        ORDER BY w.started_utc_s DESC, w.id DESC
+        ",
+    )
+    .bind(id)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get workouts for exercise with id {id}"))?;
+
+    let months = sqlx::query_as(
+        "
+        SELECT
+            STRFTIME('%Y-%m', w.started_utc_s, 'unixepoch') AS month,
+            COUNT(DISTINCT w.id) AS workout_count,
+            COUNT(*) AS set_count
+        FROM exercise_set es
+        JOIN workout w ON w.id = es.workout_id
+        WHERE es.exercise_id = ?
+        GROUP BY month
+        ORDER BY month
+        ",
+    )
+    .bind(id)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get monthly usage for exercise with id {id}"))?;
+
+    Ok(ExerciseUsageEntity {
+        set_count: workouts.iter().map(|workout| workout.set_count).sum(),
+        months,
+        workouts,
+    })
+}
+
+pub async fn count_exercises<'local, E>(conn: E) -> Result<i64>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_scalar("SELECT COUNT(*) FROM exercise")
+        .fetch_one(conn)
+        .await
+        .context("Failed to count exercises")
+}
+
+/// Counts the sets created since the start of the current day in UTC.
+pub async fn count_exercise_sets_created_today<'local, E>(conn: E) -> Result<i64>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_scalar(
+        "SELECT COUNT(*) FROM exercise_set WHERE created_utc_s >= UNIXEPOCH(date('now'))",
+    )
+    .fetch_one(conn)
+    .await
+    .context("Failed to count exercise sets created today")
+}
+
+/// Usage and bests are maintained in `exercise_statistics` by triggers on
+/// `exercise_set`. The best estimated one-repetition maximum uses the Epley
+/// formula, like `one_rep_max::estimate`, so it can be aggregated in SQL.
+const GET_ALL_EXERCISES_WITH_MUSCLES_QUERY: &str = "
+    SELECT
+        e.id, e.name, e.modality, e.category, e.description, e.instructions, e.video_url,
+        e.favorite, e.default_increment, e.min_repetitions, e.max_repetitions,
+        (
+            SELECT GROUP_CONCAT(m.muscle) FROM exercise_muscle m
+            WHERE m.exercise_id = e.id AND m.role = 'primary'
+        ) AS primary_muscles,
+        (
+            SELECT GROUP_CONCAT(m.muscle) FROM exercise_muscle m
+            WHERE m.exercise_id = e.id AND m.role = 'secondary'
+        ) AS secondary_muscles,
+        (
+            SELECT GROUP_CONCAT(q.equipment) FROM exercise_equipment q
+            WHERE q.exercise_id = e.id
+        ) AS equipment,
+        (SELECT GROUP_CONCAT(t.tag) FROM exercise_tag t WHERE t.exercise_id = e.id) AS tags,
+        u.last_used_utc_s,
+        COALESCE(u.usage_count, 0) AS usage_count,
+        u.best_weight,
+        u.best_one_rep_max
+    FROM exercise e
+    LEFT JOIN exercise_statistics u ON u.exercise_id = e.id
+";
+
+/// Condition that the exercise with the id in the given column needs no
+/// equipment missing at a gym, binds the gym id twice. Without a gym every
+/// exercise is available.
+fn available_at_gym(exercise_id: &str) -> String {
+    format!(
+        "
+        (
+            ? IS NULL
+            OR NOT EXISTS (
+                SELECT 1 FROM exercise_equipment q
+                WHERE q.exercise_id = {exercise_id}
+                    AND q.equipment NOT IN (
+                        SELECT g.equipment FROM gym_equipment g WHERE g.gym_id = ?
+                    )
+            )
+        )
+        "
+    )
+}
+
+pub async fn get_exercise<'local, E>(conn: E, id: i64) -> Result<Option<ExerciseEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!(
+        "{GET_ALL_EXERCISES_WITH_MUSCLES_QUERY} WHERE e.id = ?"
+    ))
+    .bind(id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get exercise with id {id}"))
+}
+
+pub async fn get_exercises<'local, E>(
+    conn: E,
+    filter: &ExerciseFilterInput,
+) -> Result<Vec<ExerciseEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    let order = match filter.sort {
+        ExerciseSort::Name => "e.favorite DESC, e.name",
+        ExerciseSort::Recent => "last_used_utc_s DESC, e.name",
+        ExerciseSort::Usage => "usage_count DESC, e.name",
+    };
+    let available = available_at_gym("e.id");
+
+    sqlx::query_as(&format!(
+        "
+        {GET_ALL_EXERCISES_WITH_MUSCLES_QUERY}
+        WHERE (
+                ? IS NULL
+                OR EXISTS (
+                    SELECT 1 FROM exercise_muscle m WHERE m.exercise_id = e.id AND m.muscle = ?
+                )
+            )
+            AND (? IS NULL OR e.category = ?)
+            AND {available}
+            AND (
+                ? IS NULL
+                OR EXISTS (SELECT 1 FROM exercise_tag t WHERE t.exercise_id = e.id AND t.tag = ?)
+            )
+        ORDER BY {order}
+        "
+    ))
+    .bind(&filter.muscle)
+    .bind(&filter.muscle)
+    .bind(filter.category)
+    .bind(filter.category)
+    .bind(filter.gym_id)
+    .bind(filter.gym_id)
+    .bind(&filter.tag)
+    .bind(&filter.tag)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get exercises")
+}
+
+pub async fn set_exercise_favorite<'local, E>(
+    conn: E,
+    id: i64,
+    favorite: bool,
+) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("UPDATE exercise SET favorite = ? WHERE id = ?")
+        .bind(favorite)
+        .bind(id)
+        .execute(conn)
+        .await
+        .with_context(|| format!("Failed to update favorite of exercise with id {id}"))
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+}
+
+pub async fn create_exercise(
+    pool: &Pool<Sqlite>,
+    exercise: &ExerciseInput,
+) -> Result<ExerciseEntity> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+    let id = insert_exercise(&mut tx, exercise).await?;
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(get_exercise(pool, id)
+        .await?
+        .expect("Exercise must exist as it was written by the previous query"))
+}
+
+pub async fn insert_exercise(
+    tx: &mut Transaction<'_, Sqlite>,
+    exercise: &ExerciseInput,
+) -> Result<i64> {
+    let name = &exercise.name;
+
+    let id = sqlx::query_scalar::<_, i64>(
+        "
+        INSERT INTO exercise (
+            name, modality, category, description, instructions, video_url,
+            default_increment, min_repetitions, max_repetitions
+        )
+        VALUES (
+            ?, COALESCE(?, 'strength'), ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''),
+            NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, 0)
+        )
+        RETURNING id
+        ",
+    )
+    .bind(name)
+    .bind(exercise.modality)
+    .bind(exercise.category)
+    .bind(&exercise.description)
+    .bind(&exercise.instructions)
+    .bind(&exercise.video_url)
+    .bind(exercise.default_increment)
+    .bind(exercise.min_repetitions)
+    .bind(exercise.max_repetitions)
+    .fetch_one(&mut *tx)
+    .await
+    .with_context(|| format!(r#"Failed to create exercise with name "{name}""#))?;
+
+    replace_exercise_muscles(tx, id, exercise).await?;
+    replace_exercise_equipment(tx, id, exercise).await?;
+    replace_exercise_tags(tx, id, exercise).await?;
+
+    Ok(id)
+}
+
+/// Replaces the muscle groups of every role that is given in the input.
+async fn replace_exercise_muscles(
+    tx: &mut Transaction<'_, Sqlite>,
+    id: i64,
+    exercise: &ExerciseInput,
+) -> Result<()> {
+    let roles = [
+        (MuscleRole::Primary, &exercise.primary_muscles),
+        (MuscleRole::Secondary, &exercise.secondary_muscles),
+    ];
+
+    for (role, muscles) in roles {
+        let Some(muscles) = muscles else {
+            continue;
+        };
+
+        sqlx::query("DELETE FROM exercise_muscle WHERE exercise_id = ? AND role = ?")
+            .bind(id)
+            .bind(role)
+            .execute(&mut *tx)
+            .await
+            .with_context(|| format!("Failed to delete muscles of exercise with id {id}"))?;
+
+        for muscle in muscles {
+            sqlx::query(
+                "
+                INSERT INTO exercise_muscle (exercise_id, muscle, role) VALUES (?, ?, ?)
+                ON CONFLICT (exercise_id, muscle) DO UPDATE SET role = excluded.role
+                ",
+            )
+            .bind(id)
+            .bind(muscle)
+            .bind(role)
+            .execute(&mut *tx)
+            .await
+            .with_context(|| format!("Failed to add muscle {muscle} to exercise with id {id}"))?;
+        }
+    }
+
+    Ok(())
+}
+
+async fn replace_exercise_equipment(
+    tx: &mut Transaction<'_, Sqlite>,
+    id: i64,
+    exercise: &ExerciseInput,
+) -> Result<()> {
+    let Some(equipment) = &exercise.equipment else {
+        return Ok(());
+    };
+
+    sqlx::query("DELETE FROM exercise_equipment WHERE exercise_id = ?")
+        .bind(id)
+        .execute(&mut *tx)
+        .await
+        .with_context(|| format!("Failed to delete equipment of exercise with id {id}"))?;
+
+    for item in equipment {
+        sqlx::query(
+            "INSERT OR IGNORE INTO exercise_equipment (exercise_id, equipment) VALUES (?, ?)",
+        )
+        .bind(id)
+        .bind(item)
+        .execute(&mut *tx)
+        .await
+        .with_context(|| format!("Failed to add equipment {item} to exercise with id {id}"))?;
+    }
+
+    Ok(())
+}
+
+async fn replace_exercise_tags(
+    tx: &mut Transaction<'_, Sqlite>,
+    id: i64,
+    exercise: &ExerciseInput,
+) -> Result<()> {
+    let Some(tags) = &exercise.tags else {
+        return Ok(());
+    };
+
+    sqlx::query("DELETE FROM exercise_tag WHERE exercise_id = ?")
+        .bind(id)
+        .execute(&mut *tx)
+        .await
+        .with_context(|| format!("Failed to delete tags of exercise with id {id}"))?;
+
+    for tag in tags {
+        sqlx::query("INSERT OR IGNORE INTO exercise_tag (exercise_id, tag) VALUES (?, ?)")
+            .bind(id)
+            .bind(tag)
+            .execute(&mut *tx)
+            .await
+            .with_context(|| format!("Failed to add tag {tag} to exercise with id {id}"))?;
+    }
+
+    Ok(())
+}
+
+pub async fn delete_exercise<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM exercise WHERE id = ?")
+        .bind(id)
+        .execute(conn)
+        .await
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete exercise with id {id}"))
+}
+
+/// Deletes an exercise together with all of its sets in one transaction.
+/// Returns the number of deleted sets.
+pub async fn delete_exercise_with_sets(pool: &Pool<Sqlite>, id: i64) -> Result<Option<u64>> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let deleted_sets = sqlx::query("DELETE FROM exercise_set WHERE exercise_id = ?")
+        .bind(id)
+        .execute(&mut tx)
+        .await
+        .with_context(|| format!("Failed to delete sets of exercise with id {id}"))?
+        .rows_affected();
+
+    if delete_exercise(&mut *tx, id).await?.is_none() {
+        return Ok(None);
+    }
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(Some(deleted_sets))
+}
+
+pub async fn update_exercise(
+    pool: &Pool<Sqlite>,
+    id: i64,
+    exercise: &ExerciseInput,
+) -> Result<ExerciseEntity> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+    write_exercise(&mut tx, id, exercise).await?;
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    get_exercise(pool, id)
+        .await?
+        .with_context(|| format!("Failed to get updated exercise with id {id}"))
+}
+
+async fn write_exercise(
+    tx: &mut Transaction<'_, Sqlite>,
+    id: i64,
+    exercise: &ExerciseInput,
+) -> Result<()> {
+    let name = &exercise.name;
+
+    sqlx::query(
+        "
+        INSERT INTO exercise_name_history (exercise_id, name, renamed_utc_s)
+        SELECT id, name, UNIXEPOCH(datetime()) FROM exercise WHERE id = ? AND name != ?
+        ",
+    )
+    .bind(id)
+    .bind(name)
+    .execute(&mut *tx)
+    .await
+    .with_context(|| format!("Failed to record previous name of exercise with id {id}"))?;
+
+    sqlx::query(
+        "
+        UPDATE exercise SET
+            name = ?, modality = COALESCE(?, modality), category = COALESCE(?, category),
+            description = NULLIF(COALESCE(?, description), ''),
+            instructions = NULLIF(COALESCE(?, instructions), ''),
+            video_url = NULLIF(COALESCE(?, video_url), ''),
+            default_increment = NULLIF(COALESCE(?, default_increment), 0),
+            min_repetitions = NULLIF(COALESCE(?, min_repetitions), 0),
+            max_repetitions = NULLIF(COALESCE(?, max_repetitions), 0)
+        WHERE id = ?
+        ",
+    )
+    .bind(name)
+    .bind(exercise.modality)
+    .bind(exercise.category)
+    .bind(&exercise.description)
+    .bind(&exercise.instructions)
+    .bind(&exercise.video_url)
+    .bind(exercise.default_increment)
+    .bind(exercise.min_repetitions)
+    .bind(exercise.max_repetitions)
+    .bind(id)
+    .execute(&mut *tx)
+    .await
+    .with_context(|| format!(r#"Failed to update exercise with id {id} and name "{name}""#))?;
+
+    replace_exercise_muscles(tx, id, exercise).await?;
+    replace_exercise_equipment(tx, id, exercise).await?;
+    replace_exercise_tags(tx, id, exercise).await?;
+
+    Ok(())
+}
+
+/// Creates or updates the exercises by name, ignoring case, all or none of
+/// them are written. An exercise named like an earlier one of the same call
+/// updates it.
+pub async fn upsert_exercises(
+    pool: &Pool<Sqlite>,
+    exercises: &[ExerciseInput],
+) -> Result<UpsertResultEntity> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let mut result = UpsertResultEntity::default();
+    for exercise in exercises {
+        let name = &exercise.name;
+        let id =
+            sqlx::query_scalar::<_, i64>("SELECT id FROM exercise WHERE name = ? COLLATE NOCASE")
+                .bind(name)
+                .fetch_optional(&mut tx)
+                .await
+                .with_context(|| format!(r#"Failed to get exercise with name "{name}""#))?;
+
+        match id {
+            Some(id) => {
+                write_exercise(&mut tx, id, exercise).await?;
+                result.updated += 1;
+            }
+            None => {
+                insert_exercise(&mut tx, exercise).await?;
+                result.created += 1;
+            }
+        }
+    }
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(result)
+}
+
+/// Previous names of an exercise, most recent first.
+pub async fn get_exercise_name_history<'local, E>(
+    conn: E,
+    exercise_id: i64,
+) -> Result<Vec<ExerciseNameEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT name, renamed_utc_s FROM exercise_name_history
+        WHERE exercise_id = ?
+        ORDER BY renamed_utc_s DESC, id DESC
+        ",
+    )
+    .bind(exercise_id)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get name history of exercise with id {exercise_id}"))
+}
+
+const GET_ALL_GYMS_QUERY: &str = "
+    SELECT
+        g.id, g.name,
+        (SELECT GROUP_CONCAT(q.equipment) FROM gym_equipment q WHERE q.gym_id = g.id) AS equipment,
+        g.bar_weight,
+        (
+            SELECT GROUP_CONCAT(p.weight || ':' || p.count) FROM gym_plate p
+            WHERE p.gym_id = g.id
+        ) AS plates
+    FROM gym g
+";
+
+pub async fn get_gym<'local, E>(conn: E, id: i64) -> Result<Option<GymEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!("{GET_ALL_GYMS_QUERY} WHERE g.id = ?"))
+        .bind(id)
+        .fetch_optional(conn)
+        .await
+        .with_context(|| format!("Failed to get gym with id {id}"))
+}
+
+pub async fn get_gyms<'local, E>(conn: E) -> Result<Vec<GymEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!("{GET_ALL_GYMS_QUERY} ORDER BY g.name"))
+        .fetch_all(conn)
+        .await
+        .context("Failed to get gyms")
+}
+
+pub async fn create_gym(pool: &Pool<Sqlite>, gym: &GymInput) -> Result<GymEntity> {
+    let name = &gym.name;
+
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let id = sqlx::query_scalar::<_, i64>(
+        "INSERT INTO gym (name, bar_weight) VALUES (?, ?) RETURNING id",
+    )
+    .bind(name)
+    .bind(gym.bar_weight)
+    .fetch_one(&mut tx)
+    .await
+    .with_context(|| format!(r#"Failed to create gym with name "{name}""#))?;
+
+    replace_gym_equipment(&mut tx, id, gym).await?;
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(get_gym(pool, id)
+        .await?
+        .expect("Gym must exist as it was written by the previous query"))
+}
+
+pub async fn update_gym(pool: &Pool<Sqlite>, id: i64, gym: &GymInput) -> Result<GymEntity> {
+    let name = &gym.name;
+
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    sqlx::query("UPDATE gym SET name = ?, bar_weight = ? WHERE id = ?")
+        .bind(name)
+        .bind(gym.bar_weight)
+        .bind(id)
+        .execute(&mut tx)
+        .await
+        .with_context(|| format!(r#"Failed to update gym with id {id} and name "{name}""#))?;
+
+    replace_gym_equipment(&mut tx, id, gym).await?;
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    get_gym(pool, id)
+        .await?
+        .with_context(|| format!("Failed to get updated gym with id {id}"))
+}
+
+async fn replace_gym_equipment(
+    tx: &mut Transaction<'_, Sqlite>,
+    id: i64,
+    gym: &GymInput,
+) -> Result<()> {
+    sqlx::query("DELETE FROM gym_equipment WHERE gym_id = ?")
+        .bind(id)
+        .execute(&mut *tx)
+        .await
+        .with_context(|| format!("Failed to delete equipment of gym with id {id}"))?;
+
+    for item in &gym.equipment {
+        sqlx::query("INSERT OR IGNORE INTO gym_equipment (gym_id, equipment) VALUES (?, ?)")
+            .bind(id)
+            .bind(item)
+            .execute(&mut *tx)
+            .await
+            .with_context(|| format!("Failed to add equipment {item} to gym with id {id}"))?;
+    }
+
+    sqlx::query("DELETE FROM gym_plate WHERE gym_id = ?")
+        .bind(id)
+        .execute(&mut *tx)
+        .await
+        .with_context(|| format!("Failed to delete plates of gym with id {id}"))?;
+
+    for plate in &gym.plates {
+        let weight = plate.weight;
+        sqlx::query(
+            "
+            INSERT INTO gym_plate (gym_id, weight, count) VALUES (?, ?, ?)
+            ON CONFLICT (gym_id, weight) DO UPDATE SET count = count + excluded.count
+            ",
+        )
+        .bind(id)
+        .bind(weight)
+        .bind(plate.count)
+        .execute(&mut *tx)
+        .await
+        .with_context(|| format!("Failed to add plates of {weight} to gym with id {id}"))?;
+    }
+
+    Ok(())
+}
+
+pub async fn delete_gym<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM gym WHERE id = ?")
+        .bind(id)
+        .execute(conn)
+        .await
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete gym with id {id}"))
+}
+
+/// Body weights measured within `from` (inclusive) and `to` (exclusive) in
+/// UTC seconds, oldest first.
+pub async fn get_body_weights<'local, E>(
+    conn: E,
+    from: Option<i64>,
+    to: Option<i64>,
+) -> Result<Vec<BodyWeightEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT id, weight, measured_utc_s
+        FROM body_weight
+        WHERE (? IS NULL OR measured_utc_s >= ?) AND (? IS NULL OR measured_utc_s < ?)
+        ORDER BY measured_utc_s, id
+        ",
+    )
+    .bind(from)
+    .bind(from)
+    .bind(to)
+    .bind(to)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get body weights")
+}
+
+/// The body weight as of the given time in UTC seconds: the last one measured
+/// before, or the first one measured after if there is none before.
+pub async fn get_body_weight_at<'local, E>(conn: E, at: i64) -> Result<Option<BodyWeightEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT id, weight, measured_utc_s
+        FROM body_weight
+        ORDER BY
+            measured_utc_s > ?,
+            CASE WHEN measured_utc_s > ? THEN measured_utc_s ELSE -measured_utc_s END,
+            id DESC
+        LIMIT 1
+        ",
+    )
+    .bind(at)
+    .bind(at)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get body weight at {at}"))
+}
+
+pub async fn create_body_weight<'local, E>(
+    conn: E,
+    body_weight: &BodyWeightInput,
+) -> Result<BodyWeightEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        INSERT INTO body_weight (weight, measured_utc_s)
+        VALUES (?, COALESCE(?, UNIXEPOCH(datetime())))
+        RETURNING id, weight, measured_utc_s
+        ",
+    )
+    .bind(body_weight.weight)
+    .bind(body_weight.measured_utc_s)
+    .fetch_one(conn)
+    .await
+    .context("Failed to create body weight")
+}
+
+pub async fn delete_body_weight<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM body_weight WHERE id = ?")
+        .bind(id)
+        .execute(conn)
+        .await
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete body weight with id {id}"))
+}
+
+/// Progress photos taken within `from` (inclusive) and `to` (exclusive) in
+/// UTC seconds, oldest first.
+pub async fn get_progress_photos<'local, E>(
+    conn: E,
+    from: Option<i64>,
+    to: Option<i64>,
+) -> Result<Vec<ProgressPhotoEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT id, content_type, size, taken_utc_s, created_utc_s
+        FROM progress_photo
+        WHERE (? IS NULL OR taken_utc_s >= ?) AND (? IS NULL OR taken_utc_s < ?)
+        ORDER BY taken_utc_s, id
+        ",
+    )
+    .bind(from)
+    .bind(from)
+    .bind(to)
+    .bind(to)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get progress photos")
+}
+
+pub async fn get_progress_photo<'local, E>(conn: E, id: i64) -> Result<Option<ProgressPhotoEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT id, content_type, size, taken_utc_s, created_utc_s
+        FROM progress_photo
+        WHERE id = ?
+        ",
+    )
+    .bind(id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get progress photo with id {id}"))
+}
+
+/// Total size of all progress photos in bytes.
+pub async fn get_progress_photos_size<'local, E>(conn: E) -> Result<i64>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_scalar("SELECT CAST(COALESCE(SUM(size), 0) AS integer) FROM progress_photo")
+        .fetch_one(conn)
+        .await
+        .context("Failed to get size of progress photos")
+}
+
+pub async fn create_progress_photo<'local, E>(
+    conn: E,
+    photo: &ProgressPhotoInput,
+) -> Result<ProgressPhotoEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        INSERT INTO progress_photo (content_type, size, taken_utc_s, created_utc_s)
+        VALUES (?, ?, COALESCE(?, UNIXEPOCH(datetime())), UNIXEPOCH(datetime()))
+        RETURNING id, content_type, size, taken_utc_s, created_utc_s
+        ",
+    )
+    .bind(&photo.content_type)
+    .bind(photo.size)
+    .bind(photo.taken_utc_s)
+    .fetch_one(conn)
+    .await
+    .context("Failed to create progress photo")
+}
+
+pub async fn delete_progress_photo<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM progress_photo WHERE id = ?")
+        .bind(id)
+        .execute(conn)
+        .await
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete progress photo with id {id}"))
+}
+
+/// Finished workouts that weren't pushed to Google Fit since they were last
+/// finished, the longest finished first. Workouts finished before `since` in
+/// UTC seconds and workouts whose last push failed until their retry is due
+/// are left out.
+pub async fn get_unsynced_google_fit_workouts<'local, E>(
+    conn: E,
+    since: Option<i64>,
+    limit: i64,
+) -> Result<Vec<GoogleFitWorkoutEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT
+            w.id, w.started_utc_s, w.finished_utc_s, w.note,
+            COUNT(DISTINCT es.exercise_id) AS exercises, COUNT(es.id) AS sets
+        FROM workout w
+        LEFT JOIN google_fit_session s ON s.workout_id = w.id
+        LEFT JOIN google_fit_failure f
+            ON f.workout_id = w.id AND f.finished_utc_s = w.finished_utc_s
+        LEFT JOIN exercise_set es ON es.workout_id = w.id AND es.completed
+        WHERE w.finished_utc_s IS NOT NULL
+            AND s.finished_utc_s IS NOT w.finished_utc_s
+            AND (? IS NULL OR w.finished_utc_s >= ?)
+            AND (f.retry_utc_s IS NULL OR f.retry_utc_s <= UNIXEPOCH(datetime()))
+        GROUP BY w.id
+        ORDER BY w.finished_utc_s, w.id
+        LIMIT ?
+        ",
+    )
+    .bind(since)
+    .bind(since)
+    .bind(limit)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get workouts to sync to Google Fit")
+}
+
+/// Remembers that the workout was pushed as finished at the given time, so
+/// it is pushed again once finished anew.
+pub async fn set_google_fit_synced<'local, E>(
+    conn: E,
+    workout_id: i64,
+    finished_utc_s: i64,
+) -> Result<()>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query(
+        "
+        INSERT INTO google_fit_session (workout_id, finished_utc_s, synced_utc_s)
+        VALUES (?, ?, UNIXEPOCH(datetime()))
+        ON CONFLICT (workout_id) DO UPDATE
+        SET finished_utc_s = excluded.finished_utc_s, synced_utc_s = excluded.synced_utc_s
+        ",
+    )
+    .bind(workout_id)
+    .bind(finished_utc_s)
+    .execute(conn)
+    .await
+    .map(|_| ())
+    .with_context(|| format!("Failed to mark workout with id {workout_id} as synced to Google Fit"))
+}
+
+/// Postpones the next push of the workout as finished at the given time by
+/// `delay_s`, doubled for every further failed attempt up to `max_delay_s`.
+/// Finishing the workout anew starts over.
+pub async fn set_google_fit_failed<'local, E>(
+    conn: E,
+    workout_id: i64,
+    finished_utc_s: i64,
+    delay_s: i64,
+    max_delay_s: i64,
+) -> Result<()>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query(
+        "
+        INSERT INTO google_fit_failure (workout_id, finished_utc_s, attempts, retry_utc_s)
+        VALUES (?, ?, 1, UNIXEPOCH(datetime()) + ?)
+        ON CONFLICT (workout_id) DO UPDATE SET
+            attempts = CASE WHEN finished_utc_s = excluded.finished_utc_s
+                THEN attempts + 1 ELSE 1
+            END,
+            retry_utc_s = UNIXEPOCH(datetime()) + MIN(
+                ? << CASE WHEN finished_utc_s = excluded.finished_utc_s
+                    THEN MIN(attempts, 16) ELSE 0
+                END,
+                ?
+            ),
+            finished_utc_s = excluded.finished_utc_s
+        ",
+    )
+    .bind(workout_id)
+    .bind(finished_utc_s)
+    .bind(delay_s)
+    .bind(delay_s)
+    .bind(max_delay_s)
+    .execute(conn)
+    .await
+    .map(|_| ())
+    .with_context(|| {
+        format!("Failed to record failed push of workout with id {workout_id} to Google Fit")
+    })
+}
+
+/// Ids of deleted workouts whose sessions are still in Google Fit and due to
+/// be deleted.
+pub async fn get_pending_google_fit_deletions<'local, E>(conn: E, limit: i64) -> Result<Vec<i64>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_scalar(
+        "
+        SELECT workout_id
+        FROM google_fit_deletion
+        WHERE retry_utc_s <= UNIXEPOCH(datetime())
+        ORDER BY workout_id
+        LIMIT ?
+        ",
+    )
+    .bind(limit)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get sessions to delete from Google Fit")
+}
+
+pub async fn delete_google_fit_deletion<'local, E>(conn: E, workout_id: i64) -> Result<()>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM google_fit_deletion WHERE workout_id = ?")
+        .bind(workout_id)
+        .execute(conn)
+        .await
+        .map(|_| ())
+        .with_context(|| {
+            format!("Failed to remove Google Fit deletion of workout with id {workout_id}")
+        })
+}
+
+/// Postpones the next deletion of the session of the workout like
+/// [`set_google_fit_failed`].
+pub async fn set_google_fit_deletion_failed<'local, E>(
+    conn: E,
+    workout_id: i64,
+    delay_s: i64,
+    max_delay_s: i64,
+) -> Result<()>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query(
+        "
+        UPDATE google_fit_deletion
+        SET
+            attempts = attempts + 1,
+            retry_utc_s = UNIXEPOCH(datetime()) + MIN(? << MIN(attempts, 16), ?)
+        WHERE workout_id = ?
+        ",
+    )
+    .bind(delay_s)
+    .bind(max_delay_s)
+    .bind(workout_id)
+    .execute(conn)
+    .await
+    .map(|_| ())
+    .with_context(|| {
+        format!("Failed to record failed Google Fit deletion of workout with id {workout_id}")
+    })
+}
+
+const GET_ALL_GOALS_QUERY: &str = "
+    SELECT
+        g.id, g.kind, g.exercise_id, e.name AS exercise_name, g.target, g.deadline_utc_s,
+        g.created_utc_s
+    FROM goal g
+    LEFT JOIN exercise e ON e.id = g.exercise_id
+";
+
+pub async fn get_goal<'local, E>(conn: E, id: i64) -> Result<Option<GoalEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!("{GET_ALL_GOALS_QUERY} WHERE g.id = ?"))
+        .bind(id)
+        .fetch_optional(conn)
+        .await
+        .with_context(|| format!("Failed to get goal with id {id}"))
+}
+
+pub async fn get_goals<'local, E>(conn: E) -> Result<Vec<GoalEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!("{GET_ALL_GOALS_QUERY} ORDER BY g.id"))
+        .fetch_all(conn)
+        .await
+        .context("Failed to get goals")
+}
+
+pub async fn create_goal<'local, E>(conn: E, goal: &GoalInput) -> Result<GoalEntity>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    let id = sqlx::query_scalar::<_, i64>(
+        "
+        INSERT INTO goal (kind, exercise_id, target, deadline_utc_s, created_utc_s)
+        VALUES (?, ?, ?, ?, UNIXEPOCH(datetime()))
+        RETURNING id
+        ",
+    )
+    .bind(goal.kind)
+    .bind(goal.exercise_id)
+    .bind(goal.target)
+    .bind(goal.deadline_utc_s)
+    .fetch_one(conn)
+    .await
+    .context("Failed to create goal")?;
+
+    Ok(get_goal(conn, id)
+        .await?
+        .expect("Goal must exist as it was written by the previous query"))
+}
+
+pub async fn update_goal<'local, E>(conn: E, id: i64, goal: &GoalInput) -> Result<GoalEntity>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    sqlx::query(
+        "
+        UPDATE goal
+        SET kind = ?, exercise_id = ?, target = ?, deadline_utc_s = ?
+        WHERE id = ?
+        ",
+    )
+    .bind(goal.kind)
+    .bind(goal.exercise_id)
+    .bind(goal.target)
+    .bind(goal.deadline_utc_s)
+    .bind(id)
+    .execute(conn)
+    .await
+    .with_context(|| format!("Failed to update goal with id {id}"))?;
+
+    get_goal(conn, id)
+        .await?
+        .with_context(|| format!("Failed to get updated goal with id {id}"))
+}
+
+pub async fn delete_goal<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM goal WHERE id = ?")
+        .bind(id)
+        .execute(conn)
+        .await
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete goal with id {id}"))
+}
+
+pub async fn get_workout<'local, E>(conn: E, id: i64) -> Result<Option<WorkoutEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as("SELECT id, started_utc_s, finished_utc_s, note FROM workout WHERE id = ?")
+        .bind(id)
+        .fetch_optional(conn)
+        .await
+        .with_context(|| format!("Failed to get workout with id {id}"))
+}
+
+pub async fn get_workouts<'local, E>(conn: E) -> Result<Vec<WorkoutEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as("SELECT id, started_utc_s, finished_utc_s, note FROM workout")
+        .fetch_all(conn)
+        .await
+        .context("Failed to get workouts")
+}
+
+/// Seconds in which a repeated workout creation returns the workout that was
+/// just created, as long as it is still empty.
+const CREATE_WORKOUT_DEDUPLICATION_S: i64 = 10;
+
+/// Creates a new workout, unless an empty workout was created within the
+/// last few seconds, in which case that workout is returned instead.
+///
+/// This protects against clients retrying the request on flaky networks.
+pub async fn create_workout<'local, E>(conn: E) -> Result<WorkoutEntity>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    const RECENT_EMPTY_WORKOUT: &str = "
+        w.started_utc_s >= UNIXEPOCH(datetime()) - ?
+        AND w.note IS NULL
+        AND w.finished_utc_s IS NULL
+        AND NOT EXISTS (SELECT 1 FROM exercise_set es WHERE es.workout_id = w.id)
+    ";
+
+    sqlx::query(&format!(
+        "
+        INSERT INTO workout (started_utc_s)
+        SELECT UNIXEPOCH(datetime())
+        WHERE NOT EXISTS (SELECT 1 FROM workout w WHERE {RECENT_EMPTY_WORKOUT})
+        "
+    ))
+    .bind(CREATE_WORKOUT_DEDUPLICATION_S)
+    .execute(conn)
+    .await
+    .context("Failed to create workout")?;
+
+    let workout = sqlx::query_as(&format!(
+        "
+        SELECT w.id, w.started_utc_s, w.finished_utc_s, w.note
+        FROM workout w
+        WHERE {RECENT_EMPTY_WORKOUT}
+        ORDER BY w.id DESC
+        LIMIT 1
+        "
+    ))
+    .bind(CREATE_WORKOUT_DEDUPLICATION_S)
+    .fetch_optional(conn)
+    .await
+    .context("Failed to get created workout")?;
+
+    if let Some(workout) = workout {
+        return Ok(workout);
+    }
+
+    // The recent workout got filled in the meantime, so this is no retry.
+    sqlx::query_as(
+        "
+        INSERT INTO workout (started_utc_s) VALUES (UNIXEPOCH(datetime()))
+        RETURNING id, started_utc_s, finished_utc_s, note
+        ",
+    )
+    .fetch_one(conn)
+    .await
+    .context("Failed to create workout")
+}
+
+/// Deletes a workout and queues the deletion of its Google Fit session if it
+/// was pushed, in one transaction.
+pub async fn delete_workout(pool: &Pool<Sqlite>, id: i64) -> Result<Option<()>> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    sqlx::query(
+        "
+        INSERT OR IGNORE INTO google_fit_deletion (workout_id)
+        SELECT workout_id FROM google_fit_session WHERE workout_id = ?
+        ",
+    )
+    .bind(id)
+    .execute(&mut tx)
+    .await
+    .with_context(|| format!("Failed to queue Google Fit deletion of workout with id {id}"))?;
+
+    let deleted = sqlx::query("DELETE FROM workout WHERE id = ?")
+        .bind(id)
+        .execute(&mut tx)
+        .await
+        .with_context(|| format!("Failed to delete workout with id {id}"))?
+        .rows_affected()
+        > 0;
+    if !deleted {
+        return Ok(None);
+    }
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(Some(()))
+}
+
+/// Inserts an already finished workout, e.g. for sample data.
+pub async fn create_finished_workout<'local, E>(
+    conn: E,
+    started_utc_s: i64,
+    finished_utc_s: i64,
+) -> Result<WorkoutEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        INSERT INTO workout (started_utc_s, finished_utc_s) VALUES (?, ?)
+        RETURNING id, started_utc_s, finished_utc_s, note
+        ",
+    )
+    .bind(started_utc_s)
+    .bind(finished_utc_s)
+    .fetch_one(conn)
+    .await
+    .context("Failed to create finished workout")
+}
+
+/// Inserts a workout with its sets read from another database like a backup.
+///
+/// The workout and its sets get new ids, exercises are matched by name and
+/// created if missing, so ids of the other database never clash.
+pub async fn restore_workout(
+    pool: &Pool<Sqlite>,
+    workout: &WorkoutEntity,
+    exercises: &[ExerciseEntity],
+    exercise_sets: &[ExerciseSetEntity],
+) -> Result<WorkoutEntity> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let restored_workout: WorkoutEntity = sqlx::query_as(
+        "
+        INSERT INTO workout (started_utc_s, finished_utc_s, note) VALUES (?, ?, ?)
+        RETURNING id, started_utc_s, finished_utc_s, note
+        ",
+    )
+    .bind(workout.started.timestamp())
+    .bind(workout.finished.map(|finished| finished.timestamp()))
+    .bind(&workout.note)
+    .fetch_one(&mut tx)
+    .await
+    .with_context(|| format!("Failed to restore workout with id {}", workout.id))?;
+
+    let mut exercise_ids = HashMap::new();
+
+    for exercise in exercises {
+        let existing_id =
+            sqlx::query_scalar::<_, i64>("SELECT id FROM exercise WHERE name = ? COLLATE NOCASE")
+                .bind(&exercise.name)
+                .fetch_optional(&mut tx)
+                .await
+                .with_context(|| format!("Failed to get exercise with name {}", exercise.name))?;
+
+        let id = match existing_id {
+            Some(id) => id,
+            None => {
+                let id = sqlx::query_scalar::<_, i64>(
+                    "
+                    INSERT INTO exercise (
+                        name, modality, category, description, instructions, video_url,
+                        default_increment, min_repetitions, max_repetitions
+                    )
+                    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+                    RETURNING id
+                    ",
+                )
+                .bind(&exercise.name)
+                .bind(exercise.modality)
+                .bind(exercise.category)
+                .bind(&exercise.description)
+                .bind(&exercise.instructions)
+                .bind(&exercise.video_url)
+                .bind(exercise.default_increment)
+                .bind(exercise.min_repetitions)
+                .bind(exercise.max_repetitions)
+                .fetch_one(&mut tx)
+                .await
+                .with_context(|| {
+                    format!("Failed to create exercise with name {}", exercise.name)
+                })?;
+
+                let input = ExerciseInput {
+                    name: exercise.name.clone(),
+                    modality: Some(exercise.modality),
+                    category: exercise.category,
+                    description: None,
+                    instructions: None,
+                    video_url: None,
+                    default_increment: None,
+                    min_repetitions: None,
+                    max_repetitions: None,
+                    primary_muscles: Some(exercise.primary_muscles()),
+                    secondary_muscles: Some(exercise.secondary_muscles()),
+                    equipment: Some(exercise.equipment()),
+                    tags: Some(exercise.tags()),
+                };
+                replace_exercise_muscles(&mut tx, id, &input).await?;
+                replace_exercise_equipment(&mut tx, id, &input).await?;
+                replace_exercise_tags(&mut tx, id, &input).await?;
+
+                id
+            }
+        };
+
+        exercise_ids.insert(exercise.id, id);
+    }
+
+    for exercise_set in exercise_sets {
+        let exercise_id = exercise_ids
+            .get(&exercise_set.exercise_id)
+            .with_context(|| format!("Missing exercise for set with id {}", exercise_set.id))?;
+
+        sqlx::query(
+            "
+            INSERT INTO exercise_set
+                (workout_id, exercise_id, created_utc_s, repetitions, weight, added_weight,
+                duration_s, distance_m, set_type, position, completed, target_repetitions,
+                target_weight, note, plates)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            ",
+        )
+        .bind(restored_workout.id)
+        .bind(exercise_id)
+        .bind(exercise_set.created.timestamp())
+        .bind(exercise_set.repetitions)
+        .bind(exercise_set.weight)
+        .bind(exercise_set.added_weight)
+        .bind(exercise_set.duration_s)
+        .bind(exercise_set.distance_m)
+        .bind(exercise_set.set_type)
+        .bind(exercise_set.position)
+        .bind(exercise_set.completed)
+        .bind(exercise_set.target_repetitions)
+        .bind(exercise_set.target_weight)
+        .bind(&exercise_set.note)
+        .bind(&exercise_set.plates)
+        .execute(&mut tx)
+        .await
+        .with_context(|| format!("Failed to restore exercise set with id {}", exercise_set.id))?;
+    }
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(restored_workout)
+}
+
+pub async fn update_workout_meta_data<'local, E>(
+    conn: E,
+    id: i64,
+    note: &str,
+) -> Result<Option<WorkoutEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    let note = match note.trim() {
+        "" => None,
+        note => Some(note),
+    };
+
+    sqlx::query_as(
+        "
+        UPDATE workout
+        SET note = ?
+        WHERE id = ?
+        RETURNING id, started_utc_s, finished_utc_s, note
+        ",
+    )
+    .bind(note)
+    .bind(id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to update note for workout with id {id}"))
+}
+
+/// Finishes or reopens a workout and records the change in the audit log.
+///
+/// Finishing an already finished workout keeps its original finish time.
+pub async fn set_workout_finished(
+    pool: &Pool<Sqlite>,
+    id: i64,
+    action: WorkoutAuditAction,
+) -> Result<Option<WorkoutEntity>> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let query = match action {
+        WorkoutAuditAction::Finish => {
+            "
+            UPDATE workout
+            SET finished_utc_s = COALESCE(finished_utc_s, UNIXEPOCH(datetime()))
+            WHERE id = ?
+            RETURNING id, started_utc_s, finished_utc_s, note
+            "
+        }
+        WorkoutAuditAction::Reopen => {
+            "
+            UPDATE workout
+            SET finished_utc_s = NULL
+            WHERE id = ?
+            RETURNING id, started_utc_s, finished_utc_s, note
+            "
+        }
+    };
+
+    let workout: Option<WorkoutEntity> = sqlx::query_as(query)
+        .bind(id)
+        .fetch_optional(&mut tx)
+        .await
+        .with_context(|| format!("Failed to update finish time of workout with id {id}"))?;
+
+    if workout.is_none() {
+        return Ok(None);
+    }
+
+    sqlx::query(
+        "
+        INSERT INTO workout_audit (workout_id, action, created_utc_s)
+        VALUES (?, ?, UNIXEPOCH(datetime()))
+        ",
+    )
+    .bind(id)
+    .bind(action)
+    .execute(&mut tx)
+    .await
+    .with_context(|| format!("Failed to audit workout with id {id}"))?;
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(workout)
+}
+
+pub async fn get_workout_audit<'local, E>(conn: E, id: i64) -> Result<Vec<WorkoutAuditEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT id, workout_id, action, created_utc_s
+        FROM workout_audit
+        WHERE workout_id = ?
+        ORDER BY id
+        ",
+    )
+    .bind(id)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get audit log of workout with id {id}"))
+}
+
+pub async fn get_workout_draft<'local, E>(
+    conn: E,
+    workout_id: i64,
+) -> Result<Option<WorkoutDraftEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT workout_id, data, updated_utc_s
+        FROM workout_draft
+        WHERE workout_id = ?
+        ",
+    )
+    .bind(workout_id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get draft of workout with id {workout_id}"))
+}
+
+/// Replaces the draft of a workout, the last write wins.
+pub async fn save_workout_draft<'local, E>(
+    conn: E,
+    workout_id: i64,
+    data: &str,
+) -> Result<WorkoutDraftEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        INSERT INTO workout_draft (workout_id, data, updated_utc_s)
+        VALUES (?, ?, UNIXEPOCH(datetime()))
+        ON CONFLICT (workout_id) DO UPDATE
+        SET data = excluded.data, updated_utc_s = excluded.updated_utc_s
+        RETURNING workout_id, data, updated_utc_s
+        ",
+    )
+    .bind(workout_id)
+    .bind(data)
+    .fetch_one(conn)
+    .await
+    .with_context(|| format!("Failed to save draft of workout with id {workout_id}"))
+}
+
+pub async fn delete_workout_draft<'local, E>(conn: E, workout_id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM workout_draft WHERE workout_id = ?")
+        .bind(workout_id)
+        .execute(conn)
+        .await
+        .with_context(|| format!("Failed to delete draft of workout with id {workout_id}"))
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+}
+
+pub async fn get_rest_timer<'local, E>(conn: E, workout_id: i64) -> Result<Option<RestTimerEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT workout_id, started_utc_s, duration_s
+        FROM rest_timer
+        WHERE workout_id = ?
+        ",
+    )
+    .bind(workout_id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get rest timer of workout with id {workout_id}"))
+}
+
+/// Starts the rest timer of a workout now, replacing a running one.
+pub async fn start_rest_timer<'local, E>(
+    conn: E,
+    workout_id: i64,
+    duration_s: i64,
+) -> Result<RestTimerEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        INSERT INTO rest_timer (workout_id, started_utc_s, duration_s)
+        VALUES (?, UNIXEPOCH(datetime()), ?)
+        ON CONFLICT (workout_id) DO UPDATE
+        SET started_utc_s = excluded.started_utc_s, duration_s = excluded.duration_s
+        RETURNING workout_id, started_utc_s, duration_s
+        ",
+    )
+    .bind(workout_id)
+    .bind(duration_s)
+    .fetch_one(conn)
+    .await
+    .with_context(|| format!("Failed to start rest timer of workout with id {workout_id}"))
+}
+
+pub async fn delete_rest_timer<'local, E>(conn: E, workout_id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM rest_timer WHERE workout_id = ?")
+        .bind(workout_id)
+        .execute(conn)
+        .await
+        .with_context(|| format!("Failed to delete rest timer of workout with id {workout_id}"))
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+}
+
+enum ExerciseSetConstraintId {
+    ExerciseSet,
+    Workout,
+    Exercise,
+}
+
+const GET_ALL_EXERCISES_QUERY: &str = "
+    SELECT
+        es.id, es.exercise_id, e.name AS exercise_name,
+        es.workout_id, es.created_utc_s, es.repetitions, es.weight,
+        es.added_weight, es.duration_s, es.distance_m, es.set_type, es.position,
+        es.completed, es.target_repetitions, es.target_weight, es.note, es.plates, es.client_id
+    FROM exercise_set es
+    JOIN exercise e ON es.exercise_id = e.id
+";
+
+fn create_get_exercise_query(constraint: Option<ExerciseSetConstraintId>) -> String {
+    match constraint {
+        Some(ExerciseSetConstraintId::ExerciseSet) => {
+            format!("{GET_ALL_EXERCISES_QUERY} WHERE es.id = ?")
+        }
+        Some(ExerciseSetConstraintId::Workout) => {
+            format!("{GET_ALL_EXERCISES_QUERY} WHERE es.workout_id = ?")
+        }
+        Some(ExerciseSetConstraintId::Exercise) => {
+            format!("{GET_ALL_EXERCISES_QUERY} WHERE es.exercise_id = ?")
+        }
+        None => GET_ALL_EXERCISES_QUERY.to_string(),
+    }
+}
+
+pub async fn get_exercise_set<'local, E>(conn: E, id: i64) -> Result<Option<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&create_get_exercise_query(Some(
+        ExerciseSetConstraintId::ExerciseSet,
+    )))
+    .bind(id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get exercise set with id {id}"))
+}
+
+pub async fn get_exercise_set_by_client_id<'local, E>(
+    conn: E,
+    client_id: &str,
+) -> Result<Option<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!("{GET_ALL_EXERCISES_QUERY} WHERE es.client_id = ?"))
+        .bind(client_id)
+        .fetch_optional(conn)
+        .await
+        .with_context(|| format!("Failed to get exercise set with client id {client_id}"))
+}
+
+pub async fn get_exercise_sets<'local, E>(
+    conn: E,
+    page: PageInput,
+) -> Result<Vec<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!(
+        "
+        {GET_ALL_EXERCISES_QUERY}
+        WHERE ? IS NULL OR es.id > ?
+        ORDER BY es.id
+        LIMIT COALESCE(?, -1)
+        "
+    ))
+    .bind(page.after)
+    .bind(page.after)
+    .bind(page.limit)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get all exercise sets")
+}
+
+pub async fn get_exercise_sets_by_workout_id<'local, E>(
+    conn: E,
+    id: i64,
+    set_type: Option<SetType>,
+    page: PageInput,
+) -> Result<Vec<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!(
+        "
+        {}
+            AND (? IS NULL OR es.set_type = ?)
+            AND (
+                ? IS NULL
+                OR (es.position, es.id) > (
+                    SELECT position, id FROM exercise_set WHERE id = ? AND workout_id = ?
+                )
+            )
+        ORDER BY es.position, es.id
+        LIMIT COALESCE(?, -1)
+        ",
+        create_get_exercise_query(Some(ExerciseSetConstraintId::Workout))
+    ))
+    .bind(id)
+    .bind(set_type)
+    .bind(set_type)
+    .bind(page.after)
+    .bind(page.after)
+    .bind(id)
+    .bind(page.limit)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get exercise sets for workout with id {id}"))
+}
+
+/// Returns the sets of an exercise over time, optionally only those created
+/// within the given range and limited to the most recent ones.
+pub async fn get_exercise_sets_by_exercise_id<'local, E>(
+    conn: E,
+    id: i64,
+    from_utc_s: Option<i64>,
+    to_utc_s: Option<i64>,
+    limit: Option<i64>,
+) -> Result<Vec<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    sqlx::query_as(&format!(
+        "
+        SELECT *
+        FROM (
+            {}
+                AND (? IS NULL OR es.created_utc_s >= ?)
+                AND (? IS NULL OR es.created_utc_s < ?)
+            ORDER BY es.created_utc_s DESC, es.id DESC
+            LIMIT COALESCE(?, -1)
+        )
+        ORDER BY created_utc_s, id
+        ",
+        create_get_exercise_query(Some(ExerciseSetConstraintId::Exercise))
+    ))
+    .bind(id)
+    .bind(from_utc_s)
+    .bind(from_utc_s)
+    .bind(to_utc_s)
+    .bind(to_utc_s)
+    .bind(limit)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get exercise sets for exercise with id {id}"))
+}
+
+pub async fn get_last_session_sets_by_exercise_id<'local, E>(
+    conn: E,
+    exercise_id: i64,
+    exclude_workout_id: Option<i64>,
+) -> Result<Vec<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!(
+        "
+        {GET_ALL_EXERCISES_QUERY}
+        WHERE es.exercise_id = ?
+            AND es.completed
+            AND es.workout_id = (
+                SELECT w.id
+                FROM workout w
+                JOIN exercise_set es ON w.id = es.workout_id
+                WHERE es.exercise_id = ?
+                    AND es.completed
+                    AND w.id IS NOT ?
+                ORDER BY w.started_utc_s DESC
+                LIMIT 1
+            )
+        ORDER BY es.position, es.id
+        "
+    ))
+    .bind(exercise_id)
+    .bind(exercise_id)
+    .bind(exclude_workout_id)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get last session sets for exercise with id {exercise_id}"))
+}
+
+/// All completed weighted working sets, optionally of a single exercise,
+/// ordered by exercise and session.
+pub async fn get_session_sets<'local, E>(
+    conn: E,
+    exercise_id: Option<i64>,
+) -> Result<Vec<SessionSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT
+            es.exercise_id, e.name AS exercise_name, es.workout_id, w.started_utc_s,
+            es.weight, es.repetitions
+        FROM exercise_set es
+        JOIN exercise e ON e.id = es.exercise_id
+        JOIN workout w ON w.id = es.workout_id
+        WHERE es.completed
+            AND es.set_type != 'warmup'
+            AND es.weight > 0
+            AND es.repetitions > 0
+            AND (? IS NULL OR es.exercise_id = ?)
+        ORDER BY es.exercise_id, w.started_utc_s, w.id
+        ",
+    )
+    .bind(exercise_id)
+    .bind(exercise_id)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get session sets")
+}
+
+pub async fn get_personal_record_by_exercise_id<'local, E>(
+    conn: E,
+    exercise_id: i64,
+) -> Result<Option<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!(
+        "
+        {GET_ALL_EXERCISES_QUERY}
+        WHERE es.exercise_id = ?
+            AND es.completed
+            AND es.set_type != 'warmup'
+        ORDER BY es.weight DESC, es.added_weight DESC, es.repetitions DESC, es.created_utc_s
+        LIMIT 1
+        "
+    ))
+    .bind(exercise_id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get personal record for exercise with id {exercise_id}"))
+}
+
+/// Completed working sets with a weight and repetitions created within `from`
+/// (inclusive) and `to` (exclusive) in UTC seconds, oldest first.
+pub async fn get_weighted_working_sets<'local, E>(
+    conn: E,
+    from: Option<i64>,
+    to: Option<i64>,
+) -> Result<Vec<ExerciseSetEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!(
+        "
+        {GET_ALL_EXERCISES_QUERY}
+        WHERE es.completed
+            AND es.set_type != 'warmup'
+            AND es.weight IS NOT NULL
+            AND es.duration_s IS NULL
+            AND (? IS NULL OR es.created_utc_s >= ?)
+            AND (? IS NULL OR es.created_utc_s < ?)
+        ORDER BY es.created_utc_s, es.id
+        "
+    ))
+    .bind(from)
+    .bind(from)
+    .bind(to)
+    .bind(to)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get weighted working sets")
+}
+
+pub async fn get_exercise_stats<'local, E>(conn: E, exercise_id: i64) -> Result<ExerciseStatsEntity>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    let (set_count, last_performed): (i64, Option<DateTime<Utc>>) = sqlx::query_as(
+        "
+        SELECT COUNT(*), MAX(w.started_utc_s)
+        FROM exercise_set es
+        JOIN workout w ON w.id = es.workout_id
+        WHERE es.exercise_id = ? AND es.completed
+        ",
+    )
+    .bind(exercise_id)
+    .fetch_one(conn)
+    .await
+    .with_context(|| format!("Failed to get set count for exercise with id {exercise_id}"))?;
+
+    // Distinct combinations are enough to find the best estimate.
+    let weights_and_repetitions: Vec<(i64, i64)> = sqlx::query_as(
+        "
+        SELECT DISTINCT weight, repetitions
+        FROM exercise_set
+        WHERE exercise_id = ?
+            AND completed
+            AND set_type != 'warmup'
+            AND weight IS NOT NULL
+        ",
+    )
+    .bind(exercise_id)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get weights for exercise with id {exercise_id}"))?;
+
+    let best_set = get_personal_record_by_exercise_id(conn, exercise_id).await?;
+
+    Ok(ExerciseStatsEntity {
+        set_count,
+        last_performed,
+        estimated_one_rep_max: one_rep_max::best(weights_and_repetitions),
+        best_set,
+    })
+}
+
+pub async fn create_or_update_exercise_set<'local, E>(
+    conn: E,
+    exercise_set_id: Option<i64>,
+    exercise_set: ExerciseSetInput,
+) -> Result<ExerciseSetEntity>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    let id = write_exercise_set(conn, exercise_set_id, exercise_set).await?;
+
+    Ok(get_exercise_set(conn, id)
+        .await?
+        .expect("Exercise set must exist as it was written by the previous query"))
+}
+
+/// Creates or updates a set like `create_or_update_exercise_set` but only
+/// returns its id, so it can be used within a transaction.
+pub async fn write_exercise_set<'local, E>(
+    conn: E,
+    exercise_set_id: Option<i64>,
+    exercise_set: ExerciseSetInput,
+) -> Result<i64>
+where
+    E: SqliteExecutor<'local>,
+{
+    let query = match exercise_set_id {
+        Some(_) => {
+            "
+            UPDATE exercise_set
+            SET position = CASE
+                    WHEN workout_id = ? THEN position
+                    ELSE (SELECT COALESCE(MAX(position) + 1, 0) FROM exercise_set WHERE workout_id = ?)
+                END,
+                workout_id = ?, exercise_id = ?, repetitions = ?, weight = ?, added_weight = ?,
+                duration_s = ?, distance_m = ?, set_type = COALESCE(?, set_type),
+                created_utc_s = COALESCE(?, created_utc_s), completed = COALESCE(?, completed),
+                target_repetitions = COALESCE(?, target_repetitions),
+                target_weight = COALESCE(?, target_weight), note = ?, plates = ?
+            WHERE id = ?
+            RETURNING id
+            "
+        }
+        None => {
+            "
+            INSERT INTO exercise_set
+                (position, workout_id, exercise_id, repetitions, weight, added_weight, duration_s,
+                distance_m, set_type, created_utc_s, completed, target_repetitions, target_weight,
+                note, plates, client_id)
+            VALUES (
+                (SELECT COALESCE(MAX(position) + 1, 0) FROM exercise_set WHERE workout_id = ?),
+                ?, ?, ?, ?, ?, ?, ?, COALESCE(?, 'working'), COALESCE(?, UNIXEPOCH(datetime())),
+                COALESCE(?, 1), ?, ?, ?, ?, ?
+            )
+            RETURNING id
+            "
+        }
+    };
+
+    let workout_id = exercise_set.workout_id;
+    let exercise_id = exercise_set.exercise_id;
+
+    // Empty notes are stored as NULL in the database.
+    let note = match exercise_set.note.trim() {
+        "" => None,
+        note => Some(note),
+    };
+
+    // New sets and sets moved to another workout are appended to the end.
+    let mut query = sqlx::query_scalar::<_, i64>(query).bind(workout_id);
+
+    if exercise_set_id.is_some() {
+        query = query.bind(workout_id);
+    }
+
+    query = query
+        .bind(workout_id)
+        .bind(exercise_id)
+        .bind(exercise_set.repetitions)
+        .bind(exercise_set.weight)
+        .bind(exercise_set.added_weight)
+        .bind(exercise_set.duration_s)
+        .bind(exercise_set.distance_m)
+        .bind(exercise_set.set_type)
+        .bind(exercise_set.created_utc_s)
+        .bind(exercise_set.completed)
+        .bind(exercise_set.target_repetitions)
+        .bind(exercise_set.target_weight)
+        .bind(note)
+        .bind(exercise_set.plates);
+
+    query = match exercise_set_id {
+        Some(id) => query.bind(id),
+        None => query.bind(exercise_set.client_id),
+    };
+
+    query
+        .fetch_one(conn)
+        .await
+        .with_context(|| {
+            format!("Failed to create exercise set with workout id {workout_id} and exercise id {exercise_id}")
+        })
+}
+
+pub async fn complete_exercise_set<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query(
+        "
+        UPDATE exercise_set
+        SET completed = 1, created_utc_s = UNIXEPOCH(datetime())
+        WHERE id = ?
+        ",
+    )
+    .bind(id)
+    .execute(conn)
+    .await
+    .map(|res| (res.rows_affected() > 0).then_some(()))
+    .with_context(|| format!("Failed to complete exercise set with id {id}"))
+}
+
+/// Moves a set to another workout, appending it to the sets of that workout.
+pub async fn move_exercise_set<'local, E>(conn: E, id: i64, workout_id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query(
+        "
+        UPDATE exercise_set
+        SET position = CASE
+                WHEN workout_id = ? THEN position
+                ELSE (SELECT COALESCE(MAX(position) + 1, 0) FROM exercise_set WHERE workout_id = ?)
+            END,
+            workout_id = ?
+        WHERE id = ?
+        ",
+    )
+    .bind(workout_id)
+    .bind(workout_id)
+    .bind(workout_id)
+    .bind(id)
+    .execute(conn)
+    .await
+    .map(|res| (res.rows_affected() > 0).then_some(()))
+    .with_context(|| {
+        format!("Failed to move exercise set with id {id} to workout with id {workout_id}")
+    })
+}
+
+/// Orders the sets of a workout like the given set ids, which have to contain
+/// every set of the workout exactly once.
+pub async fn reorder_exercise_sets(
+    pool: &Pool<Sqlite>,
+    workout_id: i64,
+    exercise_set_ids: &[i64],
+) -> Result<Option<()>> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let mut current_ids =
+        sqlx::query_scalar::<_, i64>("SELECT id FROM exercise_set WHERE workout_id = ?")
+            .bind(workout_id)
+            .fetch_all(&mut tx)
+            .await
+            .with_context(|| {
+                format!("Failed to get exercise sets of workout with id {workout_id}")
+            })?;
+
+    let mut requested_ids = exercise_set_ids.to_vec();
+
+    current_ids.sort_unstable();
+    requested_ids.sort_unstable();
+
+    if current_ids != requested_ids {
+        return Ok(None);
+    }
+
+    for (position, id) in exercise_set_ids.iter().enumerate() {
+        sqlx::query("UPDATE exercise_set SET position = ? WHERE id = ?")
+            .bind(position as i64)
+            .bind(id)
+            .execute(&mut tx)
+            .await
+            .with_context(|| format!("Failed to update position of exercise set with id {id}"))?;
+    }
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(Some(()))
+}
+
+pub async fn delete_exercise_set<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM exercise_set WHERE id = ?")
+        .bind(id)
+        .execute(conn)
+        .await
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete exercise set with id {id}"))
+}
+
+/// Deletes the sets of a workout matching all given filters, or every set of
+/// the workout without filters, in one transaction.
+///
+/// With `preview` set the transaction is rolled back, so the returned sets
+/// show what would have been deleted.
+pub async fn delete_exercise_sets_by_workout_id(
+    pool: &Pool<Sqlite>,
+    workout_id: i64,
+    exercise_id: Option<i64>,
+    created_within_minutes: Option<i64>,
+    preview: bool,
+) -> Result<Vec<ExerciseSetEntity>> {
+    let created_after_utc_s =
+        created_within_minutes.map(|minutes| Utc::now().timestamp() - minutes * 60);
+
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let exercise_sets = sqlx::query_as::<_, ExerciseSetEntity>(&format!(
+        "
+        {GET_ALL_EXERCISES_QUERY}
+        WHERE es.workout_id = ?
+            AND (? IS NULL OR es.exercise_id = ?)
+            AND (? IS NULL OR es.created_utc_s >= ?)
+        "
+    ))
+    .bind(workout_id)
+    .bind(exercise_id)
+    .bind(exercise_id)
+    .bind(created_after_utc_s)
+    .bind(created_after_utc_s)
+    .fetch_all(&mut tx)
+    .await
+    .with_context(|| {
+        format!("Failed to get exercise sets to delete for workout with id {workout_id}")
+    })?;
+
+    if preview {
+        return Ok(exercise_sets);
+    }
+
+    sqlx::query(
+        "
+        DELETE FROM exercise_set
+        WHERE workout_id = ?
+            AND (? IS NULL OR exercise_id = ?)
+            AND (? IS NULL OR created_utc_s >= ?)
+        ",
+    )
+    .bind(workout_id)
+    .bind(exercise_id)
+    .bind(exercise_id)
+    .bind(created_after_utc_s)
+    .bind(created_after_utc_s)
+    .execute(&mut tx)
+    .await
+    .with_context(|| format!("Failed to delete exercise sets for workout with id {workout_id}"))?;
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(exercise_sets)
+}
+
+/// Applies a weight correction in one transaction, keeping the previous and
+/// new weights of every changed set in `correction_set` for auditing.
+///
+/// Summaries of archived workouts are updated as well, as their volume is
+/// derived from the weights. Finished workouts are corrected on purpose.
+pub async fn correct_weights(
+    pool: &Pool<Sqlite>,
+    correction: &WeightCorrectionInput,
+) -> Result<CorrectionEntity> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let id = sqlx::query_scalar::<_, i64>(
+        "
+        INSERT INTO correction (description, affected_sets, created_utc_s)
+        VALUES (?, 0, UNIXEPOCH(datetime()))
+        RETURNING id
+        ",
+    )
+    .bind(&correction.description)
+    .fetch_one(&mut tx)
+    .await
+    .context("Failed to create correction")?;
+
+    let affected_sets = sqlx::query(
+        "
+        INSERT INTO correction_set (
+            correction_id, exercise_set_id, old_weight, new_weight, old_added_weight,
+            new_added_weight, old_target_weight, new_target_weight
+        )
+        SELECT
+            ?, id,
+            weight, CAST(ROUND(weight * ?) AS INT),
+            added_weight, CAST(ROUND(added_weight * ?) AS INT),
+            target_weight, CAST(ROUND(target_weight * ?) AS INT)
+        FROM exercise_set
+        WHERE exercise_id = ?
+            AND (? IS NULL OR created_utc_s < ?)
+            AND (weight IS NOT NULL OR added_weight IS NOT NULL OR target_weight IS NOT NULL)
+        ",
+    )
+    .bind(id)
+    .bind(correction.factor)
+    .bind(correction.factor)
+    .bind(correction.factor)
+    .bind(correction.exercise_id)
+    .bind(correction.created_before_utc_s)
+    .bind(correction.created_before_utc_s)
+    .execute(&mut tx)
+    .await
+    .with_context(|| format!("Failed to audit correction with id {id}"))?
+    .rows_affected();
+
+    sqlx::query(
+        "
+        UPDATE exercise_set
+        SET weight = cs.new_weight, added_weight = cs.new_added_weight,
+            target_weight = cs.new_target_weight
+        FROM correction_set cs
+        WHERE cs.exercise_set_id = exercise_set.id
+            AND cs.correction_id = ?
+        ",
+    )
+    .bind(id)
+    .execute(&mut tx)
+    .await
+    .with_context(|| format!("Failed to apply correction with id {id}"))?;
+
+    sqlx::query(
+        "
+        UPDATE workout_summary
+        SET total_volume = (
+            SELECT CAST(
+                COALESCE(
+                    SUM(es.repetitions * es.weight)
+                        FILTER (WHERE es.duration_s IS NULL AND es.set_type != 'warmup'),
+                    0
+                ) AS INT
+            )
+            FROM exercise_set es
+            WHERE es.workout_id = workout_summary.workout_id
+                AND es.completed
+        )
+        WHERE workout_id IN (
+            SELECT es.workout_id
+            FROM correction_set cs
+            JOIN exercise_set es ON cs.exercise_set_id = es.id
+            WHERE cs.correction_id = ?
+        )
+        ",
+    )
+    .bind(id)
+    .execute(&mut tx)
+    .await
+    .with_context(|| format!("Failed to refresh workout summaries for correction with id {id}"))?;
+
+    let correction = sqlx::query_as(
+        "
+        UPDATE correction SET affected_sets = ?
+        WHERE id = ?
+        RETURNING id, description, affected_sets, created_utc_s
+        ",
+    )
+    .bind(affected_sets as i64)
+    .bind(id)
+    .fetch_one(&mut tx)
+    .await
+    .with_context(|| format!("Failed to update correction with id {id}"))?;
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(correction)
+}
+
+pub async fn get_corrections<'local, E>(conn: E) -> Result<Vec<CorrectionEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT id, description, affected_sets, created_utc_s
+        FROM correction
+        ORDER BY id DESC
+        ",
+    )
+    .fetch_all(conn)
+    .await
+    .context("Failed to get corrections")
+}
+
+/// Summarizes the completed sets of all workouts started before the given
+/// time into `workout_summary`, optionally deleting all sets of these workouts.
+///
+/// Workouts whose sets were already deleted keep their existing summary.
+pub async fn archive_workouts(
+    pool: &Pool<Sqlite>,
+    started_before_utc_s: i64,
+    delete_sets: bool,
+) -> Result<ArchiveResultEntity> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let archived_workouts = sqlx::query(
+        "
+        INSERT OR REPLACE INTO workout_summary (
+            workout_id, end_utc_s, total_sets, repetition_sets, total_repetitions, total_volume,
+            total_set_duration_s, archived_utc_s
+        )
+        SELECT
+            w.id,
+            MAX(es.created_utc_s),
+            COUNT(es.id),
+            COUNT(es.id) FILTER (WHERE es.duration_s IS NULL),
+            COALESCE(SUM(es.repetitions) FILTER (WHERE es.duration_s IS NULL), 0),
+            CAST(
+                COALESCE(
+                    SUM(es.repetitions * es.weight)
+                        FILTER (WHERE es.duration_s IS NULL AND es.set_type != 'warmup'),
+                    0
+                ) AS INT
+            ),
+            COALESCE(SUM(es.duration_s), 0),
+            ?
+        FROM workout w
+        JOIN exercise_set es ON es.workout_id = w.id
+        WHERE w.started_utc_s < ?
+            AND es.completed
+        GROUP BY w.id
+        ",
+    )
+    .bind(Utc::now().timestamp())
+    .bind(started_before_utc_s)
+    .execute(&mut tx)
+    .await
+    .context("Failed to summarize workouts")?
+    .rows_affected();
+
+    let deleted_sets = if delete_sets {
+        sqlx::query(
+            "
+            DELETE FROM exercise_set
+            WHERE workout_id IN (
+                SELECT ws.workout_id
+                FROM workout_summary ws
+                JOIN workout w ON ws.workout_id = w.id
+                WHERE w.started_utc_s < ?
+            )
+            ",
+        )
+        .bind(started_before_utc_s)
+        .execute(&mut tx)
+        .await
+        .context("Failed to delete archived exercise sets")?
+        .rows_affected()
+    } else {
+        0
+    };
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(ArchiveResultEntity {
+        archived_workouts,
+        deleted_sets,
+    })
+}
+
+pub async fn get_max_weight_by_exercise_id<'local, E>(
+    conn: E,
+    exercise_id: i64,
+    exclude_exercise_set_id: i64,
+) -> Result<Option<i64>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_scalar(
+        "SELECT MAX(weight) FROM exercise_set WHERE exercise_id = ? AND id != ? AND completed",
+    )
+    .bind(exercise_id)
+    .bind(exclude_exercise_set_id)
+    .fetch_one(conn)
+    .await
+    .with_context(|| format!("Failed to get max weight for exercise with id {exercise_id}"))
+}
+
+/// Bests of an exercise before the given set, by creation time, so a set
+/// only beats the records it would have beaten when it was logged. The
+/// repetitions are of sets with exactly the given weight.
+pub async fn get_exercise_bests<'local, E>(
+    conn: E,
+    exercise_id: i64,
+    exercise_set_id: i64,
+    weight: i64,
+) -> Result<ExerciseBestsEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT
+            MAX(weight) AS weight,
+            MAX(
+                CASE WHEN repetitions = 1 THEN weight ELSE weight * (1 + repetitions / 30.0) END
+            ) AS one_rep_max,
+            MAX(repetitions) FILTER (WHERE weight = ?) AS repetitions_at_weight
+        FROM exercise_set
+        WHERE exercise_id = ?
+            AND (created_utc_s, id) < (SELECT created_utc_s, id FROM exercise_set WHERE id = ?)
+            AND completed
+            AND set_type != 'warmup'
+            AND duration_s IS NULL
+            AND weight > 0
+            AND repetitions > 0
+        ",
+    )
+    .bind(weight)
+    .bind(exercise_id)
+    .bind(exercise_set_id)
+    .fetch_one(conn)
+    .await
+    .with_context(|| format!("Failed to get bests of exercise with id {exercise_id}"))
+}
 
-#[derive(Debug, FromRow)]
-pub struct ExerciseEntity {
-    pub id: i64,
-    pub name: String,
+/// Replaces the records of a set, records of a kind it already had keep
+/// their creation time. Returns the kinds that are new.
+pub async fn replace_pr_events(
+    pool: &Pool<Sqlite>,
+    exercise_set_id: i64,
+    events: &[PrEventInput],
+) -> Result<Vec<PrKind>> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let existing: Vec<PrKind> =
+        sqlx::query_scalar("SELECT kind FROM pr_event WHERE exercise_set_id = ?")
+            .bind(exercise_set_id)
+            .fetch_all(&mut tx)
+            .await
+            .with_context(|| {
+                format!("Failed to get records of exercise set with id {exercise_set_id}")
+            })?;
+
+    for kind in &existing {
+        if events.iter().all(|event| event.kind != *kind) {
+            sqlx::query("DELETE FROM pr_event WHERE exercise_set_id = ? AND kind = ?")
+                .bind(exercise_set_id)
+                .bind(kind)
+                .execute(&mut tx)
+                .await
+                .with_context(|| {
+                    format!("Failed to delete record of exercise set with id {exercise_set_id}")
+                })?;
+        }
+    }
+
+    for event in events {
+        sqlx::query(
+            "
+            INSERT INTO pr_event (exercise_set_id, kind, value, previous_value, created_utc_s)
+            VALUES (?, ?, ?, ?, UNIXEPOCH(datetime()))
+            ON CONFLICT (exercise_set_id, kind)
+            DO UPDATE SET value = excluded.value, previous_value = excluded.previous_value
+            ",
+        )
+        .bind(exercise_set_id)
+        .bind(event.kind)
+        .bind(event.value)
+        .bind(event.previous_value)
+        .execute(&mut tx)
+        .await
+        .with_context(|| {
+            format!("Failed to create record of exercise set with id {exercise_set_id}")
+        })?;
+    }
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(events
+        .iter()
+        .map(|event| event.kind)
+        .filter(|kind| !existing.contains(kind))
+        .collect())
 }
 
-#[derive(Debug, FromRow)]
-pub struct WorkoutEntity {
-    pub id: i64,
-    #[sqlx(rename = "started_utc_s")]
-    pub started: chrono::DateTime<chrono::Utc>,
-    pub note: Option<String>,
+/// The most recent records first.
+pub async fn get_recent_pr_events<'local, E>(conn: E, limit: i64) -> Result<Vec<PrEventEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT
+            p.id, p.exercise_set_id, es.workout_id, es.exercise_id, e.name AS exercise_name,
+            p.kind, es.weight, es.repetitions, p.value, p.previous_value, p.created_utc_s
+        FROM pr_event p
+        JOIN exercise_set es ON es.id = p.exercise_set_id
+        JOIN exercise e ON e.id = es.exercise_id
+        ORDER BY p.created_utc_s DESC, p.id DESC
+        LIMIT ?
+        ",
+    )
+    .bind(limit)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get recent records")
 }
 
-#[derive(Debug, FromRow)]
-pub struct SetSuggestionEntity {
-    pub exercise_id: i64,
-    pub repetitions: i64,
-    pub weight: i64,
+pub async fn get_set_anomalies<'local, E>(conn: E) -> Result<Vec<SetAnomalyEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT
+            sa.id, sa.exercise_set_id, es.workout_id, es.exercise_id, e.name AS exercise_name,
+            sa.code, sa.message, sa.created_utc_s
+        FROM set_anomaly sa
+        JOIN exercise_set es ON sa.exercise_set_id = es.id
+        JOIN exercise e ON es.exercise_id = e.id
+        ORDER BY sa.created_utc_s DESC
+        ",
+    )
+    .fetch_all(conn)
+    .await
+    .context("Failed to get set anomalies")
 }
 
-#[derive(Debug, FromRow)]
-pub struct ExerciseSetEntity {
-    pub id: i64,
-    pub exercise_id: i64,
-    pub exercise_name: String,
-    pub workout_id: i64,
-    #[sqlx(rename = "created_utc_s")]
-    pub created: DateTime<Utc>,
-    pub repetitions: i64,
-    pub weight: i64,
-    pub note: Option<String>,
+pub async fn get_insights<'local, E>(conn: E, include_dismissed: bool) -> Result<Vec<InsightEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        SELECT
+            id, rule, subject, title, message, created_utc_s, acknowledged_utc_s,
+            dismissed_utc_s
+        FROM insight
+        WHERE ? OR dismissed_utc_s IS NULL
+        ORDER BY created_utc_s DESC, id DESC
+        ",
+    )
+    .bind(include_dismissed)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get insights")
 }
 
-#[derive(Debug, FromRow)]
-pub struct ExerciseCountEntity {
-    pub count: i64,
+/// Replaces the insights of a rule with its current findings in one
+/// transaction, keeping the state of insights that are still found. Returns
+/// the insights that are new.
+pub async fn replace_insights<'a>(
+    pool: &Pool<Sqlite>,
+    rule: &str,
+    insights: &'a [InsightInput],
+) -> Result<Vec<&'a InsightInput>> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    // Writing first takes the write lock right away, a transaction that read
+    // first fails when another one wrote in the meantime.
+    let placeholders = vec!["?"; insights.len()].join(", ");
+    let query = format!("DELETE FROM insight WHERE rule = ? AND subject NOT IN ({placeholders})");
+    let mut delete = sqlx::query(&query).bind(rule);
+    for insight in insights {
+        delete = delete.bind(&insight.subject);
+    }
+    delete
+        .execute(&mut tx)
+        .await
+        .with_context(|| format!("Failed to delete resolved insights of rule {rule}"))?;
+
+    let subjects: Vec<String> = sqlx::query_scalar("SELECT subject FROM insight WHERE rule = ?")
+        .bind(rule)
+        .fetch_all(&mut tx)
+        .await
+        .with_context(|| format!("Failed to get insights of rule {rule}"))?;
+
+    for insight in insights {
+        sqlx::query(
+            "
+            INSERT INTO insight (rule, subject, title, message, created_utc_s)
+            VALUES (?, ?, ?, ?, UNIXEPOCH(datetime()))
+            ON CONFLICT (rule, subject) DO UPDATE
+            SET title = excluded.title, message = excluded.message
+            ",
+        )
+        .bind(rule)
+        .bind(&insight.subject)
+        .bind(&insight.title)
+        .bind(&insight.message)
+        .execute(&mut tx)
+        .await
+        .with_context(|| format!("Failed to write insight {} of rule {rule}", insight.subject))?;
+    }
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    Ok(insights
+        .iter()
+        .filter(|insight| !subjects.contains(&insight.subject))
+        .collect())
 }
 
-#[derive(Debug, Default, FromRow)]
-pub struct StatisticsOverviewEntity {
-    pub total_workouts: i64,
-    pub total_duration_s: i64,
-    pub avg_duration_s: i64,
-    pub total_sets: i64,
-    pub total_repetitions: i64,
-    pub avg_repetitions_per_set: i64,
+pub async fn acknowledge_insight<'local, E>(conn: E, id: i64) -> Result<Option<InsightEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
+        "
+        UPDATE insight SET acknowledged_utc_s = COALESCE(acknowledged_utc_s, UNIXEPOCH(datetime()))
+        WHERE id = ?
+        RETURNING
+            id, rule, subject, title, message, created_utc_s, acknowledged_utc_s,
+            dismissed_utc_s
+        ",
+    )
+    .bind(id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to acknowledge insight with id {id}"))
 }
 
-pub async fn get_exercise_count<'local, E>(conn: E, id: i64) -> Result<ExerciseCountEntity>
+pub async fn dismiss_insight<'local, E>(conn: E, id: i64) -> Result<Option<InsightEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as("SELECT COUNT(*) AS count FROM exercise_set WHERE exercise_id = ?")
+    sqlx::query_as(
+        "
+        UPDATE insight SET dismissed_utc_s = COALESCE(dismissed_utc_s, UNIXEPOCH(datetime()))
+        WHERE id = ?
+        RETURNING
+            id, rule, subject, title, message, created_utc_s, acknowledged_utc_s,
+            dismissed_utc_s
+        ",
+    )
+    .bind(id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to dismiss insight with id {id}"))
+}
+
+/// Replaces the anomalies flagged for an exercise set in one transaction.
+pub async fn replace_set_anomalies(
+    pool: &Pool<Sqlite>,
+    exercise_set_id: i64,
+    anomalies: &[(&str, &str)],
+) -> Result<()> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    sqlx::query("DELETE FROM set_anomaly WHERE exercise_set_id = ?")
+        .bind(exercise_set_id)
+        .execute(&mut tx)
+        .await
+        .with_context(|| {
+            format!("Failed to delete anomalies of exercise set with id {exercise_set_id}")
+        })?;
+
+    for &(code, message) in anomalies {
+        sqlx::query(
+            "
+            INSERT INTO set_anomaly (exercise_set_id, code, message, created_utc_s)
+            VALUES (?, ?, ?, UNIXEPOCH(datetime()))
+            ",
+        )
+        .bind(exercise_set_id)
+        .bind(code)
+        .bind(message)
+        .execute(&mut tx)
+        .await
+        .with_context(|| {
+            format!("Failed to create anomaly for exercise set with id {exercise_set_id}")
+        })?;
+    }
+
+    tx.commit().await.context("Failed to commit transaction")
+}
+
+pub async fn delete_set_anomaly<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM set_anomaly WHERE id = ?")
         .bind(id)
-        .fetch_one(conn)
+        .execute(conn)
         .await
-        .with_context(|| format!("Failed to get exercise count for exercise with id {id}"))
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete set anomaly with id {id}"))
 }
 
-pub async fn get_exercise<'local, E>(conn: E, id: i64) -> Result<Option<ExerciseEntity>>
+const GET_ALL_ATTACHMENTS_QUERY: &str = "
+    SELECT
+        id, exercise_set_id, content_type, file_name, url, COALESCE(LENGTH(data), 0) AS size,
+        created_utc_s
+    FROM set_attachment
+";
+
+pub async fn get_attachments_by_exercise_set_id<'local, E>(
+    conn: E,
+    exercise_set_id: i64,
+) -> Result<Vec<AttachmentEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(&format!(
+        "{GET_ALL_ATTACHMENTS_QUERY} WHERE exercise_set_id = ? ORDER BY id"
+    ))
+    .bind(exercise_set_id)
+    .fetch_all(conn)
+    .await
+    .with_context(|| {
+        format!("Failed to get attachments for exercise set with id {exercise_set_id}")
+    })
+}
+
+pub async fn get_attachment_content<'local, E>(
+    conn: E,
+    id: i64,
+) -> Result<Option<AttachmentContentEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as("SELECT id, name FROM exercise WHERE id = ?")
+    sqlx::query_as("SELECT content_type, url, data FROM set_attachment WHERE id = ?")
         .bind(id)
         .fetch_optional(conn)
         .await
-        .with_context(|| format!("Failed to get exercise with id {id}"))
+        .with_context(|| format!("Failed to get content of attachment with id {id}"))
 }
 
-pub async fn get_exercises<'local, E>(conn: E) -> Result<Vec<ExerciseEntity>>
+/// Total size of all uploaded attachments in bytes.
+pub async fn get_attachments_size<'local, E>(conn: E) -> Result<i64>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as("SELECT id, name FROM exercise ORDER BY name")
-        .fetch_all(conn)
+    sqlx::query_scalar("SELECT CAST(COALESCE(SUM(LENGTH(data)), 0) AS integer) FROM set_attachment")
+        .fetch_one(conn)
         .await
-        .context("Failed to get exercises")
+        .context("Failed to get size of attachments")
 }
 
-pub async fn create_exercise<'local, E>(conn: E, name: &str) -> Result<ExerciseEntity>
+pub async fn create_attachment<'local, E>(
+    conn: E,
+    exercise_set_id: i64,
+    attachment: AttachmentInput,
+) -> Result<AttachmentEntity>
 where
-    E: SqliteExecutor<'local>,
+    E: SqliteExecutor<'local> + Copy,
 {
-    sqlx::query_as("INSERT INTO exercise (name) VALUES (?) RETURNING id, name")
-        .bind(name)
+    let id = sqlx::query_scalar::<_, i64>(
+        "
+        INSERT INTO set_attachment
+            (exercise_set_id, content_type, file_name, url, data, created_utc_s)
+        VALUES (?, ?, ?, ?, ?, UNIXEPOCH(datetime()))
+        RETURNING id
+        ",
+    )
+    .bind(exercise_set_id)
+    .bind(attachment.content_type)
+    .bind(attachment.file_name)
+    .bind(attachment.url)
+    .bind(attachment.data)
+    .fetch_one(conn)
+    .await
+    .with_context(|| {
+        format!("Failed to create attachment for exercise set with id {exercise_set_id}")
+    })?;
+
+    sqlx::query_as(&format!("{GET_ALL_ATTACHMENTS_QUERY} WHERE id = ?"))
+        .bind(id)
         .fetch_one(conn)
         .await
-        .with_context(|| format!(r#"Failed to create exercise with name "{name}""#))
+        .with_context(|| format!("Failed to get attachment with id {id}"))
 }
 
-pub async fn delete_exercise<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+pub async fn delete_attachment<'local, E>(conn: E, id: i64) -> Result<Option<()>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query("DELETE FROM exercise WHERE id = ?")
+    sqlx::query("DELETE FROM set_attachment WHERE id = ?")
         .bind(id)
         .execute(conn)
         .await
         .map(|res| (res.rows_affected() > 0).then_some(()))
-        .with_context(|| format!("Failed to delete exercise with id {id}"))
+        .with_context(|| format!("Failed to delete attachment with id {id}"))
 }
 
-pub async fn update_exercise<'local, E>(conn: E, id: i64, name: &str) -> Result<ExerciseEntity>
+pub async fn get_notification_channel<'local, E>(
+    conn: E,
+    id: i64,
+) -> Result<Option<NotificationChannelEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as("UPDATE exercise SET name = ? WHERE id = ? RETURNING id, name")
-        .bind(name)
-        .bind(id)
-        .fetch_one(conn)
-        .await
-        .with_context(|| format!(r#"Failed to update name of exercise with id {id} to "{name}""#))
+    sqlx::query_as(
+        "SELECT id, name, transport, created_utc_s FROM notification_channel WHERE id = ?",
+    )
+    .bind(id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get notification channel with id {id}"))
 }
 
-pub async fn get_workout<'local, E>(conn: E, id: i64) -> Result<Option<WorkoutEntity>>
+pub async fn get_notification_channels<'local, E>(conn: E) -> Result<Vec<NotificationChannelEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as("SELECT id, started_utc_s, note FROM workout WHERE id = ?")
-        .bind(id)
-        .fetch_optional(conn)
-        .await
-        .with_context(|| format!("Failed to get workout with id {id}"))
+    sqlx::query_as(
+        "SELECT id, name, transport, created_utc_s FROM notification_channel ORDER BY id",
+    )
+    .fetch_all(conn)
+    .await
+    .context("Failed to get notification channels")
 }
 
-pub async fn get_workouts<'local, E>(conn: E) -> Result<Vec<WorkoutEntity>>
+/// Channels that notifications of the event are routed to.
+pub async fn get_notification_channels_by_event<'local, E>(
+    conn: E,
+    event: NotificationEvent,
+) -> Result<Vec<NotificationChannelEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as("SELECT id, started_utc_s, note FROM workout")
-        .fetch_all(conn)
-        .await
-        .context("Failed to get workouts")
+    sqlx::query_as(
+        "
+        SELECT c.id, c.name, c.transport, c.created_utc_s
+        FROM notification_channel c
+        JOIN notification_rule r ON r.channel_id = c.id
+        WHERE r.event = ?
+        ORDER BY c.id
+        ",
+    )
+    .bind(event)
+    .fetch_all(conn)
+    .await
+    .with_context(|| format!("Failed to get notification channels for event {event:?}"))
 }
 
-pub async fn create_workout<'local, E>(conn: E) -> Result<WorkoutEntity>
+pub async fn create_notification_channel<'local, E>(
+    conn: E,
+    channel: &NotificationChannelInput,
+) -> Result<NotificationChannelEntity>
 where
     E: SqliteExecutor<'local>,
 {
+    let name = &channel.name;
+
     sqlx::query_as(
         "
-        INSERT INTO workout (started_utc_s) VALUES (UNIXEPOCH(datetime()))
-        RETURNING id, started_utc_s, note
+        INSERT INTO notification_channel (name, transport, created_utc_s)
+        VALUES (?, ?, UNIXEPOCH(datetime()))
+        RETURNING id, name, transport, created_utc_s
         ",
     )
+    .bind(name)
+    .bind(&channel.transport)
     .fetch_one(conn)
     .await
-    .context("Failed to create workout")
+    .with_context(|| format!(r#"Failed to create notification channel with name "{name}""#))
 }
 
-pub async fn delete_workout<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+pub async fn delete_notification_channel<'local, E>(conn: E, id: i64) -> Result<Option<()>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query("DELETE FROM workout WHERE id = ?")
+    sqlx::query("DELETE FROM notification_channel WHERE id = ?")
         .bind(id)
         .execute(conn)
         .await
-        .with_context(|| format!("Failed to delete workout with id {id}"))
         .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete notification channel with id {id}"))
 }
 
-pub async fn update_workout_meta_data<'local, E>(
-    conn: E,
-    id: i64,
-    note: &str,
-) -> Result<Option<WorkoutEntity>>
+pub async fn get_notification_rules<'local, E>(conn: E) -> Result<Vec<NotificationRuleEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    let note = match note.trim() {
-        "" => None,
-        note => Some(note),
-    };
+    sqlx::query_as("SELECT id, event, channel_id FROM notification_rule ORDER BY id")
+        .fetch_all(conn)
+        .await
+        .context("Failed to get notification rules")
+}
 
+/// Routes the event to the channel, returns the existing rule if the event
+/// is already routed there.
+pub async fn create_notification_rule<'local, E>(
+    conn: E,
+    event: NotificationEvent,
+    channel_id: i64,
+) -> Result<NotificationRuleEntity>
+where
+    E: SqliteExecutor<'local>,
+{
     sqlx::query_as(
         "
-        UPDATE workout
-        SET note = ?
-        WHERE id = ?
-        RETURNING id, started_utc_s, note
+        INSERT INTO notification_rule (event, channel_id) VALUES (?, ?)
+        ON CONFLICT (event, channel_id) DO UPDATE SET event = excluded.event
+        RETURNING id, event, channel_id
         ",
     )
-    .bind(note)
-    .bind(id)
-    .fetch_optional(conn)
+    .bind(event)
+    .bind(channel_id)
+    .fetch_one(conn)
     .await
-    .with_context(|| format!("Failed to update note for workout with id {id}"))
+    .with_context(|| {
+        format!("Failed to route event {event:?} to notification channel with id {channel_id}")
+    })
+}
+
+pub async fn delete_notification_rule<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query("DELETE FROM notification_rule WHERE id = ?")
+        .bind(id)
+        .execute(conn)
+        .await
+        .map(|res| (res.rows_affected() > 0).then_some(()))
+        .with_context(|| format!("Failed to delete notification rule with id {id}"))
 }
 
-enum ExerciseSetConstraintId {
-    ExerciseSet,
-    Workout,
-    Exercise,
-}
+/// The last completed set of the exercise in the workout. Without an
+/// exercise the last set of the workout, or else the first set of the last
+/// workout with sets, if its exercise is available at the gym. An exercise
+/// chosen by the client is always suggested. `None` if there is no such set.
+pub async fn get_set_suggestion_for_workout<'local, E>(
+    conn: E,
+    workout_id: i64,
+    exercise_id: Option<i64>,
+    gym_id: Option<i64>,
+) -> Result<Option<SetSuggestionEntity>>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    if let Some(exercise_id) = exercise_id {
+        return sqlx::query_as(
+            "
+            SELECT exercise_id, repetitions, weight, added_weight, duration_s
+            FROM exercise_set
+            WHERE workout_id = ?
+                AND exercise_id = ?
+                AND completed
+            ORDER BY position DESC, id DESC
+            LIMIT 1
+            ",
+        )
+        .bind(workout_id)
+        .bind(exercise_id)
+        .fetch_optional(conn)
+        .await
+        .with_context(|| {
+            format!("Failed to get set suggestion for workout with id {workout_id} and exercise id {exercise_id}")
+        });
+    }
+
+    let available = available_at_gym("es.exercise_id");
 
-fn create_get_exercise_query(constraint: Option<ExerciseSetConstraintId>) -> String {
-    const GET_ALL_EXERCISES_QUERY: &str = "
-    SELECT
-        es.id, es.exercise_id, e.name AS exercise_name,
-        es.workout_id, es.created_utc_s, es.repetitions, es.weight, es.note
-    FROM exercise_set es
-    JOIN exercise e ON es.exercise_id = e.id
-";
+    // Just suggest the last set again.
+    let suggestion = sqlx::query_as::<_, SetSuggestionEntity>(&format!(
+        "
+        SELECT es.exercise_id, es.repetitions, es.weight, es.added_weight, es.duration_s
+        FROM exercise_set es
+        WHERE es.workout_id = ?
+            AND es.completed
+            AND {available}
+        ORDER BY es.position DESC, es.id DESC
+        LIMIT 1
+        "
+    ))
+    .bind(workout_id)
+    .bind(gym_id)
+    .bind(gym_id)
+    .fetch_optional(conn)
+    .await
+    .with_context(|| format!("Failed to get set suggestion for workout with id {workout_id}"))?;
 
-    match constraint {
-        Some(ExerciseSetConstraintId::ExerciseSet) => {
-            format!("{GET_ALL_EXERCISES_QUERY} WHERE es.id = ?")
-        }
-        Some(ExerciseSetConstraintId::Workout) => {
-            format!("{GET_ALL_EXERCISES_QUERY} WHERE es.workout_id = ?")
-        }
-        Some(ExerciseSetConstraintId::Exercise) => {
-            format!("{GET_ALL_EXERCISES_QUERY} WHERE es.exercise_id = ?")
-        }
-        None => GET_ALL_EXERCISES_QUERY.to_string(),
+    if suggestion.is_some() {
+        return Ok(suggestion);
     }
+
+    // Suggest the first set of the last workout that contains sets.
+    sqlx::query_as(&format!(
+        "
+        SELECT es.exercise_id, es.repetitions, es.weight, es.added_weight, es.duration_s
+        FROM exercise_set es
+        WHERE es.completed
+            AND {available}
+            AND es.workout_id = (
+            SELECT MAX(w.id)
+            FROM workout w
+            JOIN exercise_set s ON w.id = s.workout_id
+            WHERE s.completed
+        )
+        ORDER BY es.position, es.id
+        LIMIT 1
+        "
+    ))
+    .bind(gym_id)
+    .bind(gym_id)
+    .fetch_optional(conn)
+    .await
+    .context("Failed to get set suggestion from the last workout")
 }
 
-pub async fn get_exercise_set<'local, E>(conn: E, id: i64) -> Result<Option<ExerciseSetEntity>>
+/// Completed sets of the last sessions of an exercise, most recent session
+/// first and ordered by position within a session. Warm-up and drop sets
+/// are left out, as they aren't meant to hit the targets of the session.
+pub async fn get_recent_exercise_history<'local, E>(
+    conn: E,
+    exercise_id: i64,
+    sessions: i64,
+) -> Result<Vec<HistorySetEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as(&create_get_exercise_query(Some(
-        ExerciseSetConstraintId::ExerciseSet,
-    )))
-    .bind(id)
-    .fetch_optional(conn)
+    sqlx::query_as(
+        "
+        WITH history_set AS (
+            SELECT es.*
+            FROM exercise_set es
+            WHERE es.exercise_id = ?
+                AND es.completed
+                AND es.set_type NOT IN ('warmup', 'drop')
+        ),
+        recent_workout AS (
+            SELECT w.id, w.started_utc_s
+            FROM workout w
+            WHERE w.id IN (SELECT workout_id FROM history_set)
+            ORDER BY w.started_utc_s DESC, w.id DESC
+            LIMIT ?
+        )
+        SELECT
+            hs.workout_id, hs.repetitions, hs.weight, hs.added_weight, hs.duration_s,
+            hs.target_repetitions, hs.target_weight
+        FROM history_set hs
+        JOIN recent_workout rw ON rw.id = hs.workout_id
+        ORDER BY rw.started_utc_s DESC, rw.id DESC, hs.position, hs.id
+        ",
+    )
+    .bind(exercise_id)
+    .bind(sessions)
+    .fetch_all(conn)
     .await
-    .with_context(|| format!("Failed to get exercise set with id {id}"))
+    .with_context(|| format!("Failed to get recent history of exercise with id {exercise_id}"))
 }
 
-pub async fn get_exercise_sets<'local, E>(conn: E) -> Result<Vec<ExerciseSetEntity>>
+/// Counter of writes to the data of statistics, maintained by triggers. It
+/// changes with every write from any process, so cached statistics of an
+/// older version are stale.
+pub async fn get_statistics_version<'local, E>(conn: E) -> Result<i64>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as(&create_get_exercise_query(None))
-        .fetch_all(conn)
+    sqlx::query_scalar("SELECT version FROM statistics_version")
+        .fetch_one(conn)
         .await
-        .context("Failed to get all exercise sets")
+        .context("Failed to get statistics version")
 }
 
-pub async fn get_exercise_sets_by_workout_id<'local, E>(
+/// Workouts within the time range of a [`StatisticsFilterInput`], binds
+/// `from` twice and then `to` twice.
+const WORKOUTS_IN_RANGE_CTE: &str = "
+    WITH workout_in_range AS (
+        SELECT id FROM workout
+        WHERE (? IS NULL OR started_utc_s >= ?) AND (? IS NULL OR started_utc_s < ?)
+    )
+";
+
+/// Archived workouts only keep a summary without exercises, so they are left
+/// out if the statistics are limited to a tag. All statistics are aggregated
+/// in a single query from `workout_exercise_statistics`, which is maintained
+/// by triggers on `exercise_set`, an empty database yields zeros.
+pub async fn get_statistics_overview<'local, E>(
     conn: E,
-    id: i64,
-) -> Result<Vec<ExerciseSetEntity>>
+    body_weight: Option<i64>,
+    filter: &StatisticsFilterInput,
+) -> Result<StatisticsOverviewEntity>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query_as(&create_get_exercise_query(Some(
-        ExerciseSetConstraintId::Workout,
-    )))
-    .bind(id)
-    .fetch_all(conn)
+    // Bodyweight sets only count towards the volume if a body weight is given,
+    // the added weight of sets with an absolute weight is ignored.
+    // Timed sets only count towards the total set duration, warm-up sets don't
+    // count towards the volume. Workouts count towards the volume of the week
+    // and the month they were started in, weeks start on Monday in UTC.
+    // Archived workouts without remaining sets only contribute their summary,
+    // their volume never includes bodyweight sets.
+    sqlx::query_as(&format!(
+        "
+        {WORKOUTS_IN_RANGE_CTE},
+        workout_total AS (
+            SELECT
+                w.started_utc_s AS start_utc_s,
+                MAX(s.end_utc_s) AS end_utc_s,
+                SUM(s.set_count) AS total_sets,
+                SUM(s.repetition_set_count) AS repetition_sets,
+                SUM(s.repetitions) AS total_repetitions,
+                SUM(s.tonnage)
+                    + COALESCE(? * SUM(s.bodyweight_repetitions) + SUM(s.added_tonnage), 0)
+                    AS total_volume,
+                SUM(s.set_duration_s) AS total_set_duration_s,
+                COALESCE(SUM(s.set_count) FILTER (WHERE e.modality = 'cardio'), 0)
+                    AS cardio_sets,
+                COALESCE(SUM(s.distance_m) FILTER (WHERE e.modality = 'cardio'), 0)
+                    AS cardio_distance_m,
+                COALESCE(SUM(s.set_duration_s) FILTER (WHERE e.modality = 'cardio'), 0)
+                    AS cardio_duration_s
+            FROM workout_exercise_statistics s
+            JOIN workout w ON w.id = s.workout_id
+            JOIN exercise e ON e.id = s.exercise_id
+            WHERE s.workout_id IN (SELECT id FROM workout_in_range)
+                AND (
+                    ? IS NULL
+                    OR s.exercise_id IN (SELECT exercise_id FROM exercise_tag WHERE tag = ?)
+                )
+            GROUP BY w.id
+            UNION ALL
+            SELECT
+                w.started_utc_s,
+                ws.end_utc_s,
+                ws.total_sets,
+                ws.repetition_sets,
+                ws.total_repetitions,
+                ws.total_volume,
+                ws.total_set_duration_s,
+                0,
+                0,
+                0
+            FROM workout_summary ws
+            JOIN workout w ON w.id = ws.workout_id
+            WHERE ? IS NULL
+                AND w.id IN (SELECT id FROM workout_in_range)
+                AND NOT EXISTS (
+                    SELECT 1 FROM exercise_set es WHERE es.workout_id = w.id AND es.completed
+                )
+        )
+        SELECT
+            COUNT(*) AS total_workouts,
+            COALESCE(SUM(end_utc_s - start_utc_s), 0) AS total_duration_s,
+            COALESCE(SUM(end_utc_s - start_utc_s) / NULLIF(COUNT(*), 0), 0) AS avg_duration_s,
+            COALESCE(SUM(total_sets), 0) AS total_sets,
+            COALESCE(SUM(total_repetitions), 0) AS total_repetitions,
+            COALESCE(SUM(total_repetitions) / NULLIF(SUM(repetition_sets), 0), 0)
+                AS avg_repetitions_per_set,
+            CAST(COALESCE(SUM(total_volume), 0) AS INT) AS total_volume,
+            CAST(
+                COALESCE(
+                    SUM(total_volume) FILTER (
+                        WHERE start_utc_s >= UNIXEPOCH(DATE('now', 'weekday 0', '-6 days'))
+                    ),
+                    0
+                ) AS INT
+            ) AS week_volume,
+            CAST(
+                COALESCE(
+                    SUM(total_volume) FILTER (
+                        WHERE start_utc_s >= UNIXEPOCH(DATE('now', 'start of month'))
+                    ),
+                    0
+                ) AS INT
+            ) AS month_volume,
+            COALESCE(SUM(total_set_duration_s), 0) AS total_set_duration_s,
+            COALESCE(SUM(cardio_sets), 0) AS total_cardio_sets,
+            COALESCE(SUM(cardio_distance_m), 0) AS total_cardio_distance_m,
+            COALESCE(SUM(cardio_duration_s), 0) AS total_cardio_duration_s
+        FROM workout_total
+        "
+    ))
+    .bind(filter.from)
+    .bind(filter.from)
+    .bind(filter.to)
+    .bind(filter.to)
+    .bind(body_weight)
+    .bind(&filter.tag)
+    .bind(&filter.tag)
+    .bind(&filter.tag)
+    .fetch_one(conn)
     .await
-    .with_context(|| format!("Failed to get exercise sets for workout with id {id}"))
+    .context("Failed to get statistics overview")
 }
 
-pub async fn get_exercise_sets_by_exercise_id<'local, E>(
+/// Start times of the workouts, with a tag only of those containing a
+/// completed set of an exercise with the tag.
+pub async fn get_workout_starts<'local, E>(
     conn: E,
-    id: i64,
-) -> Result<Vec<ExerciseSetEntity>>
+    filter: &StatisticsFilterInput,
+) -> Result<Vec<DateTime<Utc>>>
 where
-    E: SqliteExecutor<'local> + Copy,
+    E: SqliteExecutor<'local>,
 {
-    sqlx::query_as(&create_get_exercise_query(Some(
-        ExerciseSetConstraintId::Exercise,
-    )))
-    .bind(id)
+    sqlx::query_scalar(
+        "
+        SELECT w.started_utc_s
+        FROM workout w
+        WHERE ? IS NULL
+            OR EXISTS (
+                SELECT 1 FROM exercise_set es
+                JOIN exercise_tag t ON t.exercise_id = es.exercise_id
+                WHERE es.workout_id = w.id AND es.completed AND t.tag = ?
+            )
+        ORDER BY w.started_utc_s
+        ",
+    )
+    .bind(&filter.tag)
+    .bind(&filter.tag)
     .fetch_all(conn)
     .await
-    .with_context(|| format!("Failed to get exercise sets for exercise with id {id}"))
+    .context("Failed to get workout starts")
 }
 
-pub async fn create_or_update_exercise_set<'local, E>(
+pub async fn get_weekly_progression<'local, E>(
     conn: E,
-    exercise_set_id: Option<i64>,
-    workout_id: i64,
-    exercise_id: i64,
-    repetitions: i64,
-    weight: i64,
-    note: String,
-) -> Result<ExerciseSetEntity>
+    exercise_ids: &[i64],
+) -> Result<ProgressionEntity>
 where
-    E: SqliteExecutor<'local> + Copy,
+    E: SqliteExecutor<'local>,
 {
-    let query = match exercise_set_id {
-        Some(_) => {
-            "
-            UPDATE exercise_set
-            SET workout_id = ?, exercise_id = ?, repetitions = ?, weight = ?, note = ?
-            WHERE id = ?
-            RETURNING id, exercise_id, workout_id, created_utc_s, repetitions, weight, note,
-                '' AS exercise_name
-            "
-        }
-        None => {
-            "
-            INSERT INTO exercise_set (workout_id, exercise_id, repetitions, weight, note, created_utc_s)
-            VALUES (?, ?, ?, ?, ?, UNIXEPOCH(datetime()))
-            RETURNING id, exercise_id, workout_id, created_utc_s, repetitions, weight, note,
-                '' AS exercise_name
-            "
-        }
-    };
+    #[derive(Debug, FromRow)]
+    struct SetRow {
+        exercise_id: i64,
+        week: String,
+        repetitions: i64,
+        weight: Option<i64>,
+    }
 
-    // Empty notes are stored as NULL in the database.
-    let note = match note.trim() {
-        "" => None,
-        note => Some(note),
-    };
+    let placeholders = vec!["?"; exercise_ids.len()].join(", ");
 
-    let mut query = sqlx::query_as::<_, ExerciseSetEntity>(query)
-        .bind(workout_id)
-        .bind(exercise_id)
-        .bind(repetitions)
-        .bind(weight)
-        .bind(note);
+    let query = format!(
+        "
+        SELECT
+            es.exercise_id,
+            DATE(w.started_utc_s, 'unixepoch', 'weekday 0', '-6 days') AS week,
+            es.repetitions,
+            es.weight
+        FROM exercise_set es
+        JOIN workout w ON es.workout_id = w.id
+        WHERE es.exercise_id IN ({placeholders})
+            AND es.duration_s IS NULL
+            AND es.set_type != 'warmup'
+            AND es.completed
+        "
+    );
+
+    let mut query = sqlx::query_as::<_, SetRow>(&query);
 
-    if let Some(id) = exercise_set_id {
+    for &id in exercise_ids {
         query = query.bind(id);
     }
 
-    let mut exercise_set = query
-        .fetch_one(conn)
+    let sets = query
+        .fetch_all(conn)
         .await
-        .with_context(|| {
-            format!("Failed to create exercise set with workout id {workout_id} and exercise id {exercise_id}")
-        })?;
+        .context("Failed to get sets for weekly progression")?;
 
-    exercise_set.exercise_name = get_exercise(conn, exercise_id)
-        .await?
-        .expect("Exercise must exist as it is used as a foreign key in the previous query")
-        .name;
+    let weeks = sets
+        .iter()
+        .map(|set| set.week.clone())
+        .collect::<BTreeSet<_>>();
+
+    let mut buckets = HashMap::<(i64, &str), (Option<f64>, i64)>::new();
+
+    for set in &sets {
+        let bucket = buckets
+            .entry((set.exercise_id, set.week.as_str()))
+            .or_default();
 
-    Ok(exercise_set)
+        if let Some(weight) = set.weight {
+            let estimate = one_rep_max::estimate(weight, set.repetitions);
+            bucket.0 = bucket.0.into_iter().chain(estimate).reduce(f64::max);
+            bucket.1 += weight * set.repetitions;
+        }
+    }
+
+    let series = exercise_ids
+        .iter()
+        .map(|&exercise_id| {
+            let values = weeks
+                .iter()
+                .map(|week| buckets.get(&(exercise_id, week.as_str())))
+                .collect::<Vec<_>>();
+
+            ProgressionSeriesEntity {
+                exercise_id,
+                estimated_one_rep_max: values.iter().map(|v| v.and_then(|v| v.0)).collect(),
+                volume: values.iter().map(|v| v.map(|v| v.1)).collect(),
+            }
+        })
+        .collect();
+
+    Ok(ProgressionEntity {
+        weeks: weeks.into_iter().collect(),
+        series,
+    })
 }
 
-pub async fn delete_exercise_set<'local, E>(conn: E, id: i64) -> Result<Option<()>>
+pub async fn get_weekly_workout_counts<'local, E>(conn: E) -> Result<Vec<WeeklyCountEntity>>
 where
     E: SqliteExecutor<'local>,
 {
-    sqlx::query("DELETE FROM exercise_set WHERE id = ?")
-        .bind(id)
-        .execute(conn)
-        .await
-        .map(|res| (res.rows_affected() > 0).then_some(()))
-        .with_context(|| format!("Failed to delete exercise set with id {id}"))
+    sqlx::query_as(
+        "
+        SELECT
+            DATE(started_utc_s, 'unixepoch', 'weekday 0', '-6 days') AS week,
+            COUNT(*) AS count
+        FROM workout
+        GROUP BY week
+        ORDER BY week
+        ",
+    )
+    .fetch_all(conn)
+    .await
+    .context("Failed to get weekly workout counts")
 }
 
-pub async fn get_set_suggestion_for_workout<'local, E>(
+/// Completed working sets per period, periods without sets are missing.
+/// Timed sets count as sets but don't add to the tonnage. Bodyweight sets add
+/// their repetitions times the body weight and the added weight, like in
+/// `get_statistics_overview`, and nothing without a body weight.
+pub async fn get_volume<'local, E>(
     conn: E,
-    workout_id: i64,
-    exercise_id: Option<i64>,
-) -> Result<SetSuggestionEntity>
+    body_weight: Option<i64>,
+    filter: &VolumeFilterInput,
+) -> Result<Vec<VolumeEntity>>
 where
-    E: SqliteExecutor<'local> + Copy,
+    E: SqliteExecutor<'local>,
 {
-    let suggest_with_exercise_id = |exercise_id: i64| async move {
-        // Suggest the last set of the same exercise in the same workout.
-        let suggestion = sqlx::query_as::<_, SetSuggestionEntity>(
-            "
-            SELECT exercise_id, repetitions, weight
-            FROM exercise_set
-            WHERE workout_id = ?
-                AND exercise_id = ?
-            ORDER BY created_utc_s DESC
-            LIMIT 1
-            ",
-        )
-        .bind(workout_id)
-        .bind(exercise_id)
-        .fetch_optional(conn)
-        .await?;
-
-        if let Some(set) = suggestion {
-            return Ok(set);
-        }
-
-        // Suggest the first set of the same exercise in the most recent workout
-        // that contains this exercise.
-        let suggestion = sqlx::query_as::<_, SetSuggestionEntity>(
-            "
-            SELECT exercise_id, repetitions, weight
-            FROM exercise_set
-            WHERE exercise_id = ?
-                AND workout_id = (
-                SELECT w.id
-                FROM workout w
-                JOIN exercise_set es ON w.id = es.workout_id
-                WHERE es.exercise_id = ?
-                ORDER BY started_utc_s DESC
-                LIMIT 1
-            )
-            ORDER BY created_utc_s
-            LIMIT 1
-            ",
-        )
-        .bind(exercise_id)
-        .bind(exercise_id)
-        .fetch_optional(conn)
-        .await?;
-
-        Ok(suggestion.unwrap_or(SetSuggestionEntity {
-            exercise_id,
-            repetitions: 0,
-            weight: 0,
-        }))
+    let period = match filter.group {
+        VolumeGroup::Week => "DATE(w.started_utc_s, 'unixepoch', 'weekday 0', '-6 days')",
+        VolumeGroup::Month => "DATE(w.started_utc_s, 'unixepoch', 'start of month')",
     };
 
-    let suggest_without_exercise_id = || async {
-        // Just suggest the last set again.
-        let suggestion = sqlx::query_as::<_, SetSuggestionEntity>(
-            "
-            SELECT exercise_id, repetitions, weight
-            FROM exercise_set
-            WHERE workout_id = ?
-            ORDER BY created_utc_s DESC
-            LIMIT 1
-            ",
-        )
-        .bind(workout_id)
-        .fetch_optional(conn)
-        .await?;
-
-        if let Some(set) = suggestion {
-            return Ok(set);
-        }
-
-        // Suggest the first set of the last workout that contains sets.
-        let suggestion = sqlx::query_as::<_, SetSuggestionEntity>(
-            "
-            SELECT exercise_id, repetitions, weight
-            FROM exercise_set
-            WHERE workout_id = (
-                SELECT MAX(w.id)
-                FROM workout w
-                JOIN exercise_set es ON w.id = es.workout_id
+    sqlx::query_as(&format!(
+        "
+        SELECT
+            {period} AS period,
+            COUNT(*) AS set_count,
+            COALESCE(
+                SUM(es.repetitions * es.weight) FILTER (WHERE es.duration_s IS NULL),
+                0
             )
-            ORDER BY created_utc_s
-            LIMIT 1
-            ",
-        )
-        .fetch_optional(conn)
-        .await?;
-
-        if let Some(set) = suggestion {
-            return Ok(set);
-        }
-
-        // Just return some sane defaults.
-        Ok(SetSuggestionEntity {
-            exercise_id: 0,
-            repetitions: 0,
-            weight: 0,
-        })
-    };
-
-    match exercise_id {
-        Some(id) => suggest_with_exercise_id(id).await,
-        None => suggest_without_exercise_id().await,
-    }
+                + COALESCE(
+                    SUM(es.repetitions * (? + COALESCE(es.added_weight, 0))) FILTER (
+                        WHERE es.duration_s IS NULL AND es.weight IS NULL
+                    ),
+                    0
+                ) AS tonnage
+        FROM exercise_set es
+        JOIN workout w ON w.id = es.workout_id
+        WHERE es.completed
+            AND es.set_type != 'warmup'
+            AND (
+                ? IS NULL
+                OR EXISTS (
+                    SELECT 1 FROM exercise_muscle m
+                    WHERE m.exercise_id = es.exercise_id AND m.muscle = ?
+                )
+            )
+            AND (? IS NULL OR es.exercise_id = ?)
+        GROUP BY period
+        ORDER BY period
+        "
+    ))
+    .bind(body_weight)
+    .bind(&filter.muscle)
+    .bind(&filter.muscle)
+    .bind(filter.exercise_id)
+    .bind(filter.exercise_id)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get volume")
 }
 
-pub async fn get_statistics_overview<'local, E>(conn: E) -> Result<StatisticsOverviewEntity>
+/// Completed working sets per week and muscle group, counted separately by
+/// the role of the muscle group. Sets count in the week starting on Monday
+/// in which their workout started in local time. Only workouts started
+/// within `from` (inclusive) and `to` (exclusive) in UTC seconds count.
+pub async fn get_muscle_volume<'local, E>(
+    conn: E,
+    utc_offset_s: i64,
+    from: Option<i64>,
+    to: Option<i64>,
+) -> Result<Vec<MuscleVolumeEntity>>
 where
-    E: SqliteExecutor<'local> + Copy,
+    E: SqliteExecutor<'local>,
 {
-    #[derive(Debug, FromRow)]
-    struct DatesRow {
-        start_utc_s: i64,
-        end_utc_s: i64,
-    }
-
-    let workouts = sqlx::query_as::<_, DatesRow>(
+    sqlx::query_as(
         "
-        SELECT w.started_utc_s AS start_utc_s, MAX(es.created_utc_s) AS end_utc_s
+        SELECT
+            DATE(w.started_utc_s + ?, 'unixepoch', 'weekday 0', '-6 days') AS week,
+            m.muscle,
+            COUNT(*) FILTER (WHERE m.role = 'primary') AS primary_sets,
+            COUNT(*) FILTER (WHERE m.role = 'secondary') AS secondary_sets
         FROM exercise_set es
-        JOIN workout w on es.workout_id = w.id
-        GROUP BY w.id
+        JOIN workout w ON w.id = es.workout_id
+        JOIN exercise_muscle m ON m.exercise_id = es.exercise_id
+        WHERE es.completed
+            AND es.set_type != 'warmup'
+            AND (? IS NULL OR w.started_utc_s >= ?)
+            AND (? IS NULL OR w.started_utc_s < ?)
+        GROUP BY week, m.muscle
+        ORDER BY week, m.muscle
         ",
     )
+    .bind(utc_offset_s)
+    .bind(from)
+    .bind(from)
+    .bind(to)
+    .bind(to)
     .fetch_all(conn)
-    .await?;
-
-    if workouts.is_empty() {
-        return Ok(Default::default());
-    }
+    .await
+    .context("Failed to get muscle volume")
+}
 
-    let mut overview = StatisticsOverviewEntity {
-        total_workouts: workouts.len() as i64,
-        total_duration_s: workouts.iter().map(|w| w.end_utc_s - w.start_utc_s).sum(),
-        ..Default::default()
+/// Workouts and their average volume per slot of the local start time, slots
+/// without workouts are missing. Only workouts started within `from`
+/// (inclusive) and `to` (exclusive) in UTC seconds count, workouts without
+/// completed working sets count with no volume. The volume of workouts is
+/// maintained in `workout_exercise_statistics` by triggers on `exercise_set`.
+pub async fn get_volume_by_time_slot<'local, E>(
+    conn: E,
+    slot: TimeSlot,
+    utc_offset_s: i64,
+    from: Option<i64>,
+    to: Option<i64>,
+) -> Result<Vec<TimeSlotVolumeEntity>>
+where
+    E: SqliteExecutor<'local>,
+{
+    let format = match slot {
+        TimeSlot::Weekday => "%w",
+        TimeSlot::Hour => "%H",
     };
 
-    overview.avg_duration_s = overview.total_duration_s / overview.total_workouts;
-
-    #[derive(Debug, FromRow)]
-    struct SetsRepsRow {
-        total_sets: i64,
-        total_repetitions: i64,
-        avg_repetitions_per_set: i64,
-    }
+    sqlx::query_as(&format!(
+        "
+        SELECT
+            CAST(STRFTIME('{format}', w.started_utc_s + ?, 'unixepoch') AS INTEGER) AS slot,
+            COUNT(*) AS workout_count,
+            AVG(COALESCE(s.set_count, 0)) AS average_set_count,
+            AVG(COALESCE(s.tonnage, 0)) AS average_tonnage
+        FROM workout w
+        LEFT JOIN (
+            SELECT workout_id, SUM(working_set_count) AS set_count, SUM(tonnage) AS tonnage
+            FROM workout_exercise_statistics
+            GROUP BY workout_id
+        ) s ON s.workout_id = w.id
+        WHERE (? IS NULL OR w.started_utc_s >= ?) AND (? IS NULL OR w.started_utc_s < ?)
+        GROUP BY slot
+        ORDER BY slot
+        "
+    ))
+    .bind(utc_offset_s)
+    .bind(from)
+    .bind(from)
+    .bind(to)
+    .bind(to)
+    .fetch_all(conn)
+    .await
+    .context("Failed to get volume by time slot")
+}
 
-    let sets_reps = sqlx::query_as::<_, SetsRepsRow>(
+/// Adherence to the planned sets of the workouts started within `from`
+/// (inclusive) and `to` (exclusive) in UTC seconds.
+pub async fn get_adherence<'local, E>(conn: E, from: i64, to: i64) -> Result<AdherenceEntity>
+where
+    E: SqliteExecutor<'local>,
+{
+    sqlx::query_as(
         "
         SELECT
-            COUNT(id) AS total_sets,
-            SUM(repetitions) AS total_repetitions,
-            CAST(AVG(repetitions) AS INT) AS avg_repetitions_per_set
-        FROM exercise_set
+            COUNT(*) AS planned_sets,
+            COUNT(*) FILTER (WHERE es.completed) AS completed_sets,
+            COUNT(*) FILTER (
+                WHERE es.completed
+                    AND es.repetitions >= COALESCE(es.target_repetitions, 0)
+                    AND COALESCE(es.weight, 0) >= COALESCE(es.target_weight, 0)
+            ) AS targets_met
+        FROM exercise_set es
+        JOIN workout w ON w.id = es.workout_id
+        WHERE (es.target_repetitions IS NOT NULL OR es.target_weight IS NOT NULL)
+            AND w.started_utc_s >= ?
+            AND w.started_utc_s < ?
         ",
     )
+    .bind(from)
+    .bind(to)
     .fetch_one(conn)
-    .await?;
+    .await
+    .context("Failed to get adherence")
+}
 
-    overview.total_sets = sets_reps.total_sets;
-    overview.total_repetitions = sets_reps.total_repetitions;
-    overview.avg_repetitions_per_set = sets_reps.avg_repetitions_per_set;
+/// Deletes the rows of all tables but keeps the schema, so the database is
+/// like a freshly migrated one. The statistics version is kept, as it must
+/// only grow for cached statistics to become stale.
+pub async fn delete_all_data(tx: &mut Transaction<'_, Sqlite>) -> Result<()> {
+    // Tables are emptied in any order, references are only checked on commit.
+    sqlx::query("PRAGMA defer_foreign_keys = ON")
+        .execute(&mut *tx)
+        .await
+        .context("Failed to defer foreign keys")?;
+
+    let tables: Vec<String> = sqlx::query_scalar(
+        "
+        SELECT name
+        FROM sqlite_master
+        WHERE type = 'table'
+            AND name NOT LIKE 'sqlite_%'
+            AND name NOT IN ('_sqlx_migrations', 'statistics_version')
+        ",
+    )
+    .fetch_all(&mut *tx)
+    .await
+    .context("Failed to get tables")?;
+
+    for table in tables {
+        sqlx::query(&format!("DELETE FROM \"{table}\""))
+            .execute(&mut *tx)
+            .await
+            .with_context(|| format!("Failed to delete rows of table {table}"))?;
+    }
 
-    Ok(overview)
+    Ok(())
 }
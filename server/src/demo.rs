@@ -0,0 +1,47 @@
+use std::time::Duration;
+
+use anyhow::{Context, Result};
+use chrono::Utc;
+use sqlx::{Pool, Sqlite};
+use tokio::time::{interval_at, Instant};
+use tracing::{error, info};
+
+use crate::{
+    dal,
+    fixtures::{self, HistoryOptions},
+    insights::{self, Trigger},
+    seed,
+};
+
+/// Deletes all data and fills the database with the exercise catalog and a
+/// few weeks of sample workouts. The data is replaced in a single
+/// transaction, so a failed reset keeps the previous data and visitors never
+/// see an empty database. Insights are derived from the new data afterwards.
+pub async fn reset(pool: &Pool<Sqlite>) -> Result<()> {
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    dal::delete_all_data(&mut tx).await?;
+    seed::seed_exercises(&mut tx).await?;
+
+    let history = fixtures::build_history(Utc::now().date_naive(), &HistoryOptions::default());
+    fixtures::insert_history(&mut tx, &history).await?;
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    insights::evaluate(pool, None, Trigger::Schedule).await?;
+
+    Ok(())
+}
+
+/// Resets the database periodically, the first reset is due after one
+/// interval.
+pub async fn schedule_resets(pool: Pool<Sqlite>, period: Duration) {
+    let mut interval = interval_at(Instant::now() + period, period);
+    loop {
+        interval.tick().await;
+        match reset(&pool).await {
+            Ok(()) => info!("Reset demo data."),
+            Err(err) => error!(err = format!("{err:#}"), "Failed to reset demo data."),
+        }
+    }
+}
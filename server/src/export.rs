@@ -0,0 +1,290 @@
+use std::fmt::Write;
+
+use chrono::{DateTime, Datelike, Duration, NaiveDate, SecondsFormat, Utc};
+use serde::Deserialize;
+use serde_json::Value;
+
+use crate::{
+    dal::{ExerciseSetEntity, VolumeEntity, VolumeGroup, WorkoutEntity},
+    one_rep_max::SessionEstimate,
+    report,
+    units::{WeightConversion, WeightUnit},
+};
+
+/// File formats of exported workouts.
+#[derive(Debug, Clone, Copy, Default, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ActivityFormat {
+    /// Training Center XML, accepted by Garmin Connect and Strava.
+    #[default]
+    Tcx,
+}
+
+/// Renders the volume per period as CSV, weights in the target unit of the
+/// conversion.
+pub fn volume_csv(volume: &[VolumeEntity], conversion: WeightConversion) -> String {
+    let columns = [
+        "period".to_string(),
+        "set_count".to_string(),
+        format!("tonnage_{}", conversion.to.as_str()),
+    ];
+    let rows = volume
+        .iter()
+        .map(|volume| {
+            vec![
+                Value::from(volume.period.as_str()),
+                Value::from(volume.set_count),
+                Value::from(conversion.weight(volume.tonnage)),
+            ]
+        })
+        .collect::<Vec<_>>();
+
+    report::render_csv(&columns, &rows)
+}
+
+/// Renders the best estimated one-repetition maximum per period as CSV. The
+/// estimates must be ordered by session.
+pub fn one_rep_max_csv(
+    estimates: &[SessionEstimate],
+    group: VolumeGroup,
+    conversion: WeightConversion,
+) -> String {
+    let columns = [
+        "period".to_string(),
+        "session_count".to_string(),
+        format!("estimated_one_rep_max_{}", conversion.to.as_str()),
+    ];
+
+    let mut periods: Vec<(NaiveDate, i64, f64)> = Vec::new();
+    for estimate in estimates {
+        let start = period_start(estimate.started.date_naive(), group);
+        match periods.last_mut() {
+            Some((period, sessions, best)) if *period == start => {
+                *sessions += 1;
+                *best = best.max(estimate.estimate);
+            }
+            _ => periods.push((start, 1, estimate.estimate)),
+        }
+    }
+
+    let rows = periods
+        .into_iter()
+        .map(|(period, sessions, best)| {
+            vec![
+                Value::from(period.to_string()),
+                Value::from(sessions),
+                Value::from(conversion.value(best)),
+            ]
+        })
+        .collect::<Vec<_>>();
+
+    report::render_csv(&columns, &rows)
+}
+
+/// First day of the period containing the date, matching the periods of the
+/// volume.
+fn period_start(date: NaiveDate, group: VolumeGroup) -> NaiveDate {
+    match group {
+        VolumeGroup::Week => {
+            date - Duration::days(i64::from(date.weekday().num_days_from_monday()))
+        }
+        VolumeGroup::Month => date.with_day(1).expect("First day must be valid"),
+    }
+}
+
+/// Renders a workout as a TCX activity. TCX has no sport for strength
+/// training, so the activity is of sport `Other` with a lap per run of
+/// consecutive sets of an exercise, whose sets are listed in the notes of the
+/// lap. Laps end with their last completed set, weights are in the unit of
+/// the instance. Unfinished workouts end with their last set.
+pub fn workout_tcx(
+    workout: &WorkoutEntity,
+    sets: &[ExerciseSetEntity],
+    unit: WeightUnit,
+) -> String {
+    let mut runs: Vec<Vec<&ExerciseSetEntity>> = Vec::new();
+    for set in sets.iter().filter(|set| set.completed) {
+        match runs.last_mut() {
+            Some(run) if run[0].exercise_id == set.exercise_id => run.push(set),
+            _ => runs.push(vec![set]),
+        }
+    }
+
+    let start = workout.started;
+    let end = workout
+        .finished
+        .or_else(|| sets.iter().map(|set| set.created).max())
+        .unwrap_or(start)
+        .max(start);
+
+    let mut tcx = String::from(
+        r#"<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities>
+    <Activity Sport="Other">
+"#,
+    );
+    let _ = writeln!(tcx, "      <Id>{}</Id>", tcx_time(start));
+
+    if runs.is_empty() {
+        write_lap(&mut tcx, start, end, 0, None);
+    }
+
+    let mut lap_start = start;
+    for (i, run) in runs.iter().enumerate() {
+        let lap_end = if i + 1 == runs.len() {
+            end
+        } else {
+            run.iter()
+                .map(|set| set.created)
+                .max()
+                .unwrap_or(lap_start)
+                .clamp(lap_start, end)
+        };
+
+        let distance_m = run.iter().filter_map(|set| set.distance_m).sum();
+        let notes = format!(
+            "{}: {}",
+            run[0].exercise_name,
+            run.iter()
+                .map(|set| describe_set(set, unit))
+                .collect::<Vec<_>>()
+                .join(", ")
+        );
+        write_lap(&mut tcx, lap_start, lap_end, distance_m, Some(&notes));
+        lap_start = lap_end;
+    }
+
+    if let Some(note) = &workout.note {
+        let _ = writeln!(tcx, "      <Notes>{}</Notes>", escape_xml(note));
+    }
+
+    tcx.push_str(
+        "    </Activity>
+  </Activities>
+</TrainingCenterDatabase>
+",
+    );
+    tcx
+}
+
+/// Laps carry a track with their start and end, as uploads without any
+/// trackpoints are rejected.
+fn write_lap(
+    tcx: &mut String,
+    start: DateTime<Utc>,
+    end: DateTime<Utc>,
+    distance_m: i64,
+    notes: Option<&str>,
+) {
+    let _ = writeln!(tcx, r#"      <Lap StartTime="{}">"#, tcx_time(start));
+    let _ = writeln!(
+        tcx,
+        "        <TotalTimeSeconds>{}</TotalTimeSeconds>",
+        (end - start).num_seconds()
+    );
+    let _ = writeln!(tcx, "        <DistanceMeters>{distance_m}</DistanceMeters>");
+    tcx.push_str(
+        "        <Calories>0</Calories>
+        <Intensity>Active</Intensity>
+        <TriggerMethod>Manual</TriggerMethod>
+        <Track>
+",
+    );
+    for time in [start, end] {
+        let _ = writeln!(
+            tcx,
+            "          <Trackpoint><Time>{}</Time></Trackpoint>",
+            tcx_time(time)
+        );
+    }
+    tcx.push_str("        </Track>\n");
+    if let Some(notes) = notes {
+        let _ = writeln!(tcx, "        <Notes>{}</Notes>", escape_xml(notes));
+    }
+    tcx.push_str("      </Lap>\n");
+}
+
+/// E.g. `8 × 60 kg`, `10 × bodyweight + 10 kg` or `60 s, 200 m`.
+fn describe_set(set: &ExerciseSetEntity, unit: WeightUnit) -> String {
+    if let Some(duration_s) = set.duration_s {
+        return match set.distance_m {
+            Some(distance_m) => format!("{duration_s} s, {distance_m} m"),
+            None => format!("{duration_s} s"),
+        };
+    }
+
+    let unit = unit.as_str();
+    let load = match (set.weight, set.added_weight) {
+        (Some(weight), _) => format!("{weight} {unit}"),
+        (None, Some(added_weight)) if added_weight > 0 => {
+            format!("bodyweight + {added_weight} {unit}")
+        }
+        (None, _) => "bodyweight".to_string(),
+    };
+    format!("{} × {load}", set.repetitions)
+}
+
+fn tcx_time(time: DateTime<Utc>) -> String {
+    time.to_rfc3339_opts(SecondsFormat::Secs, true)
+}
+
+fn escape_xml(text: &str) -> String {
+    text.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+        .replace('"', "&quot;")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::fixtures::{self, HistoryOptions};
+
+    fn history() -> Vec<fixtures::WorkoutFixture> {
+        let end = NaiveDate::from_ymd_opt(2023, 11, 5).expect("Date must be valid");
+        fixtures::build_history(end, &HistoryOptions::default())
+    }
+
+    #[test]
+    fn escapes_xml() {
+        assert_eq!(
+            escape_xml(r#"<a href="x">R&D</a>"#),
+            "&lt;a href=&quot;x&quot;&gt;R&amp;D&lt;/a&gt;"
+        );
+    }
+
+    #[test]
+    fn workouts_have_a_lap_per_exercise() {
+        let (workout, sets) = fixtures::workout_entities(&history(), 0);
+
+        let tcx = workout_tcx(&workout, &sets, WeightUnit::Kg);
+
+        assert_eq!(tcx.matches("<Lap ").count(), 3);
+        assert!(tcx.contains("Back Squat: 5 × 80 kg, 5 × 80 kg, 5 × 80 kg"));
+        assert!(tcx.contains(&format!("<Id>{}</Id>", tcx_time(workout.started))));
+        assert!(tcx.contains(&format!(
+            "<Trackpoint><Time>{}</Time></Trackpoint>",
+            tcx_time(workout.finished.unwrap())
+        )));
+    }
+
+    #[test]
+    fn describes_bodyweight_sets() {
+        let (workout, sets) = fixtures::workout_entities(&history(), 1);
+
+        let tcx = workout_tcx(&workout, &sets, WeightUnit::Kg);
+
+        assert!(tcx.contains("Pull-Up: 5 × bodyweight, 5 × bodyweight, 5 × bodyweight"));
+    }
+
+    #[test]
+    fn escapes_notes() {
+        let (mut workout, sets) = fixtures::workout_entities(&history(), 0);
+        workout.note = Some("Squats & <PR>".to_string());
+
+        let tcx = workout_tcx(&workout, &sets, WeightUnit::Kg);
+
+        assert!(tcx.contains("<Notes>Squats &amp; &lt;PR&gt;</Notes>"));
+    }
+}
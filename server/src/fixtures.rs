@@ -0,0 +1,513 @@
+use std::collections::HashMap;
+
+use anyhow::{Context, Result};
+use chrono::{Duration, NaiveDate, NaiveTime};
+use sqlx::{Sqlite, Transaction};
+
+use crate::dal::{self, ExerciseFilterInput, ExerciseSetInput};
+
+/// A lift of a program. Lifts without a weight progress in repetitions.
+struct Lift {
+    exercise: &'static str,
+    weight: Option<i64>,
+    /// Added weight or repetitions per session.
+    increment: i64,
+    /// Stops progressing once the history stalls, see
+    /// [`HistoryOptions::stall_after_weeks`].
+    stalls: bool,
+}
+
+/// Programs that are alternated workout by workout. All exercises are part of
+/// the built-in catalog.
+const PROGRAMS: [&[Lift]; 2] = [
+    &[
+        Lift {
+            exercise: "Back Squat",
+            weight: Some(80),
+            increment: 5,
+            stalls: false,
+        },
+        Lift {
+            exercise: "Bench Press",
+            weight: Some(60),
+            increment: 2,
+            stalls: true,
+        },
+        Lift {
+            exercise: "Barbell Row",
+            weight: Some(50),
+            increment: 2,
+            stalls: false,
+        },
+    ],
+    &[
+        Lift {
+            exercise: "Deadlift",
+            weight: Some(100),
+            increment: 5,
+            stalls: false,
+        },
+        Lift {
+            exercise: "Overhead Press",
+            weight: Some(40),
+            increment: 1,
+            stalls: false,
+        },
+        Lift {
+            exercise: "Pull-Up",
+            weight: None,
+            increment: 1,
+            stalls: false,
+        },
+    ],
+];
+
+const SETS_PER_EXERCISE: i64 = 3;
+
+const REPETITIONS: i64 = 5;
+
+/// Percentage of the working weight used during a deload week.
+const DELOAD_PERCENT: i64 = 90;
+
+/// Repetitions missed per set by stalled lifts.
+const STALL_MISSED_REPETITIONS: i64 = 1;
+
+const WORKOUT_START_HOUR: u32 = 18;
+
+const WORKOUT_DURATION_S: i64 = 60 * 60;
+
+const REST_S: i64 = 3 * 60;
+
+#[derive(Debug, Clone, Copy)]
+pub struct HistoryOptions {
+    /// Weeks of workouts, ending with the week of the end date.
+    pub weeks: i64,
+    pub workouts_per_week: i64,
+    /// Every this many weeks the weights are reduced for a week, so the
+    /// following weeks set new records. `None` never deloads.
+    pub deload_every_weeks: Option<i64>,
+    /// After this many weeks lifts that stall keep their weight and miss
+    /// their target repetitions, except in deload weeks. `None` never stalls.
+    pub stall_after_weeks: Option<i64>,
+}
+
+/// The demo history: long enough to contain a deload week and a stalled
+/// lift, so every kind of insight has something to show.
+impl Default for HistoryOptions {
+    fn default() -> Self {
+        Self {
+            weeks: 8,
+            workouts_per_week: 3,
+            deload_every_weeks: Some(5),
+            stall_after_weeks: Some(5),
+        }
+    }
+}
+
+/// Eight weeks with a deload in the fifth week, after which Bench Press
+/// stalls.
+#[cfg(test)]
+pub const STALLING: HistoryOptions = HistoryOptions {
+    weeks: 8,
+    workouts_per_week: 3,
+    deload_every_weeks: Some(5),
+    stall_after_weeks: Some(5),
+};
+
+#[derive(Debug)]
+pub struct WorkoutFixture {
+    pub started_utc_s: i64,
+    pub finished_utc_s: i64,
+    pub sets: Vec<SetFixture>,
+}
+
+#[derive(Debug)]
+pub struct SetFixture {
+    pub exercise_name: &'static str,
+    pub repetitions: i64,
+    pub weight: Option<i64>,
+    pub target_repetitions: i64,
+    pub created_utc_s: i64,
+}
+
+/// Builds a realistic training history: alternating programs with linear
+/// progression, interrupted by deload weeks after which new records follow,
+/// and lifts that stall towards the end.
+pub fn build_history(end: NaiveDate, options: &HistoryOptions) -> Vec<WorkoutFixture> {
+    let workouts_per_week = options.workouts_per_week.clamp(1, 7);
+    let start_time =
+        NaiveTime::from_hms_opt(WORKOUT_START_HOUR, 0, 0).expect("Start time must be valid");
+
+    let mut workouts = Vec::new();
+    let mut sessions = [0; PROGRAMS.len()];
+    // Sessions of each program when its lifts stalled.
+    let mut stalled_sessions: [Option<i64>; PROGRAMS.len()] = [None; PROGRAMS.len()];
+
+    for week in 0..options.weeks {
+        let deload = options
+            .deload_every_weeks
+            .map_or(false, |every| every > 0 && (week + 1) % every == 0);
+        let stalled = !deload
+            && options
+                .stall_after_weeks
+                .map_or(false, |after| week >= after);
+
+        for i in 0..workouts_per_week {
+            let days_ago = (options.weeks - week) * 7 - i * 7 / workouts_per_week;
+            let started_utc_s = (end - Duration::days(days_ago))
+                .and_time(start_time)
+                .timestamp();
+
+            let program = workouts.len() % PROGRAMS.len();
+            let session = sessions[program];
+            if stalled && stalled_sessions[program].is_none() {
+                stalled_sessions[program] = Some(session);
+            }
+
+            let mut created_utc_s = started_utc_s;
+            let mut sets = Vec::new();
+            for lift in PROGRAMS[program] {
+                let (repetitions, weight) = match lift.weight {
+                    Some(weight) if lift.stalls && stalled => {
+                        let session = stalled_sessions[program].unwrap_or(session);
+                        (REPETITIONS, Some(weight + session * lift.increment))
+                    }
+                    Some(weight) => {
+                        let weight = weight + session * lift.increment;
+                        let weight = if deload {
+                            weight * DELOAD_PERCENT / 100
+                        } else {
+                            weight
+                        };
+                        (REPETITIONS, Some(weight))
+                    }
+                    None => {
+                        let repetitions = REPETITIONS + session * lift.increment;
+                        let repetitions = if deload {
+                            repetitions * DELOAD_PERCENT / 100
+                        } else {
+                            repetitions
+                        };
+                        (repetitions, None)
+                    }
+                };
+
+                let missed = if lift.stalls && stalled {
+                    STALL_MISSED_REPETITIONS
+                } else {
+                    0
+                };
+
+                for _ in 0..SETS_PER_EXERCISE {
+                    created_utc_s += REST_S;
+                    sets.push(SetFixture {
+                        exercise_name: lift.exercise,
+                        repetitions: repetitions - missed,
+                        weight,
+                        target_repetitions: repetitions,
+                        created_utc_s,
+                    });
+                }
+            }
+
+            // Progression pauses during deloads.
+            if !deload {
+                sessions[program] += 1;
+            }
+
+            workouts.push(WorkoutFixture {
+                started_utc_s,
+                finished_utc_s: started_utc_s + WORKOUT_DURATION_S,
+                sets,
+            });
+        }
+    }
+
+    workouts
+}
+
+/// Inserts the workouts with their sets. The exercises must exist already,
+/// e.g. by seeding the catalog first.
+pub async fn insert_history(
+    tx: &mut Transaction<'_, Sqlite>,
+    workouts: &[WorkoutFixture],
+) -> Result<()> {
+    let exercise_ids = dal::get_exercises(&mut *tx, &ExerciseFilterInput::default())
+        .await?
+        .into_iter()
+        .map(|exercise| (exercise.name, exercise.id))
+        .collect::<HashMap<_, _>>();
+
+    for workout in workouts {
+        let created =
+            dal::create_finished_workout(&mut *tx, workout.started_utc_s, workout.finished_utc_s)
+                .await?;
+
+        for set in &workout.sets {
+            let exercise_id = *exercise_ids
+                .get(set.exercise_name)
+                .with_context(|| format!("Missing fixture exercise {}", set.exercise_name))?;
+
+            dal::write_exercise_set(
+                &mut *tx,
+                None,
+                ExerciseSetInput {
+                    workout_id: created.id,
+                    exercise_id,
+                    repetitions: set.repetitions,
+                    weight: set.weight,
+                    added_weight: None,
+                    duration_s: None,
+                    distance_m: None,
+                    set_type: None,
+                    created_utc_s: Some(set.created_utc_s),
+                    completed: Some(true),
+                    target_repetitions: Some(set.target_repetitions),
+                    target_weight: None,
+                    note: String::new(),
+                    plates: None,
+                    client_id: None,
+                },
+            )
+            .await?;
+        }
+    }
+
+    Ok(())
+}
+
+/// Ids of the exercises of a history in the order of their first set, as
+/// tests work without a database.
+#[cfg(test)]
+fn exercise_ids(workouts: &[WorkoutFixture]) -> HashMap<&'static str, i64> {
+    let mut ids = HashMap::new();
+    for set in workouts.iter().flat_map(|workout| &workout.sets) {
+        let next = ids.len() as i64 + 1;
+        ids.entry(set.exercise_name).or_insert(next);
+    }
+    ids
+}
+
+#[cfg(test)]
+fn utc(utc_s: i64) -> chrono::DateTime<chrono::Utc> {
+    use chrono::TimeZone;
+
+    chrono::Utc
+        .timestamp_opt(utc_s, 0)
+        .single()
+        .expect("Fixture times must be valid")
+}
+
+/// An exercise of the history like `dal::get_exercise`, its increment is the
+/// one of its lift.
+#[cfg(test)]
+pub fn exercise_entity(workouts: &[WorkoutFixture], name: &str) -> dal::ExerciseEntity {
+    let lift = PROGRAMS
+        .iter()
+        .flat_map(|lifts| lifts.iter())
+        .find(|lift| lift.exercise == name)
+        .expect("Exercise must be part of a program");
+
+    dal::ExerciseEntity {
+        id: exercise_ids(workouts)[name],
+        name: name.to_string(),
+        modality: dal::ExerciseModality::Strength,
+        category: None,
+        description: None,
+        instructions: None,
+        video_url: None,
+        favorite: false,
+        default_increment: lift.weight.map(|_| lift.increment),
+        min_repetitions: None,
+        max_repetitions: None,
+        primary_muscles: None,
+        secondary_muscles: None,
+        equipment: None,
+        tags: None,
+        last_used: None,
+        usage_count: 0,
+        best_weight: None,
+        best_one_rep_max: None,
+    }
+}
+
+/// Weighted sets of the history like `dal::get_session_sets`, ordered by
+/// exercise and session. Workouts are numbered from 1 in order.
+#[cfg(test)]
+pub fn session_sets(workouts: &[WorkoutFixture]) -> Vec<dal::SessionSetEntity> {
+    let ids = exercise_ids(workouts);
+
+    let mut sets = Vec::new();
+    for (i, workout) in workouts.iter().enumerate() {
+        for set in &workout.sets {
+            let Some(weight) = set.weight else {
+                continue;
+            };
+            sets.push(dal::SessionSetEntity {
+                exercise_id: ids[set.exercise_name],
+                exercise_name: set.exercise_name.to_string(),
+                workout_id: i as i64 + 1,
+                started: utc(workout.started_utc_s),
+                weight,
+                repetitions: set.repetitions,
+            });
+        }
+    }
+    sets.sort_by_key(|set| (set.exercise_id, set.started));
+    sets
+}
+
+/// Sets of the last sessions of an exercise like
+/// `dal::get_recent_exercise_history`, the most recent session first.
+#[cfg(test)]
+pub fn history_sets(
+    workouts: &[WorkoutFixture],
+    name: &str,
+    sessions: usize,
+) -> Vec<dal::HistorySetEntity> {
+    workouts
+        .iter()
+        .enumerate()
+        .rev()
+        .filter(|(_, workout)| workout.sets.iter().any(|set| set.exercise_name == name))
+        .take(sessions)
+        .flat_map(|(i, workout)| {
+            workout
+                .sets
+                .iter()
+                .filter(|set| set.exercise_name == name)
+                .map(move |set| dal::HistorySetEntity {
+                    workout_id: i as i64 + 1,
+                    repetitions: set.repetitions,
+                    weight: set.weight,
+                    added_weight: None,
+                    duration_s: None,
+                    target_repetitions: Some(set.target_repetitions),
+                    target_weight: None,
+                })
+        })
+        .collect()
+}
+
+/// A finished workout of the history with its sets like
+/// `dal::get_exercise_sets_by_workout_id`, numbered from 1 in order.
+#[cfg(test)]
+pub fn workout_entities(
+    workouts: &[WorkoutFixture],
+    index: usize,
+) -> (dal::WorkoutEntity, Vec<dal::ExerciseSetEntity>) {
+    let ids = exercise_ids(workouts);
+    let workout = &workouts[index];
+    let workout_id = index as i64 + 1;
+
+    let sets = workout
+        .sets
+        .iter()
+        .enumerate()
+        .map(|(position, set)| dal::ExerciseSetEntity {
+            id: workout_id * 100 + position as i64,
+            exercise_id: ids[set.exercise_name],
+            exercise_name: set.exercise_name.to_string(),
+            workout_id,
+            created: utc(set.created_utc_s),
+            repetitions: set.repetitions,
+            weight: set.weight,
+            added_weight: None,
+            duration_s: None,
+            distance_m: None,
+            set_type: dal::SetType::Working,
+            position: position as i64,
+            completed: true,
+            target_repetitions: Some(set.target_repetitions),
+            target_weight: None,
+            note: None,
+            plates: None,
+            client_id: None,
+        })
+        .collect();
+
+    let workout = dal::WorkoutEntity {
+        id: workout_id,
+        started: utc(workout.started_utc_s),
+        finished: Some(utc(workout.finished_utc_s)),
+        note: None,
+    };
+
+    (workout, sets)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn end() -> NaiveDate {
+        NaiveDate::from_ymd_opt(2023, 11, 5).expect("Date must be valid")
+    }
+
+    fn weights(workouts: &[WorkoutFixture], name: &str) -> Vec<i64> {
+        workouts
+            .iter()
+            .flat_map(|workout| &workout.sets)
+            .filter(|set| set.exercise_name == name)
+            .step_by(SETS_PER_EXERCISE as usize)
+            .filter_map(|set| set.weight)
+            .collect()
+    }
+
+    #[test]
+    fn history_spans_the_weeks() {
+        let options = STALLING;
+        let workouts = build_history(end(), &options);
+
+        assert_eq!(
+            workouts.len() as i64,
+            options.weeks * options.workouts_per_week
+        );
+        assert!(workouts
+            .windows(2)
+            .all(|pair| pair[0].finished_utc_s < pair[1].started_utc_s));
+    }
+
+    #[test]
+    fn history_deloads() {
+        let workouts = build_history(end(), &STALLING);
+        let squats = weights(&workouts, "Back Squat");
+
+        assert!(squats.windows(2).any(|pair| pair[1] < pair[0]));
+        assert!(squats.last() > squats.first());
+    }
+
+    #[test]
+    fn history_stalls() {
+        let workouts = build_history(end(), &STALLING);
+        let bench = workouts
+            .iter()
+            .flat_map(|workout| &workout.sets)
+            .filter(|set| set.exercise_name == "Bench Press")
+            .collect::<Vec<_>>();
+        let weights = weights(&workouts, "Bench Press");
+
+        let last = bench.last().expect("Bench press must be trained");
+        assert!(last.repetitions < last.target_repetitions);
+        assert_eq!(weights[weights.len() - 1], weights[weights.len() - 2]);
+    }
+
+    #[test]
+    fn history_without_deloads_and_stalls_progresses() {
+        let options = HistoryOptions {
+            deload_every_weeks: None,
+            stall_after_weeks: None,
+            ..Default::default()
+        };
+        let workouts = build_history(end(), &options);
+
+        for name in ["Back Squat", "Bench Press", "Deadlift"] {
+            let weights = weights(&workouts, name);
+            assert!(weights.windows(2).all(|pair| pair[1] > pair[0]), "{name}");
+        }
+        assert!(workouts
+            .iter()
+            .flat_map(|workout| &workout.sets)
+            .all(|set| set.repetitions == set.target_repetitions));
+    }
+}
@@ -0,0 +1,82 @@
+use chrono::{DateTime, Datelike, Duration, FixedOffset, NaiveDate, Utc};
+
+/// How regularly workouts are done. Streaks are counted in weeks starting on
+/// Monday, a streak is current if it includes this or the previous week, as
+/// this week may just not have had a workout yet.
+#[derive(Debug, Default)]
+pub struct Frequency {
+    pub current_streak_weeks: i64,
+    pub longest_streak_weeks: i64,
+    /// Average since the week of the first workout, including this week.
+    pub avg_workouts_per_week: f64,
+}
+
+/// Computes the frequency of the workouts started at the given times, weeks
+/// are determined in the time zone of the offset.
+pub fn frequency(starts: &[DateTime<Utc>], offset: FixedOffset, now: DateTime<Utc>) -> Frequency {
+    let mut weeks = starts
+        .iter()
+        .map(|start| week_of(start.with_timezone(&offset).date_naive()))
+        .collect::<Vec<_>>();
+    weeks.sort();
+    weeks.dedup();
+
+    let Some(&first) = weeks.first() else {
+        return Frequency::default();
+    };
+
+    let this_week = week_of(now.with_timezone(&offset).date_naive());
+
+    let mut longest = 0;
+    let mut current = 0;
+    let mut previous: Option<NaiveDate> = None;
+    for &week in &weeks {
+        current = match previous {
+            Some(previous) if week - previous == Duration::weeks(1) => current + 1,
+            _ => 1,
+        };
+        longest = longest.max(current);
+        previous = Some(week);
+    }
+
+    // The loop ends with the streak of the last week with a workout.
+    let last = weeks[weeks.len() - 1];
+    if this_week - last > Duration::weeks(1) {
+        current = 0;
+    }
+
+    let total_weeks = ((this_week - first).num_weeks() + 1).max(1);
+
+    Frequency {
+        current_streak_weeks: current,
+        longest_streak_weeks: longest,
+        avg_workouts_per_week: starts.len() as f64 / total_weeks as f64,
+    }
+}
+
+/// Monday of the week of the date.
+pub fn week_of(date: NaiveDate) -> NaiveDate {
+    date - Duration::days(date.weekday().num_days_from_monday() as i64)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn date(day: u32) -> NaiveDate {
+        NaiveDate::from_ymd_opt(2023, 11, day).expect("Date must be valid")
+    }
+
+    #[test]
+    fn weeks_start_on_monday() {
+        let monday = date(6);
+
+        assert_eq!(week_of(monday), monday);
+        assert_eq!(week_of(date(8)), monday);
+        assert_eq!(week_of(date(12)), monday);
+        assert_eq!(
+            week_of(date(5)),
+            NaiveDate::from_ymd_opt(2023, 10, 30).expect("Date must be valid")
+        );
+    }
+}
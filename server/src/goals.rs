@@ -0,0 +1,136 @@
+use anyhow::Result;
+use chrono::{DateTime, FixedOffset, Utc};
+use sqlx::SqliteExecutor;
+
+use crate::{
+    dal::{self, GoalEntity, GoalKind, StatisticsFilterInput},
+    frequency, one_rep_max,
+};
+
+/// A goal evaluated against the actual data.
+#[derive(Debug)]
+pub struct GoalProgress {
+    pub goal: GoalEntity,
+    /// The value the target is compared with, `None` without data.
+    pub current: Option<f64>,
+    /// Share of the way to the target covered, from 0 to 1.
+    pub progress: f64,
+    pub achieved: bool,
+}
+
+/// Evaluates the goals, weeks of workouts start on Monday in the time zone of
+/// the offset.
+pub async fn progress<'local, E>(
+    conn: E,
+    goals: Vec<GoalEntity>,
+    offset: FixedOffset,
+    now: DateTime<Utc>,
+) -> Result<Vec<GoalProgress>>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    let this_week = frequency::week_of(now.with_timezone(&offset).date_naive());
+    let mut workouts_this_week = None;
+
+    let mut progress = Vec::with_capacity(goals.len());
+    for goal in goals {
+        // The way to the target starts at zero, only body weights start at
+        // the body weight when the goal was set and may have to go down.
+        let (start, current) = match goal.kind {
+            GoalKind::OneRepMax => {
+                let current = match goal.exercise_id {
+                    Some(exercise_id) => {
+                        let sets = dal::get_session_sets(conn, Some(exercise_id)).await?;
+                        one_rep_max::best(sets.iter().map(|set| (set.weight, set.repetitions)))
+                    }
+                    None => None,
+                };
+                (Some(0.0), current)
+            }
+            GoalKind::WeeklyWorkouts => {
+                if workouts_this_week.is_none() {
+                    let starts =
+                        dal::get_workout_starts(conn, &StatisticsFilterInput::default()).await?;
+                    let count = starts
+                        .iter()
+                        .filter(|start| {
+                            frequency::week_of(start.with_timezone(&offset).date_naive())
+                                == this_week
+                        })
+                        .count();
+                    workouts_this_week = Some(count as f64);
+                }
+                (Some(0.0), workouts_this_week)
+            }
+            GoalKind::BodyWeight => {
+                let start = dal::get_body_weight_at(conn, goal.created.timestamp()).await?;
+                let current = dal::get_body_weight_at(conn, now.timestamp()).await?;
+                (
+                    start.map(|start| start.weight as f64),
+                    current.map(|current| current.weight as f64),
+                )
+            }
+        };
+
+        let (share, achieved) = match (start, current) {
+            (Some(start), Some(current)) => toward(start, current, goal.target as f64),
+            _ => (0.0, false),
+        };
+        progress.push(GoalProgress {
+            goal,
+            current,
+            progress: share,
+            achieved,
+        });
+    }
+
+    Ok(progress)
+}
+
+/// Share of the way from the start to the target that was covered, and
+/// whether the target was reached. A target equal to the start has no way to
+/// cover, so its share is all or nothing.
+fn toward(start: f64, current: f64, target: f64) -> (f64, bool) {
+    let achieved = if target < start {
+        current <= target
+    } else {
+        current >= target
+    };
+
+    if achieved {
+        return (1.0, true);
+    }
+    if start == target {
+        return (0.0, false);
+    }
+
+    (
+        ((current - start) / (target - start)).clamp(0.0, 1.0),
+        false,
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn progress_towards_a_higher_target() {
+        assert_eq!(toward(0.0, 50.0, 100.0), (0.5, false));
+        assert_eq!(toward(0.0, -10.0, 100.0), (0.0, false));
+        assert_eq!(toward(0.0, 120.0, 100.0), (1.0, true));
+    }
+
+    #[test]
+    fn progress_towards_a_lower_target() {
+        assert_eq!(toward(90.0, 85.0, 80.0), (0.5, false));
+        assert_eq!(toward(90.0, 95.0, 80.0), (0.0, false));
+        assert_eq!(toward(90.0, 79.0, 80.0), (1.0, true));
+    }
+
+    #[test]
+    fn target_at_the_start_is_only_achieved_when_reached() {
+        assert_eq!(toward(80.0, 80.0, 80.0), (1.0, true));
+        assert_eq!(toward(80.0, 79.0, 80.0), (0.0, false));
+    }
+}
@@ -0,0 +1,276 @@
+use std::{
+    sync::{Arc, Mutex},
+    time::{Duration, Instant},
+};
+
+use anyhow::{Context, Result};
+use chrono::{DateTime, Utc};
+use reqwest::StatusCode;
+use serde::Deserialize;
+use serde_json::json;
+use sqlx::{Pool, Sqlite};
+use tracing::{error, info, warn};
+
+use crate::dal::{self, GoogleFitWorkoutEntity};
+
+const TOKEN_URL: &str = "https://oauth2.googleapis.com/token";
+
+const SESSIONS_URL: &str = "https://www.googleapis.com/fitness/v1/users/me/sessions";
+
+/// Activity type of strength training in Google Fit.
+const STRENGTH_TRAINING: i64 = 80;
+
+/// Interval in which workouts that failed to sync are retried.
+const SCHEDULE_INTERVAL: Duration = Duration::from_secs(15 * 60);
+
+/// Delay after the first failed push or deletion of a workout, doubled for
+/// every further failure up to [`MAX_RETRY_DELAY`], so workouts that Google
+/// Fit keeps rejecting don't take up every sync.
+const RETRY_DELAY: Duration = Duration::from_secs(15 * 60);
+
+const MAX_RETRY_DELAY: Duration = Duration::from_secs(24 * 60 * 60);
+
+/// Workouts pushed per sync, so the first sync of a long history is spread
+/// over several runs.
+const SYNC_BATCH: i64 = 50;
+
+/// Access tokens are refreshed this long before they expire.
+const TOKEN_EXPIRY_MARGIN: Duration = Duration::from_secs(60);
+
+/// OAuth client of the instance and the refresh token of the account the
+/// workouts are pushed to, needs the `fitness.activity.write` scope.
+#[derive(Debug, Clone)]
+pub struct GoogleFitCredentials {
+    pub client_id: String,
+    pub client_secret: String,
+    pub refresh_token: String,
+}
+
+/// Pushes finished workouts as strength training sessions to Google Fit.
+/// Sessions are identified by the workout, so pushing a workout again after
+/// it was reopened and finished anew updates its session, and the session of
+/// a deleted workout is deleted.
+///
+/// All workouts finished since `since` are pushed, by default this includes
+/// the whole history from before Google Fit was configured.
+#[derive(Debug, Clone)]
+pub struct GoogleFit {
+    pool: Pool<Sqlite>,
+    client: reqwest::Client,
+    credentials: GoogleFitCredentials,
+    since: Option<DateTime<Utc>>,
+    token: Arc<Mutex<Option<AccessToken>>>,
+}
+
+#[derive(Debug, Clone)]
+struct AccessToken {
+    value: String,
+    expires: Instant,
+}
+
+#[derive(Debug, Deserialize)]
+struct TokenResponse {
+    access_token: String,
+    expires_in: u64,
+}
+
+impl GoogleFit {
+    pub fn new(
+        pool: Pool<Sqlite>,
+        credentials: GoogleFitCredentials,
+        since: Option<DateTime<Utc>>,
+    ) -> Self {
+        Self {
+            pool,
+            client: reqwest::Client::new(),
+            credentials,
+            since,
+            token: Arc::default(),
+        }
+    }
+
+    /// Syncs in the background, meant to be called after a workout was
+    /// finished or deleted.
+    pub fn spawn_sync(&self) {
+        let google_fit = self.clone();
+        tokio::spawn(async move {
+            if let Err(err) = google_fit.sync().await {
+                error!(err = format!("{err:#}"), "Failed to sync to Google Fit.");
+            }
+        });
+    }
+
+    /// Syncs periodically, starting right away.
+    pub async fn schedule(self) {
+        let mut interval = tokio::time::interval(SCHEDULE_INTERVAL);
+        loop {
+            interval.tick().await;
+            if let Err(err) = self.sync().await {
+                error!(err = format!("{err:#}"), "Failed to sync to Google Fit.");
+            }
+        }
+    }
+
+    /// Deletes the sessions of deleted workouts first, so a workout restored
+    /// with the same id is pushed again afterwards, and then pushes the
+    /// workouts that aren't in Google Fit as they are now. A workout that
+    /// fails is retried after a delay.
+    async fn sync(&self) -> Result<()> {
+        let deletions = dal::get_pending_google_fit_deletions(&self.pool, SYNC_BATCH).await?;
+        let since = self.since.map(|since| since.timestamp());
+        let workouts = dal::get_unsynced_google_fit_workouts(&self.pool, since, SYNC_BATCH).await?;
+        if deletions.is_empty() && workouts.is_empty() {
+            return Ok(());
+        }
+
+        let token = self.access_token().await?;
+        let retry_delay_s = RETRY_DELAY.as_secs() as i64;
+        let max_retry_delay_s = MAX_RETRY_DELAY.as_secs() as i64;
+
+        let mut deleted = 0;
+        for &workout_id in &deletions {
+            if let Err(err) = self.delete(&token, workout_id).await {
+                warn!(
+                    err = format!("{err:#}"),
+                    workout = workout_id,
+                    "Failed to delete workout from Google Fit."
+                );
+                dal::set_google_fit_deletion_failed(
+                    &self.pool,
+                    workout_id,
+                    retry_delay_s,
+                    max_retry_delay_s,
+                )
+                .await?;
+                continue;
+            }
+
+            dal::delete_google_fit_deletion(&self.pool, workout_id).await?;
+            deleted += 1;
+        }
+
+        let mut synced = 0;
+        for workout in &workouts {
+            let finished_utc_s = workout.finished.timestamp();
+            if let Err(err) = self.push(&token, workout).await {
+                warn!(
+                    err = format!("{err:#}"),
+                    workout = workout.id,
+                    "Failed to push workout to Google Fit."
+                );
+                dal::set_google_fit_failed(
+                    &self.pool,
+                    workout.id,
+                    finished_utc_s,
+                    retry_delay_s,
+                    max_retry_delay_s,
+                )
+                .await?;
+                continue;
+            }
+
+            dal::set_google_fit_synced(&self.pool, workout.id, finished_utc_s).await?;
+            synced += 1;
+        }
+
+        info!(synced, deleted, "Synced workouts to Google Fit.");
+        Ok(())
+    }
+
+    async fn push(&self, token: &str, workout: &GoogleFitWorkoutEntity) -> Result<()> {
+        let id = session_id(workout.id);
+        let description = workout
+            .note
+            .clone()
+            .unwrap_or_else(|| format!("{} sets of {} exercises", workout.sets, workout.exercises));
+
+        let session = json!({
+            "id": id,
+            "name": "Strength training",
+            "description": description,
+            "startTimeMillis": workout.started.timestamp_millis(),
+            "endTimeMillis": workout.finished.timestamp_millis(),
+            "activityType": STRENGTH_TRAINING,
+            "application": { "name": "workout-tracker" },
+        });
+
+        self.client
+            .put(format!("{SESSIONS_URL}/{id}"))
+            .bearer_auth(token)
+            .json(&session)
+            .send()
+            .await
+            .context("Failed to send session")?
+            .error_for_status()
+            .context("Session was rejected")?;
+
+        Ok(())
+    }
+
+    /// Deletes the session of a workout, a session that doesn't exist counts
+    /// as deleted.
+    async fn delete(&self, token: &str, workout_id: i64) -> Result<()> {
+        let response = self
+            .client
+            .delete(format!("{SESSIONS_URL}/{}", session_id(workout_id)))
+            .bearer_auth(token)
+            .send()
+            .await
+            .context("Failed to send session deletion")?;
+        if response.status() == StatusCode::NOT_FOUND {
+            return Ok(());
+        }
+
+        response
+            .error_for_status()
+            .context("Session deletion was rejected")?;
+
+        Ok(())
+    }
+
+    /// Returns the cached access token, or exchanges the refresh token for a
+    /// new one once it is about to expire.
+    async fn access_token(&self) -> Result<String> {
+        let cached = self
+            .token
+            .lock()
+            .expect("Token must not be poisoned")
+            .clone();
+        if let Some(token) =
+            cached.filter(|token| token.expires > Instant::now() + TOKEN_EXPIRY_MARGIN)
+        {
+            return Ok(token.value);
+        }
+
+        let response: TokenResponse = self
+            .client
+            .post(TOKEN_URL)
+            .form(&[
+                ("client_id", self.credentials.client_id.as_str()),
+                ("client_secret", self.credentials.client_secret.as_str()),
+                ("refresh_token", self.credentials.refresh_token.as_str()),
+                ("grant_type", "refresh_token"),
+            ])
+            .send()
+            .await
+            .context("Failed to refresh Google Fit access token")?
+            .error_for_status()
+            .context("Google Fit access token refresh was rejected")?
+            .json()
+            .await
+            .context("Failed to parse Google Fit access token")?;
+
+        let token = AccessToken {
+            value: response.access_token,
+            expires: Instant::now() + Duration::from_secs(response.expires_in),
+        };
+        let value = token.value.clone();
+        *self.token.lock().expect("Token must not be poisoned") = Some(token);
+
+        Ok(value)
+    }
+}
+
+fn session_id(workout_id: i64) -> String {
+    format!("workout-tracker-{workout_id}")
+}
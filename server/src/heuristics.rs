@@ -0,0 +1,112 @@
+use anyhow::Result;
+use sqlx::{Pool, Sqlite, SqliteExecutor};
+
+use crate::dal::{self, ExerciseSetEntity, ExerciseSetInput, SetType};
+
+/// Relative change of the weight compared to the last session of the same
+/// exercise that is considered suspicious.
+const WEIGHT_JUMP_RATIO: f64 = 0.3;
+
+/// Absolute change of the weight that is always tolerated, so light
+/// exercises don't trigger warnings for small increments.
+const WEIGHT_JUMP_MIN: i64 = 10;
+
+const MAX_PLAUSIBLE_REPETITIONS: i64 = 50;
+
+/// Factor of the previous max weight of an exercise above which a set is
+/// flagged as an anomaly.
+const ANOMALY_WEIGHT_FACTOR: i64 = 10;
+
+const ANOMALY_REPETITIONS: i64 = 200;
+
+/// A non-fatal finding about a written entity that clients can show as a
+/// sanity check.
+#[derive(Debug)]
+pub struct Warning {
+    pub code: &'static str,
+    pub message: String,
+}
+
+pub async fn check_exercise_set<'local, E>(
+    conn: E,
+    exercise_set: &ExerciseSetInput,
+) -> Result<Vec<Warning>>
+where
+    E: SqliteExecutor<'local>,
+{
+    let mut warnings = Vec::new();
+
+    if exercise_set.repetitions > MAX_PLAUSIBLE_REPETITIONS {
+        warnings.push(Warning {
+            code: "high_repetitions",
+            message: format!(
+                "{} repetitions in a single set, typo?",
+                exercise_set.repetitions
+            ),
+        });
+    }
+
+    // Warm-up sets are expected to be way lighter than the last session.
+    if exercise_set.set_type == Some(SetType::Warmup) {
+        return Ok(warnings);
+    }
+
+    let Some(weight) = exercise_set.weight else {
+        return Ok(warnings);
+    };
+
+    let last_session = dal::get_last_session_sets_by_exercise_id(
+        conn,
+        exercise_set.exercise_id,
+        Some(exercise_set.workout_id),
+    )
+    .await?;
+
+    if let Some(last_weight) = last_session.iter().filter_map(|set| set.weight).max() {
+        let jump = (weight - last_weight).abs();
+
+        if jump > WEIGHT_JUMP_MIN && jump as f64 > last_weight as f64 * WEIGHT_JUMP_RATIO {
+            warnings.push(Warning {
+                code: "weight_jump",
+                message: format!("Weight jump of {jump} vs last session ({last_weight}), typo?"),
+            });
+        }
+    }
+
+    Ok(warnings)
+}
+
+/// Flags statistically unlikely values of a written exercise set for review,
+/// replacing anomalies flagged by previous writes of the same set.
+pub async fn flag_anomalies(pool: &Pool<Sqlite>, exercise_set: &ExerciseSetEntity) -> Result<()> {
+    let mut anomalies = Vec::new();
+
+    if exercise_set.repetitions >= ANOMALY_REPETITIONS {
+        anomalies.push((
+            "repetitions",
+            format!("{} repetitions in a single set", exercise_set.repetitions),
+        ));
+    }
+
+    if let Some(weight) = exercise_set.weight {
+        let max_weight =
+            dal::get_max_weight_by_exercise_id(pool, exercise_set.exercise_id, exercise_set.id)
+                .await?;
+
+        if let Some(max_weight) = max_weight.filter(|max| *max > 0) {
+            if weight >= max_weight * ANOMALY_WEIGHT_FACTOR {
+                anomalies.push((
+                    "weight",
+                    format!("Weight of {weight} is at least {ANOMALY_WEIGHT_FACTOR} times the previous max of {max_weight}"),
+                ));
+            }
+        }
+    }
+
+    let anomalies = anomalies
+        .iter()
+        .map(|(code, message)| (*code, message.as_str()))
+        .collect::<Vec<_>>();
+
+    dal::replace_set_anomalies(pool, exercise_set.id, &anomalies).await
+}
@@ -0,0 +1,470 @@
+use std::{future::Future, pin::Pin, time::Duration};
+
+use anyhow::Result;
+use chrono::{DateTime, Utc};
+use sqlx::{Pool, Sqlite, SqliteExecutor};
+use tracing::error;
+
+use crate::{
+    dal::{self, InsightInput, NotificationEvent, SessionSetEntity},
+    notifications::{Notification, Notifier},
+    one_rep_max,
+};
+
+/// Interval of evaluating all rules, in addition to evaluating them on writes.
+const SCHEDULE_INTERVAL: Duration = Duration::from_secs(60 * 60);
+
+/// Sessions without a new best estimated one-repetition maximum after which
+/// a lift counts as stalled.
+pub const DEFAULT_STALL_SESSIONS: usize = 3;
+
+/// Weeks without a new best set after which a lift counts as plateaued.
+pub const DEFAULT_PLATEAU_WEEKS: i64 = 6;
+
+pub const MAX_PLATEAU_WEEKS: i64 = 520;
+
+/// Multiple of the stall sessions after which a deload is suggested.
+const DELOAD_STALL_FACTOR: usize = 2;
+
+/// Relative improvement of the estimated one-repetition maximum that counts
+/// as progress, so rounding of weights doesn't hide a stall.
+const MIN_IMPROVEMENT_RATIO: f64 = 0.005;
+
+type BoxFuture<'a, T> = Pin<Box<dyn Future<Output = T> + Send + 'a>>;
+
+/// What caused an evaluation of the rules.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Trigger {
+    Schedule,
+    SetWritten,
+    WorkoutFinished,
+}
+
+/// A check that derives insights from the stored data. Rules are evaluated
+/// from scratch, insights whose subject is no longer found are resolved.
+pub trait Rule: Send + Sync {
+    /// Stored with every insight of the rule, must never change.
+    fn name(&self) -> &'static str;
+
+    /// Writes that may change the findings of the rule, all rules run on
+    /// schedule.
+    fn triggers(&self) -> &'static [Trigger];
+
+    fn evaluate<'a>(&'a self, pool: &'a Pool<Sqlite>) -> BoxFuture<'a, Result<Vec<InsightInput>>>;
+
+    /// Event new insights of the rule are sent as notifications with, if any.
+    fn notification_event(&self) -> Option<NotificationEvent> {
+        None
+    }
+}
+
+/// All rules, new rules only have to be added here.
+pub fn rules() -> Vec<Box<dyn Rule>> {
+    vec![
+        Box::new(StallRule),
+        Box::new(DeloadRule),
+        Box::new(AnomalyRule),
+    ]
+}
+
+/// Evaluates the rules that react to the trigger and persists their findings.
+/// New insights of rules with a notification event are sent through the
+/// notifier, if one is given, so every finding is notified once.
+pub async fn evaluate(
+    pool: &Pool<Sqlite>,
+    notifier: Option<&Notifier>,
+    trigger: Trigger,
+) -> Result<()> {
+    for rule in rules() {
+        if trigger != Trigger::Schedule && !rule.triggers().contains(&trigger) {
+            continue;
+        }
+
+        let insights = rule.evaluate(pool).await?;
+        let new = dal::replace_insights(pool, rule.name(), &insights).await?;
+
+        if let (Some(notifier), Some(event)) = (notifier, rule.notification_event()) {
+            for insight in new {
+                notifier.notify(Notification {
+                    event,
+                    title: insight.title.clone(),
+                    message: insight.message.clone(),
+                });
+            }
+        }
+    }
+
+    Ok(())
+}
+
+/// Evaluates the rules in the background, so writes aren't delayed.
+pub fn spawn_evaluation(pool: Pool<Sqlite>, notifier: Notifier, trigger: Trigger) {
+    tokio::spawn(async move {
+        if let Err(err) = evaluate(&pool, Some(&notifier), trigger).await {
+            error!(
+                err = format!("{err:#}"),
+                ?trigger,
+                "Failed to evaluate insights."
+            );
+        }
+    });
+}
+
+/// Evaluates all rules periodically, starting right away.
+pub async fn schedule(pool: Pool<Sqlite>, notifier: Notifier) {
+    let mut interval = tokio::time::interval(SCHEDULE_INTERVAL);
+    loop {
+        interval.tick().await;
+        if let Err(err) = evaluate(&pool, Some(&notifier), Trigger::Schedule).await {
+            error!(err = format!("{err:#}"), "Failed to evaluate insights.");
+        }
+    }
+}
+
+/// A lift whose estimated one-repetition maximum did not improve within the
+/// most recent sessions.
+#[derive(Debug)]
+pub struct Stall {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    /// Sessions since the best estimated one-repetition maximum was set.
+    pub sessions: usize,
+    pub best_one_rep_max: f64,
+    pub best_date: DateTime<Utc>,
+    /// Best estimated one-repetition maximum of the stalled sessions.
+    pub recent_one_rep_max: f64,
+    pub last_workout_id: i64,
+    pub suggestions: Vec<Suggestion>,
+}
+
+/// A lift that is still trained but whose best set, by estimated
+/// one-repetition maximum, wasn't beaten for a while.
+#[derive(Debug)]
+pub struct Plateau {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub record: PlateauRecord,
+    /// Sessions since the record was set.
+    pub sessions: usize,
+    pub last_session: DateTime<Utc>,
+}
+
+#[derive(Debug, Clone, Copy)]
+pub struct PlateauRecord {
+    pub weight: i64,
+    pub repetitions: i64,
+    pub estimate: f64,
+    pub achieved: DateTime<Utc>,
+}
+
+/// An action that may get a stalled lift moving again, `code` is meant to be
+/// matched by clients.
+#[derive(Debug)]
+pub struct Suggestion {
+    pub code: &'static str,
+    pub message: &'static str,
+}
+
+struct Session {
+    workout_id: i64,
+    started: DateTime<Utc>,
+    best: f64,
+    /// Weight and repetitions of the set with the best estimate.
+    best_weight: i64,
+    best_repetitions: i64,
+    top_weight: i64,
+}
+
+/// Finds lifts without a new best estimated one-repetition maximum in at least
+/// the given number of sessions.
+pub async fn find_stalls<'local, E>(conn: E, min_sessions: usize) -> Result<Vec<Stall>>
+where
+    E: SqliteExecutor<'local>,
+{
+    let sets = dal::get_session_sets(conn, None).await?;
+
+    let mut stalls = Vec::new();
+    for exercise_sets in chunk_by_exercise(&sets) {
+        if let Some(stall) = find_stall(exercise_sets, min_sessions.max(1)) {
+            stalls.push(stall);
+        }
+    }
+    stalls.sort_by(|a, b| b.sessions.cmp(&a.sessions));
+
+    Ok(stalls)
+}
+
+/// Finds lifts trained within the last `weeks` weeks whose best set is older
+/// than that, the longest plateaus first.
+pub async fn find_plateaus<'local, E>(
+    conn: E,
+    weeks: i64,
+    now: DateTime<Utc>,
+) -> Result<Vec<Plateau>>
+where
+    E: SqliteExecutor<'local>,
+{
+    let since = now - chrono::Duration::weeks(weeks);
+    let sets = dal::get_session_sets(conn, None).await?;
+
+    let mut plateaus = chunk_by_exercise(&sets)
+        .into_iter()
+        .filter_map(|exercise_sets| find_plateau(exercise_sets, since))
+        .collect::<Vec<_>>();
+    plateaus.sort_by_key(|plateau| plateau.record.achieved);
+
+    Ok(plateaus)
+}
+
+fn find_stall(sets: &[SessionSetEntity], min_sessions: usize) -> Option<Stall> {
+    let first = sets.first()?;
+    let sessions = group_sessions(sets);
+
+    let (best, sessions_since_best) = latest_best(&sessions)?;
+    if sessions_since_best < min_sessions {
+        return None;
+    }
+
+    let recent = &sessions[sessions.len() - sessions_since_best..];
+    let last = recent.last()?;
+
+    Some(Stall {
+        exercise_id: first.exercise_id,
+        exercise_name: first.exercise_name.clone(),
+        sessions: sessions_since_best,
+        best_one_rep_max: best.best,
+        best_date: best.started,
+        recent_one_rep_max: recent
+            .iter()
+            .map(|session| session.best)
+            .fold(0.0, f64::max),
+        last_workout_id: last.workout_id,
+        suggestions: suggest(recent, min_sessions),
+    })
+}
+
+fn find_plateau(sets: &[SessionSetEntity], since: DateTime<Utc>) -> Option<Plateau> {
+    let first = sets.first()?;
+    let sessions = group_sessions(sets);
+
+    let (best, sessions_since_best) = latest_best(&sessions)?;
+    let last = sessions.last()?;
+    if best.started >= since || last.started < since {
+        return None;
+    }
+
+    Some(Plateau {
+        exercise_id: first.exercise_id,
+        exercise_name: first.exercise_name.clone(),
+        record: PlateauRecord {
+            weight: best.best_weight,
+            repetitions: best.best_repetitions,
+            estimate: best.best,
+            achieved: best.started,
+        },
+        sessions: sessions_since_best,
+        last_session: last.started,
+    })
+}
+
+/// The session that set the latest best estimated one-repetition maximum,
+/// together with the number of sessions since. Stalls and plateaus are both
+/// measured from it.
+fn latest_best(sessions: &[Session]) -> Option<(&Session, usize)> {
+    let mut best: Option<&Session> = None;
+    let mut sessions_since_best = 0;
+    for session in sessions {
+        match best {
+            Some(current) if session.best <= current.best * (1.0 + MIN_IMPROVEMENT_RATIO) => {
+                sessions_since_best += 1;
+            }
+            _ => {
+                best = Some(session);
+                sessions_since_best = 0;
+            }
+        }
+    }
+
+    Some((best?, sessions_since_best))
+}
+
+fn group_sessions(sets: &[SessionSetEntity]) -> Vec<Session> {
+    let mut sessions: Vec<Session> = Vec::new();
+
+    for set in sets {
+        let Some(estimate) = one_rep_max::estimate(set.weight, set.repetitions) else {
+            continue;
+        };
+
+        match sessions.last_mut() {
+            Some(session) if session.workout_id == set.workout_id => {
+                if estimate > session.best {
+                    session.best = estimate;
+                    session.best_weight = set.weight;
+                    session.best_repetitions = set.repetitions;
+                }
+                session.top_weight = session.top_weight.max(set.weight);
+            }
+            _ => sessions.push(Session {
+                workout_id: set.workout_id,
+                started: set.started,
+                best: estimate,
+                best_weight: set.weight,
+                best_repetitions: set.repetitions,
+                top_weight: set.weight,
+            }),
+        }
+    }
+
+    sessions
+}
+
+fn suggest(recent: &[Session], min_sessions: usize) -> Vec<Suggestion> {
+    let mut suggestions = Vec::new();
+
+    if recent.len() >= DELOAD_STALL_FACTOR * min_sessions {
+        suggestions.push(Suggestion {
+            code: "deload",
+            message: "Deload by about 10% for a week and build back up.",
+        });
+    }
+
+    let same_weight = recent
+        .windows(2)
+        .all(|pair| pair[0].top_weight == pair[1].top_weight);
+    if same_weight {
+        suggestions.push(Suggestion {
+            code: "increase_weight",
+            message: "The top weight didn't change, try a small increment.",
+        });
+    }
+
+    suggestions.push(Suggestion {
+        code: "change_repetitions",
+        message: "Switch to a different repetition range for a few weeks.",
+    });
+
+    suggestions
+}
+
+/// Splits sets ordered by exercise into the sets of each exercise.
+pub fn chunk_by_exercise(sets: &[SessionSetEntity]) -> Vec<&[SessionSetEntity]> {
+    chunk_by(sets, |set| set.exercise_id)
+}
+
+/// Splits items ordered by a key into the runs of items with the same key.
+pub fn chunk_by<T, K>(items: &[T], key: impl Fn(&T) -> K) -> Vec<&[T]>
+where
+    K: PartialEq,
+{
+    let mut chunks = Vec::new();
+    let mut start = 0;
+    for i in 1..=items.len() {
+        if i == items.len() || key(&items[i]) != key(&items[start]) {
+            chunks.push(&items[start..i]);
+            start = i;
+        }
+    }
+    chunks
+}
+
+/// Lifts that stalled recently.
+struct StallRule;
+
+impl Rule for StallRule {
+    fn name(&self) -> &'static str {
+        "stall"
+    }
+
+    fn triggers(&self) -> &'static [Trigger] {
+        &[Trigger::WorkoutFinished]
+    }
+
+    fn evaluate<'a>(&'a self, pool: &'a Pool<Sqlite>) -> BoxFuture<'a, Result<Vec<InsightInput>>> {
+        Box::pin(async move {
+            let stalls = find_stalls(pool, DEFAULT_STALL_SESSIONS).await?;
+
+            Ok(stalls
+                .into_iter()
+                .filter(|stall| stall.sessions < DELOAD_STALL_FACTOR * DEFAULT_STALL_SESSIONS)
+                .map(|stall| InsightInput {
+                    subject: format!("exercise:{}", stall.exercise_id),
+                    title: format!("{} stalled", stall.exercise_name),
+                    message: format!(
+                        "No new best in the last {} sessions, the estimated max is {:.1} since {}.",
+                        stall.sessions,
+                        stall.best_one_rep_max,
+                        stall.best_date.format("%Y-%m-%d"),
+                    ),
+                })
+                .collect())
+        })
+    }
+
+    fn notification_event(&self) -> Option<NotificationEvent> {
+        Some(NotificationEvent::Stall)
+    }
+}
+
+/// Lifts that stalled for so long that a deload is due.
+struct DeloadRule;
+
+impl Rule for DeloadRule {
+    fn name(&self) -> &'static str {
+        "deload"
+    }
+
+    fn triggers(&self) -> &'static [Trigger] {
+        &[Trigger::WorkoutFinished]
+    }
+
+    fn evaluate<'a>(&'a self, pool: &'a Pool<Sqlite>) -> BoxFuture<'a, Result<Vec<InsightInput>>> {
+        Box::pin(async move {
+            let stalls = find_stalls(pool, DELOAD_STALL_FACTOR * DEFAULT_STALL_SESSIONS).await?;
+
+            Ok(stalls
+                .into_iter()
+                .map(|stall| InsightInput {
+                    subject: format!("exercise:{}", stall.exercise_id),
+                    title: format!("Deload {}", stall.exercise_name),
+                    message: format!(
+                        "No new best in the last {} sessions. Deload by about 10% for a week and build back up.",
+                        stall.sessions,
+                    ),
+                })
+                .collect())
+        })
+    }
+
+    fn notification_event(&self) -> Option<NotificationEvent> {
+        Some(NotificationEvent::Stall)
+    }
+}
+
+/// Sets with unlikely values that should be reviewed.
+struct AnomalyRule;
+
+impl Rule for AnomalyRule {
+    fn name(&self) -> &'static str {
+        "anomaly"
+    }
+
+    fn triggers(&self) -> &'static [Trigger] {
+        &[Trigger::SetWritten]
+    }
+
+    fn evaluate<'a>(&'a self, pool: &'a Pool<Sqlite>) -> BoxFuture<'a, Result<Vec<InsightInput>>> {
+        Box::pin(async move {
+            let anomalies = dal::get_set_anomalies(pool).await?;
+
+            Ok(anomalies
+                .into_iter()
+                .map(|anomaly| InsightInput {
+                    subject: format!("set:{}:{}", anomaly.exercise_set_id, anomaly.code),
+                    title: format!("Unusual {} set", anomaly.exercise_name),
+                    message: anomaly.message,
+                })
+                .collect())
+        })
+    }
+}
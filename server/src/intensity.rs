@@ -0,0 +1,123 @@
+use std::collections::BTreeMap;
+
+use chrono::{DateTime, Utc};
+
+use crate::{dal::SessionSetEntity, insights, one_rep_max};
+
+/// Intensity of sets relative to the best estimated one-repetition maximum
+/// known when they were done, in percent.
+#[derive(Debug, Clone, Copy)]
+pub struct Intensity {
+    pub set_count: usize,
+    pub average_percent: f64,
+    pub peak_percent: f64,
+}
+
+#[derive(Debug)]
+pub struct ExerciseIntensity {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    /// Best estimate as of the last considered session.
+    pub one_rep_max: f64,
+    pub intensity: Intensity,
+}
+
+#[derive(Debug)]
+pub struct WorkoutIntensity {
+    pub workout_id: i64,
+    pub started: DateTime<Utc>,
+    pub intensity: Intensity,
+}
+
+#[derive(Debug, Default)]
+struct Accumulator {
+    set_count: usize,
+    sum: f64,
+    peak: f64,
+}
+
+impl Accumulator {
+    fn add(&mut self, percent: f64) {
+        self.set_count += 1;
+        self.sum += percent;
+        self.peak = self.peak.max(percent);
+    }
+
+    fn intensity(&self) -> Intensity {
+        Intensity {
+            set_count: self.set_count,
+            average_percent: self.sum / self.set_count as f64,
+            peak_percent: self.peak,
+        }
+    }
+}
+
+/// Computes the intensity of the sets of workouts started within `from`
+/// (inclusive) and `to` (exclusive) in UTC seconds. Earlier sets still count towards the
+/// estimate, so the first sessions of a window aren't measured against
+/// themselves only. The estimate includes the session of a set, which keeps
+/// every intensity at or below 100%. The sets must be ordered by exercise and
+/// session.
+pub fn intensity(
+    sets: &[SessionSetEntity],
+    from: Option<i64>,
+    to: Option<i64>,
+) -> (Vec<ExerciseIntensity>, Vec<WorkoutIntensity>) {
+    let in_range = |started: DateTime<Utc>| {
+        let started = started.timestamp();
+        from.map_or(true, |from| started >= from) && to.map_or(true, |to| started < to)
+    };
+
+    let mut exercises = Vec::new();
+    let mut workouts: BTreeMap<(DateTime<Utc>, i64), Accumulator> = BTreeMap::new();
+
+    for sets in insights::chunk_by_exercise(sets) {
+        let Some(first) = sets.first() else {
+            continue;
+        };
+
+        let mut best: f64 = 0.0;
+        let mut reference = 0.0;
+        let mut exercise = Accumulator::default();
+        for session in insights::chunk_by(sets, |set| set.workout_id) {
+            best = session
+                .iter()
+                .filter_map(|set| one_rep_max::estimate(set.weight, set.repetitions))
+                .fold(best, f64::max);
+            if best <= 0.0 || !in_range(session[0].started) {
+                continue;
+            }
+            reference = best;
+
+            let workout = workouts
+                .entry((session[0].started, session[0].workout_id))
+                .or_default();
+            for set in session {
+                let percent = set.weight as f64 / best * 100.0;
+                exercise.add(percent);
+                workout.add(percent);
+            }
+        }
+
+        if exercise.set_count > 0 {
+            exercises.push(ExerciseIntensity {
+                exercise_id: first.exercise_id,
+                exercise_name: first.exercise_name.clone(),
+                one_rep_max: reference,
+                intensity: exercise.intensity(),
+            });
+        }
+    }
+
+    exercises.sort_by(|a, b| a.exercise_name.cmp(&b.exercise_name));
+    let workouts = workouts
+        .into_iter()
+        .map(|((started, workout_id), workout)| WorkoutIntensity {
+            workout_id,
+            started,
+            intensity: workout.intensity(),
+        })
+        .collect();
+
+    (exercises, workouts)
+}
@@ -0,0 +1,109 @@
+use anyhow::Result;
+use sqlx::SqliteExecutor;
+
+use crate::dal;
+
+/// Caps of an instance, so a publicly shared one can't be abused into an
+/// enormous database. `None` means unlimited.
+#[derive(Debug, Clone, Copy, Default)]
+pub struct Limits {
+    pub max_exercises: Option<i64>,
+    /// Sets created per UTC day.
+    pub max_sets_per_day: Option<i64>,
+    /// Total size of uploaded attachments in bytes.
+    pub max_attachment_bytes: Option<i64>,
+    /// Total size of progress photos in bytes.
+    pub max_photo_bytes: Option<i64>,
+}
+
+/// A write that was rejected because of a limit, `code` is meant to be
+/// matched by clients.
+#[derive(Debug)]
+pub struct LimitExceeded {
+    pub code: &'static str,
+    pub message: String,
+}
+
+impl Limits {
+    pub async fn check_exercises<'local, E>(
+        &self,
+        conn: E,
+        additional: i64,
+    ) -> Result<Option<LimitExceeded>>
+    where
+        E: SqliteExecutor<'local>,
+    {
+        let Some(max) = self.max_exercises else {
+            return Ok(None);
+        };
+
+        let count = dal::count_exercises(conn).await? + additional;
+
+        Ok((count > max).then(|| LimitExceeded {
+            code: "max_exercises",
+            message: format!("This instance allows at most {max} exercises."),
+        }))
+    }
+
+    pub async fn check_sets_per_day<'local, E>(&self, conn: E) -> Result<Option<LimitExceeded>>
+    where
+        E: SqliteExecutor<'local>,
+    {
+        let Some(max) = self.max_sets_per_day else {
+            return Ok(None);
+        };
+
+        let count = dal::count_exercise_sets_created_today(conn).await?;
+
+        Ok((count >= max).then(|| LimitExceeded {
+            code: "max_sets_per_day",
+            message: format!("This instance allows at most {max} sets per day."),
+        }))
+    }
+
+    pub async fn check_attachment_storage<'local, E>(
+        &self,
+        conn: E,
+        additional_bytes: usize,
+    ) -> Result<Option<LimitExceeded>>
+    where
+        E: SqliteExecutor<'local>,
+    {
+        let Some(max) = self.max_attachment_bytes else {
+            return Ok(None);
+        };
+
+        let size = dal::get_attachments_size(conn).await? + additional_bytes as i64;
+
+        Ok((size > max).then(|| LimitExceeded {
+            code: "max_attachment_storage",
+            message: format!(
+                "This instance allows at most {} MB of attachments.",
+                max / (1024 * 1024)
+            ),
+        }))
+    }
+
+    pub async fn check_photo_storage<'local, E>(
+        &self,
+        conn: E,
+        additional_bytes: usize,
+    ) -> Result<Option<LimitExceeded>>
+    where
+        E: SqliteExecutor<'local>,
+    {
+        let Some(max) = self.max_photo_bytes else {
+            return Ok(None);
+        };
+
+        let size = dal::get_progress_photos_size(conn).await? + additional_bytes as i64;
+
+        Ok((size > max).then(|| LimitExceeded {
+            code: "max_photo_storage",
+            message: format!(
+                "This instance allows at most {} MB of progress photos.",
+                max / (1024 * 1024)
+            ),
+        }))
+    }
+}
@@ -1,18 +1,57 @@
+mod cache;
+mod charts;
 mod dal;
+mod demo;
+mod export;
+mod fixtures;
+mod frequency;
+mod goals;
+mod google_fit;
+mod heuristics;
+mod insights;
+mod intensity;
+mod limits;
+mod monitoring;
+mod notifications;
+mod one_rep_max;
+mod overload;
+mod pdf;
+mod photos;
+mod plates;
+mod records;
+mod report;
+mod restore;
+mod seed;
+mod series;
 mod server;
+mod strength;
+mod supersets;
+mod training_report;
+mod trends;
+mod units;
+mod validation;
+mod wger;
 
 use std::{
     net::SocketAddr,
     path::{Path, PathBuf},
 };
 
+use anyhow::Context;
 use argh::FromArgs;
+use chrono::{Duration, NaiveDate, TimeZone, Utc};
+use fixtures::HistoryOptions;
+use google_fit::{GoogleFit, GoogleFitCredentials};
+use limits::Limits;
+use photos::PhotoStorage;
+use report::OutputFormat;
 use sqlx::{
     sqlite::{SqliteConnectOptions, SqlitePoolOptions},
     Pool, Sqlite,
 };
-use tracing::{info, trace};
+use tracing::{error, info, trace};
 use tracing_subscriber::EnvFilter;
+use units::{UnitConfig, WeightUnit};
 
 /// Server binary for the `workout-tracker` application.
 #[derive(Debug, FromArgs)]
@@ -24,6 +63,146 @@ struct Args {
     /// address and port to listen on (default 127.0.0.1:8080)
     #[argh(option, default = "\"127.0.0.1:8080\".parse().unwrap()")]
     addr: SocketAddr,
+
+    /// unit of stored weights, either kg or lb (default kg)
+    #[argh(option, default = "WeightUnit::Kg")]
+    weight_unit: WeightUnit,
+
+    /// locale used by clients to format numbers (default en-US)
+    #[argh(option, default = "String::from(\"en-US\")")]
+    locale: String,
+
+    /// maximum number of exercises (default unlimited)
+    #[argh(option)]
+    max_exercises: Option<i64>,
+
+    /// maximum number of sets created per day (default unlimited)
+    #[argh(option)]
+    max_sets_per_day: Option<i64>,
+
+    /// maximum total size of uploaded attachments in MB (default unlimited)
+    #[argh(option)]
+    max_attachment_storage_mb: Option<i64>,
+
+    /// directory to store progress photos in, progress photos are disabled
+    /// without one
+    #[argh(option)]
+    photo_dir: Option<PathBuf>,
+
+    /// token clients need to present as bearer token to access progress
+    /// photos, required with --photo-dir
+    #[argh(option)]
+    photo_token: Option<String>,
+
+    /// maximum size of a single progress photo in MB (default 10)
+    #[argh(option, default = "10")]
+    max_photo_size_mb: usize,
+
+    /// maximum total size of progress photos in MB (default unlimited)
+    #[argh(option)]
+    max_photo_storage_mb: Option<i64>,
+
+    /// client id of the OAuth client used to push finished workouts to
+    /// Google Fit, together with --google-fit-client-secret and
+    /// --google-fit-refresh-token
+    #[argh(option)]
+    google_fit_client_id: Option<String>,
+
+    /// client secret of the OAuth client used for Google Fit
+    #[argh(option)]
+    google_fit_client_secret: Option<String>,
+
+    /// refresh token of the Google account to push finished workouts to,
+    /// needs the fitness.activity.write scope
+    #[argh(option)]
+    google_fit_refresh_token: Option<String>,
+
+    /// only push workouts finished on or after this date (YYYY-MM-DD, UTC)
+    /// to Google Fit, by default all workouts are pushed, including those
+    /// finished before Google Fit was configured
+    #[argh(option)]
+    google_fit_since: Option<NaiveDate>,
+
+    /// run a public demo: seed sample data, disable destructive endpoints and
+    /// reset the database periodically, deleting all other data
+    #[argh(switch)]
+    demo: bool,
+
+    /// minutes between resets of the demo database (default 60)
+    #[argh(option, default = "60")]
+    demo_reset_minutes: u64,
+
+    #[argh(subcommand)]
+    command: Option<Command>,
+}
+
+#[derive(Debug, FromArgs)]
+#[argh(subcommand)]
+enum Command {
+    Query(QueryCommand),
+    Archive(ArchiveCommand),
+    Restore(RestoreCommand),
+    SeedExercises(SeedExercisesCommand),
+    ImportWger(ImportWgerCommand),
+}
+
+/// Run a read-only SQL query against the database and print the result.
+#[derive(Debug, FromArgs)]
+#[argh(subcommand, name = "query")]
+struct QueryCommand {
+    /// the SELECT statement to run
+    #[argh(positional)]
+    sql: String,
+
+    /// output format, either json or csv (default json)
+    #[argh(option, default = "OutputFormat::Json")]
+    format: OutputFormat,
+}
+
+/// Summarize workouts older than the given number of years, keeping their
+/// totals in the statistics.
+#[derive(Debug, FromArgs)]
+#[argh(subcommand, name = "archive")]
+struct ArchiveCommand {
+    /// archive workouts started more than this many years ago
+    #[argh(option)]
+    older_than_years: u32,
+
+    /// delete the individual sets of archived workouts
+    #[argh(switch)]
+    delete_sets: bool,
+}
+
+/// Restore a single workout with its sets from a backup database.
+#[derive(Debug, FromArgs)]
+#[argh(subcommand, name = "restore-workout")]
+struct RestoreCommand {
+    /// path to the backup database file
+    #[argh(option)]
+    backup: PathBuf,
+
+    /// id of the workout in the backup
+    #[argh(option)]
+    workout_id: i64,
+}
+
+/// Add the built-in exercise catalog, skipping exercises that already exist.
+#[derive(Debug, FromArgs)]
+#[argh(subcommand, name = "seed-exercises")]
+struct SeedExercisesCommand {
+    /// also add sample workouts of this many weeks, ending today
+    #[argh(option)]
+    history_weeks: Option<i64>,
+}
+
+/// Import exercises with their muscles and equipment from a wger instance,
+/// skipping exercises that already exist.
+#[derive(Debug, FromArgs)]
+#[argh(subcommand, name = "import-wger")]
+struct ImportWgerCommand {
+    /// base URL of the wger instance (default https://wger.de)
+    #[argh(option, default = "String::from(\"https://wger.de\")")]
+    url: String,
 }
 
 #[tokio::main]
@@ -33,9 +212,197 @@ async fn main() {
     let args: Args = argh::from_env();
     trace!(?args, "Parsed CLI arguments.");
 
-    let pool = setup_database(&args.db).await.unwrap();
+    match args.command {
+        Some(Command::Query(command)) => {
+            match report::run_query(&args.db, &command.sql, command.format).await {
+                Ok(output) => print!("{output}"),
+                Err(err) => {
+                    error!(err = format!("{err:#}"), "Failed to run query.");
+                    std::process::exit(1);
+                }
+            }
+        }
+        Some(Command::Archive(command)) => {
+            if let Err(err) = archive(&args.db, &command).await {
+                error!(err = format!("{err:#}"), "Failed to archive workouts.");
+                std::process::exit(1);
+            }
+        }
+        Some(Command::Restore(command)) => {
+            if let Err(err) = restore_workout(&args.db, &command).await {
+                error!(err = format!("{err:#}"), "Failed to restore workout.");
+                std::process::exit(1);
+            }
+        }
+        Some(Command::SeedExercises(command)) => {
+            if let Err(err) = seed_exercises(&args.db, &command).await {
+                error!(err = format!("{err:#}"), "Failed to seed exercises.");
+                std::process::exit(1);
+            }
+        }
+        Some(Command::ImportWger(command)) => {
+            if let Err(err) = import_wger(&args.db, &command).await {
+                error!(
+                    err = format!("{err:#}"),
+                    "Failed to import exercises from wger."
+                );
+                std::process::exit(1);
+            }
+        }
+        None => {
+            let pool = setup_database(&args.db).await.unwrap();
+
+            let config = UnitConfig {
+                weight_unit: args.weight_unit,
+                locale: args.locale,
+            };
+
+            let limits = Limits {
+                max_exercises: args.max_exercises,
+                max_sets_per_day: args.max_sets_per_day,
+                max_attachment_bytes: args.max_attachment_storage_mb.map(|mb| mb * 1024 * 1024),
+                max_photo_bytes: args.max_photo_storage_mb.map(|mb| mb * 1024 * 1024),
+            };
+
+            let photos = match (args.photo_dir, args.photo_token) {
+                (Some(dir), Some(token)) if !token.is_empty() => {
+                    if let Err(err) = tokio::fs::create_dir_all(&dir).await {
+                        error!(
+                            err = format!("{err:#}"),
+                            "Failed to create photo directory."
+                        );
+                        std::process::exit(1);
+                    }
+                    Some(PhotoStorage::new(
+                        dir,
+                        token,
+                        args.max_photo_size_mb * 1024 * 1024,
+                    ))
+                }
+                (Some(_), _) => {
+                    error!("Progress photos require a --photo-token.");
+                    std::process::exit(1);
+                }
+                (None, _) => None,
+            };
+
+            let google_fit = match (
+                args.google_fit_client_id,
+                args.google_fit_client_secret,
+                args.google_fit_refresh_token,
+            ) {
+                (Some(client_id), Some(client_secret), Some(refresh_token)) => {
+                    let credentials = GoogleFitCredentials {
+                        client_id,
+                        client_secret,
+                        refresh_token,
+                    };
+                    let since = args.google_fit_since.map(|date| {
+                        Utc.from_utc_datetime(
+                            &date.and_hms_opt(0, 0, 0).expect("Midnight must be valid"),
+                        )
+                    });
+                    Some(GoogleFit::new(pool.clone(), credentials, since))
+                }
+                (None, None, None) => None,
+                _ => {
+                    error!("Google Fit requires a client id, client secret and refresh token.");
+                    std::process::exit(1);
+                }
+            };
+
+            if args.demo {
+                info!("Resetting demo data.");
+                if let Err(err) = demo::reset(&pool).await {
+                    error!(err = format!("{err:#}"), "Failed to reset demo data.");
+                    std::process::exit(1);
+                }
+
+                tokio::spawn(demo::schedule_resets(
+                    pool.clone(),
+                    std::time::Duration::from_secs(args.demo_reset_minutes.max(1) * 60),
+                ));
+            }
+
+            server::run(
+                &args.addr, pool, config, limits, args.demo, photos, google_fit,
+            )
+            .await;
+        }
+    }
+}
+
+async fn archive(db: &Path, command: &ArchiveCommand) -> anyhow::Result<()> {
+    let pool = setup_database(db).await?;
+
+    let started_before = Utc::now() - Duration::days(365 * i64::from(command.older_than_years));
+
+    let result =
+        dal::archive_workouts(&pool, started_before.timestamp(), command.delete_sets).await?;
+
+    info!(
+        archived_workouts = result.archived_workouts,
+        deleted_sets = result.deleted_sets,
+        "Archived workouts started before {}.",
+        started_before.date_naive()
+    );
+
+    Ok(())
+}
+
+async fn restore_workout(db: &Path, command: &RestoreCommand) -> anyhow::Result<()> {
+    let pool = setup_database(db).await?;
+
+    let workout = restore::restore_workout(&pool, &command.backup, command.workout_id).await?;
+
+    info!(
+        backup_workout_id = command.workout_id,
+        workout_id = workout.id,
+        "Restored workout."
+    );
+
+    Ok(())
+}
+
+async fn seed_exercises(db: &Path, command: &SeedExercisesCommand) -> anyhow::Result<()> {
+    let pool = setup_database(db).await?;
+    let mut tx = pool.begin().await.context("Failed to begin transaction")?;
+
+    let created = seed::seed_exercises(&mut tx).await?;
+
+    let workouts = match command.history_weeks {
+        Some(weeks) => {
+            let options = HistoryOptions {
+                weeks,
+                ..Default::default()
+            };
+            let history = fixtures::build_history(Utc::now().date_naive(), &options);
+            fixtures::insert_history(&mut tx, &history).await?;
+            history.len()
+        }
+        None => 0,
+    };
+
+    tx.commit().await.context("Failed to commit transaction")?;
+
+    info!(created, workouts, "Seeded exercises.");
+
+    Ok(())
+}
+
+async fn import_wger(db: &Path, command: &ImportWgerCommand) -> anyhow::Result<()> {
+    let pool = setup_database(db).await?;
+
+    let result = wger::import(&pool, &command.url).await?;
+
+    info!(
+        created = result.created,
+        updated = result.updated,
+        skipped = result.skipped,
+        "Imported exercises from wger."
+    );
 
-    server::run(&args.addr, pool).await;
+    Ok(())
 }
 
 fn setup_tracing() {
@@ -43,8 +410,10 @@ fn setup_tracing() {
         std::env::set_var("RUST_LOG", "server=trace,tower_http=trace");
     }
 
+    // Log to stderr, so the output of commands can be piped.
     tracing_subscriber::fmt()
         .with_env_filter(EnvFilter::from_default_env())
+        .with_writer(std::io::stderr)
         .init();
 }
 
@@ -0,0 +1,100 @@
+use std::{
+    collections::{BTreeMap, VecDeque},
+    sync::{Arc, Mutex},
+};
+
+use chrono::{DateTime, Duration, Utc};
+
+/// Errors older than this are forgotten.
+pub const ERROR_WINDOW_S: i64 = 60 * 60;
+
+/// Upper bound of remembered errors so a failing instance can't run out of
+/// memory, the oldest errors are dropped first.
+const MAX_ERRORS: usize = 10_000;
+
+/// Number of most recent errors returned with their details.
+const MAX_SAMPLES: usize = 20;
+
+/// A response with a server error status.
+#[derive(Debug, Clone)]
+pub struct ErrorEvent {
+    pub method: String,
+    /// The matched route, e.g. `/api/workouts/:id`, so errors of the same
+    /// endpoint are counted together.
+    pub route: String,
+    pub status: u16,
+    pub message: Option<String>,
+    pub occurred: DateTime<Utc>,
+}
+
+#[derive(Debug)]
+pub struct RouteErrorCount {
+    pub method: String,
+    pub route: String,
+    pub count: usize,
+}
+
+#[derive(Debug)]
+pub struct ErrorReport {
+    pub total: usize,
+    /// Routes with the most errors first.
+    pub routes: Vec<RouteErrorCount>,
+    /// The most recent errors first.
+    pub samples: Vec<ErrorEvent>,
+}
+
+/// Server errors of the last hour kept in memory, so problems surface
+/// without an external monitoring stack. Restarting the server resets it.
+#[derive(Debug, Clone, Default)]
+pub struct ErrorLog {
+    events: Arc<Mutex<VecDeque<ErrorEvent>>>,
+}
+
+impl ErrorLog {
+    pub fn record(&self, event: ErrorEvent) {
+        let mut events = self.events.lock().expect("Error log must not be poisoned");
+        prune(&mut events, event.occurred);
+        if events.len() == MAX_ERRORS {
+            events.pop_front();
+        }
+        events.push_back(event);
+    }
+
+    pub fn report(&self, now: DateTime<Utc>) -> ErrorReport {
+        let mut events = self.events.lock().expect("Error log must not be poisoned");
+        prune(&mut events, now);
+
+        let mut counts = BTreeMap::new();
+        for event in events.iter() {
+            *counts
+                .entry((event.method.clone(), event.route.clone()))
+                .or_insert(0) += 1;
+        }
+
+        let mut routes = counts
+            .into_iter()
+            .map(|((method, route), count)| RouteErrorCount {
+                method,
+                route,
+                count,
+            })
+            .collect::<Vec<_>>();
+        routes.sort_by(|a, b| b.count.cmp(&a.count));
+
+        ErrorReport {
+            total: events.len(),
+            routes,
+            samples: events.iter().rev().take(MAX_SAMPLES).cloned().collect(),
+        }
+    }
+}
+
+fn prune(events: &mut VecDeque<ErrorEvent>, now: DateTime<Utc>) {
+    let oldest = now - Duration::seconds(ERROR_WINDOW_S);
+    while events
+        .front()
+        .map_or(false, |event| event.occurred < oldest)
+    {
+        events.pop_front();
+    }
+}
@@ -0,0 +1,316 @@
+use std::process::Stdio;
+
+use anyhow::{bail, Context, Result};
+use serde::{Deserialize, Serialize};
+use serde_json::json;
+use sqlx::{Pool, Sqlite};
+use tokio::{io::AsyncWriteExt, process::Command};
+use tracing::{error, warn};
+
+use crate::dal::{self, NotificationChannelEntity, NotificationEvent};
+
+/// Replaces secrets of transports shown to clients.
+const REDACTED: &str = "********";
+
+/// A message about something that happened, delivered to every channel the
+/// event is routed to.
+#[derive(Debug, Clone, Serialize)]
+pub struct Notification {
+    pub event: NotificationEvent,
+    pub title: String,
+    pub message: String,
+}
+
+/// How notifications of a channel are delivered, together with the settings
+/// of the transport. Stored as JSON so new transports need no migration.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "lowercase")]
+pub enum Transport {
+    /// Sends mails with the local `sendmail` binary.
+    Email { to: String },
+    Ntfy {
+        /// Base URL of the ntfy server, e.g. `https://ntfy.sh`.
+        url: String,
+        topic: String,
+    },
+    Gotify {
+        /// Base URL of the Gotify server.
+        url: String,
+        token: String,
+    },
+    /// Posts the notification as JSON, or the rendered template if given.
+    Webhook {
+        url: String,
+        /// JSON payload with `{{event}}`, `{{title}}` and `{{message}}`
+        /// placeholders, e.g. for a Discord embed.
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        template: Option<String>,
+    },
+    Telegram {
+        #[serde(rename = "botToken")]
+        bot_token: String,
+        #[serde(rename = "chatId")]
+        chat_id: String,
+    },
+}
+
+impl Transport {
+    pub fn from_channel(channel: &NotificationChannelEntity) -> Result<Self> {
+        serde_json::from_str(&channel.transport).with_context(|| {
+            format!(
+                "Failed to parse transport of notification channel with id {}",
+                channel.id
+            )
+        })
+    }
+
+    /// Copy of the transport that can be shown to clients, with tokens and
+    /// the paths of webhook URLs, which usually carry a secret, masked.
+    pub fn redacted(&self) -> Self {
+        match self {
+            Self::Gotify { url, .. } => Self::Gotify {
+                url: url.clone(),
+                token: REDACTED.to_string(),
+            },
+            Self::Webhook { url, template } => Self::Webhook {
+                url: redact_url(url),
+                template: template.clone(),
+            },
+            Self::Telegram { chat_id, .. } => Self::Telegram {
+                bot_token: REDACTED.to_string(),
+                chat_id: chat_id.clone(),
+            },
+            transport => transport.clone(),
+        }
+    }
+
+    pub async fn send(&self, client: &reqwest::Client, notification: &Notification) -> Result<()> {
+        let Notification { title, message, .. } = notification;
+
+        let request = match self {
+            Self::Email { to } => return send_mail(to, title, message).await,
+            Self::Ntfy { url, topic } => client
+                .post(format!("{}/{topic}", url.trim_end_matches('/')))
+                .header("Title", title)
+                .body(message.clone()),
+            Self::Gotify { url, token } => client
+                .post(format!("{}/message", url.trim_end_matches('/')))
+                .header("X-Gotify-Key", token)
+                .json(&json!({ "title": title, "message": message })),
+            Self::Webhook {
+                url,
+                template: None,
+            } => client.post(url).json(notification),
+            Self::Webhook {
+                url,
+                template: Some(template),
+            } => client
+                .post(url)
+                .json(&render_template(template, notification)?),
+            Self::Telegram { bot_token, chat_id } => client
+                .post(format!(
+                    "https://api.telegram.org/bot{bot_token}/sendMessage"
+                ))
+                .json(&json!({ "chat_id": chat_id, "text": format!("{title}\n\n{message}") })),
+        };
+
+        request
+            .send()
+            .await
+            .context("Failed to send notification")?
+            .error_for_status()
+            .context("Notification was rejected")?;
+
+        Ok(())
+    }
+}
+
+/// Keeps the scheme and host of a URL, e.g. `https://discord.com/********`.
+fn redact_url(url: &str) -> String {
+    let host_start = url.find("://").map_or(0, |scheme_end| scheme_end + 3);
+    match url[host_start..].find('/') {
+        Some(path_start) => format!("{}/{REDACTED}", &url[..host_start + path_start]),
+        None => url.to_string(),
+    }
+}
+
+/// Whether the address is a single plain `local@domain` address, so it can
+/// neither add recipients nor break out of a mail header.
+pub fn is_mail_address(address: &str) -> bool {
+    let Some((local, domain)) = address.split_once('@') else {
+        return false;
+    };
+
+    !local.is_empty()
+        && !domain.is_empty()
+        && !address.starts_with('-')
+        && !domain.contains('@')
+        && !address.chars().any(|c| {
+            c.is_whitespace() || c.is_control() || matches!(c, ',' | ';' | '<' | '>' | '"')
+        })
+}
+
+/// Replaces the placeholders of a webhook template. Values are JSON escaped
+/// without quotes, so placeholders belong inside of JSON strings.
+pub fn render_template(template: &str, notification: &Notification) -> Result<serde_json::Value> {
+    let event = serde_json::to_value(notification.event).context("Failed to encode event")?;
+
+    let mut rendered = String::with_capacity(template.len());
+    let mut rest = template;
+    while let Some(start) = rest.find("{{") {
+        rendered.push_str(&rest[..start]);
+
+        let end = rest[start..]
+            .find("}}")
+            .map(|end| start + end)
+            .context("Placeholder is not closed")?;
+
+        let value = match rest[start + 2..end].trim() {
+            "event" => event.as_str().unwrap_or_default(),
+            "title" => notification.title.as_str(),
+            "message" => notification.message.as_str(),
+            name => bail!("Unknown placeholder {name}"),
+        };
+        let quoted = serde_json::Value::from(value).to_string();
+        rendered.push_str(&quoted[1..quoted.len() - 1]);
+
+        rest = &rest[end + 2..];
+    }
+    rendered.push_str(rest);
+
+    serde_json::from_str(&rendered).context("Rendered template is no valid JSON")
+}
+
+/// The recipient is passed as argument instead of being read from the
+/// headers. Line breaks in the subject, e.g. from exercise names, are folded
+/// into spaces so they can't add headers.
+async fn send_mail(to: &str, subject: &str, body: &str) -> Result<()> {
+    if !is_mail_address(to) {
+        bail!("Invalid mail address {to:?}");
+    }
+    let subject = subject.replace(['\r', '\n'], " ");
+
+    let mut sendmail = Command::new("sendmail")
+        .arg("--")
+        .arg(to)
+        .stdin(Stdio::piped())
+        .spawn()
+        .context("Failed to start sendmail")?;
+
+    let mail = format!(
+        "To: {to}\nSubject: {subject}\nContent-Type: text/plain; charset=utf-8\n\n{body}\n"
+    );
+    sendmail
+        .stdin
+        .take()
+        .context("Failed to open stdin of sendmail")?
+        .write_all(mail.as_bytes())
+        .await
+        .context("Failed to write mail to sendmail")?;
+
+    let status = sendmail
+        .wait()
+        .await
+        .context("Failed to wait for sendmail")?;
+    if !status.success() {
+        bail!("sendmail exited with {status}");
+    }
+
+    Ok(())
+}
+
+/// Delivers notifications in the background, so failing channels never fail
+/// or delay the request that caused a notification.
+#[derive(Debug, Clone)]
+pub struct Notifier {
+    pool: Pool<Sqlite>,
+    client: reqwest::Client,
+}
+
+impl Notifier {
+    pub fn new(pool: Pool<Sqlite>) -> Self {
+        Self {
+            pool,
+            client: reqwest::Client::new(),
+        }
+    }
+
+    pub fn client(&self) -> &reqwest::Client {
+        &self.client
+    }
+
+    pub fn notify(&self, notification: Notification) {
+        let notifier = self.clone();
+        tokio::spawn(async move {
+            if let Err(err) = notifier.deliver(&notification).await {
+                error!(err = format!("{err:#}"), "Failed to deliver notification.");
+            }
+        });
+    }
+
+    async fn deliver(&self, notification: &Notification) -> Result<()> {
+        let channels =
+            dal::get_notification_channels_by_event(&self.pool, notification.event).await?;
+
+        for channel in channels {
+            let result = match Transport::from_channel(&channel) {
+                Ok(transport) => transport.send(&self.client, notification).await,
+                Err(err) => Err(err),
+            };
+
+            if let Err(err) = result {
+                warn!(
+                    err = format!("{err:#}"),
+                    channel = channel.id,
+                    "Failed to notify channel."
+                );
+            }
+        }
+
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn notification() -> Notification {
+        Notification {
+            event: NotificationEvent::PersonalRecord,
+            title: r#"New "record""#.to_string(),
+            message: "Bench Press\n100 kg".to_string(),
+        }
+    }
+
+    #[test]
+    fn renders_escaped_placeholders() {
+        let rendered = render_template(
+            r#"{"content": "{{ title }}: {{message}}", "event": "{{event}}"}"#,
+            &notification(),
+        )
+        .expect("Template must render");
+
+        assert_eq!(
+            rendered,
+            json!({
+                "content": "New \"record\": Bench Press\n100 kg",
+                "event": "personal_record",
+            })
+        );
+    }
+
+    #[test]
+    fn rejects_invalid_templates() {
+        for template in [
+            r#"{"content": "{{user}}"}"#,
+            r#"{"content": "{{title"}"#,
+            "{{title}}",
+        ] {
+            assert!(
+                render_template(template, &notification()).is_err(),
+                "{template}"
+            );
+        }
+    }
+}
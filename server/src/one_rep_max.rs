@@ -0,0 +1,155 @@
+use chrono::{DateTime, Utc};
+use serde::Deserialize;
+
+use crate::dal::SessionSetEntity;
+
+/// Formulas to estimate the one-repetition maximum from a set, all of them
+/// return the weight itself for a single repetition.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Formula {
+    #[default]
+    Epley,
+    Brzycki,
+    Lombardi,
+}
+
+impl Formula {
+    pub fn estimate(self, weight: i64, repetitions: i64) -> Option<f64> {
+        if weight <= 0 || repetitions <= 0 {
+            return None;
+        }
+
+        let weight = weight as f64;
+        let reps = repetitions as f64;
+
+        match (self, repetitions) {
+            (_, 1) => Some(weight),
+            (Self::Epley, _) => Some(weight * (1.0 + reps / 30.0)),
+            // Diverges towards 37 repetitions.
+            (Self::Brzycki, 37..) => None,
+            (Self::Brzycki, _) => Some(weight * 36.0 / (37.0 - reps)),
+            (Self::Lombardi, _) => Some(weight * reps.powf(0.1)),
+        }
+    }
+}
+
+/// Estimates the one-repetition maximum of a set using the Epley formula.
+pub fn estimate(weight: i64, repetitions: i64) -> Option<f64> {
+    Formula::Epley.estimate(weight, repetitions)
+}
+
+/// Best estimate of a session.
+#[derive(Debug)]
+pub struct SessionEstimate {
+    pub workout_id: i64,
+    pub started: DateTime<Utc>,
+    pub estimate: f64,
+}
+
+/// Returns the best estimate of every session, the sets must be ordered by
+/// session.
+pub fn series(sets: &[SessionSetEntity], formula: Formula) -> Vec<SessionEstimate> {
+    let mut series: Vec<SessionEstimate> = Vec::new();
+
+    for set in sets {
+        let Some(estimate) = formula.estimate(set.weight, set.repetitions) else {
+            continue;
+        };
+
+        match series.last_mut() {
+            Some(last) if last.workout_id == set.workout_id => {
+                last.estimate = last.estimate.max(estimate);
+            }
+            _ => series.push(SessionEstimate {
+                workout_id: set.workout_id,
+                started: set.started,
+                estimate,
+            }),
+        }
+    }
+
+    series
+}
+
+/// Returns the best estimated one-repetition maximum of the given sets.
+pub fn best<I>(sets: I) -> Option<f64>
+where
+    I: IntoIterator<Item = (i64, i64)>,
+{
+    sets.into_iter()
+        .filter_map(|(weight, repetitions)| estimate(weight, repetitions))
+        .reduce(f64::max)
+}
+
+#[cfg(test)]
+mod tests {
+    use chrono::NaiveDate;
+
+    use super::*;
+    use crate::fixtures::{self, HistoryOptions};
+
+    #[test]
+    fn single_repetitions_estimate_the_weight() {
+        for formula in [Formula::Epley, Formula::Brzycki, Formula::Lombardi] {
+            assert_eq!(formula.estimate(100, 1), Some(100.0));
+        }
+    }
+
+    #[test]
+    fn estimates_with_the_formula() {
+        assert_eq!(estimate(90, 10), Some(120.0));
+        assert_eq!(
+            Formula::Brzycki.estimate(100, 10),
+            Some(100.0 * 36.0 / 27.0)
+        );
+        assert_eq!(
+            Formula::Lombardi.estimate(100, 10),
+            Some(100.0 * 10f64.powf(0.1))
+        );
+    }
+
+    #[test]
+    fn rejects_empty_and_diverging_sets() {
+        assert_eq!(estimate(0, 5), None);
+        assert_eq!(estimate(100, 0), None);
+        assert_eq!(Formula::Brzycki.estimate(100, 37), None);
+    }
+
+    #[test]
+    fn best_of_sets() {
+        assert_eq!(best([(100, 1), (90, 10), (0, 5)]), Some(120.0));
+        assert_eq!(best(Vec::<(i64, i64)>::new()), None);
+    }
+
+    #[test]
+    fn series_has_the_best_estimate_of_every_session() {
+        let end = NaiveDate::from_ymd_opt(2023, 11, 5).expect("Date must be valid");
+        let workouts = fixtures::build_history(end, &HistoryOptions::default());
+        let sets = fixtures::session_sets(&workouts)
+            .into_iter()
+            .filter(|set| set.exercise_name == "Back Squat")
+            .collect::<Vec<_>>();
+
+        let series = series(&sets, Formula::Epley);
+
+        let sessions = workouts
+            .iter()
+            .filter(|workout| {
+                workout
+                    .sets
+                    .iter()
+                    .any(|set| set.exercise_name == "Back Squat")
+            })
+            .count();
+        assert_eq!(series.len(), sessions);
+        for session in &series {
+            let session_best = best(
+                sets.iter()
+                    .filter(|set| set.workout_id == session.workout_id)
+                    .map(|set| (set.weight, set.repetitions)),
+            );
+            assert_eq!(Some(session.estimate), session_best);
+        }
+    }
+}
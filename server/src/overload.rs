@@ -0,0 +1,230 @@
+use anyhow::Result;
+use sqlx::SqliteExecutor;
+
+use crate::{
+    dal::{self, ExerciseEntity, HistorySetEntity, SetSuggestionEntity},
+    insights,
+};
+
+/// Sessions of an exercise that are looked at to recommend its next set.
+const HISTORY_SESSIONS: i64 = 3;
+
+/// Consecutive sessions with missed targets after which the weight is
+/// reduced instead of tried again.
+const MISSES_BEFORE_DELOAD: usize = 2;
+
+/// Percentage of the weight used after repeated misses.
+const DELOAD_PERCENT: i64 = 90;
+
+/// Suggests the next set of a workout, see
+/// `dal::get_set_suggestion_for_workout`. An exercise without a set in the
+/// workout gets a recommendation based on its recent sessions. An exercise id
+/// of 0 means there was nothing to base the suggestion on.
+pub async fn suggest<'local, E>(
+    conn: E,
+    workout_id: i64,
+    exercise_id: Option<i64>,
+    gym_id: Option<i64>,
+) -> Result<SetSuggestionEntity>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    if let Some(set) =
+        dal::get_set_suggestion_for_workout(conn, workout_id, exercise_id, gym_id).await?
+    {
+        return Ok(set);
+    }
+
+    if let Some(exercise_id) = exercise_id {
+        if let Some(exercise) = dal::get_exercise(conn, exercise_id).await? {
+            let history =
+                dal::get_recent_exercise_history(conn, exercise_id, HISTORY_SESSIONS).await?;
+            if let Some(set) = recommend(&history, &exercise) {
+                return Ok(set);
+            }
+        }
+    }
+
+    Ok(SetSuggestionEntity {
+        exercise_id: exercise_id.unwrap_or(0),
+        repetitions: 0,
+        weight: None,
+        added_weight: None,
+        duration_s: None,
+    })
+}
+
+/// Recommends the first set of the next session of an exercise based on the
+/// sets of its recent sessions, most recent session first. The first set of
+/// the last session is progressed if all of its sets hit their targets.
+/// After a miss its targets are tried again, after repeated misses the weight
+/// is reduced. Returns `None` without history.
+pub fn recommend(
+    sets: &[HistorySetEntity],
+    exercise: &ExerciseEntity,
+) -> Option<SetSuggestionEntity> {
+    let sessions = insights::chunk_by(sets, |set| set.workout_id);
+    let last = sessions.first()?.first()?;
+    let set = SetSuggestionEntity {
+        exercise_id: exercise.id,
+        repetitions: last.repetitions,
+        weight: last.weight,
+        added_weight: last.added_weight,
+        duration_s: last.duration_s,
+    };
+
+    let misses = sessions
+        .iter()
+        .take_while(|sets| !targets_met(sets, exercise))
+        .count();
+
+    Some(match misses {
+        0 => progress(set, exercise),
+        n if n >= MISSES_BEFORE_DELOAD => deload(retry(set, last), exercise),
+        _ => retry(set, last),
+    })
+}
+
+/// Sets without a target need to reach the bottom of the repetition range
+/// of the exercise, if it has one.
+fn targets_met(sets: &[HistorySetEntity], exercise: &ExerciseEntity) -> bool {
+    sets.iter().all(|set| {
+        let repetitions = set.target_repetitions.or(exercise.min_repetitions);
+        repetitions.map_or(true, |target| set.repetitions >= target)
+            && set
+                .target_weight
+                .map_or(true, |target| set.weight.unwrap_or(0) >= target)
+    })
+}
+
+/// Progresses the first set of the previous session of an exercise. Within
+/// the repetition range of the exercise repetitions go up by one, once the
+/// top is reached the weight goes up by the increment and repetitions start
+/// at the bottom of the range again. Without a range the weight goes up every
+/// session, timed sets never change.
+fn progress(mut set: SetSuggestionEntity, exercise: &ExerciseEntity) -> SetSuggestionEntity {
+    if set.duration_s.is_some() {
+        return set;
+    }
+
+    let increase_weight = |set: &mut SetSuggestionEntity| {
+        if let (Some(weight), Some(increment)) = (set.weight, exercise.default_increment) {
+            set.weight = Some(weight + increment);
+        }
+    };
+
+    match (exercise.min_repetitions, exercise.max_repetitions) {
+        (min, Some(max)) if set.repetitions >= max => {
+            increase_weight(&mut set);
+            set.repetitions = min.unwrap_or(max);
+        }
+        (Some(min), _) if set.repetitions < min => set.repetitions = min,
+        (_, Some(_)) => set.repetitions += 1,
+        (_, None) => increase_weight(&mut set),
+    }
+
+    set
+}
+
+/// Aims for the targets of the set again, or what was done if it had none.
+fn retry(mut set: SetSuggestionEntity, last: &HistorySetEntity) -> SetSuggestionEntity {
+    set.repetitions = last.target_repetitions.unwrap_or(last.repetitions);
+    set.weight = last.target_weight.or(last.weight);
+    set
+}
+
+/// Reduces the weight, rounded down to a multiple of the increment of the
+/// exercise so it can still be loaded.
+fn deload(mut set: SetSuggestionEntity, exercise: &ExerciseEntity) -> SetSuggestionEntity {
+    set.weight = set.weight.map(|weight| {
+        let weight = weight * DELOAD_PERCENT / 100;
+        match exercise.default_increment {
+            Some(increment) if increment > 0 => weight - weight % increment,
+            _ => weight,
+        }
+    });
+    set
+}
+
+#[cfg(test)]
+mod tests {
+    use chrono::NaiveDate;
+
+    use super::*;
+    use crate::fixtures::{self, HistoryOptions, WorkoutFixture};
+
+    fn history(options: &HistoryOptions) -> Vec<WorkoutFixture> {
+        let end = NaiveDate::from_ymd_opt(2023, 11, 5).expect("Date must be valid");
+        fixtures::build_history(end, options)
+    }
+
+    fn steady() -> HistoryOptions {
+        HistoryOptions {
+            deload_every_weeks: None,
+            stall_after_weeks: None,
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn nothing_without_history() {
+        let workouts = history(&steady());
+        let exercise = fixtures::exercise_entity(&workouts, "Back Squat");
+
+        assert!(recommend(&[], &exercise).is_none());
+    }
+
+    #[test]
+    fn progresses_after_met_targets() {
+        let workouts = history(&steady());
+        let exercise = fixtures::exercise_entity(&workouts, "Back Squat");
+        let sets = fixtures::history_sets(&workouts, "Back Squat", HISTORY_SESSIONS as usize);
+        let last = &sets[0];
+
+        let set = recommend(&sets, &exercise).expect("Set must be recommended");
+
+        assert_eq!(set.exercise_id, exercise.id);
+        assert_eq!(set.repetitions, last.repetitions);
+        assert_eq!(
+            set.weight,
+            Some(last.weight.unwrap() + exercise.default_increment.unwrap())
+        );
+    }
+
+    #[test]
+    fn retries_after_a_miss() {
+        let workouts = history(&steady());
+        let exercise = fixtures::exercise_entity(&workouts, "Bench Press");
+        let mut sets = fixtures::history_sets(&workouts, "Bench Press", HISTORY_SESSIONS as usize);
+        let last_workout_id = sets[0].workout_id;
+        for set in sets
+            .iter_mut()
+            .filter(|set| set.workout_id == last_workout_id)
+        {
+            set.repetitions -= 1;
+        }
+
+        let set = recommend(&sets, &exercise).expect("Set must be recommended");
+
+        assert_eq!(Some(set.repetitions), sets[0].target_repetitions);
+        assert_eq!(set.weight, sets[0].weight);
+    }
+
+    #[test]
+    fn deloads_a_stalled_lift() {
+        let workouts = history(&fixtures::STALLING);
+        let exercise = fixtures::exercise_entity(&workouts, "Bench Press");
+        let sets = fixtures::history_sets(&workouts, "Bench Press", HISTORY_SESSIONS as usize);
+        assert!(sets
+            .iter()
+            .all(|set| Some(set.repetitions) < set.target_repetitions));
+
+        let set = recommend(&sets, &exercise).expect("Set must be recommended");
+
+        let increment = exercise.default_increment.unwrap();
+        let weight = sets[0].weight.unwrap() * DELOAD_PERCENT / 100;
+        assert_eq!(set.weight, Some(weight - weight % increment));
+        assert_eq!(Some(set.repetitions), sets[0].target_repetitions);
+        assert!(set.weight < sets[0].weight);
+    }
+}
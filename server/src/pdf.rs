@@ -0,0 +1,153 @@
+use std::fmt::Write;
+
+/// A4 in points.
+const PAGE_WIDTH: f64 = 595.0;
+const PAGE_HEIGHT: f64 = 842.0;
+
+const MARGIN: f64 = 56.0;
+
+/// A minimal PDF document of text lines in Helvetica, which every reader
+/// provides, so no fonts need to be embedded. Lines continue on a new page
+/// once a page is full.
+#[derive(Debug, Default)]
+pub struct Document {
+    pages: Vec<String>,
+    y: f64,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Style {
+    Title,
+    Heading,
+    Text,
+}
+
+impl Style {
+    fn font(self) -> (&'static str, f64) {
+        match self {
+            Self::Title => ("F2", 20.0),
+            Self::Heading => ("F2", 13.0),
+            Self::Text => ("F1", 11.0),
+        }
+    }
+}
+
+impl Document {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn line(&mut self, style: Style, text: &str) {
+        let (font, size) = style.font();
+        let leading = size * 1.4;
+        // Headings get some space above them, but not at the top of a page.
+        let space = if style == Style::Text {
+            0.0
+        } else {
+            size * 0.6
+        };
+
+        if self.pages.is_empty() || self.y - space - leading < MARGIN {
+            self.pages.push(String::new());
+            self.y = PAGE_HEIGHT - MARGIN;
+        } else {
+            self.y -= space;
+        }
+        self.y -= leading;
+
+        let page = self.pages.last_mut().expect("Page must exist");
+        let _ = writeln!(
+            page,
+            "BT /{font} {size} Tf {MARGIN} {:.1} Td ({}) Tj ET",
+            self.y,
+            escape(text)
+        );
+    }
+
+    /// Renders the document, a document without lines has a single empty
+    /// page.
+    pub fn render(mut self) -> Vec<u8> {
+        if self.pages.is_empty() {
+            self.pages.push(String::new());
+        }
+
+        // Objects 1 to 4 are the catalog, the page tree and the fonts, every
+        // page is followed by its content stream.
+        let mut objects = vec![
+            "<< /Type /Catalog /Pages 2 0 R >>".as_bytes().to_vec(),
+            Vec::new(),
+            font_object("Helvetica"),
+            font_object("Helvetica-Bold"),
+        ];
+
+        let mut kids = Vec::new();
+        for content in &self.pages {
+            let page_id = objects.len() + 1;
+            kids.push(format!("{page_id} 0 R"));
+            objects.push(
+                format!(
+                    "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 {PAGE_WIDTH} {PAGE_HEIGHT}] \
+                     /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents {} 0 R >>",
+                    page_id + 1
+                )
+                .into_bytes(),
+            );
+
+            let content = encode(content);
+            let mut stream = format!("<< /Length {} >>\nstream\n", content.len()).into_bytes();
+            stream.extend(content);
+            stream.extend(b"\nendstream");
+            objects.push(stream);
+        }
+
+        objects[1] = format!(
+            "<< /Type /Pages /Kids [{}] /Count {} >>",
+            kids.join(" "),
+            kids.len()
+        )
+        .into_bytes();
+
+        let mut pdf = b"%PDF-1.4\n".to_vec();
+        let mut offsets = Vec::with_capacity(objects.len());
+        for (i, object) in objects.iter().enumerate() {
+            offsets.push(pdf.len());
+            pdf.extend(format!("{} 0 obj\n", i + 1).into_bytes());
+            pdf.extend(object);
+            pdf.extend(b"\nendobj\n");
+        }
+
+        let xref = pdf.len();
+        let mut trailer = format!("xref\n0 {}\n0000000000 65535 f \n", objects.len() + 1);
+        for offset in offsets {
+            let _ = writeln!(trailer, "{offset:010} 00000 n ");
+        }
+        let _ = write!(
+            trailer,
+            "trailer\n<< /Size {} /Root 1 0 R >>\nstartxref\n{xref}\n%%EOF\n",
+            objects.len() + 1
+        );
+        pdf.extend(trailer.into_bytes());
+
+        pdf
+    }
+}
+
+fn font_object(name: &str) -> Vec<u8> {
+    format!("<< /Type /Font /Subtype /Type1 /BaseFont /{name} /Encoding /WinAnsiEncoding >>")
+        .into_bytes()
+}
+
+fn escape(text: &str) -> String {
+    text.replace('\\', r"\\")
+        .replace('(', r"\(")
+        .replace(')', r"\)")
+}
+
+/// Encodes the content as Latin-1, which matches WinAnsiEncoding for most
+/// letters. Other characters are replaced.
+fn encode(content: &str) -> Vec<u8> {
+    content
+        .chars()
+        .map(|c| u8::try_from(u32::from(c)).unwrap_or(b'?'))
+        .collect()
+}
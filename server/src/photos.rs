@@ -0,0 +1,120 @@
+use std::{io::ErrorKind, path::PathBuf};
+
+use anyhow::{Context, Result};
+use axum::http::{header::AUTHORIZATION, HeaderMap};
+
+/// Storage of progress photos in a directory, one file per photo named after
+/// its id. Photos are only served to clients presenting the token of the
+/// instance, as they are more private than anything else stored.
+#[derive(Debug, Clone)]
+pub struct PhotoStorage {
+    dir: PathBuf,
+    token: String,
+    /// Maximum size of a single photo in bytes.
+    pub max_size: usize,
+}
+
+impl PhotoStorage {
+    pub fn new(dir: PathBuf, token: String, max_size: usize) -> Self {
+        Self {
+            dir,
+            token,
+            max_size,
+        }
+    }
+
+    /// Whether the request carries the token as `Authorization: Bearer`.
+    pub fn authorize(&self, headers: &HeaderMap) -> bool {
+        headers
+            .get(AUTHORIZATION)
+            .and_then(|value| value.to_str().ok())
+            .and_then(|value| value.strip_prefix("Bearer "))
+            .map_or(false, |token| {
+                constant_time_eq(token.as_bytes(), self.token.as_bytes())
+            })
+    }
+
+    pub async fn write(&self, id: i64, data: &[u8]) -> Result<()> {
+        tokio::fs::write(self.path(id), data)
+            .await
+            .with_context(|| format!("Failed to write progress photo with id {id}"))
+    }
+
+    /// Returns `None` if the file of the photo is missing.
+    pub async fn read(&self, id: i64) -> Result<Option<Vec<u8>>> {
+        match tokio::fs::read(self.path(id)).await {
+            Ok(data) => Ok(Some(data)),
+            Err(err) if err.kind() == ErrorKind::NotFound => Ok(None),
+            Err(err) => {
+                Err(err).with_context(|| format!("Failed to read progress photo with id {id}"))
+            }
+        }
+    }
+
+    /// A missing file counts as removed.
+    pub async fn remove(&self, id: i64) -> Result<()> {
+        match tokio::fs::remove_file(self.path(id)).await {
+            Err(err) if err.kind() != ErrorKind::NotFound => {
+                Err(err).with_context(|| format!("Failed to remove progress photo with id {id}"))
+            }
+            _ => Ok(()),
+        }
+    }
+
+    fn path(&self, id: i64) -> PathBuf {
+        self.dir.join(format!("photo-{id}"))
+    }
+}
+
+/// Image formats accepted for progress photos with their file extensions.
+/// Formats that can contain scripts, like SVG, are left out as photos are
+/// served from the origin of the application.
+const IMAGE_TYPES: [(&str, &str); 4] = [
+    ("image/jpeg", "jpg"),
+    ("image/png", "png"),
+    ("image/webp", "webp"),
+    ("image/heic", "heic"),
+];
+
+/// Video formats accepted for set attachments next to the image formats of
+/// progress photos.
+const VIDEO_TYPES: [&str; 3] = ["video/mp4", "video/webm", "video/quicktime"];
+
+/// Returns the accepted image type of a `Content-Type` header without its
+/// parameters, or `None` if it isn't accepted.
+pub fn image_type(content_type: &str) -> Option<&'static str> {
+    let media_type = media_type(content_type)?;
+    IMAGE_TYPES
+        .iter()
+        .find(|(image_type, _)| image_type.eq_ignore_ascii_case(media_type))
+        .map(|(image_type, _)| *image_type)
+}
+
+/// Like `image_type`, but also accepts the video formats of set attachments.
+pub fn attachment_type(content_type: &str) -> Option<&'static str> {
+    image_type(content_type).or_else(|| {
+        let media_type = media_type(content_type)?;
+        VIDEO_TYPES
+            .iter()
+            .find(|video_type| video_type.eq_ignore_ascii_case(media_type))
+            .copied()
+    })
+}
+
+fn media_type(content_type: &str) -> Option<&str> {
+    content_type.split(';').next().map(str::trim)
+}
+
+/// File extension of an accepted image type.
+pub fn extension(image_type: &str) -> Option<&'static str> {
+    IMAGE_TYPES
+        .iter()
+        .find(|(other, _)| *other == image_type)
+        .map(|(_, extension)| *extension)
+}
+
+/// Compares without returning early, so the time taken doesn't reveal how
+/// much of the token was guessed right.
+fn constant_time_eq(a: &[u8], b: &[u8]) -> bool {
+    a.len() == b.len() && a.iter().zip(b).fold(0, |acc, (x, y)| acc | (x ^ y)) == 0
+}
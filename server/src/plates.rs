@@ -0,0 +1,134 @@
+use serde::{Deserialize, Serialize};
+
+use crate::units::WeightUnit;
+
+/// Plates of a single weight available at a gym, counted over both sides of
+/// the bar.
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct PlateStock {
+    pub weight: f64,
+    pub count: i64,
+}
+
+/// Plates on a barbell as entered by a client, listed once for a single side
+/// of the bar. They are resolved with the bar and the plates of the gym.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PlateSelection {
+    #[serde(rename = "gymId")]
+    pub gym_id: i64,
+    #[serde(rename = "perSide")]
+    pub per_side: Vec<f64>,
+}
+
+impl PlateSelection {
+    /// Weights of plates the stock doesn't have enough of to load both sides,
+    /// each listed once.
+    pub fn missing_plates(&self, stock: &[PlateStock]) -> Vec<f64> {
+        let mut missing: Vec<f64> = Vec::new();
+        for &weight in &self.per_side {
+            let used = self
+                .per_side
+                .iter()
+                .filter(|&&other| other == weight)
+                .count() as i64;
+            let available = stock
+                .iter()
+                .find(|plates| plates.weight == weight)
+                .map_or(0, |plates| plates.count);
+            if 2 * used > available && !missing.contains(&weight) {
+                missing.push(weight);
+            }
+        }
+        missing
+    }
+}
+
+/// Load of a barbell given as its plates, which resolves to the total weight
+/// of a set. Plates are listed once for a single side of the bar.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PlateConfiguration {
+    /// Gym whose bar and plates were used, missing for configurations stored
+    /// before plates were taken from the gym.
+    #[serde(rename = "gymId", default, skip_serializing_if = "Option::is_none")]
+    pub gym_id: Option<i64>,
+    #[serde(rename = "barWeight")]
+    pub bar_weight: f64,
+    #[serde(rename = "perSide")]
+    pub per_side: Vec<f64>,
+    /// Unit of the bar and plate weights, set when the configuration is stored.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub unit: Option<WeightUnit>,
+}
+
+impl PlateConfiguration {
+    /// Total weight of the bar with the plates on both sides. It is rounded
+    /// to whole units when stored as the weight of a set, so the exact total
+    /// is only kept here.
+    pub fn total_weight(&self) -> f64 {
+        self.bar_weight + 2.0 * self.per_side.iter().sum::<f64>()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn stock() -> Vec<PlateStock> {
+        vec![
+            PlateStock {
+                weight: 20.0,
+                count: 2,
+            },
+            PlateStock {
+                weight: 10.0,
+                count: 4,
+            },
+            PlateStock {
+                weight: 1.25,
+                count: 2,
+            },
+        ]
+    }
+
+    #[test]
+    fn total_weight_keeps_fractions() {
+        let configuration = PlateConfiguration {
+            gym_id: Some(1),
+            bar_weight: 20.0,
+            per_side: vec![1.25],
+            unit: Some(WeightUnit::Kg),
+        };
+
+        assert_eq!(configuration.total_weight(), 22.5);
+    }
+
+    #[test]
+    fn selection_within_stock_is_complete() {
+        let selection = PlateSelection {
+            gym_id: 1,
+            per_side: vec![20.0, 10.0, 10.0, 1.25],
+        };
+
+        assert!(selection.missing_plates(&stock()).is_empty());
+    }
+
+    #[test]
+    fn missing_plates_are_listed_once() {
+        let selection = PlateSelection {
+            gym_id: 1,
+            per_side: vec![20.0, 20.0, 5.0, 10.0],
+        };
+
+        assert_eq!(selection.missing_plates(&stock()), [20.0, 5.0]);
+    }
+
+    #[test]
+    fn configurations_without_gym_still_decode() {
+        let configuration: PlateConfiguration =
+            serde_json::from_str(r#"{"barWeight": 20, "perSide": [25, 2.5]}"#)
+                .expect("Configuration must decode");
+
+        assert_eq!(configuration.gym_id, None);
+        assert_eq!(configuration.total_weight(), 75.0);
+    }
+}
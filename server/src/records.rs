@@ -0,0 +1,234 @@
+use std::collections::BTreeMap;
+
+use anyhow::Result;
+use chrono::{DateTime, Utc};
+use sqlx::{Pool, Sqlite};
+use tracing::error;
+
+use crate::{
+    dal::{self, ExerciseSetEntity, NotificationEvent, PrEventInput, PrKind, SetType},
+    notifications::{Notification, Notifier},
+    one_rep_max,
+};
+
+/// Personal records of an exercise within the considered sets.
+#[derive(Debug)]
+pub struct PersonalRecords {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    /// Most repetitions win between sets of the heaviest weight.
+    pub heaviest: Record,
+    pub best_one_rep_max: OneRepMaxRecord,
+    /// Most repetitions per weight, lightest weight first.
+    pub repetitions: Vec<Record>,
+}
+
+/// A set that set a record, the earliest one wins on ties.
+#[derive(Debug, Clone, Copy)]
+pub struct Record {
+    pub weight: i64,
+    pub repetitions: i64,
+    pub achieved: DateTime<Utc>,
+}
+
+#[derive(Debug, Clone, Copy)]
+pub struct OneRepMaxRecord {
+    pub estimate: f64,
+    pub set: Record,
+}
+
+/// Finds the records of every exercise of the given working sets. Sets must
+/// be sorted by their creation time, bodyweight sets are ignored.
+pub fn personal_records(sets: &[ExerciseSetEntity]) -> Vec<PersonalRecords> {
+    let mut records: BTreeMap<i64, PersonalRecords> = BTreeMap::new();
+    let mut repetitions: BTreeMap<(i64, i64), Record> = BTreeMap::new();
+
+    for set in sets {
+        let Some(weight) = set.weight else {
+            continue;
+        };
+        let Some(estimate) = one_rep_max::estimate(weight, set.repetitions) else {
+            continue;
+        };
+
+        let record = Record {
+            weight,
+            repetitions: set.repetitions,
+            achieved: set.created,
+        };
+
+        repetitions
+            .entry((set.exercise_id, weight))
+            .and_modify(|best| {
+                if record.repetitions > best.repetitions {
+                    *best = record;
+                }
+            })
+            .or_insert(record);
+
+        records
+            .entry(set.exercise_id)
+            .and_modify(|current| {
+                let heaviest = &current.heaviest;
+                if (weight, record.repetitions) > (heaviest.weight, heaviest.repetitions) {
+                    current.heaviest = record;
+                }
+                if estimate > current.best_one_rep_max.estimate {
+                    current.best_one_rep_max = OneRepMaxRecord {
+                        estimate,
+                        set: record,
+                    };
+                }
+            })
+            .or_insert_with(|| PersonalRecords {
+                exercise_id: set.exercise_id,
+                exercise_name: set.exercise_name.clone(),
+                heaviest: record,
+                best_one_rep_max: OneRepMaxRecord {
+                    estimate,
+                    set: record,
+                },
+                repetitions: Vec::new(),
+            });
+    }
+
+    for ((exercise_id, _), record) in repetitions {
+        if let Some(records) = records.get_mut(&exercise_id) {
+            records.repetitions.push(record);
+        }
+    }
+
+    let mut records = records.into_values().collect::<Vec<_>>();
+    records.sort_by(|a, b| a.exercise_name.cmp(&b.exercise_name));
+    records
+}
+
+/// Stores the records a written set beats and notifies about records it
+/// didn't have before, e.g. when a planned set gets completed. The first set
+/// of an exercise beats no record.
+pub async fn record_events(
+    pool: &Pool<Sqlite>,
+    notifier: &Notifier,
+    exercise_set: &ExerciseSetEntity,
+) -> Result<()> {
+    let events = find_beaten_records(pool, exercise_set).await?;
+    let new_kinds = dal::replace_pr_events(pool, exercise_set.id, &events).await?;
+
+    for event in events
+        .iter()
+        .filter(|event| new_kinds.contains(&event.kind))
+    {
+        notifier.notify(Notification {
+            event: NotificationEvent::PersonalRecord,
+            title: format!("New record in {}", exercise_set.exercise_name),
+            message: describe(exercise_set, event),
+        });
+    }
+
+    Ok(())
+}
+
+/// Re-evaluates the records of the sets of the same exercise created after
+/// the given set in the background, e.g. once it was deleted or changed, so
+/// records it beat or held are attributed as if it had always been like
+/// this. Records are replaced without notifications.
+pub fn spawn_reevaluation(pool: Pool<Sqlite>, exercise_set: ExerciseSetEntity) {
+    tokio::spawn(async move {
+        if let Err(err) = reevaluate_later_sets(&pool, &exercise_set).await {
+            error!(
+                err = format!("{err:#}"),
+                exercise_set = exercise_set.id,
+                "Failed to re-evaluate records."
+            );
+        }
+    });
+}
+
+async fn reevaluate_later_sets(
+    pool: &Pool<Sqlite>,
+    exercise_set: &ExerciseSetEntity,
+) -> Result<()> {
+    let sets = dal::get_exercise_sets_by_exercise_id(
+        pool,
+        exercise_set.exercise_id,
+        Some(exercise_set.created.timestamp()),
+        None,
+        None,
+    )
+    .await?;
+
+    for later in sets
+        .iter()
+        .filter(|set| (set.created, set.id) > (exercise_set.created, exercise_set.id))
+    {
+        let events = find_beaten_records(pool, later).await?;
+        dal::replace_pr_events(pool, later.id, &events).await?;
+    }
+
+    Ok(())
+}
+
+async fn find_beaten_records(
+    pool: &Pool<Sqlite>,
+    exercise_set: &ExerciseSetEntity,
+) -> Result<Vec<PrEventInput>> {
+    let (Some(weight), true) = (exercise_set.weight, exercise_set.completed) else {
+        return Ok(Vec::new());
+    };
+    if exercise_set.set_type == SetType::Warmup || exercise_set.duration_s.is_some() {
+        return Ok(Vec::new());
+    }
+    let Some(estimate) = one_rep_max::estimate(weight, exercise_set.repetitions) else {
+        return Ok(Vec::new());
+    };
+
+    let bests =
+        dal::get_exercise_bests(pool, exercise_set.exercise_id, exercise_set.id, weight).await?;
+
+    let mut events = Vec::new();
+    if let Some(previous) = bests.weight.filter(|&previous| weight > previous) {
+        events.push(PrEventInput {
+            kind: PrKind::Weight,
+            value: weight as f64,
+            previous_value: previous as f64,
+        });
+    }
+    if let Some(previous) = bests.one_rep_max.filter(|&previous| estimate > previous) {
+        events.push(PrEventInput {
+            kind: PrKind::OneRepMax,
+            value: estimate,
+            previous_value: previous,
+        });
+    }
+    if let Some(previous) = bests
+        .repetitions_at_weight
+        .filter(|&previous| exercise_set.repetitions > previous)
+    {
+        events.push(PrEventInput {
+            kind: PrKind::Repetitions,
+            value: exercise_set.repetitions as f64,
+            previous_value: previous as f64,
+        });
+    }
+
+    Ok(events)
+}
+
+fn describe(exercise_set: &ExerciseSetEntity, event: &PrEventInput) -> String {
+    match event.kind {
+        PrKind::Weight => format!(
+            "Heaviest weight of {}, previously {}.",
+            event.value, event.previous_value
+        ),
+        PrKind::OneRepMax => format!(
+            "Estimated 1RM of {:.1}, previously {:.1}.",
+            event.value, event.previous_value
+        ),
+        PrKind::Repetitions => format!(
+            "{} repetitions with {}, previously {}.",
+            event.value,
+            exercise_set.weight.unwrap_or_default(),
+            event.previous_value
+        ),
+    }
+}
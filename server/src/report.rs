@@ -0,0 +1,183 @@
+use std::{fmt::Write, path::Path, str::FromStr};
+
+use anyhow::{bail, Context, Result};
+use serde_json::{Map, Value};
+use sqlx::{
+    sqlite::{SqliteConnectOptions, SqlitePoolOptions, SqliteRow},
+    Column, Row,
+};
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum OutputFormat {
+    Json,
+    Csv,
+}
+
+impl FromStr for OutputFormat {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "json" => Ok(Self::Json),
+            "csv" => Ok(Self::Csv),
+            other => Err(format!(
+                r#"unknown output format "{other}", expected json or csv"#
+            )),
+        }
+    }
+}
+
+/// Runs a single read-only query against the database and renders the
+/// resulting rows in the given format.
+///
+/// Only `SELECT` and `WITH` statements are accepted, and the database is
+/// opened read-only on top of that, so reports can never modify data.
+pub async fn run_query(db: &Path, sql: &str, format: OutputFormat) -> Result<String> {
+    check_query(sql)?;
+
+    let pool = SqlitePoolOptions::new()
+        .max_connections(1)
+        .connect_with(SqliteConnectOptions::new().filename(db).read_only(true))
+        .await
+        .with_context(|| format!("Failed to open database {} read-only", db.display()))?;
+
+    let rows = sqlx::query(sql)
+        .fetch_all(&pool)
+        .await
+        .context("Failed to run report query")?;
+
+    let columns = rows
+        .first()
+        .map(|row| {
+            row.columns()
+                .iter()
+                .map(|column| column.name().to_string())
+                .collect::<Vec<_>>()
+        })
+        .unwrap_or_default();
+
+    let values = rows.iter().map(row_values).collect::<Vec<_>>();
+
+    Ok(match format {
+        OutputFormat::Json => render_json(&columns, values)?,
+        OutputFormat::Csv => render_csv(&columns, &values),
+    })
+}
+
+fn check_query(sql: &str) -> Result<()> {
+    let sql = sql.trim().trim_end_matches(';');
+
+    if sql.contains(';') {
+        bail!("Only a single statement is allowed");
+    }
+
+    let keyword = sql
+        .split_whitespace()
+        .next()
+        .unwrap_or_default()
+        .to_ascii_uppercase();
+
+    if keyword != "SELECT" && keyword != "WITH" {
+        bail!("Only SELECT and WITH statements are allowed");
+    }
+
+    Ok(())
+}
+
+fn row_values(row: &SqliteRow) -> Vec<Value> {
+    (0..row.len())
+        .map(|i| {
+            if let Ok(value) = row.try_get::<Option<i64>, _>(i) {
+                return value.map(Value::from).unwrap_or_default();
+            }
+            if let Ok(value) = row.try_get::<Option<bool>, _>(i) {
+                return value.map(Value::from).unwrap_or_default();
+            }
+            if let Ok(value) = row.try_get::<Option<f64>, _>(i) {
+                return value.map(Value::from).unwrap_or_default();
+            }
+            if let Ok(value) = row.try_get::<Option<String>, _>(i) {
+                return value.map(Value::from).unwrap_or_default();
+            }
+            if let Ok(value) = row.try_get::<Option<Vec<u8>>, _>(i) {
+                return value
+                    .map(|bytes| {
+                        Value::from(bytes.iter().fold(String::new(), |mut hex, b| {
+                            let _ = write!(hex, "{b:02x}");
+                            hex
+                        }))
+                    })
+                    .unwrap_or_default();
+            }
+            Value::Null
+        })
+        .collect()
+}
+
+fn render_json(columns: &[String], rows: Vec<Vec<Value>>) -> Result<String> {
+    let rows = rows
+        .into_iter()
+        .map(|values| columns.iter().cloned().zip(values).collect::<Map<_, _>>())
+        .collect::<Vec<_>>();
+
+    serde_json::to_string_pretty(&rows).context("Failed to serialize report as JSON")
+}
+
+pub fn render_csv(columns: &[String], rows: &[Vec<Value>]) -> String {
+    fn escape(field: &str) -> String {
+        if field.contains([',', '"', '\n', '\r']) {
+            format!(r#""{}""#, field.replace('"', r#""""#))
+        } else {
+            field.to_string()
+        }
+    }
+
+    let mut csv = String::new();
+
+    let header = columns.iter().map(|c| escape(c)).collect::<Vec<_>>();
+    csv.push_str(&header.join(","));
+    csv.push('\n');
+
+    for values in rows {
+        let fields = values
+            .iter()
+            .map(|value| match value {
+                Value::Null => String::new(),
+                Value::String(s) => escape(s),
+                other => other.to_string(),
+            })
+            .collect::<Vec<_>>();
+        csv.push_str(&fields.join(","));
+        csv.push('\n');
+    }
+
+    csv
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn accepts_single_reads() {
+        for sql in [
+            "SELECT 1",
+            "select name from exercise;",
+            "  WITH recent AS (SELECT * FROM workout) SELECT COUNT(*) FROM recent ;",
+        ] {
+            assert!(check_query(sql).is_ok(), "{sql}");
+        }
+    }
+
+    #[test]
+    fn rejects_writes_and_multiple_statements() {
+        for sql in [
+            "",
+            "DELETE FROM workout",
+            "SELECT 1; DROP TABLE workout",
+            "PRAGMA foreign_keys = OFF",
+        ] {
+            assert!(check_query(sql).is_err(), "{sql}");
+        }
+    }
+}
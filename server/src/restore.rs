@@ -0,0 +1,46 @@
+use std::path::Path;
+
+use anyhow::{Context, Result};
+use sqlx::{
+    sqlite::{SqliteConnectOptions, SqlitePoolOptions},
+    Pool, Sqlite,
+};
+
+use crate::dal::{self, ExerciseFilterInput, PageInput, WorkoutEntity};
+
+/// Copies a single workout with its sets from a backup database into the live
+/// database, e.g. to recover an accidentally deleted session.
+///
+/// The backup is opened read-only and has to be on the same schema version as
+/// the live database.
+pub async fn restore_workout(
+    pool: &Pool<Sqlite>,
+    backup: &Path,
+    workout_id: i64,
+) -> Result<WorkoutEntity> {
+    let backup_pool = SqlitePoolOptions::new()
+        .max_connections(1)
+        .connect_with(SqliteConnectOptions::new().filename(backup).read_only(true))
+        .await
+        .with_context(|| format!("Failed to open backup {} read-only", backup.display()))?;
+
+    let workout = dal::get_workout(&backup_pool, workout_id)
+        .await?
+        .with_context(|| format!("Workout with id {workout_id} not found in backup"))?;
+
+    let exercise_sets =
+        dal::get_exercise_sets_by_workout_id(&backup_pool, workout_id, None, PageInput::default())
+            .await?;
+
+    let exercises = dal::get_exercises(&backup_pool, &ExerciseFilterInput::default())
+        .await?
+        .into_iter()
+        .filter(|exercise| {
+            exercise_sets
+                .iter()
+                .any(|set| set.exercise_id == exercise.id)
+        })
+        .collect::<Vec<_>>();
+
+    dal::restore_workout(pool, &workout, &exercises, &exercise_sets).await
+}
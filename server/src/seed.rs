@@ -0,0 +1,67 @@
+use std::collections::HashSet;
+
+use anyhow::{Context, Result};
+use serde::Deserialize;
+use sqlx::{Sqlite, Transaction};
+
+use crate::dal::{self, ExerciseCategory, ExerciseFilterInput, ExerciseInput, ExerciseModality};
+
+/// Curated exercises shipped with the binary, so a fresh database isn't empty.
+static CATALOG: &str = include_str!("../seed/exercises.json");
+
+#[derive(Debug, Deserialize)]
+struct CatalogExercise {
+    name: String,
+    modality: Option<ExerciseModality>,
+    category: Option<ExerciseCategory>,
+    #[serde(rename = "primaryMuscles")]
+    primary_muscles: Vec<String>,
+    #[serde(rename = "secondaryMuscles")]
+    secondary_muscles: Vec<String>,
+    equipment: Vec<String>,
+}
+
+impl From<CatalogExercise> for ExerciseInput {
+    fn from(value: CatalogExercise) -> Self {
+        Self {
+            name: value.name,
+            modality: value.modality,
+            category: value.category,
+            description: None,
+            instructions: None,
+            video_url: None,
+            default_increment: None,
+            min_repetitions: None,
+            max_repetitions: None,
+            primary_muscles: Some(value.primary_muscles),
+            secondary_muscles: Some(value.secondary_muscles),
+            equipment: Some(value.equipment),
+            tags: None,
+        }
+    }
+}
+
+/// Creates the exercises of the catalog that don't exist yet, compared by
+/// name ignoring case. Returns the number of created exercises.
+pub async fn seed_exercises(tx: &mut Transaction<'_, Sqlite>) -> Result<usize> {
+    let catalog: Vec<CatalogExercise> =
+        serde_json::from_str(CATALOG).context("Failed to parse exercise catalog")?;
+
+    let mut names = dal::get_exercises(&mut *tx, &ExerciseFilterInput::default())
+        .await?
+        .into_iter()
+        .map(|exercise| exercise.name.to_lowercase())
+        .collect::<HashSet<_>>();
+
+    let mut created = 0;
+    for exercise in catalog {
+        if !names.insert(exercise.name.to_lowercase()) {
+            continue;
+        }
+
+        dal::insert_exercise(tx, &exercise.into()).await?;
+        created += 1;
+    }
+
+    Ok(created)
+}
@@ -0,0 +1,152 @@
+use std::collections::BTreeMap;
+
+use crate::{dal::SessionSetEntity, one_rep_max};
+
+pub const DEFAULT_POINTS: usize = 200;
+
+/// Fewer points can't keep the first and the last point and any in between.
+pub const MIN_POINTS: usize = 3;
+
+pub const MAX_POINTS: usize = 5000;
+
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct Point {
+    /// UTC seconds.
+    pub time: i64,
+    pub value: f64,
+}
+
+/// Best estimated one-repetition maximum of every session, the sets must be
+/// ordered by session.
+pub fn estimated_one_rep_max(sets: &[SessionSetEntity]) -> Vec<Point> {
+    one_rep_max::series(sets, one_rep_max::Formula::Epley)
+        .into_iter()
+        .map(|session| Point {
+            time: session.started.timestamp(),
+            value: session.estimate,
+        })
+        .collect()
+}
+
+/// Tonnage of every workout, the sets may be of several exercises in any
+/// order.
+pub fn volume(sets: &[SessionSetEntity]) -> Vec<Point> {
+    let mut workouts: BTreeMap<(i64, i64), f64> = BTreeMap::new();
+    for set in sets {
+        *workouts
+            .entry((set.started.timestamp(), set.workout_id))
+            .or_default() += (set.weight * set.repetitions) as f64;
+    }
+
+    workouts
+        .into_iter()
+        .map(|((time, _), value)| Point { time, value })
+        .collect()
+}
+
+/// Reduces the points ordered by time to at most `threshold` points with
+/// Largest-Triangle-Three-Buckets, which keeps peaks and dips that averaging
+/// would flatten. The first and the last point are always kept.
+pub fn downsample(points: &[Point], threshold: usize) -> Vec<Point> {
+    if threshold < MIN_POINTS || points.len() <= threshold {
+        return points.to_vec();
+    }
+
+    // The points between the first and the last one are split into buckets,
+    // from each the point spanning the largest triangle with the previously
+    // selected point and the average of the next bucket is selected.
+    let bucket_size = (points.len() - 2) as f64 / (threshold - 2) as f64;
+    let bucket = |i: usize| {
+        let start = (i as f64 * bucket_size) as usize + 1;
+        let end = (((i + 1) as f64 * bucket_size) as usize + 1).min(points.len() - 1);
+        &points[start..end]
+    };
+
+    let mut sampled = Vec::with_capacity(threshold);
+    let mut previous = points[0];
+    sampled.push(previous);
+
+    for i in 0..threshold - 2 {
+        let next = if i + 1 < threshold - 2 {
+            bucket(i + 1)
+        } else {
+            &points[points.len() - 1..]
+        };
+        let n = next.len() as f64;
+        let next_time = next.iter().map(|p| p.time as f64).sum::<f64>() / n;
+        let next_value = next.iter().map(|p| p.value).sum::<f64>() / n;
+
+        let selected = bucket(i).iter().copied().max_by(|a, b| {
+            let area = |p: &Point| {
+                ((previous.time as f64 - next_time) * (p.value - previous.value)
+                    - (previous.time as f64 - p.time as f64) * (next_value - previous.value))
+                    .abs()
+            };
+            area(a).total_cmp(&area(b))
+        });
+
+        if let Some(selected) = selected {
+            sampled.push(selected);
+            previous = selected;
+        }
+    }
+
+    sampled.push(points[points.len() - 1]);
+    sampled
+}
+
+#[cfg(test)]
+mod tests {
+    use chrono::NaiveDate;
+
+    use super::*;
+    use crate::fixtures::{self, HistoryOptions};
+
+    fn line(values: &[f64]) -> Vec<Point> {
+        values
+            .iter()
+            .enumerate()
+            .map(|(i, &value)| Point {
+                time: i as i64 * 60,
+                value,
+            })
+            .collect()
+    }
+
+    #[test]
+    fn short_series_are_unchanged() {
+        let points = line(&[1.0, 2.0, 3.0, 4.0]);
+
+        assert_eq!(downsample(&points, 4), points);
+        assert_eq!(downsample(&points, MIN_POINTS - 1), points);
+    }
+
+    #[test]
+    fn keeps_the_ends_and_peaks() {
+        let mut values = vec![10.0; 50];
+        values[17] = 100.0;
+        values[33] = 0.0;
+        let points = line(&values);
+
+        let sampled = downsample(&points, 5);
+
+        assert_eq!(sampled.len(), 5);
+        assert_eq!(sampled.first(), points.first());
+        assert_eq!(sampled.last(), points.last());
+        assert!(sampled.contains(&points[17]));
+        assert!(sampled.contains(&points[33]));
+    }
+
+    #[test]
+    fn downsamples_the_volume_of_a_history() {
+        let end = NaiveDate::from_ymd_opt(2023, 11, 5).expect("Date must be valid");
+        let workouts = fixtures::build_history(end, &HistoryOptions::default());
+        let points = volume(&fixtures::session_sets(&workouts));
+        assert_eq!(points.len(), workouts.len());
+
+        let sampled = downsample(&points, 10);
+
+        assert_eq!(sampled.len(), 10);
+        assert!(sampled.windows(2).all(|pair| pair[0].time < pair[1].time));
+    }
+}
@@ -1,13 +1,22 @@
-use std::net::SocketAddr;
+use std::{collections::HashSet, net::SocketAddr};
 
+use anyhow::Context;
 use axum::{
-    extract::{Path, State},
-    http::{header::CONTENT_TYPE, Request, StatusCode, Uri},
+    body::Bytes,
+    extract::{DefaultBodyLimit, MatchedPath, Path, Query, State},
+    http::{
+        header::{
+            CACHE_CONTROL, CONTENT_DISPOSITION, CONTENT_TYPE, WWW_AUTHENTICATE,
+            X_CONTENT_TYPE_OPTIONS,
+        },
+        HeaderMap, HeaderValue, Method, Request, StatusCode, Uri,
+    },
     middleware::{self, Next},
-    response::{IntoResponse, Response},
-    routing::{get, post},
+    response::{IntoResponse, Redirect, Response},
+    routing::{delete, get, post, put},
     Json, Router, Server, ServiceExt,
 };
+use chrono::{DateTime, FixedOffset, TimeZone, Utc};
 use include_dir::{include_dir, Dir};
 use sqlx::{Pool, Sqlite};
 use tokio::signal;
@@ -17,26 +26,151 @@ use tower_http::{
     trace::{DefaultMakeSpan, TraceLayer},
     ServiceBuilderExt,
 };
-use tracing::{error, info};
+use tracing::{error, info, warn};
 
-use crate::dal;
+use crate::{
+    cache::StatisticsCache,
+    charts::{self, ProgressionMetric},
+    dal::{
+        self, AttachmentInput, BodyWeightInput, ExerciseEntity, ExerciseFilterInput, ExerciseInput,
+        ExerciseSetEntity, ExerciseSetInput, GoalInput, GymInput, NotificationChannelInput,
+        NotificationEvent, PageInput, ProgressPhotoInput, ProgressionEntity, SetType,
+        StatisticsFilterInput, TimeSlot, VolumeFilterInput, WeightCorrectionInput,
+        WorkoutAuditAction,
+    },
+    export::{self, ActivityFormat},
+    frequency, goals,
+    google_fit::GoogleFit,
+    heuristics,
+    insights::{self, Trigger},
+    intensity,
+    limits::{LimitExceeded, Limits},
+    monitoring::{ErrorEvent, ErrorLog},
+    notifications::{Notification, Notifier, Transport},
+    one_rep_max::{self, Formula},
+    overload,
+    photos::{self, PhotoStorage},
+    plates::PlateConfiguration,
+    records, series,
+    strength::{self, Sex},
+    supersets,
+    training_report::{self, Period},
+    trends,
+    units::{UnitConfig, WeightConversion, WeightUnit},
+    validation::{self, FieldError, ValidationError},
+};
 
 use self::{
     requests::{
-        CreateUpdateExercise, CreateUpdateExerciseSet, GetSetSuggestion, UpdateWorkoutMetaData,
+        CreateBodyWeight, CreateNotificationChannel, CreateNotificationRule, CreateUpdateExercise,
+        CreateUpdateExerciseSet, CreateUpdateGoal, CreateUpdateGym, CreateWeightCorrection,
+        DeleteExercise, DeleteExerciseSets, ExportStatistics, ExportWorkout, GetBodyWeights,
+        GetEstimatedOneRepMax, GetExerciseQuickStats, GetExerciseSets, GetExerciseSetsByExerciseId,
+        GetExerciseSetsByWorkoutId, GetExercises, GetGoalProgress, GetInsights, GetIntensity,
+        GetMuscleVolume, GetPersonalRecords, GetPlateaus, GetProgressPhotos, GetProgression,
+        GetProgressionChart, GetRecentPrEvents, GetRelativeStrength, GetSeries, GetSetSuggestion,
+        GetStalls, GetStatisticsOverview, GetTrainingReport, GetTrainingTimes, GetTrends,
+        GetVolume, GroupBy, LinkAttachment, MoveExerciseSet, ReorderExerciseSets, StartRestTimer,
+        UpdateWorkoutMetaData, UploadAttachment, UploadProgressPhoto,
+    },
+    responses::{
+        Attachment, BodyWeight, Config, Correction, DeletedExercise, DeletedExerciseSets, Errors,
+        Exercise, ExerciseCount, ExerciseDetails, ExerciseGroupSets, ExerciseQuickStats,
+        ExerciseSet, ExerciseTrend, ExerciseUsage, ExerciseWithStats, Goal, GoalProgress, Gym,
+        Insight, Intensity, LimitError, MuscleVolume, NotificationChannel, NotificationRule,
+        PersonalRecords, Plateau, PrEvent, ProgressPhoto, Progression, RelativeStrength, RestTimer,
+        Series, SessionEstimate, SetAnomaly, SetSuggestion, Stall, StatisticsOverview,
+        TrainingReport, TrainingTimes, UpsertedExercises, ValidationErrors, Volume,
+        WithAttachments, WithWarnings, Workout, WorkoutAudit, WorkoutDisplay, WorkoutDraft,
+        WorkoutExerciseSets,
     },
-    responses::{Exercise, ExerciseCount, ExerciseSet, SetSuggestion, StatisticsOverview, Workout},
 };
 
 static STATIC_FILES: Dir<'_> = include_dir!("../client/dist");
 
+/// Cache policies of API reads, the first pattern matching the path wins and
+/// `*` matches any single path segment. Reads not listed here return live
+/// workout data that must never be cached, which includes exercises with
+/// their favorite flag and usage.
+const CACHE_POLICIES: &[(&str, &str)] = &[
+    ("/api/config", "private, max-age=3600"),
+    ("/api/statistics", "private, max-age=60"),
+    ("/api/statistics/*", "private, max-age=60"),
+    ("/api/charts/*", "private, max-age=60"),
+    ("/api/attachments/*", "private, max-age=86400"),
+];
+
+/// Endpoints that are disabled in demo mode, because they rewrite data in bulk,
+/// send requests to other hosts or write files that outlive resets. `*`
+/// matches any single path segment.
+const DEMO_BLOCKED_ENDPOINTS: &[(&str, &str)] = &[
+    ("POST", "/api/admin/corrections"),
+    ("POST", "/api/exercises/bulk"),
+    ("POST", "/api/notifications/channels"),
+    ("DELETE", "/api/notifications/channels/*"),
+    ("POST", "/api/notifications/channels/*/test"),
+    ("POST", "/api/notifications/rules"),
+    ("POST", "/api/photos"),
+    ("DELETE", "/api/notifications/rules/*"),
+];
+
+/// Rest between sets assumed by workout displays without a running rest
+/// timer, and the duration of rest timers by default.
+const DEFAULT_REST_S: i64 = 120;
+
+/// Maximum number of exercises of a bulk import.
+const MAX_BULK_EXERCISES: usize = 1000;
+
+/// Number of recent personal records returned if the client doesn't ask for
+/// a number.
+const DEFAULT_PR_EVENTS: i64 = 20;
+
+const MAX_PR_EVENTS: i64 = 100;
+
+/// Maximum size of an uploaded photo or video in bytes.
+const MAX_ATTACHMENT_SIZE: usize = 25 * 1024 * 1024;
+
+const DEFAULT_CACHE_POLICY: &str = "no-store";
+
 #[derive(Debug, Clone)]
 struct AppState {
     pool: Pool<Sqlite>,
+    config: UnitConfig,
+    errors: ErrorLog,
+    notifier: Notifier,
+    limits: Limits,
+    cache: StatisticsCache,
+    demo: bool,
+    /// Progress photos are only available if a storage is configured.
+    photos: Option<PhotoStorage>,
+    /// Finished workouts are pushed to Google Fit if configured.
+    google_fit: Option<GoogleFit>,
 }
 
-pub async fn run(addr: &SocketAddr, pool: Pool<Sqlite>) {
-    let state = AppState { pool };
+/// Message of an internal error, attached to the response for the error log.
+#[derive(Debug, Clone)]
+struct ErrorMessage(String);
+
+pub async fn run(
+    addr: &SocketAddr,
+    pool: Pool<Sqlite>,
+    config: UnitConfig,
+    limits: Limits,
+    demo: bool,
+    photos: Option<PhotoStorage>,
+    google_fit: Option<GoogleFit>,
+) {
+    let state = AppState {
+        notifier: Notifier::new(pool.clone()),
+        pool,
+        config,
+        errors: ErrorLog::default(),
+        limits,
+        cache: StatisticsCache::default(),
+        demo,
+        photos,
+        google_fit,
+    };
 
     let check_workout_exists_layer =
         || middleware::from_fn_with_state(state.clone(), check_workout_exists);
@@ -44,10 +178,21 @@ pub async fn run(addr: &SocketAddr, pool: Pool<Sqlite>) {
     let check_exercise_exists_layer =
         || middleware::from_fn_with_state(state.clone(), check_exercise_exists);
 
+    let check_gym_exists_layer = || middleware::from_fn_with_state(state.clone(), check_gym_exists);
+
+    let check_goal_exists_layer =
+        || middleware::from_fn_with_state(state.clone(), check_goal_exists);
+
     let check_exercise_set_exists_layer =
         || middleware::from_fn_with_state(state.clone(), check_exercise_set_exists);
 
+    let require_photo_token_layer =
+        || middleware::from_fn_with_state(state.clone(), require_photo_token);
+
+    let max_photo_size = state.photos.as_ref().map_or(0, |photos| photos.max_size);
+
     let endpoints = Router::new()
+        .route("/config", get(get_config))
         .route("/workouts", get(get_workouts).post(create_workout))
         .route(
             "/workouts/:id",
@@ -58,10 +203,90 @@ pub async fn run(addr: &SocketAddr, pool: Pool<Sqlite>) {
         )
         .route(
             "/workouts/:id/sets",
-            get(get_exercise_sets_by_workout_id).route_layer(check_workout_exists_layer()),
+            get(get_exercise_sets_by_workout_id)
+                .delete(delete_exercise_sets_by_workout_id)
+                .route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/finish",
+            post(finish_workout).route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/reopen",
+            post(reopen_workout).route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/display",
+            get(get_workout_display).route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/export",
+            get(export_workout).route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/audit",
+            get(get_workout_audit).route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/draft",
+            get(get_workout_draft)
+                .put(save_workout_draft)
+                .delete(delete_workout_draft)
+                .route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/rest-timer",
+            get(get_rest_timer)
+                .post(start_rest_timer)
+                .delete(delete_rest_timer)
+                .route_layer(check_workout_exists_layer()),
+        )
+        .route(
+            "/workouts/:id/sets/order",
+            put(reorder_exercise_sets).route_layer(check_workout_exists_layer()),
         )
         .route("/workouts/:id/sets/suggest", post(get_set_suggestion))
+        .route(
+            "/workouts/:id/sets/recommendation",
+            get(get_set_recommendation),
+        )
+        .route("/gyms", get(get_gyms).post(create_gym))
+        .route(
+            "/gyms/:id",
+            get(get_gym)
+                .put(update_gym)
+                .delete(delete_gym)
+                .route_layer(check_gym_exists_layer()),
+        )
+        .route(
+            "/body-weights",
+            get(get_body_weights).post(create_body_weight),
+        )
+        .route("/body-weights/:id", delete(delete_body_weight))
+        .route(
+            "/photos",
+            get(get_progress_photos)
+                .post(upload_progress_photo)
+                .layer(DefaultBodyLimit::max(max_photo_size))
+                .route_layer(require_photo_token_layer()),
+        )
+        .route(
+            "/photos/:id",
+            get(get_progress_photo_content)
+                .delete(delete_progress_photo)
+                .route_layer(require_photo_token_layer()),
+        )
+        .route("/goals", get(get_goals).post(create_goal))
+        .route("/goals/progress", get(get_goal_progress))
+        .route(
+            "/goals/:id",
+            get(get_goal)
+                .put(update_goal)
+                .delete(delete_goal)
+                .route_layer(check_goal_exists_layer()),
+        )
         .route("/exercises", get(get_exercises).post(create_exercise))
+        .route("/exercises/bulk", post(upsert_exercises))
         .route(
             "/exercises/:id",
             get(get_exercise)
@@ -73,10 +298,24 @@ pub async fn run(addr: &SocketAddr, pool: Pool<Sqlite>) {
             "/exercises/:id/sets",
             get(get_exercise_sets_by_exercise_id).route_layer(check_exercise_exists_layer()),
         )
+        .route(
+            "/exercises/:id/favorite",
+            post(add_exercise_favorite)
+                .delete(remove_exercise_favorite)
+                .route_layer(check_exercise_exists_layer()),
+        )
         .route(
             "/exercises/:id/count",
             get(get_exercise_count).route_layer(check_exercise_exists_layer()),
         )
+        .route(
+            "/exercises/:id/usage",
+            get(get_exercise_usage).route_layer(check_exercise_exists_layer()),
+        )
+        .route(
+            "/exercises/:id/quick-stats",
+            get(get_exercise_quick_stats).route_layer(check_exercise_exists_layer()),
+        )
         .route("/sets", get(get_exercise_sets).post(create_exercise_set))
         .route(
             "/sets/:id",
@@ -85,11 +324,91 @@ pub async fn run(addr: &SocketAddr, pool: Pool<Sqlite>) {
                 .delete(delete_exercise_set)
                 .route_layer(check_exercise_set_exists_layer()),
         )
-        .route("/statistics", get(get_statistics_overview));
+        .route(
+            "/sets/:id/attachments",
+            get(get_attachments)
+                .post(upload_attachment)
+                .layer(DefaultBodyLimit::max(MAX_ATTACHMENT_SIZE))
+                .route_layer(check_exercise_set_exists_layer()),
+        )
+        .route(
+            "/sets/:id/attachments/url",
+            post(link_attachment).route_layer(check_exercise_set_exists_layer()),
+        )
+        .route(
+            "/attachments/:id",
+            get(get_attachment_content).delete(delete_attachment),
+        )
+        .route(
+            "/sets/:id/move",
+            post(move_exercise_set).route_layer(check_exercise_set_exists_layer()),
+        )
+        .route(
+            "/sets/:id/complete",
+            post(complete_exercise_set).route_layer(check_exercise_set_exists_layer()),
+        )
+        .route("/statistics", get(get_statistics_overview))
+        .route("/statistics/progression", get(get_progression))
+        .route("/statistics/prs", get(get_personal_records))
+        .route("/statistics/volume", get(get_volume))
+        .route("/statistics/muscles", get(get_muscle_volume))
+        .route("/statistics/trends", get(get_trends))
+        .route("/statistics/times", get(get_training_times))
+        .route("/statistics/intensity", get(get_intensity))
+        .route("/statistics/series", get(get_series))
+        .route("/statistics/strength", get(get_relative_strength))
+        .route("/statistics/export.csv", get(export_statistics))
+        .route(
+            "/statistics/exercises/:id/e1rm",
+            get(get_estimated_one_rep_max).route_layer(check_exercise_exists_layer()),
+        )
+        .route("/reports/:period", get(get_training_report))
+        .route("/reports/:period/pdf", get(get_training_report_pdf))
+        .route("/charts/progression", get(get_progression_chart))
+        .route(
+            "/charts/workouts-per-week",
+            get(get_workouts_per_week_chart),
+        )
+        .route(
+            "/admin/corrections",
+            get(get_corrections).post(create_weight_correction),
+        )
+        .route("/insights", get(get_insights))
+        .route("/insights/stalls", get(get_stalls))
+        .route("/insights/plateaus", get(get_plateaus))
+        .route("/insights/:id/acknowledge", post(acknowledge_insight))
+        .route("/insights/:id/dismiss", post(dismiss_insight))
+        .route("/admin/errors", get(get_errors))
+        .route(
+            "/notifications/channels",
+            get(get_notification_channels).post(create_notification_channel),
+        )
+        .route(
+            "/notifications/channels/:id",
+            delete(delete_notification_channel),
+        )
+        .route(
+            "/notifications/channels/:id/test",
+            post(test_notification_channel),
+        )
+        .route(
+            "/notifications/rules",
+            get(get_notification_rules).post(create_notification_rule),
+        )
+        .route("/notifications/rules/:id", delete(delete_notification_rule))
+        .route("/prs/recent", get(get_recent_pr_events))
+        .route("/anomalies", get(get_set_anomalies))
+        .route("/anomalies/:id", delete(delete_set_anomaly))
+        .layer(middleware::from_fn_with_state(state.clone(), track_errors));
 
     let router = Router::new()
         .nest("/api", endpoints)
         .nest_service("/", get(get_static_file))
+        .layer(middleware::from_fn(set_cache_control))
+        .layer(middleware::from_fn_with_state(
+            state.clone(),
+            reject_in_demo,
+        ))
         .with_state(state);
 
     let svc = ServiceBuilder::new()
@@ -101,6 +420,15 @@ pub async fn run(addr: &SocketAddr, pool: Pool<Sqlite>) {
         .propagate_x_request_id()
         .service(router);
 
+    tokio::spawn(insights::schedule(
+        state.pool.clone(),
+        state.notifier.clone(),
+    ));
+
+    if let Some(google_fit) = state.google_fit.clone() {
+        tokio::spawn(google_fit.schedule());
+    }
+
     info!(%addr, "Listening on {}", addr);
 
     Server::bind(addr)
@@ -135,6 +463,87 @@ async fn get_static_file(uri: Uri) -> Response {
     ([(CONTENT_TYPE, guess)], file.contents()).into_response()
 }
 
+async fn set_cache_control<T>(request: Request<T>, next: Next<T>) -> Response {
+    let path = request.uri().path().to_string();
+    let is_api_read = request.method() == Method::GET && path.starts_with("/api/");
+
+    let mut response = next.run(request).await;
+
+    if is_api_read {
+        let policy = CACHE_POLICIES
+            .iter()
+            .find(|(pattern, _)| matches_path(pattern, &path))
+            .map_or(DEFAULT_CACHE_POLICY, |&(_, policy)| policy);
+
+        response
+            .headers_mut()
+            .insert(CACHE_CONTROL, HeaderValue::from_static(policy));
+    }
+
+    response
+}
+
+async fn reject_in_demo<T>(
+    State(state): State<AppState>,
+    request: Request<T>,
+    next: Next<T>,
+) -> Response {
+    let blocked = state.demo
+        && DEMO_BLOCKED_ENDPOINTS.iter().any(|&(method, pattern)| {
+            request.method().as_str() == method && matches_path(pattern, request.uri().path())
+        });
+
+    if blocked {
+        return (StatusCode::FORBIDDEN, "Disabled in the demo.").into_response();
+    }
+
+    next.run(request).await
+}
+
+async fn track_errors<T>(
+    State(state): State<AppState>,
+    request: Request<T>,
+    next: Next<T>,
+) -> Response {
+    let method = request.method().to_string();
+    let route = request
+        .extensions()
+        .get::<MatchedPath>()
+        .map_or_else(|| request.uri().path(), MatchedPath::as_str)
+        .to_string();
+
+    let response = next.run(request).await;
+
+    if response.status().is_server_error() {
+        state.errors.record(ErrorEvent {
+            method,
+            route,
+            status: response.status().as_u16(),
+            message: response
+                .extensions()
+                .get::<ErrorMessage>()
+                .map(|message| message.0.clone()),
+            occurred: Utc::now(),
+        });
+    }
+
+    response
+}
+
+fn matches_path(pattern: &str, path: &str) -> bool {
+    let mut segments = path.trim_end_matches('/').split('/');
+    let mut pattern_segments = pattern.split('/');
+
+    loop {
+        match (pattern_segments.next(), segments.next()) {
+            (None, None) => return true,
+            (Some("*"), Some(_)) => {}
+            (Some(expected), Some(segment)) if expected == segment => {}
+            _ => return false,
+        }
+    }
+}
+
 async fn check_workout_exists<T>(
     State(state): State<AppState>,
     Path(id): Path<i64>,
@@ -186,15 +595,31 @@ async fn check_exercise_set_exists<T>(
 async fn get_exercise(
     State(state): State<AppState>,
     Path(id): Path<i64>,
-) -> Result<Json<Exercise>, AppError> {
-    dal::get_exercise(&state.pool, id)
+) -> Result<Json<ExerciseWithStats>, AppError> {
+    let exercise = dal::get_exercise(&state.pool, id)
         .await?
-        .map(|exercise| Json(Exercise::from(exercise)))
-        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+    let stats = dal::get_exercise_stats(&state.pool, id).await?;
+    let previous_names = dal::get_exercise_name_history(&state.pool, id).await?;
+    Ok(Json(ExerciseWithStats::new(
+        exercise,
+        stats,
+        previous_names,
+    )))
 }
 
-async fn get_exercises(State(state): State<AppState>) -> Result<Json<Vec<Exercise>>, AppError> {
-    let exercises = dal::get_exercises(&state.pool)
+async fn get_exercises(
+    State(state): State<AppState>,
+    Query(query): Query<GetExercises>,
+) -> Result<Json<Vec<Exercise>>, AppError> {
+    let filter = ExerciseFilterInput {
+        muscle: query.muscle.map(|muscle| muscle.trim().to_lowercase()),
+        category: query.category,
+        gym_id: query.gym_id,
+        tag: query.tag.map(|tag| tag.trim().to_lowercase()),
+        sort: query.sort,
+    };
+    let exercises = dal::get_exercises(&state.pool, &filter)
         .await?
         .into_iter()
         .map(Exercise::from)
@@ -205,336 +630,4382 @@ async fn get_exercises(State(state): State<AppState>) -> Result<Json<Vec<Exercis
 async fn create_exercise(
     State(state): State<AppState>,
     Json(exercise): Json<CreateUpdateExercise>,
-) -> Result<Json<Exercise>, AppError> {
-    let exercise = dal::create_exercise(&state.pool, &exercise.name).await?;
-    Ok(Json(Exercise::from(exercise)))
+) -> Result<Json<ExerciseDetails>, AppError> {
+    let exercise: ExerciseInput = exercise.into();
+    validation::validate_exercise(&exercise)?;
+    if let Some(exceeded) = state.limits.check_exercises(&state.pool, 1).await? {
+        return Err(AppError::Limit(StatusCode::FORBIDDEN, exceeded));
+    }
+    let exercise = dal::create_exercise(&state.pool, &exercise)
+        .await
+        .map_err(name_conflict)?;
+    Ok(Json(ExerciseDetails::from(exercise)))
 }
 
 async fn update_exercise(
     State(state): State<AppState>,
     Path(id): Path<i64>,
     Json(exercise): Json<CreateUpdateExercise>,
-) -> Result<Json<Exercise>, AppError> {
-    let exercise = dal::update_exercise(&state.pool, id, &exercise.name).await?;
-    Ok(Json(Exercise::from(exercise)))
+) -> Result<Json<ExerciseDetails>, AppError> {
+    let exercise: ExerciseInput = exercise.into();
+    validation::validate_exercise(&exercise)?;
+    let exercise = dal::update_exercise(&state.pool, id, &exercise)
+        .await
+        .map_err(name_conflict)?;
+    Ok(Json(ExerciseDetails::from(exercise)))
 }
 
-async fn delete_exercise(
+/// Creates or updates exercises by name in one transaction, e.g. to migrate
+/// the catalog of another app. Invalid exercises and repeated names are
+/// skipped.
+async fn upsert_exercises(
     State(state): State<AppState>,
-    Path(id): Path<i64>,
-) -> Result<StatusCode, AppError> {
-    dal::delete_exercise(&state.pool, id)
+    Json(exercises): Json<Vec<CreateUpdateExercise>>,
+) -> Result<Json<UpsertedExercises>, AppError> {
+    if exercises.len() > MAX_BULK_EXERCISES {
+        return Err(AppError::StatusCode(StatusCode::PAYLOAD_TOO_LARGE));
+    }
+
+    let existing = dal::get_exercises(&state.pool, &ExerciseFilterInput::default())
         .await?
-        .map(|_| StatusCode::NO_CONTENT)
-        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+        .into_iter()
+        .map(|exercise| exercise.name.to_lowercase())
+        .collect::<HashSet<_>>();
+
+    let mut names = HashSet::new();
+    let mut skipped = 0;
+    let exercises = exercises
+        .into_iter()
+        .map(ExerciseInput::from)
+        .filter(|exercise| {
+            let valid = validation::validate_exercise(exercise).is_ok()
+                && names.insert(exercise.name.to_lowercase());
+            if !valid {
+                skipped += 1;
+            }
+            valid
+        })
+        .collect::<Vec<_>>();
+
+    let new_exercises = names.difference(&existing).count() as i64;
+    if let Some(exceeded) = state
+        .limits
+        .check_exercises(&state.pool, new_exercises)
+        .await?
+    {
+        return Err(AppError::Limit(StatusCode::FORBIDDEN, exceeded));
+    }
+
+    let result = dal::upsert_exercises(&state.pool, &exercises)
+        .await
+        .map_err(name_conflict)?;
+    Ok(Json(UpsertedExercises::new(result, skipped)))
 }
 
-async fn get_exercise_count(
+/// Names are unique ignoring case, enforced by the database so concurrent
+/// writes can't create duplicates.
+fn name_conflict(err: anyhow::Error) -> AppError {
+    if dal::is_unique_violation(&err) {
+        AppError::StatusCode(StatusCode::CONFLICT)
+    } else {
+        AppError::Err(err)
+    }
+}
+
+/// Exercises with sets can only be deleted with `force`, which deletes the
+/// sets as well.
+async fn delete_exercise(
     State(state): State<AppState>,
     Path(id): Path<i64>,
-) -> Result<Json<responses::ExerciseCount>, AppError> {
-    let count = dal::get_exercise_count(&state.pool, id).await?;
-    Ok(Json(ExerciseCount::from(count)))
+    Query(query): Query<DeleteExercise>,
+) -> Result<Response, AppError> {
+    if query.force {
+        return dal::delete_exercise_with_sets(&state.pool, id)
+            .await?
+            .map(|deleted_sets| Json(DeletedExercise { deleted_sets }).into_response())
+            .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND));
+    }
+
+    match dal::delete_exercise(&state.pool, id).await {
+        Ok(Some(())) => Ok(StatusCode::NO_CONTENT.into_response()),
+        Ok(None) => Err(AppError::StatusCode(StatusCode::NOT_FOUND)),
+        Err(err) if dal::is_foreign_key_violation(&err) => {
+            Err(AppError::StatusCode(StatusCode::CONFLICT))
+        }
+        Err(err) => Err(AppError::Err(err)),
+    }
 }
 
-async fn get_workout(
+async fn add_exercise_favorite(
     State(state): State<AppState>,
     Path(id): Path<i64>,
-) -> Result<Json<Workout>, AppError> {
-    dal::get_workout(&state.pool, id)
+) -> Result<StatusCode, AppError> {
+    dal::set_exercise_favorite(&state.pool, id, true)
         .await?
-        .map(|workout| Json(Workout::from(workout)))
+        .map(|_| StatusCode::NO_CONTENT)
         .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
 }
 
-async fn get_workouts(State(state): State<AppState>) -> Result<Json<Vec<Workout>>, AppError> {
-    let workouts = dal::get_workouts(&state.pool)
-        .await?
-        .into_iter()
-        .map(Workout::from)
-        .collect();
-    Ok(Json(workouts))
-}
-
-async fn create_workout(State(state): State<AppState>) -> Result<Json<Workout>, AppError> {
-    let workout = dal::create_workout(&state.pool).await?;
-    Ok(Json(Workout::from(workout)))
-}
-
-async fn delete_workout(
+async fn remove_exercise_favorite(
     State(state): State<AppState>,
     Path(id): Path<i64>,
 ) -> Result<StatusCode, AppError> {
-    dal::delete_workout(&state.pool, id)
+    dal::set_exercise_favorite(&state.pool, id, false)
         .await?
         .map(|_| StatusCode::NO_CONTENT)
         .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
 }
 
-async fn update_workout_meta_data(
+async fn check_gym_exists<T>(
     State(state): State<AppState>,
     Path(id): Path<i64>,
-    Json(request): Json<UpdateWorkoutMetaData>,
-) -> Result<Json<Workout>, AppError> {
-    dal::update_workout_meta_data(&state.pool, id, &request.note)
-        .await?
-        .map(|workout| Json(Workout::from(workout)))
-        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+    request: Request<T>,
+    next: Next<T>,
+) -> Response {
+    match dal::get_gym(&state.pool, id).await {
+        Err(err) => {
+            error!(%err, "Failed to check if gym exists.");
+            StatusCode::INTERNAL_SERVER_ERROR.into_response()
+        }
+        Ok(None) => StatusCode::NOT_FOUND.into_response(),
+        _ => next.run(request).await,
+    }
 }
 
-async fn get_exercise_set(
+async fn get_gym(
     State(state): State<AppState>,
     Path(id): Path<i64>,
-) -> Result<Json<ExerciseSet>, AppError> {
-    dal::get_exercise_set(&state.pool, id)
+) -> Result<Json<Gym>, AppError> {
+    dal::get_gym(&state.pool, id)
         .await?
-        .map(|exercise| Json(ExerciseSet::from(exercise)))
+        .map(|gym| Json(Gym::from(gym)))
         .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
 }
 
-async fn get_exercise_sets(
-    State(state): State<AppState>,
-) -> Result<Json<Vec<ExerciseSet>>, AppError> {
-    let exercise_sets = dal::get_exercise_sets(&state.pool)
+async fn get_gyms(State(state): State<AppState>) -> Result<Json<Vec<Gym>>, AppError> {
+    let gyms = dal::get_gyms(&state.pool)
         .await?
         .into_iter()
-        .map(ExerciseSet::from)
+        .map(Gym::from)
         .collect();
-    Ok(Json(exercise_sets))
+    Ok(Json(gyms))
 }
 
-async fn get_exercise_sets_by_workout_id(
+async fn create_gym(
+    State(state): State<AppState>,
+    Json(gym): Json<CreateUpdateGym>,
+) -> Result<Json<Gym>, AppError> {
+    let gym: GymInput = gym.into();
+    validation::validate_gym(&gym)?;
+    let gym = dal::create_gym(&state.pool, &gym).await?;
+    Ok(Json(Gym::from(gym)))
+}
+
+async fn update_gym(
     State(state): State<AppState>,
     Path(id): Path<i64>,
-) -> Result<Json<Vec<ExerciseSet>>, AppError> {
-    let exercise_sets = dal::get_exercise_sets_by_workout_id(&state.pool, id)
-        .await?
-        .into_iter()
-        .map(ExerciseSet::from)
-        .collect();
-    Ok(Json(exercise_sets))
+    Json(gym): Json<CreateUpdateGym>,
+) -> Result<Json<Gym>, AppError> {
+    let gym: GymInput = gym.into();
+    validation::validate_gym(&gym)?;
+    let gym = dal::update_gym(&state.pool, id, &gym).await?;
+    Ok(Json(Gym::from(gym)))
 }
 
-async fn get_exercise_sets_by_exercise_id(
+async fn delete_gym(
     State(state): State<AppState>,
     Path(id): Path<i64>,
-) -> Result<Json<Vec<ExerciseSet>>, AppError> {
-    let exercise_sets = dal::get_exercise_sets_by_exercise_id(&state.pool, id)
+) -> Result<StatusCode, AppError> {
+    dal::delete_gym(&state.pool, id)
         .await?
-        .into_iter()
-        .map(ExerciseSet::from)
-        .collect();
-    Ok(Json(exercise_sets))
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
 }
 
-async fn create_exercise_set(
+async fn get_body_weights(
     State(state): State<AppState>,
-    Json(exercise_set): Json<CreateUpdateExerciseSet>,
-) -> Result<Json<ExerciseSet>, AppError> {
-    let exercise_set = dal::create_or_update_exercise_set(
-        &state.pool,
-        None,
-        exercise_set.workout_id,
-        exercise_set.exercise_id,
-        exercise_set.repetitions,
-        exercise_set.weight,
-        exercise_set.note,
-    )
-    .await?;
-    Ok(Json(ExerciseSet::from(exercise_set)))
+    Query(query): Query<GetBodyWeights>,
+) -> Result<Json<Vec<BodyWeight>>, AppError> {
+    let body_weights = dal::get_body_weights(&state.pool, query.from, query.to)
+        .await?
+        .into_iter()
+        .map(BodyWeight::from)
+        .collect();
+    Ok(Json(body_weights))
 }
 
-async fn update_exercise_set(
+async fn create_body_weight(
     State(state): State<AppState>,
-    Path(id): Path<i64>,
-    Json(exercise_set): Json<CreateUpdateExerciseSet>,
-) -> Result<Json<ExerciseSet>, AppError> {
-    let exercise_set = dal::create_or_update_exercise_set(
-        &state.pool,
-        Some(id),
-        exercise_set.workout_id,
-        exercise_set.exercise_id,
-        exercise_set.repetitions,
-        exercise_set.weight,
-        exercise_set.note,
-    )
-    .await?;
-    Ok(Json(ExerciseSet::from(exercise_set)))
+    Json(body_weight): Json<CreateBodyWeight>,
+) -> Result<Json<BodyWeight>, AppError> {
+    let body_weight = body_weight.into_input(state.config.weight_unit);
+    validation::validate_body_weight(&body_weight)?;
+    let body_weight = dal::create_body_weight(&state.pool, &body_weight).await?;
+    Ok(Json(BodyWeight::from(body_weight)))
 }
 
-async fn delete_exercise_set(
+async fn delete_body_weight(
     State(state): State<AppState>,
     Path(id): Path<i64>,
 ) -> Result<StatusCode, AppError> {
-    dal::delete_exercise_set(&state.pool, id)
+    dal::delete_body_weight(&state.pool, id)
         .await?
         .map(|_| StatusCode::NO_CONTENT)
         .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
 }
 
-async fn get_set_suggestion(
-    State(state): State<AppState>,
-    Path(id): Path<i64>,
-    Json(request): Json<GetSetSuggestion>,
-) -> Result<Json<SetSuggestion>, AppError> {
-    let suggestion =
-        dal::get_set_suggestion_for_workout(&state.pool, id, request.exercise_id).await?;
-    Ok(Json(SetSuggestion::from(suggestion)))
+/// The last measured body weight, in the unit of the instance.
+async fn latest_body_weight(state: &AppState) -> Result<Option<i64>, AppError> {
+    Ok(dal::get_body_weight_at(&state.pool, Utc::now().timestamp())
+        .await?
+        .map(|body_weight| body_weight.weight))
 }
 
-async fn get_statistics_overview(
+/// Rejects requests without the photo token, and answers as if there were no
+/// photo endpoints if no photo storage is configured.
+async fn require_photo_token<T>(
     State(state): State<AppState>,
-) -> Result<Json<StatisticsOverview>, AppError> {
-    let overview = dal::get_statistics_overview(&state.pool).await?;
-    Ok(Json(StatisticsOverview::from(overview)))
+    request: Request<T>,
+    next: Next<T>,
+) -> Response {
+    match &state.photos {
+        None => StatusCode::NOT_FOUND.into_response(),
+        Some(photos) if !photos.authorize(request.headers()) => {
+            (StatusCode::UNAUTHORIZED, [(WWW_AUTHENTICATE, "Bearer")]).into_response()
+        }
+        _ => next.run(request).await,
+    }
 }
 
-#[derive(Debug)]
-enum AppError {
-    Err(anyhow::Error),
-    StatusCode(StatusCode),
+fn photo_storage(state: &AppState) -> Result<&PhotoStorage, AppError> {
+    state
+        .photos
+        .as_ref()
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
 }
 
-impl From<anyhow::Error> for AppError {
+async fn get_progress_photos(
+    State(state): State<AppState>,
+    Query(query): Query<GetProgressPhotos>,
+) -> Result<Json<Vec<ProgressPhoto>>, AppError> {
+    let photos = dal::get_progress_photos(&state.pool, query.from, query.to)
+        .await?
+        .into_iter()
+        .map(ProgressPhoto::from)
+        .collect();
+    Ok(Json(photos))
+}
+
+/// Stores the body as a progress photo, only JPEG, PNG, WebP and HEIC images
+/// are accepted.
+async fn upload_progress_photo(
+    State(state): State<AppState>,
+    Query(query): Query<UploadProgressPhoto>,
+    headers: HeaderMap,
+    body: Bytes,
+) -> Result<Json<ProgressPhoto>, AppError> {
+    let photos = photo_storage(&state)?;
+
+    let content_type = headers
+        .get(CONTENT_TYPE)
+        .and_then(|value| value.to_str().ok())
+        .and_then(photos::image_type)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::UNSUPPORTED_MEDIA_TYPE))?;
+
+    if body.is_empty() {
+        return Err(AppError::StatusCode(StatusCode::BAD_REQUEST));
+    }
+
+    if let Some(exceeded) = state
+        .limits
+        .check_photo_storage(&state.pool, body.len())
+        .await?
+    {
+        return Err(AppError::Limit(StatusCode::FORBIDDEN, exceeded));
+    }
+
+    let photo = ProgressPhotoInput {
+        content_type: content_type.to_string(),
+        size: body.len() as i64,
+        taken_utc_s: query.taken_utc_s,
+    };
+
+    let photo = dal::create_progress_photo(&state.pool, &photo).await?;
+    if let Err(err) = photos.write(photo.id, &body).await {
+        dal::delete_progress_photo(&state.pool, photo.id).await?;
+        return Err(err.into());
+    }
+
+    Ok(Json(ProgressPhoto::from(photo)))
+}
+
+async fn get_progress_photo_content(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Response, AppError> {
+    let photos = photo_storage(&state)?;
+
+    let photo = dal::get_progress_photo(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    let data = photos
+        .read(id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    // Photos stored before only some image types were accepted are
+    // downloaded instead of displayed.
+    let (content_type, disposition) = match photos::extension(&photo.content_type) {
+        Some(extension) => (
+            photo.content_type,
+            format!(r#"inline; filename="photo-{id}.{extension}""#),
+        ),
+        None => (
+            "application/octet-stream".to_string(),
+            format!(r#"attachment; filename="photo-{id}""#),
+        ),
+    };
+
+    Ok((
+        [
+            (CONTENT_TYPE, content_type),
+            (X_CONTENT_TYPE_OPTIONS, "nosniff".to_string()),
+            (CONTENT_DISPOSITION, disposition),
+        ],
+        data,
+    )
+        .into_response())
+}
+
+async fn delete_progress_photo(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    let photos = photo_storage(&state)?;
+
+    dal::get_progress_photo(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    // The file is removed first, so a failure leaves the photo listed and its
+    // deletion can be retried instead of leaving an orphaned file.
+    photos.remove(id).await?;
+
+    dal::delete_progress_photo(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn check_goal_exists<T>(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    request: Request<T>,
+    next: Next<T>,
+) -> Response {
+    match dal::get_goal(&state.pool, id).await {
+        Err(err) => {
+            error!(%err, "Failed to check if goal exists.");
+            StatusCode::INTERNAL_SERVER_ERROR.into_response()
+        }
+        Ok(None) => StatusCode::NOT_FOUND.into_response(),
+        _ => next.run(request).await,
+    }
+}
+
+async fn get_goal(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Goal>, AppError> {
+    dal::get_goal(&state.pool, id)
+        .await?
+        .map(|goal| Json(Goal::from(goal)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn get_goals(State(state): State<AppState>) -> Result<Json<Vec<Goal>>, AppError> {
+    let goals = dal::get_goals(&state.pool)
+        .await?
+        .into_iter()
+        .map(Goal::from)
+        .collect();
+    Ok(Json(goals))
+}
+
+async fn create_goal(
+    State(state): State<AppState>,
+    Json(goal): Json<CreateUpdateGoal>,
+) -> Result<Json<Goal>, AppError> {
+    let goal: GoalInput = goal.into();
+    validation::validate_goal(&goal)?;
+    ensure_goal_exercise_exists(&state, &goal).await?;
+    let goal = dal::create_goal(&state.pool, &goal).await?;
+    Ok(Json(Goal::from(goal)))
+}
+
+async fn update_goal(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(goal): Json<CreateUpdateGoal>,
+) -> Result<Json<Goal>, AppError> {
+    let goal: GoalInput = goal.into();
+    validation::validate_goal(&goal)?;
+    ensure_goal_exercise_exists(&state, &goal).await?;
+    let goal = dal::update_goal(&state.pool, id, &goal).await?;
+    Ok(Json(Goal::from(goal)))
+}
+
+async fn ensure_goal_exercise_exists(state: &AppState, goal: &GoalInput) -> Result<(), AppError> {
+    if let Some(exercise_id) = goal.exercise_id {
+        if dal::get_exercise(&state.pool, exercise_id).await?.is_none() {
+            return Err(AppError::StatusCode(StatusCode::NOT_FOUND));
+        }
+    }
+
+    Ok(())
+}
+
+async fn delete_goal(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_goal(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn get_goal_progress(
+    State(state): State<AppState>,
+    Query(query): Query<GetGoalProgress>,
+) -> Result<Json<Vec<GoalProgress>>, AppError> {
+    let offset = query
+        .utc_offset_minutes
+        .checked_mul(60)
+        .and_then(FixedOffset::east_opt)
+        .ok_or(AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+
+    let goals = dal::get_goals(&state.pool).await?;
+    let progress = goals::progress(&state.pool, goals, offset, Utc::now())
+        .await?
+        .into_iter()
+        .map(GoalProgress::from)
+        .collect();
+    Ok(Json(progress))
+}
+
+async fn get_exercise_count(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<responses::ExerciseCount>, AppError> {
+    let count = dal::get_exercise_count(&state.pool, id).await?;
+    Ok(Json(ExerciseCount::from(count)))
+}
+
+async fn get_exercise_usage(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<ExerciseUsage>, AppError> {
+    let usage = dal::get_exercise_usage(&state.pool, id).await?;
+    Ok(Json(ExerciseUsage::from(usage)))
+}
+
+async fn get_exercise_quick_stats(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Query(query): Query<GetExerciseQuickStats>,
+) -> Result<Json<ExerciseQuickStats>, AppError> {
+    let last_session =
+        dal::get_last_session_sets_by_exercise_id(&state.pool, id, query.workout_id).await?;
+    let personal_record = dal::get_personal_record_by_exercise_id(&state.pool, id).await?;
+
+    let estimated_one_rep_max = one_rep_max::best(
+        last_session
+            .iter()
+            .filter(|set| set.set_type != SetType::Warmup)
+            .filter_map(|set| Some((set.weight?, set.repetitions))),
+    );
+
+    Ok(Json(ExerciseQuickStats::new(
+        id,
+        last_session,
+        estimated_one_rep_max,
+        personal_record,
+        weight_conversion(&state, query.unit),
+    )))
+}
+
+async fn get_workout(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Workout>, AppError> {
+    dal::get_workout(&state.pool, id)
+        .await?
+        .map(|workout| Json(Workout::from(workout)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn get_config(State(state): State<AppState>) -> Json<Config> {
+    Json(Config::from(&state.config))
+}
+
+async fn get_workouts(State(state): State<AppState>) -> Result<Json<Vec<Workout>>, AppError> {
+    let workouts = dal::get_workouts(&state.pool)
+        .await?
+        .into_iter()
+        .map(Workout::from)
+        .collect();
+    Ok(Json(workouts))
+}
+
+async fn create_workout(State(state): State<AppState>) -> Result<Json<Workout>, AppError> {
+    let workout = dal::create_workout(&state.pool).await?;
+    Ok(Json(Workout::from(workout)))
+}
+
+async fn delete_workout(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_workout(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    if let Some(google_fit) = &state.google_fit {
+        google_fit.spawn_sync();
+    }
+
+    Ok(StatusCode::NO_CONTENT)
+}
+
+async fn update_workout_meta_data(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(request): Json<UpdateWorkoutMetaData>,
+) -> Result<Json<Workout>, AppError> {
+    dal::update_workout_meta_data(&state.pool, id, &request.note)
+        .await?
+        .map(|workout| Json(Workout::from(workout)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn finish_workout(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Workout>, AppError> {
+    let workout = dal::set_workout_finished(&state.pool, id, WorkoutAuditAction::Finish)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    state.notifier.notify(Notification {
+        event: NotificationEvent::WorkoutFinished,
+        title: "Workout finished".to_string(),
+        message: format!("The workout with id {id} was finished."),
+    });
+
+    insights::spawn_evaluation(
+        state.pool.clone(),
+        state.notifier.clone(),
+        Trigger::WorkoutFinished,
+    );
+
+    if let Some(google_fit) = &state.google_fit {
+        google_fit.spawn_sync();
+    }
+
+    Ok(Json(Workout::from(workout)))
+}
+
+async fn reopen_workout(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Workout>, AppError> {
+    dal::set_workout_finished(&state.pool, id, WorkoutAuditAction::Reopen)
+        .await?
+        .map(|workout| Json(Workout::from(workout)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+/// Exports a workout as an activity file for uploads to fitness platforms.
+async fn export_workout(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Query(query): Query<ExportWorkout>,
+) -> Result<Response, AppError> {
+    let workout = dal::get_workout(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+    let exercise_sets =
+        dal::get_exercise_sets_by_workout_id(&state.pool, id, None, PageInput::default()).await?;
+
+    let (content_type, extension, content) = match query.format {
+        ActivityFormat::Tcx => (
+            "application/vnd.garmin.tcx+xml",
+            "tcx",
+            export::workout_tcx(&workout, &exercise_sets, state.config.weight_unit),
+        ),
+    };
+
+    Ok((
+        [
+            (CONTENT_TYPE, content_type.to_string()),
+            (
+                CONTENT_DISPOSITION,
+                format!(r#"attachment; filename="workout-{id}.{extension}""#),
+            ),
+        ],
+        content,
+    )
+        .into_response())
+}
+
+/// Snapshot of a running workout for external displays like a wall screen
+/// in the gym, meant to be polled.
+async fn get_workout_display(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<WorkoutDisplay>, AppError> {
+    let workout = dal::get_workout(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+    let exercise_sets =
+        dal::get_exercise_sets_by_workout_id(&state.pool, id, None, PageInput::default()).await?;
+    let rest_timer = dal::get_rest_timer(&state.pool, id).await?;
+    Ok(Json(WorkoutDisplay::new(
+        workout,
+        exercise_sets,
+        rest_timer,
+        DEFAULT_REST_S,
+        Utc::now(),
+    )))
+}
+
+async fn get_workout_audit(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Vec<WorkoutAudit>>, AppError> {
+    let audit = dal::get_workout_audit(&state.pool, id)
+        .await?
+        .into_iter()
+        .map(WorkoutAudit::from)
+        .collect();
+    Ok(Json(audit))
+}
+
+async fn get_workout_draft(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<WorkoutDraft>, AppError> {
+    dal::get_workout_draft(&state.pool, id)
+        .await?
+        .map(|draft| Json(WorkoutDraft::from(draft)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn save_workout_draft(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(draft): Json<serde_json::Value>,
+) -> Result<Json<WorkoutDraft>, AppError> {
+    let data = draft.to_string();
+    validation::validate_workout_draft(&data)?;
+    let draft = dal::save_workout_draft(&state.pool, id, &data).await?;
+    Ok(Json(WorkoutDraft::from(draft)))
+}
+
+async fn delete_workout_draft(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_workout_draft(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn get_rest_timer(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<RestTimer>, AppError> {
+    dal::get_rest_timer(&state.pool, id)
+        .await?
+        .map(|timer| Json(RestTimer::new(timer, Utc::now())))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+/// Starts a rest countdown that every device of the workout sees, the
+/// remaining time is always computed by the server.
+async fn start_rest_timer(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(request): Json<StartRestTimer>,
+) -> Result<Json<RestTimer>, AppError> {
+    let duration_s = request.duration_s.unwrap_or(DEFAULT_REST_S);
+    validation::validate_rest_timer(duration_s)?;
+    ensure_workout_open(&state, id).await?;
+
+    let timer = dal::start_rest_timer(&state.pool, id, duration_s).await?;
+    Ok(Json(RestTimer::new(timer, Utc::now())))
+}
+
+async fn delete_rest_timer(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_rest_timer(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+/// Rejects changes to the sets of a finished workout, it has to be reopened
+/// explicitly first to protect historical data from accidental edits.
+async fn ensure_workout_open(state: &AppState, workout_id: i64) -> Result<(), AppError> {
+    match dal::get_workout(&state.pool, workout_id).await? {
+        Some(workout) if workout.finished.is_some() => {
+            Err(AppError::StatusCode(StatusCode::CONFLICT))
+        }
+        _ => Ok(()),
+    }
+}
+
+async fn ensure_exercise_set_open(state: &AppState, exercise_set_id: i64) -> Result<(), AppError> {
+    match dal::get_exercise_set(&state.pool, exercise_set_id).await? {
+        Some(exercise_set) => ensure_workout_open(state, exercise_set.workout_id).await,
+        None => Ok(()),
+    }
+}
+
+async fn get_exercise_set(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<WithAttachments<ExerciseSet>>, AppError> {
+    let exercise_set = dal::get_exercise_set(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+    let attachments = dal::get_attachments_by_exercise_set_id(&state.pool, id).await?;
+    Ok(Json(WithAttachments::new(
+        ExerciseSet::from(exercise_set),
+        attachments,
+    )))
+}
+
+async fn get_exercise_sets(
+    State(state): State<AppState>,
+    Query(query): Query<GetExerciseSets>,
+) -> Result<Json<Vec<ExerciseSet>>, AppError> {
+    let page = page_input(query.after, query.limit)?;
+
+    let exercise_sets = dal::get_exercise_sets(&state.pool, page)
+        .await?
+        .into_iter()
+        .map(ExerciseSet::from)
+        .collect();
+    Ok(Json(exercise_sets))
+}
+
+async fn get_exercise_sets_by_workout_id(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Query(query): Query<GetExerciseSetsByWorkoutId>,
+) -> Result<Response, AppError> {
+    let page = page_input(query.after, query.limit)?;
+
+    // Grouped sets are paginated by group, so all sets are needed.
+    let set_page = match query.group_by {
+        Some(_) => PageInput::default(),
+        None => page,
+    };
+
+    // A cursor that doesn't name a set of the workout, e.g. because the set
+    // was deleted, can't be continued from and would look like the end.
+    if let (None, Some(after)) = (query.group_by, page.after) {
+        dal::get_exercise_set(&state.pool, after)
+            .await?
+            .filter(|exercise_set| exercise_set.workout_id == id)
+            .ok_or_else(|| AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+    }
+
+    let exercise_sets =
+        dal::get_exercise_sets_by_workout_id(&state.pool, id, query.set_type, set_page).await?;
+
+    group_exercise_sets(exercise_sets, query.group_by, GroupPage::After(page))
+}
+
+/// Pages of sets. A limit below one is rejected instead of listing all sets.
+fn page_input(after: Option<i64>, limit: Option<i64>) -> Result<PageInput, AppError> {
+    if limit.map_or(false, |limit| limit < 1) {
+        return Err(AppError::StatusCode(StatusCode::BAD_REQUEST));
+    }
+
+    Ok(PageInput { after, limit })
+}
+
+async fn reorder_exercise_sets(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(request): Json<ReorderExerciseSets>,
+) -> Result<Json<Vec<ExerciseSet>>, AppError> {
+    ensure_workout_open(&state, id).await?;
+
+    dal::reorder_exercise_sets(&state.pool, id, &request.set_ids)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+
+    let exercise_sets =
+        dal::get_exercise_sets_by_workout_id(&state.pool, id, None, PageInput::default())
+            .await?
+            .into_iter()
+            .map(ExerciseSet::from)
+            .collect();
+    Ok(Json(exercise_sets))
+}
+
+async fn delete_exercise_sets_by_workout_id(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Query(query): Query<DeleteExerciseSets>,
+) -> Result<Json<DeletedExerciseSets>, AppError> {
+    if !query.preview {
+        ensure_workout_open(&state, id).await?;
+    }
+
+    let exercise_sets = dal::delete_exercise_sets_by_workout_id(
+        &state.pool,
+        id,
+        query.exercise_id,
+        query.created_within_minutes,
+        query.preview,
+    )
+    .await?;
+
+    Ok(Json(DeletedExerciseSets::new(query.preview, exercise_sets)))
+}
+
+async fn get_exercise_sets_by_exercise_id(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Query(query): Query<GetExerciseSetsByExerciseId>,
+) -> Result<Response, AppError> {
+    // Grouped sets are limited by group, so all sets are needed.
+    let set_limit = query.limit.filter(|_| query.group_by.is_none());
+    let exercise_sets =
+        dal::get_exercise_sets_by_exercise_id(&state.pool, id, query.from, query.to, set_limit)
+            .await?;
+
+    group_exercise_sets(exercise_sets, query.group_by, GroupPage::Last(query.limit))
+}
+
+/// Page of grouped sets, a group is never split across pages. Ungrouped sets
+/// are expected to be paginated already.
+#[derive(Debug, Clone, Copy)]
+enum GroupPage {
+    /// Groups after the group whose id is `after`, like [`PageInput`].
+    After(PageInput),
+    /// Only the last groups, e.g. the most recent workouts of an exercise.
+    Last(Option<i64>),
+}
+
+impl GroupPage {
+    /// Returns `None` if `after` isn't the id of any group.
+    fn apply<T>(self, groups: Vec<T>, id: impl Fn(&T) -> i64) -> Option<Vec<T>> {
+        let limit = |limit: Option<i64>| limit.map_or(groups.len(), |limit| limit.max(0) as usize);
+
+        let (start, count) = match self {
+            GroupPage::After(page) => {
+                let start = match page.after {
+                    Some(after) => groups.iter().position(|group| id(group) == after)? + 1,
+                    None => 0,
+                };
+                (start, limit(page.limit))
+            }
+            GroupPage::Last(last) => {
+                let count = limit(last);
+                (groups.len().saturating_sub(count), count)
+            }
+        };
+
+        Some(groups.into_iter().skip(start).take(count).collect())
+    }
+}
+
+fn group_exercise_sets(
+    exercise_sets: Vec<ExerciseSetEntity>,
+    group_by: Option<GroupBy>,
+    page: GroupPage,
+) -> Result<Response, AppError> {
+    let unresolved = || AppError::StatusCode(StatusCode::BAD_REQUEST);
+
+    Ok(match group_by {
+        Some(GroupBy::Workout) => {
+            let groups = WorkoutExerciseSets::group(exercise_sets);
+            Json(
+                page.apply(groups, |group| group.workout_id)
+                    .ok_or_else(unresolved)?,
+            )
+            .into_response()
+        }
+        Some(GroupBy::Exercise) => {
+            let groups = ExerciseGroupSets::group(exercise_sets);
+            Json(
+                page.apply(groups, |group| group.exercise_id)
+                    .ok_or_else(unresolved)?,
+            )
+            .into_response()
+        }
+        None => Json(
+            exercise_sets
+                .into_iter()
+                .map(ExerciseSet::from)
+                .collect::<Vec<_>>(),
+        )
+        .into_response(),
+    })
+}
+
+async fn create_exercise_set(
+    State(state): State<AppState>,
+    Json(exercise_set): Json<CreateUpdateExerciseSet>,
+) -> Result<Json<WithWarnings<ExerciseSet>>, AppError> {
+    let exercise_set = into_exercise_set_input(&state, exercise_set).await?;
+
+    let client_id = exercise_set.client_id.clone();
+
+    if let Some(existing) = get_exercise_set_by_client_id(&state, client_id.as_deref()).await? {
+        return Ok(Json(WithWarnings::new(
+            ExerciseSet::from(existing),
+            Vec::new(),
+        )));
+    }
+
+    ensure_workout_open(&state, exercise_set.workout_id).await?;
+    if let Some(exceeded) = state.limits.check_sets_per_day(&state.pool).await? {
+        return Err(AppError::Limit(StatusCode::TOO_MANY_REQUESTS, exceeded));
+    }
+    let warnings = heuristics::check_exercise_set(&state.pool, &exercise_set).await?;
+
+    let exercise_set =
+        match dal::create_or_update_exercise_set(&state.pool, None, exercise_set).await {
+            Ok(exercise_set) => exercise_set,
+            // A concurrent retry with the same client id won the race.
+            Err(err) => match get_exercise_set_by_client_id(&state, client_id.as_deref()).await? {
+                Some(existing) => existing,
+                None => return Err(err.into()),
+            },
+        };
+
+    review_exercise_set(&state, &exercise_set).await;
+    insights::spawn_evaluation(
+        state.pool.clone(),
+        state.notifier.clone(),
+        Trigger::SetWritten,
+    );
+    Ok(Json(WithWarnings::new(
+        ExerciseSet::from(exercise_set),
+        warnings,
+    )))
+}
+
+/// Flags anomalies and records of a written set. The set is already stored,
+/// so failures are only logged instead of failing the request.
+async fn review_exercise_set(state: &AppState, exercise_set: &ExerciseSetEntity) {
+    if let Err(err) = heuristics::flag_anomalies(&state.pool, exercise_set).await {
+        error!(
+            err = format!("{err:#}"),
+            exercise_set = exercise_set.id,
+            "Failed to flag anomalies."
+        );
+    }
+
+    if let Err(err) = records::record_events(&state.pool, &state.notifier, exercise_set).await {
+        error!(
+            err = format!("{err:#}"),
+            exercise_set = exercise_set.id,
+            "Failed to record personal records."
+        );
+    }
+}
+
+async fn get_exercise_set_by_client_id(
+    state: &AppState,
+    client_id: Option<&str>,
+) -> Result<Option<ExerciseSetEntity>, AppError> {
+    match client_id {
+        Some(client_id) => Ok(dal::get_exercise_set_by_client_id(&state.pool, client_id).await?),
+        None => Ok(None),
+    }
+}
+
+async fn update_exercise_set(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(exercise_set): Json<CreateUpdateExerciseSet>,
+) -> Result<Json<WithWarnings<ExerciseSet>>, AppError> {
+    let exercise_set = into_exercise_set_input(&state, exercise_set).await?;
+    ensure_exercise_set_open(&state, id).await?;
+    ensure_workout_open(&state, exercise_set.workout_id).await?;
+    let warnings = heuristics::check_exercise_set(&state.pool, &exercise_set).await?;
+    let previous = dal::get_exercise_set(&state.pool, id).await?;
+    let exercise_set =
+        dal::create_or_update_exercise_set(&state.pool, Some(id), exercise_set).await?;
+    review_exercise_set(&state, &exercise_set).await;
+    if let Some(previous) = previous {
+        records::spawn_reevaluation(state.pool.clone(), previous);
+    }
+    insights::spawn_evaluation(
+        state.pool.clone(),
+        state.notifier.clone(),
+        Trigger::SetWritten,
+    );
+    Ok(Json(WithWarnings::new(
+        ExerciseSet::from(exercise_set),
+        warnings,
+    )))
+}
+
+/// Validates a set as sent by the client and converts its weights to the unit
+/// of the instance if the client sent them in another unit. Validation comes
+/// first, as converted weights are rounded.
+///
+/// A weight entered as plates is resolved with the bar and plates of the gym,
+/// which are in the unit of the instance. It takes precedence over any weight
+/// sent along and keeps its exact total in the stored configuration, as the
+/// weight of the set is rounded to whole units.
+async fn into_exercise_set_input(
+    state: &AppState,
+    exercise_set: CreateUpdateExerciseSet,
+) -> Result<ExerciseSetInput, AppError> {
+    let weight_unit = state.config.weight_unit;
+    let from = exercise_set.weight_unit.unwrap_or(weight_unit);
+    let convert = |weight: Option<i64>| weight.map(|weight| from.convert(weight, weight_unit));
+
+    let plates = exercise_set.plates.clone();
+    let mut exercise_set = ExerciseSetInput::from(exercise_set);
+    validation::validate_exercise_set(&exercise_set)?;
+
+    exercise_set.weight = convert(exercise_set.weight);
+    exercise_set.added_weight = convert(exercise_set.added_weight);
+    exercise_set.target_weight = convert(exercise_set.target_weight);
+
+    if let Some(plates) = plates {
+        let gym = dal::get_gym(&state.pool, plates.gym_id).await?;
+        validation::validate_plates(&plates, gym.as_ref())?;
+        let gym = gym.expect("Gym must exist as the plates are valid");
+
+        let configuration = PlateConfiguration {
+            gym_id: Some(gym.id),
+            bar_weight: gym.bar_weight.unwrap_or_default(),
+            per_side: plates.per_side,
+            unit: Some(weight_unit),
+        };
+        exercise_set.weight = Some(configuration.total_weight().round() as i64);
+        exercise_set.plates =
+            Some(serde_json::to_string(&configuration).context("Failed to encode plates")?);
+    }
+
+    Ok(exercise_set)
+}
+
+async fn delete_exercise_set(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    ensure_exercise_set_open(&state, id).await?;
+
+    let exercise_set = dal::get_exercise_set(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    dal::delete_exercise_set(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    records::spawn_reevaluation(state.pool.clone(), exercise_set);
+    insights::spawn_evaluation(
+        state.pool.clone(),
+        state.notifier.clone(),
+        Trigger::SetWritten,
+    );
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// Moves a set that was logged against the wrong workout.
+async fn move_exercise_set(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(request): Json<MoveExerciseSet>,
+) -> Result<Json<ExerciseSet>, AppError> {
+    if dal::get_workout(&state.pool, request.workout_id)
+        .await?
+        .is_none()
+    {
+        return Err(AppError::StatusCode(StatusCode::NOT_FOUND));
+    }
+
+    ensure_exercise_set_open(&state, id).await?;
+    ensure_workout_open(&state, request.workout_id).await?;
+
+    dal::move_exercise_set(&state.pool, id, request.workout_id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    dal::get_exercise_set(&state.pool, id)
+        .await?
+        .map(|exercise_set| Json(ExerciseSet::from(exercise_set)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+/// Checks off a planned set, using the current time as its completion time.
+async fn complete_exercise_set(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<ExerciseSet>, AppError> {
+    ensure_exercise_set_open(&state, id).await?;
+
+    dal::complete_exercise_set(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    dal::get_exercise_set(&state.pool, id)
+        .await?
+        .map(|exercise_set| Json(ExerciseSet::from(exercise_set)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn get_set_suggestion(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(request): Json<GetSetSuggestion>,
+) -> Result<Json<SetSuggestion>, AppError> {
+    let suggestion =
+        overload::suggest(&state.pool, id, request.exercise_id, request.gym_id).await?;
+
+    // An exercise id of 0 means there was nothing to base the suggestion on.
+    let superset = if request.superset && suggestion.exercise_id != 0 {
+        supersets::find_pair(&state.pool, id, suggestion.exercise_id, request.gym_id).await?
+    } else {
+        None
+    };
+
+    Ok(Json(SetSuggestion::new(suggestion, superset)))
+}
+
+/// Same as [`get_set_suggestion`] with the parameters in the query, e.g.
+/// `?exercise_id=` after switching exercises mid-workout.
+async fn get_set_recommendation(
+    state: State<AppState>,
+    path: Path<i64>,
+    Query(query): Query<GetSetSuggestion>,
+) -> Result<Json<SetSuggestion>, AppError> {
+    get_set_suggestion(state, path, Json(query)).await
+}
+
+/// Weights of statistics are in the unit the client asks for, by default in
+/// the unit of the instance.
+fn weight_conversion(state: &AppState, unit: Option<WeightUnit>) -> WeightConversion {
+    let from = state.config.weight_unit;
+    WeightConversion::new(from, unit.unwrap_or(from))
+}
+
+async fn get_statistics_overview(
+    State(state): State<AppState>,
+    uri: Uri,
+    Query(query): Query<GetStatisticsOverview>,
+) -> Result<Json<StatisticsOverview>, AppError> {
+    let key = uri.to_string();
+    let generation = dal::get_statistics_version(&state.pool).await?;
+    if let Some(overview) = state.cache.get(&key, generation) {
+        return Ok(Json(overview));
+    }
+
+    let filter = StatisticsFilterInput {
+        tag: query.tag.map(|tag| tag.trim().to_lowercase()),
+        from: query.from,
+        to: query.to,
+    };
+    let offset = query
+        .utc_offset_minutes
+        .checked_mul(60)
+        .and_then(FixedOffset::east_opt)
+        .ok_or(AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+
+    let conversion = weight_conversion(&state, query.unit);
+    let latest_body_weight = latest_body_weight(&state).await?;
+    let body_weight = query
+        .body_weight
+        .map(|weight| conversion.inverse().weight(weight))
+        .or(latest_body_weight);
+
+    let overview = dal::get_statistics_overview(&state.pool, body_weight, &filter).await?;
+    let starts = dal::get_workout_starts(&state.pool, &filter).await?;
+    let frequency = frequency::frequency(&starts, offset, Utc::now());
+    let overview = StatisticsOverview::new(overview, frequency, latest_body_weight, conversion);
+    state.cache.insert(key, generation, overview.clone());
+    Ok(Json(overview))
+}
+
+async fn get_volume(
+    State(state): State<AppState>,
+    Query(query): Query<GetVolume>,
+) -> Result<Json<Vec<Volume>>, AppError> {
+    let filter = VolumeFilterInput {
+        group: query.group,
+        muscle: query.muscle.map(|muscle| muscle.trim().to_lowercase()),
+        exercise_id: query.exercise_id,
+    };
+    let conversion = weight_conversion(&state, query.unit);
+    let body_weight = match query.body_weight {
+        Some(weight) => Some(conversion.inverse().weight(weight)),
+        None => latest_body_weight(&state).await?,
+    };
+    let volume = dal::get_volume(&state.pool, body_weight, &filter)
+        .await?
+        .into_iter()
+        .map(|volume| Volume::new(volume, conversion))
+        .collect();
+    Ok(Json(volume))
+}
+
+async fn get_muscle_volume(
+    State(state): State<AppState>,
+    Query(query): Query<GetMuscleVolume>,
+) -> Result<Json<Vec<MuscleVolume>>, AppError> {
+    let offset = query
+        .utc_offset_minutes
+        .checked_mul(60)
+        .and_then(FixedOffset::east_opt)
+        .ok_or(AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+    let offset = i64::from(offset.local_minus_utc());
+
+    let volume = dal::get_muscle_volume(&state.pool, offset, query.from, query.to)
+        .await?
+        .into_iter()
+        .map(MuscleVolume::from)
+        .collect();
+    Ok(Json(volume))
+}
+
+async fn get_training_times(
+    State(state): State<AppState>,
+    Query(query): Query<GetTrainingTimes>,
+) -> Result<Json<TrainingTimes>, AppError> {
+    let offset = query
+        .utc_offset_minutes
+        .checked_mul(60)
+        .and_then(FixedOffset::east_opt)
+        .ok_or(AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+    let offset = i64::from(offset.local_minus_utc());
+
+    let weekdays =
+        dal::get_volume_by_time_slot(&state.pool, TimeSlot::Weekday, offset, query.from, query.to)
+            .await?;
+    let hours =
+        dal::get_volume_by_time_slot(&state.pool, TimeSlot::Hour, offset, query.from, query.to)
+            .await?;
+    Ok(Json(TrainingTimes::new(
+        weekdays,
+        hours,
+        weight_conversion(&state, query.unit),
+    )))
+}
+
+async fn get_intensity(
+    State(state): State<AppState>,
+    uri: Uri,
+    Query(query): Query<GetIntensity>,
+) -> Result<Json<Intensity>, AppError> {
+    let key = uri.to_string();
+    let generation = dal::get_statistics_version(&state.pool).await?;
+    if let Some(intensity) = state.cache.get(&key, generation) {
+        return Ok(Json(intensity));
+    }
+
+    let sets = dal::get_session_sets(&state.pool, None).await?;
+    let (exercises, workouts) = intensity::intensity(&sets, query.from, query.to);
+    let intensity = Intensity::new(exercises, workouts, weight_conversion(&state, query.unit));
+    state.cache.insert(key, generation, intensity.clone());
+    Ok(Json(intensity))
+}
+
+async fn get_series(
+    State(state): State<AppState>,
+    Query(query): Query<GetSeries>,
+) -> Result<Json<Series>, AppError> {
+    let points = query.points.unwrap_or(series::DEFAULT_POINTS);
+    // Estimates of different exercises can't be combined.
+    let needs_exercise = matches!(query.metric, ProgressionMetric::E1rm);
+    if !(series::MIN_POINTS..=series::MAX_POINTS).contains(&points)
+        || (needs_exercise && query.exercise_id.is_none())
+    {
+        return Err(AppError::StatusCode(StatusCode::BAD_REQUEST));
+    }
+
+    let sets = dal::get_session_sets(&state.pool, query.exercise_id).await?;
+    let values = match query.metric {
+        ProgressionMetric::E1rm => series::estimated_one_rep_max(&sets),
+        ProgressionMetric::Volume => series::volume(&sets),
+    };
+
+    Ok(Json(Series::new(
+        values.len(),
+        series::downsample(&values, points),
+        weight_conversion(&state, query.unit),
+    )))
+}
+
+async fn get_relative_strength(
+    State(state): State<AppState>,
+    Query(query): Query<GetRelativeStrength>,
+) -> Result<Json<Vec<RelativeStrength>>, AppError> {
+    let conversion = weight_conversion(&state, query.unit);
+    let body_weight = match query.body_weight {
+        Some(weight) if weight > 0 => conversion.inverse().value(weight as f64),
+        Some(_) => return Err(AppError::StatusCode(StatusCode::BAD_REQUEST)),
+        None => latest_body_weight(&state)
+            .await?
+            .map(|weight| weight as f64)
+            .ok_or(AppError::StatusCode(StatusCode::BAD_REQUEST))?,
+    };
+
+    let sets = dal::get_session_sets(&state.pool, None).await?;
+    let strengths =
+        strength::relative_strength(&sets, body_weight, state.config.weight_unit, query.sex)
+            .into_iter()
+            .map(|strength| RelativeStrength::new(strength, conversion))
+            .collect();
+    Ok(Json(strengths))
+}
+
+/// Exports an aggregated series as CSV, e.g. for spreadsheets.
+async fn export_statistics(
+    State(state): State<AppState>,
+    Query(query): Query<ExportStatistics>,
+) -> Result<Response, AppError> {
+    let conversion = weight_conversion(&state, query.unit);
+    let csv = match query.metric {
+        ProgressionMetric::Volume => {
+            let filter = VolumeFilterInput {
+                group: query.group,
+                muscle: query.muscle.map(|muscle| muscle.trim().to_lowercase()),
+                exercise_id: query.exercise_id,
+            };
+            let body_weight = latest_body_weight(&state).await?;
+            let volume = dal::get_volume(&state.pool, body_weight, &filter).await?;
+            export::volume_csv(&volume, conversion)
+        }
+        // Estimates of different exercises can't be combined.
+        ProgressionMetric::E1rm => {
+            let exercise_id = query
+                .exercise_id
+                .ok_or(AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+            let sets = dal::get_session_sets(&state.pool, Some(exercise_id)).await?;
+            let estimates = one_rep_max::series(&sets, Formula::default());
+            export::one_rep_max_csv(&estimates, query.group, conversion)
+        }
+    };
+
+    Ok((
+        [
+            (CONTENT_TYPE, "text/csv; charset=utf-8"),
+            (
+                CONTENT_DISPOSITION,
+                r#"attachment; filename="statistics.csv""#,
+            ),
+        ],
+        csv,
+    )
+        .into_response())
+}
+
+async fn get_personal_records(
+    State(state): State<AppState>,
+    Query(query): Query<GetPersonalRecords>,
+) -> Result<Json<Vec<PersonalRecords>>, AppError> {
+    let sets = dal::get_weighted_working_sets(&state.pool, query.from, query.to).await?;
+    let conversion = weight_conversion(&state, query.unit);
+    let records = records::personal_records(&sets)
+        .into_iter()
+        .map(|records| PersonalRecords::new(records, conversion))
+        .collect();
+    Ok(Json(records))
+}
+
+async fn get_trends(
+    State(state): State<AppState>,
+    uri: Uri,
+    Query(query): Query<GetTrends>,
+) -> Result<Json<Vec<ExerciseTrend>>, AppError> {
+    let weeks = query.weeks.unwrap_or(trends::DEFAULT_WEEKS);
+    if !(1..=trends::MAX_WEEKS).contains(&weeks) {
+        return Err(AppError::StatusCode(StatusCode::BAD_REQUEST));
+    }
+
+    let key = uri.to_string();
+    let generation = dal::get_statistics_version(&state.pool).await?;
+    if let Some(trends) = state.cache.get(&key, generation) {
+        return Ok(Json(trends));
+    }
+
+    let conversion = weight_conversion(&state, query.unit);
+    let since = Utc::now() - chrono::Duration::weeks(weeks);
+    let sets = dal::get_session_sets(&state.pool, None).await?;
+    let trends = trends::trends(&sets, since)
+        .into_iter()
+        .map(|trend| ExerciseTrend::new(trend, conversion))
+        .collect::<Vec<_>>();
+    state.cache.insert(key, generation, trends.clone());
+    Ok(Json(trends))
+}
+
+async fn get_estimated_one_rep_max(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Query(query): Query<GetEstimatedOneRepMax>,
+) -> Result<Json<Vec<SessionEstimate>>, AppError> {
+    let conversion = weight_conversion(&state, query.unit);
+    let sets = dal::get_session_sets(&state.pool, Some(id)).await?;
+    let series = one_rep_max::series(&sets, query.formula)
+        .into_iter()
+        .map(|estimate| SessionEstimate::new(estimate, conversion))
+        .collect();
+    Ok(Json(series))
+}
+
+async fn get_progression(
+    State(state): State<AppState>,
+    Query(query): Query<GetProgression>,
+) -> Result<Json<Progression>, AppError> {
+    let (progression, exercises) = load_progression(&state, &query.exercise_ids).await?;
+    Ok(Json(Progression::new(
+        progression,
+        exercises,
+        weight_conversion(&state, query.unit),
+    )))
+}
+
+async fn get_training_report(
+    State(state): State<AppState>,
+    Path(period): Path<Period>,
+    Query(query): Query<GetTrainingReport>,
+) -> Result<Json<TrainingReport>, AppError> {
+    let report = training_report::build(&state.pool, period, report_time(query.at)?).await?;
+    Ok(Json(TrainingReport::new(
+        report,
+        weight_conversion(&state, query.unit),
+    )))
+}
+
+async fn get_training_report_pdf(
+    State(state): State<AppState>,
+    Path(period): Path<Period>,
+    Query(query): Query<GetTrainingReport>,
+) -> Result<Response, AppError> {
+    let report = training_report::build(&state.pool, period, report_time(query.at)?).await?;
+    let filename = format!(
+        "report-{}-{}.pdf",
+        period.as_str(),
+        report.start.date_naive()
+    );
+    let pdf = training_report::render_pdf(&report, weight_conversion(&state, query.unit));
+    Ok((
+        [
+            (CONTENT_TYPE, "application/pdf".to_string()),
+            (
+                CONTENT_DISPOSITION,
+                format!(r#"attachment; filename="{filename}""#),
+            ),
+        ],
+        pdf,
+    )
+        .into_response())
+}
+
+fn report_time(at: Option<i64>) -> Result<DateTime<Utc>, AppError> {
+    match at {
+        Some(at) => Utc
+            .timestamp_opt(at, 0)
+            .single()
+            .ok_or(AppError::StatusCode(StatusCode::BAD_REQUEST)),
+        None => Ok(Utc::now()),
+    }
+}
+
+async fn get_progression_chart(
+    State(state): State<AppState>,
+    Query(query): Query<GetProgressionChart>,
+) -> Result<Json<serde_json::Value>, AppError> {
+    let (progression, exercises) = load_progression(&state, &query.exercise_ids).await?;
+    Ok(Json(charts::progression(
+        &progression,
+        &exercises,
+        query.metric,
+        weight_conversion(&state, query.unit),
+    )))
+}
+
+async fn get_workouts_per_week_chart(
+    State(state): State<AppState>,
+) -> Result<Json<serde_json::Value>, AppError> {
+    let weeks = dal::get_weekly_workout_counts(&state.pool).await?;
+    Ok(Json(charts::workouts_per_week(&weeks)))
+}
+
+/// Loads the weekly progression of a comma separated list of exercise ids.
+async fn load_progression(
+    state: &AppState,
+    exercise_ids: &str,
+) -> Result<(ProgressionEntity, Vec<ExerciseEntity>), AppError> {
+    let exercise_ids = exercise_ids
+        .split(',')
+        .map(|id| id.trim().parse::<i64>())
+        .collect::<Result<Vec<_>, _>>()
+        .map_err(|_| AppError::StatusCode(StatusCode::BAD_REQUEST))?;
+
+    let mut exercises = Vec::with_capacity(exercise_ids.len());
+
+    for &id in &exercise_ids {
+        let exercise = dal::get_exercise(&state.pool, id)
+            .await?
+            .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+        exercises.push(exercise);
+    }
+
+    let progression = dal::get_weekly_progression(&state.pool, &exercise_ids).await?;
+    Ok((progression, exercises))
+}
+
+async fn get_attachments(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Vec<Attachment>>, AppError> {
+    let attachments = dal::get_attachments_by_exercise_set_id(&state.pool, id)
+        .await?
+        .into_iter()
+        .map(Attachment::from)
+        .collect();
+    Ok(Json(attachments))
+}
+
+/// Stores a photo or video of a set, the raw file is sent as the body with a
+/// matching content type. Only the types of `photos::attachment_type` are
+/// accepted, as attachments are served from the origin of the application.
+async fn upload_attachment(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Query(query): Query<UploadAttachment>,
+    headers: HeaderMap,
+    body: Bytes,
+) -> Result<Json<Attachment>, AppError> {
+    let content_type = headers
+        .get(CONTENT_TYPE)
+        .and_then(|value| value.to_str().ok())
+        .and_then(photos::attachment_type)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::UNSUPPORTED_MEDIA_TYPE))?;
+
+    if let Some(exceeded) = state
+        .limits
+        .check_attachment_storage(&state.pool, body.len())
+        .await?
+    {
+        return Err(AppError::Limit(StatusCode::FORBIDDEN, exceeded));
+    }
+
+    let attachment = AttachmentInput {
+        content_type: Some(content_type.to_string()),
+        file_name: query.file_name,
+        url: None,
+        data: Some(body.to_vec()),
+    };
+
+    let attachment = dal::create_attachment(&state.pool, id, attachment).await?;
+    Ok(Json(Attachment::from(attachment)))
+}
+
+async fn link_attachment(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+    Json(request): Json<LinkAttachment>,
+) -> Result<Json<Attachment>, AppError> {
+    let url = request.url.trim();
+    validation::validate_attachment_url(url)?;
+
+    let attachment = AttachmentInput {
+        content_type: None,
+        file_name: None,
+        url: Some(url.to_string()),
+        data: None,
+    };
+
+    let attachment = dal::create_attachment(&state.pool, id, attachment).await?;
+    Ok(Json(Attachment::from(attachment)))
+}
+
+/// Returns the stored file of an uploaded attachment and redirects to the
+/// link of a linked one.
+async fn get_attachment_content(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Response, AppError> {
+    let content = dal::get_attachment_content(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    match (content.data, content.url) {
+        (Some(data), _) => {
+            // Files stored before only some types were accepted are
+            // downloaded instead of displayed.
+            let (content_type, disposition) = match content
+                .content_type
+                .as_deref()
+                .and_then(photos::attachment_type)
+            {
+                Some(content_type) => (content_type, "inline".to_string()),
+                None => (
+                    "application/octet-stream",
+                    format!(r#"attachment; filename="attachment-{id}""#),
+                ),
+            };
+
+            Ok((
+                [
+                    (CONTENT_TYPE, content_type.to_string()),
+                    (X_CONTENT_TYPE_OPTIONS, "nosniff".to_string()),
+                    (CONTENT_DISPOSITION, disposition),
+                ],
+                data,
+            )
+                .into_response())
+        }
+        (None, Some(url)) => Ok(Redirect::temporary(&url).into_response()),
+        (None, None) => Err(AppError::StatusCode(StatusCode::NOT_FOUND)),
+    }
+}
+
+async fn delete_attachment(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_attachment(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn get_corrections(State(state): State<AppState>) -> Result<Json<Vec<Correction>>, AppError> {
+    let corrections = dal::get_corrections(&state.pool)
+        .await?
+        .into_iter()
+        .map(Correction::from)
+        .collect();
+    Ok(Json(corrections))
+}
+
+async fn get_errors(State(state): State<AppState>) -> Json<Errors> {
+    Json(Errors::from(state.errors.report(Utc::now())))
+}
+
+async fn get_insights(
+    State(state): State<AppState>,
+    Query(query): Query<GetInsights>,
+) -> Result<Json<Vec<Insight>>, AppError> {
+    let insights = dal::get_insights(&state.pool, query.dismissed)
+        .await?
+        .into_iter()
+        .map(Insight::from)
+        .collect();
+    Ok(Json(insights))
+}
+
+async fn acknowledge_insight(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Insight>, AppError> {
+    dal::acknowledge_insight(&state.pool, id)
+        .await?
+        .map(|insight| Json(Insight::from(insight)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+async fn dismiss_insight(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<Json<Insight>, AppError> {
+    dal::dismiss_insight(&state.pool, id)
+        .await?
+        .map(|insight| Json(Insight::from(insight)))
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+/// Lifts without a new best estimated one-repetition maximum in the last
+/// `sessions` sessions.
+async fn get_plateaus(
+    State(state): State<AppState>,
+    Query(query): Query<GetPlateaus>,
+) -> Result<Json<Vec<Plateau>>, AppError> {
+    let weeks = query.weeks.unwrap_or(insights::DEFAULT_PLATEAU_WEEKS);
+    if !(1..=insights::MAX_PLATEAU_WEEKS).contains(&weeks) {
+        return Err(AppError::StatusCode(StatusCode::BAD_REQUEST));
+    }
+
+    let now = Utc::now();
+    let conversion = weight_conversion(&state, query.unit);
+    let plateaus = insights::find_plateaus(&state.pool, weeks, now)
+        .await?
+        .into_iter()
+        .map(|plateau| Plateau::new(plateau, now, conversion))
+        .collect();
+    Ok(Json(plateaus))
+}
+
+async fn get_stalls(
+    State(state): State<AppState>,
+    Query(query): Query<GetStalls>,
+) -> Result<Json<Vec<Stall>>, AppError> {
+    let sessions = query.sessions.unwrap_or(insights::DEFAULT_STALL_SESSIONS);
+    let conversion = weight_conversion(&state, query.unit);
+    let stalls = insights::find_stalls(&state.pool, sessions)
+        .await?
+        .into_iter()
+        .map(|stall| Stall::new(stall, conversion))
+        .collect();
+    Ok(Json(stalls))
+}
+
+async fn get_notification_channels(
+    State(state): State<AppState>,
+) -> Result<Json<Vec<NotificationChannel>>, AppError> {
+    let channels = dal::get_notification_channels(&state.pool)
+        .await?
+        .into_iter()
+        .map(NotificationChannel::new)
+        .collect::<anyhow::Result<_>>()?;
+    Ok(Json(channels))
+}
+
+async fn create_notification_channel(
+    State(state): State<AppState>,
+    Json(request): Json<CreateNotificationChannel>,
+) -> Result<Json<NotificationChannel>, AppError> {
+    validation::validate_transport(&request.transport)?;
+    let channel = NotificationChannelInput {
+        name: request.name,
+        transport: serde_json::to_string(&request.transport)
+            .context("Failed to serialize transport")?,
+    };
+    let channel = dal::create_notification_channel(&state.pool, &channel).await?;
+    Ok(Json(NotificationChannel::new(channel)?))
+}
+
+async fn delete_notification_channel(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_notification_channel(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+/// Sends a test notification right away, failures of the transport are
+/// reported as a bad gateway.
+async fn test_notification_channel(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    let channel = dal::get_notification_channel(&state.pool, id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    let notification = Notification {
+        event: NotificationEvent::Test,
+        title: "Test notification".to_string(),
+        message: format!(r#"The channel "{}" is set up correctly."#, channel.name),
+    };
+
+    let transport = Transport::from_channel(&channel)?;
+    match transport.send(state.notifier.client(), &notification).await {
+        Ok(()) => Ok(StatusCode::NO_CONTENT),
+        Err(err) => {
+            warn!(
+                err = format!("{err:#}"),
+                channel = id,
+                "Failed to send test notification."
+            );
+            Err(AppError::StatusCode(StatusCode::BAD_GATEWAY))
+        }
+    }
+}
+
+async fn get_notification_rules(
+    State(state): State<AppState>,
+) -> Result<Json<Vec<NotificationRule>>, AppError> {
+    let rules = dal::get_notification_rules(&state.pool)
+        .await?
+        .into_iter()
+        .map(NotificationRule::from)
+        .collect();
+    Ok(Json(rules))
+}
+
+async fn create_notification_rule(
+    State(state): State<AppState>,
+    Json(request): Json<CreateNotificationRule>,
+) -> Result<Json<NotificationRule>, AppError> {
+    let mut errors = Vec::new();
+
+    if request.event == NotificationEvent::Test {
+        errors.push(FieldError {
+            field: "event",
+            code: "invalid",
+            message: "Test notifications can't be routed.".to_string(),
+        });
+    }
+
+    if dal::get_notification_channel(&state.pool, request.channel_id)
+        .await?
+        .is_none()
+    {
+        errors.push(FieldError {
+            field: "channelId",
+            code: "not_found",
+            message: format!("There is no channel with id {}.", request.channel_id),
+        });
+    }
+
+    if !errors.is_empty() {
+        return Err(ValidationError(errors).into());
+    }
+
+    let rule =
+        dal::create_notification_rule(&state.pool, request.event, request.channel_id).await?;
+    Ok(Json(NotificationRule::from(rule)))
+}
+
+async fn delete_notification_rule(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_notification_rule(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+/// Converts the weights of an exercise that were logged in the wrong unit.
+async fn create_weight_correction(
+    State(state): State<AppState>,
+    Json(request): Json<CreateWeightCorrection>,
+) -> Result<Json<Correction>, AppError> {
+    if request.from == request.to {
+        return Err(ValidationError(vec![FieldError {
+            field: "to",
+            code: "same_unit",
+            message: "The units to convert between must differ.".to_string(),
+        }])
+        .into());
+    }
+
+    let exercise = dal::get_exercise(&state.pool, request.exercise_id)
+        .await?
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))?;
+
+    let before = request
+        .created_before_utc_s
+        .and_then(|created_before_utc_s| Utc.timestamp_opt(created_before_utc_s, 0).single())
+        .map(|created_before| format!(" before {}", created_before.date_naive()))
+        .unwrap_or_default();
+
+    let correction = WeightCorrectionInput {
+        exercise_id: exercise.id,
+        created_before_utc_s: request.created_before_utc_s,
+        factor: request.from.kilograms() / request.to.kilograms(),
+        description: format!(
+            r#"Converted weights of exercise "{}"{before} from {} to {}"#,
+            exercise.name,
+            request.from.as_str(),
+            request.to.as_str()
+        ),
+    };
+
+    let correction = dal::correct_weights(&state.pool, &correction).await?;
+    Ok(Json(Correction::from(correction)))
+}
+
+async fn get_recent_pr_events(
+    State(state): State<AppState>,
+    Query(query): Query<GetRecentPrEvents>,
+) -> Result<Json<Vec<PrEvent>>, AppError> {
+    let limit = query.limit.unwrap_or(DEFAULT_PR_EVENTS);
+    if !(1..=MAX_PR_EVENTS).contains(&limit) {
+        return Err(AppError::StatusCode(StatusCode::BAD_REQUEST));
+    }
+
+    let conversion = weight_conversion(&state, query.unit);
+    let events = dal::get_recent_pr_events(&state.pool, limit)
+        .await?
+        .into_iter()
+        .map(|event| PrEvent::new(event, conversion))
+        .collect();
+    Ok(Json(events))
+}
+
+async fn get_set_anomalies(
+    State(state): State<AppState>,
+) -> Result<Json<Vec<SetAnomaly>>, AppError> {
+    let anomalies = dal::get_set_anomalies(&state.pool)
+        .await?
+        .into_iter()
+        .map(SetAnomaly::from)
+        .collect();
+    Ok(Json(anomalies))
+}
+
+async fn delete_set_anomaly(
+    State(state): State<AppState>,
+    Path(id): Path<i64>,
+) -> Result<StatusCode, AppError> {
+    dal::delete_set_anomaly(&state.pool, id)
+        .await?
+        .map(|_| StatusCode::NO_CONTENT)
+        .ok_or_else(|| AppError::StatusCode(StatusCode::NOT_FOUND))
+}
+
+#[derive(Debug)]
+enum AppError {
+    Err(anyhow::Error),
+    StatusCode(StatusCode),
+    Validation(ValidationError),
+    Limit(StatusCode, LimitExceeded),
+}
+
+impl From<anyhow::Error> for AppError {
     fn from(err: anyhow::Error) -> Self {
         Self::Err(err)
     }
-}
+}
+
+impl From<ValidationError> for AppError {
+    fn from(err: ValidationError) -> Self {
+        Self::Validation(err)
+    }
+}
+
+impl IntoResponse for AppError {
+    fn into_response(self) -> Response {
+        match self {
+            Self::Err(err) => {
+                let category = if err.downcast_ref::<sqlx::Error>().is_some() {
+                    "Database error."
+                } else {
+                    "Unknown error."
+                };
+                let message = format!("{err:#}");
+                error!(err = message, "{category}");
+                let mut response = StatusCode::INTERNAL_SERVER_ERROR.into_response();
+                response.extensions_mut().insert(ErrorMessage(message));
+                response
+            }
+            Self::StatusCode(status) => status.into_response(),
+            Self::Validation(err) => (
+                StatusCode::UNPROCESSABLE_ENTITY,
+                Json(ValidationErrors::from(err)),
+            )
+                .into_response(),
+            Self::Limit(status, exceeded) => {
+                (status, Json(LimitError::from(exceeded))).into_response()
+            }
+        }
+    }
+}
+
+mod requests {
+    use serde::{Deserialize, Serialize};
+
+    use crate::charts::ProgressionMetric;
+    use crate::export::ActivityFormat;
+    use crate::notifications::Transport;
+    use crate::one_rep_max::Formula;
+    use crate::plates::{PlateSelection, PlateStock};
+    use crate::strength::Sex;
+    use crate::units::WeightUnit;
+
+    use crate::dal::{
+        BodyWeightInput, ExerciseCategory, ExerciseInput, ExerciseModality, ExerciseSetInput,
+        ExerciseSort, GoalInput, GoalKind, GymInput, NotificationEvent, SetType, VolumeGroup,
+    };
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct CreateUpdateExercise {
+        pub name: String,
+        pub modality: Option<ExerciseModality>,
+        pub category: Option<ExerciseCategory>,
+        pub description: Option<String>,
+        pub instructions: Option<String>,
+        #[serde(rename = "videoUrl")]
+        pub video_url: Option<String>,
+        #[serde(rename = "defaultIncrement")]
+        pub default_increment: Option<i64>,
+        #[serde(rename = "minRepetitions")]
+        pub min_repetitions: Option<i64>,
+        #[serde(rename = "maxRepetitions")]
+        pub max_repetitions: Option<i64>,
+        #[serde(rename = "primaryMuscles")]
+        pub primary_muscles: Option<Vec<String>>,
+        #[serde(rename = "secondaryMuscles")]
+        pub secondary_muscles: Option<Vec<String>>,
+        pub equipment: Option<Vec<String>>,
+        pub tags: Option<Vec<String>>,
+    }
+
+    impl From<CreateUpdateExercise> for ExerciseInput {
+        fn from(value: CreateUpdateExercise) -> Self {
+            Self {
+                name: value.name,
+                modality: value.modality,
+                category: value.category,
+                description: value.description,
+                instructions: value.instructions,
+                video_url: value.video_url.map(|url| url.trim().to_string()),
+                default_increment: value.default_increment,
+                min_repetitions: value.min_repetitions,
+                max_repetitions: value.max_repetitions,
+                primary_muscles: value.primary_muscles.map(normalize_list),
+                secondary_muscles: value.secondary_muscles.map(normalize_list),
+                equipment: value.equipment.map(normalize_list),
+                tags: value.tags.map(normalize_list),
+            }
+        }
+    }
+
+    fn normalize_list(items: Vec<String>) -> Vec<String> {
+        items
+            .into_iter()
+            .map(|item| item.trim().to_lowercase())
+            .collect()
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct DeleteExercise {
+        #[serde(default)]
+        pub force: bool,
+    }
+
+    /// Exercises are filtered by a muscle group they train, e.g. `back`, by
+    /// their category, by a tag and by the equipment available at a gym.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetExercises {
+        pub muscle: Option<String>,
+        pub category: Option<ExerciseCategory>,
+        #[serde(rename = "gymId")]
+        pub gym_id: Option<i64>,
+        pub tag: Option<String>,
+        #[serde(default)]
+        pub sort: ExerciseSort,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct CreateNotificationChannel {
+        pub name: String,
+        pub transport: Transport,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct CreateNotificationRule {
+        pub event: NotificationEvent,
+        #[serde(rename = "channelId")]
+        pub channel_id: i64,
+    }
+
+    #[derive(Debug, Deserialize)]
+    pub struct CreateBodyWeight {
+        pub weight: i64,
+        /// Unit of the weight, defaults to the unit of the instance.
+        #[serde(rename = "weightUnit")]
+        pub weight_unit: Option<WeightUnit>,
+        #[serde(rename = "measuredUtcSeconds")]
+        pub measured_utc_s: Option<i64>,
+    }
+
+    impl CreateBodyWeight {
+        pub fn into_input(self, weight_unit: WeightUnit) -> BodyWeightInput {
+            let from = self.weight_unit.unwrap_or(weight_unit);
+            BodyWeightInput {
+                weight: from.convert(self.weight, weight_unit),
+                measured_utc_s: self.measured_utc_s,
+            }
+        }
+    }
+
+    /// Only body weights measured within `from` (inclusive) and `to`
+    /// (exclusive) in UTC seconds are listed.
+    #[derive(Debug, Deserialize)]
+    pub struct GetBodyWeights {
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+    }
+
+    /// Weights of targets are in the unit of the instance.
+    #[derive(Debug, Deserialize)]
+    pub struct CreateUpdateGoal {
+        pub kind: GoalKind,
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: Option<i64>,
+        pub target: i64,
+        #[serde(rename = "deadlineUtcSeconds")]
+        pub deadline_utc_s: Option<i64>,
+    }
+
+    impl From<CreateUpdateGoal> for GoalInput {
+        fn from(value: CreateUpdateGoal) -> Self {
+            Self {
+                kind: value.kind,
+                exercise_id: value.exercise_id,
+                target: value.target,
+                deadline_utc_s: value.deadline_utc_s,
+            }
+        }
+    }
+
+    /// Weekly workouts are counted in the week starting on Monday in the time
+    /// zone of the user.
+    #[derive(Debug, Deserialize)]
+    pub struct GetGoalProgress {
+        #[serde(default, rename = "utcOffsetMinutes")]
+        pub utc_offset_minutes: i32,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct CreateUpdateGym {
+        pub name: String,
+        #[serde(default)]
+        pub equipment: Vec<String>,
+        /// Weight of the bar in the unit of the instance, none if the gym has
+        /// no barbell.
+        #[serde(rename = "barWeight")]
+        pub bar_weight: Option<f64>,
+        #[serde(default)]
+        pub plates: Vec<PlateStock>,
+    }
+
+    impl From<CreateUpdateGym> for GymInput {
+        fn from(value: CreateUpdateGym) -> Self {
+            Self {
+                name: value.name,
+                equipment: normalize_list(value.equipment),
+                bar_weight: value.bar_weight,
+                plates: value.plates,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct CreateUpdateExerciseSet {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        pub repetitions: i64,
+        pub weight: Option<i64>,
+        #[serde(rename = "addedWeight")]
+        pub added_weight: Option<i64>,
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: Option<i64>,
+        #[serde(rename = "distanceMeters")]
+        pub distance_m: Option<i64>,
+        #[serde(rename = "setType")]
+        pub set_type: Option<SetType>,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: Option<i64>,
+        pub completed: Option<bool>,
+        #[serde(rename = "targetRepetitions")]
+        pub target_repetitions: Option<i64>,
+        #[serde(rename = "targetWeight")]
+        pub target_weight: Option<i64>,
+        pub note: String,
+        /// Unit of all weights of the set, defaults to the unit of the instance.
+        #[serde(rename = "weightUnit")]
+        pub weight_unit: Option<WeightUnit>,
+        /// UUID generated by the client to make retries of a creation return
+        /// the set created by the first attempt.
+        #[serde(rename = "clientId")]
+        pub client_id: Option<String>,
+        /// Weight entered as plates of a gym in the unit of the instance,
+        /// replaces the weight.
+        pub plates: Option<PlateSelection>,
+    }
+
+    impl From<CreateUpdateExerciseSet> for ExerciseSetInput {
+        fn from(value: CreateUpdateExerciseSet) -> Self {
+            Self {
+                workout_id: value.workout_id,
+                exercise_id: value.exercise_id,
+                repetitions: value.repetitions,
+                weight: value.weight,
+                added_weight: value.added_weight,
+                duration_s: value.duration_s,
+                distance_m: value.distance_m,
+                set_type: value.set_type,
+                created_utc_s: value.created_utc_s,
+                completed: value.completed,
+                target_repetitions: value.target_repetitions,
+                target_weight: value.target_weight,
+                note: value.note,
+                plates: None,
+                client_id: value.client_id,
+            }
+        }
+    }
+
+    /// Without any filter all sets of the workout are deleted.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct DeleteExerciseSets {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: Option<i64>,
+        #[serde(rename = "createdWithinMinutes")]
+        pub created_within_minutes: Option<i64>,
+        #[serde(default)]
+        pub preview: bool,
+    }
+
+    /// Sets are paginated by passing the id of the last set of the previous
+    /// page as `after`, all sets are returned without a `limit`.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetExerciseSets {
+        pub after: Option<i64>,
+        pub limit: Option<i64>,
+    }
+
+    #[derive(Debug, Clone, Copy, Serialize, Deserialize)]
+    #[serde(rename_all = "lowercase")]
+    pub enum GroupBy {
+        Workout,
+        Exercise,
+    }
+
+    /// Sets created within `from` (inclusive) and `to` (exclusive) in UTC
+    /// seconds, `limit` keeps only the most recent sets or groups.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetExerciseSetsByExerciseId {
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+        pub limit: Option<i64>,
+        #[serde(rename = "groupBy")]
+        pub group_by: Option<GroupBy>,
+    }
+
+    /// Grouped sets are paginated by group, `after` is the id of the last
+    /// group of the previous page and `limit` the number of groups. An
+    /// `after` that isn't part of the workout is rejected.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetExerciseSetsByWorkoutId {
+        #[serde(rename = "setType")]
+        pub set_type: Option<SetType>,
+        pub after: Option<i64>,
+        pub limit: Option<i64>,
+        #[serde(rename = "groupBy")]
+        pub group_by: Option<GroupBy>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetSetSuggestion {
+        /// Without an exercise the last set of the workout is repeated.
+        #[serde(rename = "exerciseId", alias = "exercise_id")]
+        pub exercise_id: Option<i64>,
+        /// Also suggest an exercise of the antagonist muscles to alternate
+        /// with.
+        #[serde(default)]
+        pub superset: bool,
+        /// Only suggest exercises whose equipment is available at the gym.
+        #[serde(rename = "gymId", alias = "gym_id")]
+        pub gym_id: Option<i64>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetExerciseQuickStats {
+        #[serde(rename = "workoutId")]
+        pub workout_id: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetStatisticsOverview {
+        /// Counts the volume of bodyweight sets, defaults to the last measured
+        /// body weight.
+        #[serde(rename = "bodyWeight")]
+        pub body_weight: Option<i64>,
+        /// Only sets of exercises with the tag count.
+        pub tag: Option<String>,
+        /// Only workouts started within `from` (inclusive) and `to`
+        /// (exclusive) in UTC seconds count. Streaks always consider all
+        /// workouts.
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+        /// Offset of the time zone of the user, weeks of streaks start on
+        /// Monday in this time zone.
+        #[serde(default, rename = "utcOffsetMinutes")]
+        pub utc_offset_minutes: i32,
+        /// Unit of the weights of the response and of the body weight,
+        /// defaults to the unit of the instance.
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Volume per week or month, optionally of the exercises training a
+    /// muscle group or of a single exercise.
+    #[derive(Debug, Deserialize)]
+    pub struct GetVolume {
+        #[serde(default)]
+        pub group: VolumeGroup,
+        pub muscle: Option<String>,
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: Option<i64>,
+        /// Counts the volume of bodyweight sets like the statistics overview,
+        /// defaults to the last measured body weight.
+        #[serde(rename = "bodyWeight")]
+        pub body_weight: Option<i64>,
+        /// Unit of the weights of the response and of the body weight,
+        /// defaults to the unit of the instance.
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// The report covers the period containing `at` in UTC seconds, the
+    /// current one by default.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetTrainingReport {
+        pub at: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetMuscleVolume {
+        /// Only workouts started within `from` (inclusive) and `to`
+        /// (exclusive) in UTC seconds count.
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+        /// Offset of the time zone of the user, weeks start on Monday in
+        /// this time zone.
+        #[serde(default, rename = "utcOffsetMinutes")]
+        pub utc_offset_minutes: i32,
+    }
+
+    /// Only workouts started within `from` (inclusive) and `to` (exclusive)
+    /// in UTC seconds are considered.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetIntensity {
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetTrainingTimes {
+        /// Only workouts started within `from` (inclusive) and `to`
+        /// (exclusive) in UTC seconds count.
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+        /// Offset of the time zone of the user, start times are grouped by
+        /// their local time.
+        #[serde(default, rename = "utcOffsetMinutes")]
+        pub utc_offset_minutes: i32,
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Only sets created within `from` (inclusive) and `to` (exclusive) in
+    /// UTC seconds are considered.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetPersonalRecords {
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Trends are fitted over the sessions of the last `weeks` weeks.
+    #[derive(Debug, Deserialize)]
+    pub struct GetTrends {
+        pub weeks: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Deserialize)]
+    pub struct GetEstimatedOneRepMax {
+        #[serde(default)]
+        pub formula: Formula,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetProgression {
+        /// Comma separated list of exercise ids.
+        #[serde(rename = "exerciseIds")]
+        pub exercise_ids: String,
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Dismissed insights are only listed with `dismissed=true`.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetInsights {
+        #[serde(default)]
+        pub dismissed: bool,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetStalls {
+        pub sessions: Option<usize>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetRecentPrEvents {
+        pub limit: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Exercises trained within the last `weeks` weeks without a new best set
+    /// in that time.
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct GetPlateaus {
+        pub weeks: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Only photos taken within `from` (inclusive) and `to` (exclusive) in
+    /// UTC seconds are listed.
+    #[derive(Debug, Deserialize)]
+    pub struct GetProgressPhotos {
+        pub from: Option<i64>,
+        pub to: Option<i64>,
+    }
+
+    /// Photos are taken now by default.
+    #[derive(Debug, Deserialize)]
+    pub struct UploadProgressPhoto {
+        #[serde(rename = "takenUtcSeconds")]
+        pub taken_utc_s: Option<i64>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct UploadAttachment {
+        #[serde(rename = "fileName")]
+        pub file_name: Option<String>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct LinkAttachment {
+        pub url: String,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct CreateWeightCorrection {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "createdBeforeUtcSeconds")]
+        pub created_before_utc_s: Option<i64>,
+        pub from: WeightUnit,
+        pub to: WeightUnit,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct MoveExerciseSet {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct ReorderExerciseSets {
+        #[serde(rename = "setIds")]
+        pub set_ids: Vec<i64>,
+    }
+
+    /// A series of one value per session, reduced to at most `points`
+    /// points. The estimated 1RM requires an exercise, the volume is of all
+    /// exercises without one.
+    #[derive(Debug, Deserialize)]
+    pub struct GetSeries {
+        #[serde(default)]
+        pub metric: ProgressionMetric,
+        pub points: Option<usize>,
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Deserialize)]
+    pub struct ExportWorkout {
+        #[serde(default)]
+        pub format: ActivityFormat,
+    }
+
+    #[derive(Debug, Deserialize)]
+    pub struct ExportStatistics {
+        #[serde(default)]
+        pub metric: ProgressionMetric,
+        #[serde(default)]
+        pub group: VolumeGroup,
+        /// Only for the volume.
+        pub muscle: Option<String>,
+        /// Required for the estimated one-repetition maximum.
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: Option<i64>,
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Body weight in the requested unit, by default the last measured one.
+    /// The sex selects the coefficients of the Wilks and DOTS scores.
+    #[derive(Debug, Deserialize)]
+    pub struct GetRelativeStrength {
+        #[serde(rename = "bodyWeight")]
+        pub body_weight: Option<i64>,
+        pub sex: Sex,
+        pub unit: Option<WeightUnit>,
+    }
+
+    #[derive(Debug, Deserialize)]
+    pub struct GetProgressionChart {
+        /// Comma separated list of exercise ids.
+        #[serde(rename = "exerciseIds")]
+        pub exercise_ids: String,
+        #[serde(default)]
+        pub metric: ProgressionMetric,
+        pub unit: Option<WeightUnit>,
+    }
+
+    /// Without a duration the rest takes the default time.
+    #[derive(Debug, Deserialize)]
+    pub struct StartRestTimer {
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: Option<i64>,
+    }
+
+    #[derive(Debug, Serialize, Deserialize)]
+    pub struct UpdateWorkoutMetaData {
+        pub note: String,
+    }
+}
+
+mod responses {
+    use chrono::{DateTime, Utc};
+    use serde::{Deserialize, Serialize};
+
+    use crate::frequency::Frequency;
+    use crate::limits::LimitExceeded;
+    use crate::monitoring::{self, ErrorReport};
+    use crate::notifications::Transport;
+    use crate::plates::{PlateConfiguration, PlateStock};
+    use crate::supersets::SupersetPair;
+    use crate::training_report::{self, Period, RecordKind};
+    use crate::units::{UnitConfig, WeightConversion, WeightUnit};
+    use crate::validation::{FieldError, ValidationError};
+    use crate::{
+        goals, heuristics, insights, intensity, one_rep_max, records, series, strength, trends,
+    };
+
+    use crate::dal::{
+        AdherenceEntity, AttachmentEntity, BodyWeightEntity, CorrectionEntity, ExerciseCategory,
+        ExerciseCountEntity, ExerciseEntity, ExerciseModality, ExerciseNameEntity,
+        ExerciseSetEntity, ExerciseStatsEntity, ExerciseUsageEntity, GoalEntity, GoalKind,
+        GymEntity, InsightEntity, MonthlyUsageEntity, MuscleVolumeEntity,
+        NotificationChannelEntity, NotificationEvent, NotificationRuleEntity, PrEventEntity,
+        PrKind, ProgressPhotoEntity, ProgressionEntity, RestTimerEntity, SetAnomalyEntity,
+        SetSuggestionEntity, SetType, StatisticsOverviewEntity, TimeSlotVolumeEntity,
+        UpsertResultEntity, VolumeEntity, WorkoutAuditAction, WorkoutAuditEntity,
+        WorkoutDraftEntity, WorkoutEntity, WorkoutUsageEntity,
+    };
+
+    #[derive(Debug, Deserialize, Serialize)]
+    pub struct Config {
+        #[serde(rename = "weightUnit")]
+        pub weight_unit: WeightUnit,
+        pub locale: String,
+        /// Factors to convert a weight in the given unit to kilograms.
+        #[serde(rename = "kilogramsPerUnit")]
+        pub kilograms_per_unit: KilogramsPerUnit,
+    }
+
+    #[derive(Debug, Deserialize, Serialize)]
+    pub struct KilogramsPerUnit {
+        pub kg: f64,
+        pub lb: f64,
+    }
+
+    impl From<&UnitConfig> for Config {
+        fn from(value: &UnitConfig) -> Self {
+            Self {
+                weight_unit: value.weight_unit,
+                locale: value.locale.clone(),
+                kilograms_per_unit: KilogramsPerUnit {
+                    kg: WeightUnit::Kg.kilograms(),
+                    lb: WeightUnit::Lb.kilograms(),
+                },
+            }
+        }
+    }
+
+    #[derive(Debug, Deserialize, Serialize)]
+    pub struct Exercise {
+        pub id: i64,
+        pub name: String,
+        pub modality: ExerciseModality,
+        pub category: Option<ExerciseCategory>,
+        pub favorite: bool,
+        #[serde(rename = "primaryMuscles")]
+        pub primary_muscles: Vec<String>,
+        #[serde(rename = "secondaryMuscles")]
+        pub secondary_muscles: Vec<String>,
+        pub equipment: Vec<String>,
+        pub tags: Vec<String>,
+        #[serde(rename = "lastUsedUtcSeconds")]
+        pub last_used_utc_s: Option<i64>,
+        #[serde(rename = "usageCount")]
+        pub usage_count: i64,
+        #[serde(rename = "bestWeight")]
+        pub best_weight: Option<i64>,
+        #[serde(rename = "bestEstimatedOneRepMax")]
+        pub best_estimated_one_rep_max: Option<f64>,
+    }
+
+    impl From<ExerciseEntity> for Exercise {
+        fn from(value: ExerciseEntity) -> Self {
+            Self {
+                last_used_utc_s: value.last_used.map(|last_used| last_used.timestamp()),
+                usage_count: value.usage_count,
+                best_weight: value.best_weight,
+                best_estimated_one_rep_max: value.best_one_rep_max,
+                primary_muscles: value.primary_muscles(),
+                secondary_muscles: value.secondary_muscles(),
+                equipment: value.equipment(),
+                tags: value.tags(),
+                id: value.id,
+                name: value.name,
+                modality: value.modality,
+                category: value.category,
+                favorite: value.favorite,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Gym {
+        pub id: i64,
+        pub name: String,
+        pub equipment: Vec<String>,
+        #[serde(rename = "barWeight")]
+        pub bar_weight: Option<f64>,
+        pub plates: Vec<PlateStock>,
+    }
+
+    impl From<GymEntity> for Gym {
+        fn from(value: GymEntity) -> Self {
+            Self {
+                equipment: value.equipment(),
+                plates: value.plates(),
+                bar_weight: value.bar_weight,
+                id: value.id,
+                name: value.name,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct BodyWeight {
+        pub id: i64,
+        pub weight: i64,
+        #[serde(rename = "measuredUtcSeconds")]
+        pub measured_utc_s: i64,
+    }
+
+    impl From<BodyWeightEntity> for BodyWeight {
+        fn from(value: BodyWeightEntity) -> Self {
+            Self {
+                id: value.id,
+                weight: value.weight,
+                measured_utc_s: value.measured.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ProgressPhoto {
+        pub id: i64,
+        #[serde(rename = "contentType")]
+        pub content_type: String,
+        pub size: i64,
+        #[serde(rename = "takenUtcSeconds")]
+        pub taken_utc_s: i64,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+    }
+
+    impl From<ProgressPhotoEntity> for ProgressPhoto {
+        fn from(value: ProgressPhotoEntity) -> Self {
+            Self {
+                id: value.id,
+                content_type: value.content_type,
+                size: value.size,
+                taken_utc_s: value.taken.timestamp(),
+                created_utc_s: value.created.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Goal {
+        pub id: i64,
+        pub kind: GoalKind,
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: Option<i64>,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: Option<String>,
+        pub target: i64,
+        #[serde(rename = "deadlineUtcSeconds")]
+        pub deadline_utc_s: Option<i64>,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+    }
+
+    impl From<GoalEntity> for Goal {
+        fn from(value: GoalEntity) -> Self {
+            Self {
+                id: value.id,
+                kind: value.kind,
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                target: value.target,
+                deadline_utc_s: value.deadline.map(|deadline| deadline.timestamp()),
+                created_utc_s: value.created.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct GoalProgress {
+        #[serde(flatten)]
+        pub goal: Goal,
+        pub current: Option<f64>,
+        /// Share of the way to the target covered, from 0 to 1.
+        pub progress: f64,
+        pub achieved: bool,
+    }
+
+    impl From<goals::GoalProgress> for GoalProgress {
+        fn from(value: goals::GoalProgress) -> Self {
+            Self {
+                goal: Goal::from(value.goal),
+                current: value.current,
+                progress: value.progress,
+                achieved: value.achieved,
+            }
+        }
+    }
+
+    /// Response of single exercise reads and writes, lists only contain the
+    /// plain exercises.
+    #[derive(Debug, Serialize)]
+    pub struct ExerciseDetails {
+        #[serde(flatten)]
+        pub exercise: Exercise,
+        pub description: Option<String>,
+        pub instructions: Option<String>,
+        #[serde(rename = "videoUrl")]
+        pub video_url: Option<String>,
+        #[serde(rename = "defaultIncrement")]
+        pub default_increment: Option<i64>,
+        #[serde(rename = "minRepetitions")]
+        pub min_repetitions: Option<i64>,
+        #[serde(rename = "maxRepetitions")]
+        pub max_repetitions: Option<i64>,
+    }
+
+    impl From<ExerciseEntity> for ExerciseDetails {
+        fn from(mut value: ExerciseEntity) -> Self {
+            Self {
+                description: value.description.take(),
+                instructions: value.instructions.take(),
+                video_url: value.video_url.take(),
+                default_increment: value.default_increment,
+                min_repetitions: value.min_repetitions,
+                max_repetitions: value.max_repetitions,
+                exercise: Exercise::from(value),
+            }
+        }
+    }
+
+    /// Response of exercise reads, writes return the details only.
+    #[derive(Debug, Serialize)]
+    pub struct ExerciseWithStats {
+        #[serde(flatten)]
+        pub exercise: ExerciseDetails,
+        pub stats: ExerciseStats,
+        /// Most recent first, so exports and logs with an old name can still
+        /// be mapped to the exercise.
+        #[serde(rename = "previousNames")]
+        pub previous_names: Vec<PreviousName>,
+    }
+
+    impl ExerciseWithStats {
+        pub fn new(
+            exercise: ExerciseEntity,
+            stats: ExerciseStatsEntity,
+            previous_names: Vec<ExerciseNameEntity>,
+        ) -> Self {
+            Self {
+                exercise: ExerciseDetails::from(exercise),
+                stats: ExerciseStats::from(stats),
+                previous_names: previous_names.into_iter().map(PreviousName::from).collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct PreviousName {
+        pub name: String,
+        #[serde(rename = "renamedUtcSeconds")]
+        pub renamed_utc_s: i64,
+    }
+
+    impl From<ExerciseNameEntity> for PreviousName {
+        fn from(value: ExerciseNameEntity) -> Self {
+            Self {
+                name: value.name,
+                renamed_utc_s: value.renamed.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct UpsertedExercises {
+        pub created: usize,
+        pub updated: usize,
+        pub skipped: usize,
+    }
+
+    impl UpsertedExercises {
+        pub fn new(result: UpsertResultEntity, skipped: usize) -> Self {
+            Self {
+                created: result.created,
+                updated: result.updated,
+                skipped,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ExerciseStats {
+        #[serde(rename = "setCount")]
+        pub set_count: i64,
+        #[serde(rename = "lastPerformedUtcSeconds")]
+        pub last_performed_utc_s: Option<i64>,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: Option<f64>,
+        #[serde(rename = "bestSet")]
+        pub best_set: Option<QuickStatsSet>,
+    }
+
+    impl From<ExerciseStatsEntity> for ExerciseStats {
+        fn from(value: ExerciseStatsEntity) -> Self {
+            Self {
+                set_count: value.set_count,
+                last_performed_utc_s: value
+                    .last_performed
+                    .map(|last_performed| last_performed.timestamp()),
+                estimated_one_rep_max: value.estimated_one_rep_max,
+                best_set: value.best_set.map(QuickStatsSet::from),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Warning {
+        pub code: &'static str,
+        pub message: String,
+    }
+
+    impl From<heuristics::Warning> for Warning {
+        fn from(value: heuristics::Warning) -> Self {
+            Self {
+                code: value.code,
+                message: value.message,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Insight {
+        pub id: i64,
+        pub rule: String,
+        pub subject: String,
+        pub title: String,
+        pub message: String,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+        #[serde(rename = "acknowledgedUtcSeconds")]
+        pub acknowledged_utc_s: Option<i64>,
+        #[serde(rename = "dismissedUtcSeconds")]
+        pub dismissed_utc_s: Option<i64>,
+    }
+
+    impl From<InsightEntity> for Insight {
+        fn from(value: InsightEntity) -> Self {
+            Self {
+                id: value.id,
+                rule: value.rule,
+                subject: value.subject,
+                title: value.title,
+                message: value.message,
+                created_utc_s: value.created.timestamp(),
+                acknowledged_utc_s: value.acknowledged.map(|date| date.timestamp()),
+                dismissed_utc_s: value.dismissed.map(|date| date.timestamp()),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Volume {
+        pub period: String,
+        #[serde(rename = "setCount")]
+        pub set_count: i64,
+        pub tonnage: i64,
+    }
+
+    impl Volume {
+        pub fn new(value: VolumeEntity, conversion: WeightConversion) -> Self {
+            Self {
+                period: value.period,
+                set_count: value.set_count,
+                tonnage: conversion.weight(value.tonnage),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct TrainingReport {
+        pub period: Period,
+        #[serde(rename = "startUtcSeconds")]
+        pub start_utc_s: i64,
+        #[serde(rename = "endUtcSeconds")]
+        pub end_utc_s: i64,
+        pub workouts: i64,
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: i64,
+        pub sets: i64,
+        pub repetitions: i64,
+        pub volume: i64,
+        /// Most sets first.
+        pub muscles: Vec<ReportMuscle>,
+        pub records: Vec<ReportRecord>,
+        pub adherence: Adherence,
+    }
+
+    impl TrainingReport {
+        pub fn new(value: training_report::TrainingReport, conversion: WeightConversion) -> Self {
+            Self {
+                period: value.period,
+                start_utc_s: value.start.timestamp(),
+                end_utc_s: value.end.timestamp(),
+                workouts: value.overview.total_workouts,
+                duration_s: value.overview.total_duration_s,
+                sets: value.overview.total_sets,
+                repetitions: value.overview.total_repetitions,
+                volume: conversion.weight(value.overview.total_volume),
+                muscles: value.muscles.into_iter().map(ReportMuscle::from).collect(),
+                records: value
+                    .records
+                    .into_iter()
+                    .map(|record| ReportRecord::new(record, conversion))
+                    .collect(),
+                adherence: Adherence::from(value.adherence),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ReportMuscle {
+        pub muscle: String,
+        #[serde(rename = "primarySets")]
+        pub primary_sets: i64,
+        #[serde(rename = "secondarySets")]
+        pub secondary_sets: i64,
+    }
+
+    impl From<training_report::MuscleSets> for ReportMuscle {
+        fn from(value: training_report::MuscleSets) -> Self {
+            Self {
+                muscle: value.muscle,
+                primary_sets: value.primary_sets,
+                secondary_sets: value.secondary_sets,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ReportRecord {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        pub kind: RecordKind,
+        #[serde(flatten)]
+        pub record: Record,
+    }
+
+    impl ReportRecord {
+        pub fn new(value: training_report::NewRecord, conversion: WeightConversion) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                kind: value.kind,
+                record: Record::new(value.record, conversion),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Adherence {
+        #[serde(rename = "plannedSets")]
+        pub planned_sets: i64,
+        #[serde(rename = "completedSets")]
+        pub completed_sets: i64,
+        #[serde(rename = "targetsMet")]
+        pub targets_met: i64,
+    }
+
+    impl From<AdherenceEntity> for Adherence {
+        fn from(value: AdherenceEntity) -> Self {
+            Self {
+                planned_sets: value.planned_sets,
+                completed_sets: value.completed_sets,
+                targets_met: value.targets_met,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct MuscleVolume {
+        pub week: String,
+        pub muscle: String,
+        #[serde(rename = "primarySets")]
+        pub primary_sets: i64,
+        #[serde(rename = "secondarySets")]
+        pub secondary_sets: i64,
+    }
+
+    impl From<MuscleVolumeEntity> for MuscleVolume {
+        fn from(value: MuscleVolumeEntity) -> Self {
+            Self {
+                week: value.week,
+                muscle: value.muscle,
+                primary_sets: value.primary_sets,
+                secondary_sets: value.secondary_sets,
+            }
+        }
+    }
+
+    /// Intensities are in percent of the best estimated one-repetition
+    /// maximum of the exercise as of the session.
+    #[derive(Debug, Clone, Serialize)]
+    pub struct Intensity {
+        pub exercises: Vec<ExerciseIntensity>,
+        pub workouts: Vec<WorkoutIntensity>,
+    }
+
+    impl Intensity {
+        pub fn new(
+            exercises: Vec<intensity::ExerciseIntensity>,
+            workouts: Vec<intensity::WorkoutIntensity>,
+            conversion: WeightConversion,
+        ) -> Self {
+            Self {
+                exercises: exercises
+                    .into_iter()
+                    .map(|exercise| ExerciseIntensity::new(exercise, conversion))
+                    .collect(),
+                workouts: workouts.into_iter().map(WorkoutIntensity::from).collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Clone, Serialize)]
+    pub struct ExerciseIntensity {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: f64,
+        #[serde(flatten)]
+        pub intensity: RelativeIntensity,
+    }
+
+    #[derive(Debug, Clone, Serialize)]
+    pub struct WorkoutIntensity {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        #[serde(rename = "startedUtcSeconds")]
+        pub started_utc_s: i64,
+        #[serde(flatten)]
+        pub intensity: RelativeIntensity,
+    }
+
+    #[derive(Debug, Clone, Serialize)]
+    pub struct RelativeIntensity {
+        #[serde(rename = "setCount")]
+        pub set_count: usize,
+        #[serde(rename = "averagePercent")]
+        pub average_percent: f64,
+        #[serde(rename = "peakPercent")]
+        pub peak_percent: f64,
+    }
+
+    impl ExerciseIntensity {
+        pub fn new(value: intensity::ExerciseIntensity, conversion: WeightConversion) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                estimated_one_rep_max: conversion.value(value.one_rep_max),
+                intensity: RelativeIntensity::from(value.intensity),
+            }
+        }
+    }
+
+    impl From<intensity::WorkoutIntensity> for WorkoutIntensity {
+        fn from(value: intensity::WorkoutIntensity) -> Self {
+            Self {
+                workout_id: value.workout_id,
+                started_utc_s: value.started.timestamp(),
+                intensity: RelativeIntensity::from(value.intensity),
+            }
+        }
+    }
+
+    impl From<intensity::Intensity> for RelativeIntensity {
+        fn from(value: intensity::Intensity) -> Self {
+            Self {
+                set_count: value.set_count,
+                average_percent: value.average_percent,
+                peak_percent: value.peak_percent,
+            }
+        }
+    }
+
+    /// Workouts by the local time they started at.
+    #[derive(Debug, Serialize)]
+    pub struct TrainingTimes {
+        pub weekdays: Vec<TimeSlotVolume>,
+        pub hours: Vec<TimeSlotVolume>,
+    }
+
+    impl TrainingTimes {
+        pub fn new(
+            weekdays: Vec<TimeSlotVolumeEntity>,
+            hours: Vec<TimeSlotVolumeEntity>,
+            conversion: WeightConversion,
+        ) -> Self {
+            let convert = |slots: Vec<TimeSlotVolumeEntity>| {
+                slots
+                    .into_iter()
+                    .map(|slot| TimeSlotVolume::new(slot, conversion))
+                    .collect()
+            };
+
+            Self {
+                weekdays: convert(weekdays),
+                hours: convert(hours),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct TimeSlotVolume {
+        /// Day of the week from 0 (Sunday) to 6, or hour from 0 to 23.
+        pub slot: i64,
+        #[serde(rename = "workoutCount")]
+        pub workout_count: i64,
+        #[serde(rename = "averageSetCount")]
+        pub average_set_count: f64,
+        #[serde(rename = "averageTonnage")]
+        pub average_tonnage: f64,
+    }
+
+    impl TimeSlotVolume {
+        pub fn new(value: TimeSlotVolumeEntity, conversion: WeightConversion) -> Self {
+            Self {
+                slot: value.slot,
+                workout_count: value.workout_count,
+                average_set_count: value.average_set_count,
+                average_tonnage: conversion.value(value.average_tonnage),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct RelativeStrength {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: f64,
+        #[serde(rename = "bodyWeightRatio")]
+        pub body_weight_ratio: f64,
+        pub wilks: f64,
+        pub dots: f64,
+    }
+
+    impl RelativeStrength {
+        pub fn new(value: strength::RelativeStrength, conversion: WeightConversion) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                estimated_one_rep_max: conversion.value(value.one_rep_max),
+                body_weight_ratio: value.ratio,
+                wilks: value.wilks,
+                dots: value.dots,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Series {
+        /// Number of points before downsampling.
+        pub total: usize,
+        pub points: Vec<SeriesPoint>,
+    }
+
+    impl Series {
+        pub fn new(total: usize, points: Vec<series::Point>, conversion: WeightConversion) -> Self {
+            Self {
+                total,
+                points: points
+                    .into_iter()
+                    .map(|point| SeriesPoint::new(point, conversion))
+                    .collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct SeriesPoint {
+        #[serde(rename = "utcSeconds")]
+        pub utc_s: i64,
+        pub value: f64,
+    }
+
+    impl SeriesPoint {
+        pub fn new(value: series::Point, conversion: WeightConversion) -> Self {
+            Self {
+                utc_s: value.time,
+                value: conversion.value(value.value),
+            }
+        }
+    }
+
+    /// Best estimated one-repetition maximum of a workout.
+    #[derive(Debug, Serialize)]
+    pub struct SessionEstimate {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        #[serde(rename = "startedUtcSeconds")]
+        pub started_utc_s: i64,
+        pub estimate: f64,
+    }
+
+    impl SessionEstimate {
+        pub fn new(value: one_rep_max::SessionEstimate, conversion: WeightConversion) -> Self {
+            Self {
+                workout_id: value.workout_id,
+                started_utc_s: value.started.timestamp(),
+                estimate: conversion.value(value.estimate),
+            }
+        }
+    }
+
+    #[derive(Debug, Clone, Serialize)]
+    pub struct ExerciseTrend {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        pub sessions: usize,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: Trend,
+        pub volume: Trend,
+    }
+
+    #[derive(Debug, Clone, Serialize)]
+    pub struct Trend {
+        #[serde(rename = "slopePerWeek")]
+        pub slope_per_week: f64,
+        pub direction: trends::Direction,
+    }
+
+    impl ExerciseTrend {
+        pub fn new(value: trends::ExerciseTrend, conversion: WeightConversion) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                sessions: value.sessions,
+                estimated_one_rep_max: Trend::new(value.one_rep_max, conversion),
+                volume: Trend::new(value.volume, conversion),
+            }
+        }
+    }
+
+    impl Trend {
+        pub fn new(value: trends::Trend, conversion: WeightConversion) -> Self {
+            Self {
+                slope_per_week: conversion.value(value.slope),
+                direction: value.direction,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct PersonalRecords {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        pub heaviest: Record,
+        #[serde(rename = "bestEstimatedOneRepMax")]
+        pub best_estimated_one_rep_max: OneRepMaxRecord,
+        /// Most repetitions per weight, lightest weight first.
+        pub repetitions: Vec<Record>,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Record {
+        pub weight: i64,
+        pub repetitions: i64,
+        #[serde(rename = "achievedUtcSeconds")]
+        pub achieved_utc_s: i64,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct OneRepMaxRecord {
+        pub estimate: f64,
+        #[serde(flatten)]
+        pub set: Record,
+    }
+
+    impl PersonalRecords {
+        pub fn new(value: records::PersonalRecords, conversion: WeightConversion) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                heaviest: Record::new(value.heaviest, conversion),
+                best_estimated_one_rep_max: OneRepMaxRecord {
+                    estimate: conversion.value(value.best_one_rep_max.estimate),
+                    set: Record::new(value.best_one_rep_max.set, conversion),
+                },
+                repetitions: value
+                    .repetitions
+                    .into_iter()
+                    .map(|record| Record::new(record, conversion))
+                    .collect(),
+            }
+        }
+    }
+
+    impl Record {
+        pub fn new(value: records::Record, conversion: WeightConversion) -> Self {
+            Self {
+                weight: conversion.weight(value.weight),
+                ..Self::from(value)
+            }
+        }
+    }
+
+    impl From<records::Record> for Record {
+        fn from(value: records::Record) -> Self {
+            Self {
+                weight: value.weight,
+                repetitions: value.repetitions,
+                achieved_utc_s: value.achieved.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Stall {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        pub sessions: usize,
+        #[serde(rename = "bestOneRepMax")]
+        pub best_one_rep_max: f64,
+        #[serde(rename = "bestUtcSeconds")]
+        pub best_utc_s: i64,
+        #[serde(rename = "recentOneRepMax")]
+        pub recent_one_rep_max: f64,
+        #[serde(rename = "lastWorkoutId")]
+        pub last_workout_id: i64,
+        pub suggestions: Vec<Suggestion>,
+    }
+
+    /// A set that beat a personal record. The value is the weight, the
+    /// estimated 1RM or the repetitions at the weight of the set, depending on
+    /// the kind.
+    #[derive(Debug, Serialize)]
+    pub struct PrEvent {
+        pub id: i64,
+        #[serde(rename = "exerciseSetId")]
+        pub exercise_set_id: i64,
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        pub kind: PrKind,
+        pub weight: i64,
+        pub repetitions: i64,
+        pub value: f64,
+        #[serde(rename = "previousValue")]
+        pub previous_value: f64,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+    }
+
+    impl PrEvent {
+        pub fn new(value: PrEventEntity, conversion: WeightConversion) -> Self {
+            // Repetition records compare counts, not weights.
+            let convert = |record: f64| match value.kind {
+                PrKind::Repetitions => record,
+                PrKind::Weight | PrKind::OneRepMax => conversion.value(record),
+            };
+            Self {
+                id: value.id,
+                exercise_set_id: value.exercise_set_id,
+                workout_id: value.workout_id,
+                exercise_id: value.exercise_id,
+                kind: value.kind,
+                weight: conversion.weight(value.weight),
+                repetitions: value.repetitions,
+                value: convert(value.value),
+                previous_value: convert(value.previous_value),
+                exercise_name: value.exercise_name,
+                created_utc_s: value.created.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Plateau {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        /// Time since the last record.
+        #[serde(rename = "stagnationSeconds")]
+        pub stagnation_s: i64,
+        #[serde(rename = "sessionsSinceRecord")]
+        pub sessions_since_record: usize,
+        #[serde(rename = "lastRecord")]
+        pub last_record: PlateauRecord,
+        #[serde(rename = "lastSessionUtcSeconds")]
+        pub last_session_utc_s: i64,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct PlateauRecord {
+        pub weight: i64,
+        pub repetitions: i64,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: f64,
+        #[serde(rename = "achievedUtcSeconds")]
+        pub achieved_utc_s: i64,
+    }
+
+    impl Plateau {
+        pub fn new(
+            value: insights::Plateau,
+            now: DateTime<Utc>,
+            conversion: WeightConversion,
+        ) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                stagnation_s: (now - value.record.achieved).num_seconds(),
+                sessions_since_record: value.sessions,
+                last_record: PlateauRecord {
+                    weight: conversion.weight(value.record.weight),
+                    repetitions: value.record.repetitions,
+                    estimated_one_rep_max: conversion.value(value.record.estimate),
+                    achieved_utc_s: value.record.achieved.timestamp(),
+                },
+                last_session_utc_s: value.last_session.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Suggestion {
+        pub code: &'static str,
+        pub message: &'static str,
+    }
+
+    impl Stall {
+        pub fn new(value: insights::Stall, conversion: WeightConversion) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                sessions: value.sessions,
+                best_one_rep_max: conversion.value(value.best_one_rep_max),
+                best_utc_s: value.best_date.timestamp(),
+                recent_one_rep_max: conversion.value(value.recent_one_rep_max),
+                last_workout_id: value.last_workout_id,
+                suggestions: value
+                    .suggestions
+                    .into_iter()
+                    .map(|suggestion| Suggestion {
+                        code: suggestion.code,
+                        message: suggestion.message,
+                    })
+                    .collect(),
+            }
+        }
+    }
+
+    /// Response of a write that carries non-fatal warnings next to the
+    /// written entity.
+    #[derive(Debug, Serialize)]
+    pub struct WithWarnings<T> {
+        #[serde(flatten)]
+        pub inner: T,
+        pub warnings: Vec<Warning>,
+    }
+
+    impl<T> WithWarnings<T> {
+        pub fn new(inner: T, warnings: Vec<heuristics::Warning>) -> Self {
+            Self {
+                inner,
+                warnings: warnings.into_iter().map(Warning::from).collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct LimitError {
+        pub code: &'static str,
+        pub message: String,
+    }
+
+    impl From<LimitExceeded> for LimitError {
+        fn from(value: LimitExceeded) -> Self {
+            Self {
+                code: value.code,
+                message: value.message,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ValidationErrors {
+        pub errors: Vec<ValidationFieldError>,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ValidationFieldError {
+        pub field: &'static str,
+        pub code: &'static str,
+        pub message: String,
+    }
+
+    impl From<ValidationError> for ValidationErrors {
+        fn from(value: ValidationError) -> Self {
+            Self {
+                errors: value
+                    .0
+                    .into_iter()
+                    .map(ValidationFieldError::from)
+                    .collect(),
+            }
+        }
+    }
+
+    impl From<FieldError> for ValidationFieldError {
+        fn from(value: FieldError) -> Self {
+            Self {
+                field: value.field,
+                code: value.code,
+                message: value.message,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Attachment {
+        pub id: i64,
+        #[serde(rename = "setId")]
+        pub exercise_set_id: i64,
+        #[serde(rename = "contentType")]
+        pub content_type: Option<String>,
+        #[serde(rename = "fileName")]
+        pub file_name: Option<String>,
+        pub url: Option<String>,
+        pub size: i64,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+    }
+
+    impl From<AttachmentEntity> for Attachment {
+        fn from(value: AttachmentEntity) -> Self {
+            Self {
+                id: value.id,
+                exercise_set_id: value.exercise_set_id,
+                content_type: value.content_type,
+                file_name: value.file_name,
+                url: value.url,
+                size: value.size,
+                created_utc_s: value.created.timestamp(),
+            }
+        }
+    }
+
+    /// Response of a set read that lists the attachments of the set next to it.
+    #[derive(Debug, Serialize)]
+    pub struct WithAttachments<T> {
+        #[serde(flatten)]
+        pub inner: T,
+        pub attachments: Vec<Attachment>,
+    }
+
+    impl<T> WithAttachments<T> {
+        pub fn new(inner: T, attachments: Vec<AttachmentEntity>) -> Self {
+            Self {
+                inner,
+                attachments: attachments.into_iter().map(Attachment::from).collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Deserialize, Serialize)]
+    pub struct Workout {
+        pub id: i64,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+        #[serde(rename = "finishedUtcSeconds")]
+        pub finished_utc_s: Option<i64>,
+        pub note: Option<String>,
+    }
+
+    impl From<WorkoutEntity> for Workout {
+        fn from(value: WorkoutEntity) -> Self {
+            Self {
+                id: value.id,
+                created_utc_s: value.started.timestamp(),
+                finished_utc_s: value.finished.map(|finished| finished.timestamp()),
+                note: value.note,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct WorkoutAudit {
+        pub id: i64,
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        pub action: WorkoutAuditAction,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+    }
+
+    impl From<WorkoutAuditEntity> for WorkoutAudit {
+        fn from(value: WorkoutAuditEntity) -> Self {
+            Self {
+                id: value.id,
+                workout_id: value.workout_id,
+                action: value.action,
+                created_utc_s: value.created.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct WorkoutDraft {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        pub draft: serde_json::Value,
+        #[serde(rename = "updatedUtcSeconds")]
+        pub updated_utc_s: i64,
+    }
+
+    impl From<WorkoutDraftEntity> for WorkoutDraft {
+        fn from(value: WorkoutDraftEntity) -> Self {
+            Self {
+                workout_id: value.workout_id,
+                draft: serde_json::from_str(&value.data).unwrap_or_default(),
+                updated_utc_s: value.updated.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct RestTimer {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        #[serde(rename = "startedUtcSeconds")]
+        pub started_utc_s: i64,
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: i64,
+        #[serde(rename = "remainingSeconds")]
+        pub remaining_s: i64,
+    }
+
+    impl RestTimer {
+        pub fn new(value: RestTimerEntity, now: DateTime<Utc>) -> Self {
+            Self {
+                workout_id: value.workout_id,
+                started_utc_s: value.started.timestamp(),
+                duration_s: value.duration_s,
+                remaining_s: value.remaining_s(now),
+            }
+        }
+    }
+
+    #[derive(Debug, Deserialize, Serialize)]
+    pub struct ExerciseSet {
+        pub id: i64,
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+        pub repetitions: i64,
+        pub weight: Option<i64>,
+        #[serde(rename = "addedWeight")]
+        pub added_weight: Option<i64>,
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: Option<i64>,
+        #[serde(rename = "distanceMeters")]
+        pub distance_m: Option<i64>,
+        #[serde(rename = "setType")]
+        pub set_type: SetType,
+        pub position: i64,
+        pub completed: bool,
+        #[serde(rename = "targetRepetitions")]
+        pub target_repetitions: Option<i64>,
+        #[serde(rename = "targetWeight")]
+        pub target_weight: Option<i64>,
+        pub note: Option<String>,
+        pub plates: Option<Plates>,
+        #[serde(rename = "clientId")]
+        pub client_id: Option<String>,
+        /// Estimated one-repetition maximum of weighted sets, none for
+        /// bodyweight and timed sets.
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: Option<f64>,
+    }
+
+    impl From<ExerciseSetEntity> for ExerciseSet {
+        fn from(value: ExerciseSetEntity) -> Self {
+            let estimated_one_rep_max = value
+                .weight
+                .filter(|_| value.duration_s.is_none())
+                .and_then(|weight| one_rep_max::estimate(weight, value.repetitions));
+
+            Self {
+                id: value.id,
+                exercise_id: value.exercise_id,
+                exercise_name: value.exercise_name,
+                workout_id: value.workout_id,
+                created_utc_s: value.created.timestamp(),
+                repetitions: value.repetitions,
+                weight: value.weight,
+                added_weight: value.added_weight,
+                duration_s: value.duration_s,
+                distance_m: value.distance_m,
+                set_type: value.set_type,
+                position: value.position,
+                completed: value.completed,
+                target_repetitions: value.target_repetitions,
+                target_weight: value.target_weight,
+                note: value.note,
+                plates: value
+                    .plates
+                    .as_deref()
+                    .and_then(|plates| serde_json::from_str(plates).ok())
+                    .map(Plates::from),
+                client_id: value.client_id,
+                estimated_one_rep_max,
+            }
+        }
+    }
 
-impl IntoResponse for AppError {
-    fn into_response(self) -> Response {
-        match self {
-            Self::Err(err) => {
-                let category = if err.downcast_ref::<sqlx::Error>().is_some() {
-                    "Database error."
-                } else {
-                    "Unknown error."
+    /// Plates of a set with their exact total, which may be fractional unlike
+    /// the weight of the set.
+    #[derive(Debug, Serialize)]
+    pub struct Plates {
+        #[serde(flatten)]
+        pub configuration: PlateConfiguration,
+        #[serde(rename = "totalWeight")]
+        pub total_weight: f64,
+    }
+
+    impl From<PlateConfiguration> for Plates {
+        fn from(value: PlateConfiguration) -> Self {
+            Self {
+                total_weight: value.total_weight(),
+                configuration: value,
+            }
+        }
+    }
+
+    /// Sets of a single workout, in the order of the grouped sets.
+    #[derive(Debug, Serialize)]
+    pub struct WorkoutExerciseSets {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        pub sets: Vec<ExerciseSet>,
+    }
+
+    impl WorkoutExerciseSets {
+        pub fn group(exercise_sets: Vec<ExerciseSetEntity>) -> Vec<Self> {
+            let mut groups: Vec<Self> = Vec::new();
+
+            for exercise_set in exercise_sets {
+                let workout_id = exercise_set.workout_id;
+
+                match groups
+                    .iter_mut()
+                    .find(|group| group.workout_id == workout_id)
+                {
+                    Some(group) => group.sets.push(ExerciseSet::from(exercise_set)),
+                    None => groups.push(Self {
+                        workout_id,
+                        sets: vec![ExerciseSet::from(exercise_set)],
+                    }),
+                }
+            }
+
+            groups
+        }
+    }
+
+    /// Sets of a single exercise in the order of the grouped sets, with
+    /// aggregates of the completed sets. Warm-up sets don't count towards the
+    /// volume and top weight, timed sets only towards the set counts.
+    #[derive(Debug, Serialize)]
+    pub struct ExerciseGroupSets {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        #[serde(rename = "setCount")]
+        pub set_count: usize,
+        #[serde(rename = "completedSets")]
+        pub completed_sets: usize,
+        #[serde(rename = "totalRepetitions")]
+        pub total_repetitions: i64,
+        pub volume: i64,
+        #[serde(rename = "topWeight")]
+        pub top_weight: Option<i64>,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: Option<f64>,
+        pub sets: Vec<ExerciseSet>,
+    }
+
+    impl ExerciseGroupSets {
+        pub fn group(exercise_sets: Vec<ExerciseSetEntity>) -> Vec<Self> {
+            let mut groups: Vec<Self> = Vec::new();
+
+            for exercise_set in exercise_sets {
+                let exercise_id = exercise_set.exercise_id;
+                let exercise_set = ExerciseSet::from(exercise_set);
+
+                let group = match groups
+                    .iter_mut()
+                    .position(|group| group.exercise_id == exercise_id)
+                {
+                    Some(i) => &mut groups[i],
+                    None => {
+                        groups.push(Self {
+                            exercise_id,
+                            exercise_name: exercise_set.exercise_name.clone(),
+                            set_count: 0,
+                            completed_sets: 0,
+                            total_repetitions: 0,
+                            volume: 0,
+                            top_weight: None,
+                            estimated_one_rep_max: None,
+                            sets: Vec::new(),
+                        });
+                        groups.last_mut().expect("Group was just added")
+                    }
                 };
-                error!(err = format!("{err:#}"), "{category}");
-                StatusCode::INTERNAL_SERVER_ERROR.into_response()
+
+                group.add(exercise_set);
             }
-            Self::StatusCode(status) => status.into_response(),
+
+            groups
+        }
+
+        fn add(&mut self, exercise_set: ExerciseSet) {
+            self.set_count += 1;
+            self.completed_sets += usize::from(exercise_set.completed);
+
+            let counts = exercise_set.completed && exercise_set.duration_s.is_none();
+            if counts {
+                self.total_repetitions += exercise_set.repetitions;
+            }
+
+            let working_weight = exercise_set
+                .weight
+                .filter(|_| counts && exercise_set.set_type != SetType::Warmup);
+            if let Some(weight) = working_weight {
+                self.volume += weight * exercise_set.repetitions;
+                self.top_weight = self.top_weight.max(Some(weight));
+                self.estimated_one_rep_max = self
+                    .estimated_one_rep_max
+                    .into_iter()
+                    .chain(exercise_set.estimated_one_rep_max)
+                    .reduce(f64::max);
+            }
+
+            self.sets.push(exercise_set);
         }
     }
-}
 
-mod requests {
-    use serde::{Deserialize, Serialize};
+    #[derive(Debug, Serialize)]
+    pub struct DeletedExercise {
+        #[serde(rename = "deletedSets")]
+        pub deleted_sets: u64,
+    }
 
-    #[derive(Debug, Serialize, Deserialize)]
-    pub struct CreateUpdateExercise {
-        pub name: String,
+    #[derive(Debug, Serialize)]
+    pub struct DeletedExerciseSets {
+        pub preview: bool,
+        pub count: usize,
+        pub sets: Vec<ExerciseSet>,
     }
 
-    #[derive(Debug, Serialize, Deserialize)]
-    pub struct CreateUpdateExerciseSet {
-        #[serde(rename = "workoutId")]
-        pub workout_id: i64,
+    impl DeletedExerciseSets {
+        pub fn new(preview: bool, exercise_sets: Vec<ExerciseSetEntity>) -> Self {
+            Self {
+                preview,
+                count: exercise_sets.len(),
+                sets: exercise_sets.into_iter().map(ExerciseSet::from).collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct SetSuggestion {
         #[serde(rename = "exerciseId")]
         pub exercise_id: i64,
         pub repetitions: i64,
-        pub weight: i64,
-        pub note: String,
+        pub weight: Option<i64>,
+        #[serde(rename = "addedWeight")]
+        pub added_weight: Option<i64>,
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: Option<i64>,
+        /// Only set if requested and an exercise of the antagonist muscles
+        /// exists.
+        pub superset: Option<SupersetSuggestion>,
     }
 
-    #[derive(Debug, Serialize, Deserialize)]
-    pub struct GetSetSuggestion {
+    impl SetSuggestion {
+        pub fn new(value: SetSuggestionEntity, superset: Option<SupersetPair>) -> Self {
+            Self {
+                exercise_id: value.exercise_id,
+                repetitions: value.repetitions,
+                weight: value.weight,
+                added_weight: value.added_weight,
+                duration_s: value.duration_s,
+                superset: superset.map(SupersetSuggestion::from),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct SupersetSuggestion {
         #[serde(rename = "exerciseId")]
-        pub exercise_id: Option<i64>,
+        pub exercise_id: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        pub repetitions: i64,
+        pub weight: Option<i64>,
+        #[serde(rename = "addedWeight")]
+        pub added_weight: Option<i64>,
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: Option<i64>,
     }
 
-    #[derive(Debug, Serialize, Deserialize)]
-    pub struct UpdateWorkoutMetaData {
-        pub note: String,
+    impl From<SupersetPair> for SupersetSuggestion {
+        fn from(value: SupersetPair) -> Self {
+            Self {
+                exercise_id: value.exercise.id,
+                exercise_name: value.exercise.name,
+                repetitions: value.set.repetitions,
+                weight: value.set.weight,
+                added_weight: value.set.added_weight,
+                duration_s: value.set.duration_s,
+            }
+        }
     }
-}
 
-mod responses {
-    use serde::{Deserialize, Serialize};
+    #[derive(Debug, Serialize)]
+    pub struct QuickStatsSet {
+        pub repetitions: i64,
+        pub weight: Option<i64>,
+        #[serde(rename = "addedWeight")]
+        pub added_weight: Option<i64>,
+        #[serde(rename = "durationSeconds")]
+        pub duration_s: Option<i64>,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+    }
 
-    use crate::dal::{
-        ExerciseCountEntity, ExerciseEntity, ExerciseSetEntity, SetSuggestionEntity,
-        StatisticsOverviewEntity, WorkoutEntity,
-    };
+    impl QuickStatsSet {
+        pub fn new(value: ExerciseSetEntity, conversion: WeightConversion) -> Self {
+            Self {
+                weight: value.weight.map(|weight| conversion.weight(weight)),
+                added_weight: value
+                    .added_weight
+                    .map(|added_weight| conversion.weight(added_weight)),
+                ..Self::from(value)
+            }
+        }
+    }
 
-    #[derive(Debug, Deserialize, Serialize)]
-    pub struct Exercise {
+    impl From<ExerciseSetEntity> for QuickStatsSet {
+        fn from(value: ExerciseSetEntity) -> Self {
+            Self {
+                repetitions: value.repetitions,
+                weight: value.weight,
+                added_weight: value.added_weight,
+                duration_s: value.duration_s,
+                created_utc_s: value.created.timestamp(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct QuickStatsSession {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        pub sets: Vec<QuickStatsSet>,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ExerciseQuickStats {
+        #[serde(rename = "exerciseId")]
+        pub exercise_id: i64,
+        #[serde(rename = "lastSession")]
+        pub last_session: Option<QuickStatsSession>,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: Option<f64>,
+        #[serde(rename = "personalRecord")]
+        pub personal_record: Option<QuickStatsSet>,
+    }
+
+    impl ExerciseQuickStats {
+        pub fn new(
+            exercise_id: i64,
+            last_session: Vec<ExerciseSetEntity>,
+            estimated_one_rep_max: Option<f64>,
+            personal_record: Option<ExerciseSetEntity>,
+            conversion: WeightConversion,
+        ) -> Self {
+            let last_session = last_session
+                .first()
+                .map(|set| set.workout_id)
+                .map(|workout_id| QuickStatsSession {
+                    workout_id,
+                    sets: last_session
+                        .into_iter()
+                        .map(|set| QuickStatsSet::new(set, conversion))
+                        .collect(),
+                });
+
+            Self {
+                exercise_id,
+                last_session,
+                estimated_one_rep_max: estimated_one_rep_max
+                    .map(|estimate| conversion.value(estimate)),
+                personal_record: personal_record.map(|set| QuickStatsSet::new(set, conversion)),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct WorkoutDisplay {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        pub finished: bool,
+        #[serde(rename = "elapsedSeconds")]
+        pub elapsed_s: i64,
+        #[serde(rename = "currentExercise")]
+        pub current_exercise: Option<DisplayExercise>,
+        #[serde(rename = "lastSet")]
+        pub last_set: Option<ExerciseSet>,
+        #[serde(rename = "restElapsedSeconds")]
+        pub rest_elapsed_s: Option<i64>,
+        #[serde(rename = "restRemainingSeconds")]
+        pub rest_remaining_s: Option<i64>,
+        #[serde(rename = "nextSet")]
+        pub next_set: Option<ExerciseSet>,
+        #[serde(rename = "completedSets")]
+        pub completed_sets: usize,
+        #[serde(rename = "plannedSets")]
+        pub planned_sets: usize,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct DisplayExercise {
         pub id: i64,
         pub name: String,
     }
 
-    impl From<ExerciseEntity> for Exercise {
-        fn from(value: ExerciseEntity) -> Self {
+    impl WorkoutDisplay {
+        /// The current exercise is the one of the last completed set, the
+        /// next set is the first planned set in the order of the workout.
+        /// A running rest timer wins over a rest of `rest_s` after the last
+        /// set.
+        pub fn new(
+            workout: WorkoutEntity,
+            exercise_sets: Vec<ExerciseSetEntity>,
+            rest_timer: Option<RestTimerEntity>,
+            rest_s: i64,
+            now: DateTime<Utc>,
+        ) -> Self {
+            let end = workout.finished.unwrap_or(now);
+
+            let (completed, planned): (Vec<_>, Vec<_>) =
+                exercise_sets.into_iter().partition(|set| set.completed);
+
+            let completed_sets = completed.len();
+            let planned_sets = planned.len();
+
+            let last_set = completed
+                .into_iter()
+                .max_by_key(|set| (set.created, set.id));
+
+            let rest_elapsed_s = last_set
+                .as_ref()
+                .filter(|_| workout.finished.is_none())
+                .map(|set| (now - set.created).num_seconds().max(0));
+
+            let rest_remaining_s = match rest_timer {
+                Some(timer) if workout.finished.is_none() => Some(timer.remaining_s(now)),
+                _ => rest_elapsed_s.map(|elapsed| (rest_s - elapsed).max(0)),
+            };
+
+            Self {
+                workout_id: workout.id,
+                finished: workout.finished.is_some(),
+                elapsed_s: (end - workout.started).num_seconds().max(0),
+                current_exercise: last_set.as_ref().map(|set| DisplayExercise {
+                    id: set.exercise_id,
+                    name: set.exercise_name.clone(),
+                }),
+                last_set: last_set.map(ExerciseSet::from),
+                rest_elapsed_s,
+                rest_remaining_s,
+                next_set: planned.into_iter().next().map(ExerciseSet::from),
+                completed_sets,
+                planned_sets,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Correction {
+        pub id: i64,
+        pub description: String,
+        #[serde(rename = "affectedSets")]
+        pub affected_sets: i64,
+        #[serde(rename = "createdUtcSeconds")]
+        pub created_utc_s: i64,
+    }
+
+    impl From<CorrectionEntity> for Correction {
+        fn from(value: CorrectionEntity) -> Self {
             Self {
                 id: value.id,
-                name: value.name,
+                description: value.description,
+                affected_sets: value.affected_sets,
+                created_utc_s: value.created.timestamp(),
             }
         }
     }
 
-    #[derive(Debug, Deserialize, Serialize)]
-    pub struct Workout {
+    /// Secrets of the transport are redacted.
+    #[derive(Debug, Serialize)]
+    pub struct NotificationChannel {
         pub id: i64,
+        pub name: String,
+        pub transport: Transport,
         #[serde(rename = "createdUtcSeconds")]
         pub created_utc_s: i64,
-        pub note: Option<String>,
     }
 
-    impl From<WorkoutEntity> for Workout {
-        fn from(value: WorkoutEntity) -> Self {
+    impl NotificationChannel {
+        pub fn new(value: NotificationChannelEntity) -> anyhow::Result<Self> {
+            let transport = Transport::from_channel(&value)?.redacted();
+            Ok(Self {
+                id: value.id,
+                name: value.name,
+                transport,
+                created_utc_s: value.created.timestamp(),
+            })
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct NotificationRule {
+        pub id: i64,
+        pub event: NotificationEvent,
+        #[serde(rename = "channelId")]
+        pub channel_id: i64,
+    }
+
+    impl From<NotificationRuleEntity> for NotificationRule {
+        fn from(value: NotificationRuleEntity) -> Self {
             Self {
                 id: value.id,
-                created_utc_s: value.started.timestamp(),
-                note: value.note,
+                event: value.event,
+                channel_id: value.channel_id,
             }
         }
     }
 
-    #[derive(Debug, Deserialize, Serialize)]
-    pub struct ExerciseSet {
+    #[derive(Debug, Serialize)]
+    pub struct Errors {
+        #[serde(rename = "windowSeconds")]
+        pub window_s: i64,
+        pub total: usize,
+        pub routes: Vec<RouteErrors>,
+        pub samples: Vec<ErrorSample>,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct RouteErrors {
+        pub method: String,
+        pub route: String,
+        pub count: usize,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct ErrorSample {
+        pub method: String,
+        pub route: String,
+        pub status: u16,
+        pub message: Option<String>,
+        #[serde(rename = "occurredUtcSeconds")]
+        pub occurred_utc_s: i64,
+    }
+
+    impl From<ErrorReport> for Errors {
+        fn from(value: ErrorReport) -> Self {
+            Self {
+                window_s: monitoring::ERROR_WINDOW_S,
+                total: value.total,
+                routes: value
+                    .routes
+                    .into_iter()
+                    .map(|route| RouteErrors {
+                        method: route.method,
+                        route: route.route,
+                        count: route.count,
+                    })
+                    .collect(),
+                samples: value
+                    .samples
+                    .into_iter()
+                    .map(|event| ErrorSample {
+                        method: event.method,
+                        route: event.route,
+                        status: event.status,
+                        message: event.message,
+                        occurred_utc_s: event.occurred.timestamp(),
+                    })
+                    .collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct SetAnomaly {
         pub id: i64,
+        #[serde(rename = "setId")]
+        pub exercise_set_id: i64,
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
         #[serde(rename = "exerciseId")]
         pub exercise_id: i64,
         #[serde(rename = "exerciseName")]
         pub exercise_name: String,
-        #[serde(rename = "workoutId")]
-        pub workout_id: i64,
+        pub code: String,
+        pub message: String,
         #[serde(rename = "createdUtcSeconds")]
         pub created_utc_s: i64,
-        pub repetitions: i64,
-        pub weight: i64,
-        pub note: Option<String>,
     }
 
-    impl From<ExerciseSetEntity> for ExerciseSet {
-        fn from(value: ExerciseSetEntity) -> Self {
+    impl From<SetAnomalyEntity> for SetAnomaly {
+        fn from(value: SetAnomalyEntity) -> Self {
             Self {
                 id: value.id,
+                exercise_set_id: value.exercise_set_id,
+                workout_id: value.workout_id,
                 exercise_id: value.exercise_id,
                 exercise_name: value.exercise_name,
-                workout_id: value.workout_id,
+                code: value.code,
+                message: value.message,
                 created_utc_s: value.created.timestamp(),
-                repetitions: value.repetitions,
-                weight: value.weight,
-                note: value.note,
             }
         }
     }
 
     #[derive(Debug, Serialize)]
-    pub struct SetSuggestion {
+    pub struct ProgressionSeries {
         #[serde(rename = "exerciseId")]
         pub exercise_id: i64,
-        pub repetitions: i64,
-        pub weight: i64,
+        #[serde(rename = "exerciseName")]
+        pub exercise_name: String,
+        #[serde(rename = "estimatedOneRepMax")]
+        pub estimated_one_rep_max: Vec<Option<f64>>,
+        pub volume: Vec<Option<i64>>,
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct Progression {
+        pub weeks: Vec<String>,
+        pub series: Vec<ProgressionSeries>,
     }
 
-    impl From<SetSuggestionEntity> for SetSuggestion {
-        fn from(value: SetSuggestionEntity) -> Self {
+    impl Progression {
+        pub fn new(
+            progression: ProgressionEntity,
+            exercises: Vec<ExerciseEntity>,
+            conversion: WeightConversion,
+        ) -> Self {
+            let series = progression
+                .series
+                .into_iter()
+                .zip(exercises)
+                .map(|(series, exercise)| ProgressionSeries {
+                    exercise_id: series.exercise_id,
+                    exercise_name: exercise.name,
+                    estimated_one_rep_max: series
+                        .estimated_one_rep_max
+                        .into_iter()
+                        .map(|estimate| estimate.map(|estimate| conversion.value(estimate)))
+                        .collect(),
+                    volume: series
+                        .volume
+                        .into_iter()
+                        .map(|volume| volume.map(|volume| conversion.weight(volume)))
+                        .collect(),
+                })
+                .collect();
+
             Self {
-                exercise_id: value.exercise_id,
-                repetitions: value.repetitions,
-                weight: value.weight,
+                weeks: progression.weeks,
+                series,
             }
         }
     }
@@ -551,6 +5022,63 @@ mod responses {
     }
 
     #[derive(Debug, Serialize)]
+    pub struct ExerciseUsage {
+        #[serde(rename = "setCount")]
+        pub set_count: i64,
+        pub months: Vec<MonthlyUsage>,
+        pub workouts: Vec<WorkoutUsage>,
+    }
+
+    impl From<ExerciseUsageEntity> for ExerciseUsage {
+        fn from(value: ExerciseUsageEntity) -> Self {
+            Self {
+                set_count: value.set_count,
+                months: value.months.into_iter().map(MonthlyUsage::from).collect(),
+                workouts: value.workouts.into_iter().map(WorkoutUsage::from).collect(),
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct MonthlyUsage {
+        pub month: String,
+        #[serde(rename = "workoutCount")]
+        pub workout_count: i64,
+        #[serde(rename = "setCount")]
+        pub set_count: i64,
+    }
+
+    impl From<MonthlyUsageEntity> for MonthlyUsage {
+        fn from(value: MonthlyUsageEntity) -> Self {
+            Self {
+                month: value.month,
+                workout_count: value.workout_count,
+                set_count: value.set_count,
+            }
+        }
+    }
+
+    #[derive(Debug, Serialize)]
+    pub struct WorkoutUsage {
+        #[serde(rename = "workoutId")]
+        pub workout_id: i64,
+        #[serde(rename = "startedUtcSeconds")]
+        pub started_utc_s: i64,
+        #[serde(rename = "setCount")]
+        pub set_count: i64,
+    }
+
+    impl From<WorkoutUsageEntity> for WorkoutUsage {
+        fn from(value: WorkoutUsageEntity) -> Self {
+            Self {
+                workout_id: value.workout_id,
+                started_utc_s: value.started.timestamp(),
+                set_count: value.set_count,
+            }
+        }
+    }
+
+    #[derive(Debug, Clone, Serialize)]
     pub struct StatisticsOverview {
         #[serde(rename = "totalWorkouts")]
         total_workouts: i64,
@@ -564,10 +5092,38 @@ mod responses {
         total_repetitions: i64,
         #[serde(rename = "avgRepsPerSet")]
         avg_repetitions_per_set: i64,
+        #[serde(rename = "totalVolume")]
+        total_volume: i64,
+        #[serde(rename = "weekVolume")]
+        week_volume: i64,
+        #[serde(rename = "monthVolume")]
+        month_volume: i64,
+        #[serde(rename = "totalSetDurationSeconds")]
+        total_set_duration_s: i64,
+        #[serde(rename = "totalCardioSets")]
+        total_cardio_sets: i64,
+        #[serde(rename = "totalCardioDistanceMeters")]
+        total_cardio_distance_m: i64,
+        #[serde(rename = "totalCardioDurationSeconds")]
+        total_cardio_duration_s: i64,
+        #[serde(rename = "currentStreakWeeks")]
+        current_streak_weeks: i64,
+        #[serde(rename = "longestStreakWeeks")]
+        longest_streak_weeks: i64,
+        #[serde(rename = "avgWorkoutsPerWeek")]
+        avg_workouts_per_week: f64,
+        /// The last measured body weight.
+        #[serde(rename = "bodyWeight")]
+        body_weight: Option<i64>,
     }
 
-    impl From<StatisticsOverviewEntity> for StatisticsOverview {
-        fn from(value: StatisticsOverviewEntity) -> Self {
+    impl StatisticsOverview {
+        pub fn new(
+            value: StatisticsOverviewEntity,
+            frequency: Frequency,
+            body_weight: Option<i64>,
+            conversion: WeightConversion,
+        ) -> Self {
             Self {
                 total_workouts: value.total_workouts,
                 total_duration_s: value.total_duration_s,
@@ -575,6 +5131,17 @@ mod responses {
                 total_sets: value.total_sets,
                 total_repetitions: value.total_repetitions,
                 avg_repetitions_per_set: value.avg_repetitions_per_set,
+                total_volume: conversion.weight(value.total_volume),
+                week_volume: conversion.weight(value.week_volume),
+                month_volume: conversion.weight(value.month_volume),
+                total_set_duration_s: value.total_set_duration_s,
+                total_cardio_sets: value.total_cardio_sets,
+                total_cardio_distance_m: value.total_cardio_distance_m,
+                total_cardio_duration_s: value.total_cardio_duration_s,
+                current_streak_weeks: frequency.current_streak_weeks,
+                longest_streak_weeks: frequency.longest_streak_weeks,
+                avg_workouts_per_week: frequency.avg_workouts_per_week,
+                body_weight: body_weight.map(|weight| conversion.weight(weight)),
             }
         }
     }
@@ -0,0 +1,160 @@
+use serde::Deserialize;
+
+use crate::{dal::SessionSetEntity, insights, one_rep_max, units::WeightUnit};
+
+/// Selects the coefficients of the scores, which were fitted separately for
+/// men and women.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Sex {
+    Male,
+    Female,
+}
+
+/// Strength of an exercise relative to the body weight, based on its best
+/// estimated one-repetition maximum.
+#[derive(Debug)]
+pub struct RelativeStrength {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub one_rep_max: f64,
+    /// One-repetition maximum divided by the body weight.
+    pub ratio: f64,
+    pub wilks: f64,
+    pub dots: f64,
+}
+
+/// Computes the relative strength of every exercise with weighted sets. The
+/// body weight is in the unit of the sets, the scores convert both to
+/// kilograms. The sets must be ordered by exercise.
+pub fn relative_strength(
+    sets: &[SessionSetEntity],
+    body_weight: f64,
+    unit: WeightUnit,
+    sex: Sex,
+) -> Vec<RelativeStrength> {
+    let body_weight_kg = body_weight * unit.kilograms();
+
+    let mut strengths = insights::chunk_by_exercise(sets)
+        .into_iter()
+        .filter_map(|sets| {
+            let first = sets.first()?;
+            let one_rep_max = one_rep_max::best(sets.iter().map(|s| (s.weight, s.repetitions)))?;
+            let one_rep_max_kg = one_rep_max * unit.kilograms();
+
+            Some(RelativeStrength {
+                exercise_id: first.exercise_id,
+                exercise_name: first.exercise_name.clone(),
+                one_rep_max,
+                ratio: one_rep_max / body_weight,
+                wilks: wilks(one_rep_max_kg, body_weight_kg, sex),
+                dots: dots(one_rep_max_kg, body_weight_kg, sex),
+            })
+        })
+        .collect::<Vec<_>>();
+    strengths.sort_by(|a, b| a.exercise_name.cmp(&b.exercise_name));
+    strengths
+}
+
+/// Wilks score of a lift, both weights in kilograms. Body weights outside the
+/// range the formula was fitted for are clamped.
+pub fn wilks(lift: f64, body_weight: f64, sex: Sex) -> f64 {
+    let (coefficients, min, max) = match sex {
+        Sex::Male => (
+            [
+                -216.0475144,
+                16.2606339,
+                -0.002388645,
+                -0.00113732,
+                7.01863e-6,
+                -1.291e-8,
+            ],
+            40.0,
+            201.9,
+        ),
+        Sex::Female => (
+            [
+                594.31747775582,
+                -27.23842536447,
+                0.82112226871,
+                -0.00930733913,
+                4.731582e-5,
+                -9.054e-8,
+            ],
+            26.51,
+            154.53,
+        ),
+    };
+
+    lift * 500.0 / polynomial(&coefficients, body_weight.clamp(min, max))
+}
+
+/// DOTS score of a lift, both weights in kilograms. Body weights outside the
+/// range the formula was fitted for are clamped.
+pub fn dots(lift: f64, body_weight: f64, sex: Sex) -> f64 {
+    let (coefficients, max) = match sex {
+        Sex::Male => (
+            [
+                -307.75076,
+                24.0900756,
+                -0.1918759221,
+                0.0007391293,
+                -0.000001093,
+            ],
+            210.0,
+        ),
+        Sex::Female => (
+            [
+                -57.96288,
+                13.6175032,
+                -0.1126655495,
+                0.0005158568,
+                -0.0000010706,
+            ],
+            150.0,
+        ),
+    };
+
+    lift * 500.0 / polynomial(&coefficients, body_weight.clamp(40.0, max))
+}
+
+/// Evaluates a polynomial with the coefficients of the lowest degree first.
+fn polynomial(coefficients: &[f64], x: f64) -> f64 {
+    coefficients.iter().rev().fold(0.0, |sum, c| sum * x + c)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn assert_close(actual: f64, expected: f64) {
+        assert!(
+            (actual - expected).abs() < 0.01,
+            "{actual} is not {expected}"
+        );
+    }
+
+    #[test]
+    fn wilks_scores() {
+        assert_close(wilks(500.0, 100.0, Sex::Male), 304.29);
+        assert_close(wilks(300.0, 60.0, Sex::Female), 334.47);
+    }
+
+    #[test]
+    fn dots_scores() {
+        assert_close(dots(500.0, 100.0, Sex::Male), 307.76);
+        assert_close(dots(300.0, 60.0, Sex::Female), 332.56);
+    }
+
+    #[test]
+    fn body_weights_are_clamped() {
+        assert_eq!(
+            wilks(500.0, 300.0, Sex::Male),
+            wilks(500.0, 201.9, Sex::Male)
+        );
+        assert_eq!(
+            dots(300.0, 20.0, Sex::Female),
+            dots(300.0, 40.0, Sex::Female)
+        );
+    }
+}
@@ -0,0 +1,94 @@
+use anyhow::Result;
+use sqlx::SqliteExecutor;
+
+use crate::{
+    dal::{self, ExerciseEntity, ExerciseFilterInput, ExerciseSort, SetSuggestionEntity},
+    overload,
+};
+
+/// Muscle groups trained by opposing movements, so one recovers while the
+/// other works.
+const ANTAGONISTS: &[(&str, &str)] = &[
+    ("chest", "back"),
+    ("chest", "upper back"),
+    ("shoulders", "back"),
+    ("biceps", "triceps"),
+    ("quads", "hamstrings"),
+];
+
+/// An exercise to alternate with another one, with a suggestion for its next
+/// set.
+#[derive(Debug)]
+pub struct SupersetPair {
+    pub exercise: ExerciseEntity,
+    pub set: SetSuggestionEntity,
+}
+
+/// Finds an exercise that trains the antagonists of the primary muscles of
+/// the given exercise and, with a gym, only needs equipment available there.
+/// Exercises done most recently are preferred, because they are likely part
+/// of the current routine.
+pub async fn find_pair<'local, E>(
+    conn: E,
+    workout_id: i64,
+    exercise_id: i64,
+    gym_id: Option<i64>,
+) -> Result<Option<SupersetPair>>
+where
+    E: SqliteExecutor<'local> + Copy,
+{
+    let Some(exercise) = dal::get_exercise(conn, exercise_id).await? else {
+        return Ok(None);
+    };
+
+    let muscles = exercise.primary_muscles();
+    let antagonists = muscles
+        .iter()
+        .flat_map(|muscle| antagonists_of(muscle))
+        .collect::<Vec<_>>();
+    if antagonists.is_empty() {
+        return Ok(None);
+    }
+
+    let filter = ExerciseFilterInput {
+        sort: ExerciseSort::Recent,
+        gym_id,
+        ..Default::default()
+    };
+    let pair = dal::get_exercises(conn, &filter)
+        .await?
+        .into_iter()
+        .find(|candidate| {
+            let candidate_muscles = candidate.primary_muscles();
+            candidate.id != exercise.id
+                && candidate_muscles
+                    .iter()
+                    .any(|muscle| antagonists.contains(&muscle.as_str()))
+                && !candidate_muscles
+                    .iter()
+                    .any(|muscle| muscles.contains(muscle))
+        });
+
+    let Some(pair) = pair else {
+        return Ok(None);
+    };
+
+    let set = overload::suggest(conn, workout_id, Some(pair.id), gym_id).await?;
+
+    Ok(Some(SupersetPair {
+        exercise: pair,
+        set,
+    }))
+}
+
+fn antagonists_of(muscle: &str) -> impl Iterator<Item = &'static str> + '_ {
+    ANTAGONISTS.iter().filter_map(move |&(a, b)| {
+        if a == muscle {
+            Some(b)
+        } else if b == muscle {
+            Some(a)
+        } else {
+            None
+        }
+    })
+}
@@ -0,0 +1,260 @@
+use anyhow::{Context, Result};
+use chrono::{DateTime, Datelike, Duration, NaiveDate, TimeZone, Utc};
+use serde::{Deserialize, Serialize};
+use sqlx::{Pool, Sqlite};
+
+use crate::{
+    dal::{self, AdherenceEntity, StatisticsFilterInput, StatisticsOverviewEntity},
+    pdf::{Document, Style},
+    records::{self, Record},
+    units::WeightConversion,
+};
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Period {
+    /// Starting on Monday.
+    Week,
+    Month,
+}
+
+impl Period {
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Week => "week",
+            Self::Month => "month",
+        }
+    }
+
+    /// First day of the period containing the date and first day of the
+    /// following period.
+    pub fn range(self, date: NaiveDate) -> (NaiveDate, NaiveDate) {
+        match self {
+            Self::Week => {
+                let start = date - Duration::days(date.weekday().num_days_from_monday() as i64);
+                (start, start + Duration::weeks(1))
+            }
+            Self::Month => {
+                let start = date.with_day(1).expect("First day of month must exist");
+                let end = if start.month() == 12 {
+                    NaiveDate::from_ymd_opt(start.year() + 1, 1, 1)
+                } else {
+                    NaiveDate::from_ymd_opt(start.year(), start.month() + 1, 1)
+                }
+                .expect("First day of next month must exist");
+                (start, end)
+            }
+        }
+    }
+}
+
+/// Summary of the training of a week or month in UTC.
+#[derive(Debug)]
+pub struct TrainingReport {
+    pub period: Period,
+    pub start: DateTime<Utc>,
+    /// Exclusive.
+    pub end: DateTime<Utc>,
+    pub overview: StatisticsOverviewEntity,
+    /// Most sets first.
+    pub muscles: Vec<MuscleSets>,
+    pub records: Vec<NewRecord>,
+    pub adherence: AdherenceEntity,
+}
+
+#[derive(Debug)]
+pub struct MuscleSets {
+    pub muscle: String,
+    pub primary_sets: i64,
+    pub secondary_sets: i64,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub enum RecordKind {
+    Heaviest,
+    EstimatedOneRepMax,
+}
+
+/// A personal record that was set within the period.
+#[derive(Debug)]
+pub struct NewRecord {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub kind: RecordKind,
+    pub record: Record,
+}
+
+/// Builds the report of the period that contains the given time.
+pub async fn build(
+    pool: &Pool<Sqlite>,
+    period: Period,
+    at: DateTime<Utc>,
+) -> Result<TrainingReport> {
+    let (start, end) = period.range(at.date_naive());
+    let start = start_of_day(start)?;
+    let end = start_of_day(end)?;
+    let (from, to) = (start.timestamp(), end.timestamp());
+
+    let filter = StatisticsFilterInput {
+        from: Some(from),
+        to: Some(to),
+        ..Default::default()
+    };
+    let overview = dal::get_statistics_overview(pool, None, &filter).await?;
+
+    let mut muscles: Vec<MuscleSets> = Vec::new();
+    for week in dal::get_muscle_volume(pool, 0, Some(from), Some(to)).await? {
+        match muscles.iter_mut().find(|sets| sets.muscle == week.muscle) {
+            Some(sets) => {
+                sets.primary_sets += week.primary_sets;
+                sets.secondary_sets += week.secondary_sets;
+            }
+            None => muscles.push(MuscleSets {
+                muscle: week.muscle,
+                primary_sets: week.primary_sets,
+                secondary_sets: week.secondary_sets,
+            }),
+        }
+    }
+    muscles.sort_by(|a, b| {
+        (b.primary_sets, b.secondary_sets)
+            .cmp(&(a.primary_sets, a.secondary_sets))
+            .then_with(|| a.muscle.cmp(&b.muscle))
+    });
+
+    // Records are determined as of the end of the period, so records that
+    // were beaten later still count.
+    let sets = dal::get_weighted_working_sets(pool, None, Some(to)).await?;
+    let in_period = |record: &Record| record.achieved >= start && record.achieved < end;
+    let mut new_records = Vec::new();
+    for exercise in records::personal_records(&sets) {
+        let candidates = [
+            (RecordKind::Heaviest, exercise.heaviest),
+            (
+                RecordKind::EstimatedOneRepMax,
+                exercise.best_one_rep_max.set,
+            ),
+        ];
+        for (kind, record) in candidates {
+            if in_period(&record) {
+                new_records.push(NewRecord {
+                    exercise_id: exercise.exercise_id,
+                    exercise_name: exercise.exercise_name.clone(),
+                    kind,
+                    record,
+                });
+            }
+        }
+    }
+
+    let adherence = dal::get_adherence(pool, from, to).await?;
+
+    Ok(TrainingReport {
+        period,
+        start,
+        end,
+        overview,
+        muscles,
+        records: new_records,
+        adherence,
+    })
+}
+
+/// Renders the report as a printable PDF, weights are in the unit of the
+/// instance.
+/// Weights are converted to the target unit of the conversion.
+pub fn render_pdf(report: &TrainingReport, conversion: WeightConversion) -> Vec<u8> {
+    let unit = conversion.to.as_str();
+    let start = report.start.date_naive();
+    let last_day = (report.end - Duration::days(1)).date_naive();
+
+    let mut doc = Document::new();
+    let title = match report.period {
+        Period::Week => format!("Training report: week of {start}"),
+        Period::Month => format!("Training report: {}", start.format("%B %Y")),
+    };
+    doc.line(Style::Title, &title);
+    doc.line(Style::Text, &format!("{start} to {last_day}"));
+
+    let overview = &report.overview;
+    doc.line(Style::Heading, "Workouts");
+    doc.line(
+        Style::Text,
+        &format!("Workouts: {}", overview.total_workouts),
+    );
+    doc.line(
+        Style::Text,
+        &format!(
+            "Training time: {} h {} min",
+            overview.total_duration_s / 3600,
+            overview.total_duration_s % 3600 / 60
+        ),
+    );
+    doc.line(Style::Text, &format!("Sets: {}", overview.total_sets));
+    doc.line(
+        Style::Text,
+        &format!("Repetitions: {}", overview.total_repetitions),
+    );
+    doc.line(
+        Style::Text,
+        &format!(
+            "Volume: {} {unit}",
+            conversion.weight(overview.total_volume)
+        ),
+    );
+
+    doc.line(Style::Heading, "Sets per muscle group");
+    if report.muscles.is_empty() {
+        doc.line(Style::Text, "No sets.");
+    }
+    for muscle in &report.muscles {
+        doc.line(
+            Style::Text,
+            &format!(
+                "{}: {} primary, {} secondary",
+                muscle.muscle, muscle.primary_sets, muscle.secondary_sets
+            ),
+        );
+    }
+
+    doc.line(Style::Heading, "Personal records");
+    if report.records.is_empty() {
+        doc.line(Style::Text, "No new records.");
+    }
+    for record in &report.records {
+        let kind = match record.kind {
+            RecordKind::Heaviest => "heaviest",
+            RecordKind::EstimatedOneRepMax => "estimated 1RM",
+        };
+        doc.line(
+            Style::Text,
+            &format!(
+                "{} ({kind}): {} x {} {unit} on {}",
+                record.exercise_name,
+                record.record.repetitions,
+                conversion.weight(record.record.weight),
+                record.record.achieved.date_naive()
+            ),
+        );
+    }
+
+    let adherence = &report.adherence;
+    doc.line(Style::Heading, "Planned sets");
+    doc.line(
+        Style::Text,
+        &format!(
+            "{} of {} completed, {} reached their target",
+            adherence.completed_sets, adherence.planned_sets, adherence.targets_met
+        ),
+    );
+
+    doc.render()
+}
+
+fn start_of_day(date: NaiveDate) -> Result<DateTime<Utc>> {
+    let start = date
+        .and_hms_opt(0, 0, 0)
+        .with_context(|| format!("Invalid start of day {date}"))?;
+    Ok(Utc.from_utc_datetime(&start))
+}
@@ -0,0 +1,150 @@
+use chrono::{DateTime, Utc};
+use serde::Serialize;
+
+use crate::{dal::SessionSetEntity, insights, one_rep_max};
+
+/// Window of the trends if none is given.
+pub const DEFAULT_WEEKS: i64 = 8;
+
+pub const MAX_WEEKS: i64 = 520;
+
+/// Change per week relative to the average of a metric, below which a lift
+/// counts as plateaued.
+const PLATEAU_RATIO_PER_WEEK: f64 = 0.005;
+
+/// Sessions needed to fit a trend.
+const MIN_SESSIONS: usize = 3;
+
+const SECONDS_PER_WEEK: f64 = 7.0 * 24.0 * 60.0 * 60.0;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Direction {
+    Improving,
+    Plateaued,
+    Regressing,
+}
+
+/// Slope of a linear regression of a metric over time.
+#[derive(Debug)]
+pub struct Trend {
+    /// Change per week.
+    pub slope: f64,
+    pub direction: Direction,
+}
+
+#[derive(Debug)]
+pub struct ExerciseTrend {
+    pub exercise_id: i64,
+    pub exercise_name: String,
+    pub sessions: usize,
+    pub one_rep_max: Trend,
+    pub volume: Trend,
+}
+
+/// Metrics of a session of an exercise.
+struct Session {
+    workout_id: i64,
+    started: DateTime<Utc>,
+    best: f64,
+    volume: f64,
+}
+
+/// Fits the trends of the estimated one-repetition maximum and the volume
+/// per session of every exercise trained at least `MIN_SESSIONS` times
+/// since the given time. The sets must be ordered by exercise and session.
+pub fn trends(sets: &[SessionSetEntity], since: DateTime<Utc>) -> Vec<ExerciseTrend> {
+    let mut trends = insights::chunk_by_exercise(sets)
+        .into_iter()
+        .filter_map(|sets| exercise_trend(sets, since))
+        .collect::<Vec<_>>();
+    trends.sort_by(|a, b| a.exercise_name.cmp(&b.exercise_name));
+    trends
+}
+
+fn exercise_trend(sets: &[SessionSetEntity], since: DateTime<Utc>) -> Option<ExerciseTrend> {
+    let first = sets.first()?;
+    let sessions = group_sessions(sets.iter().filter(|set| set.started >= since));
+    if sessions.len() < MIN_SESSIONS {
+        return None;
+    }
+
+    let one_rep_max = fit(sessions
+        .iter()
+        .map(|session| (session.started, session.best)))?;
+    let volume = fit(sessions
+        .iter()
+        .map(|session| (session.started, session.volume)))?;
+
+    Some(ExerciseTrend {
+        exercise_id: first.exercise_id,
+        exercise_name: first.exercise_name.clone(),
+        sessions: sessions.len(),
+        one_rep_max,
+        volume,
+    })
+}
+
+fn group_sessions<'a, I>(sets: I) -> Vec<Session>
+where
+    I: Iterator<Item = &'a SessionSetEntity>,
+{
+    let mut sessions: Vec<Session> = Vec::new();
+
+    for set in sets {
+        let estimate = one_rep_max::estimate(set.weight, set.repetitions).unwrap_or(0.0);
+        let volume = (set.weight * set.repetitions) as f64;
+
+        match sessions.last_mut() {
+            Some(session) if session.workout_id == set.workout_id => {
+                session.best = session.best.max(estimate);
+                session.volume += volume;
+            }
+            _ => sessions.push(Session {
+                workout_id: set.workout_id,
+                started: set.started,
+                best: estimate,
+                volume,
+            }),
+        }
+    }
+
+    sessions
+}
+
+/// Least squares fit with the time in weeks, the slope is classified relative
+/// to the average so light and heavy lifts are treated alike. Returns `None`
+/// if all points are at the same time or the average isn't positive.
+fn fit<I>(points: I) -> Option<Trend>
+where
+    I: Iterator<Item = (DateTime<Utc>, f64)>,
+{
+    let points = points
+        .map(|(time, value)| (time.timestamp() as f64 / SECONDS_PER_WEEK, value))
+        .collect::<Vec<_>>();
+    let n = points.len() as f64;
+    let mean_x = points.iter().map(|(x, _)| x).sum::<f64>() / n;
+    let mean_y = points.iter().map(|(_, y)| y).sum::<f64>() / n;
+
+    let (covariance, variance) = points.iter().fold((0.0, 0.0), |(cov, var), (x, y)| {
+        (
+            cov + (x - mean_x) * (y - mean_y),
+            var + (x - mean_x).powi(2),
+        )
+    });
+    if variance == 0.0 || mean_y <= 0.0 {
+        return None;
+    }
+
+    let slope = covariance / variance;
+    let ratio = slope / mean_y;
+    let direction = if ratio > PLATEAU_RATIO_PER_WEEK {
+        Direction::Improving
+    } else if ratio < -PLATEAU_RATIO_PER_WEEK {
+        Direction::Regressing
+    } else {
+        Direction::Plateaued
+    };
+
+    Some(Trend { slope, direction })
+}
@@ -0,0 +1,140 @@
+use std::str::FromStr;
+
+use serde::{Deserialize, Serialize};
+
+const KILOGRAMS_PER_POUND: f64 = 0.45359237;
+
+/// Unit of all weights stored by an instance, clients may send weights in
+/// the other unit as long as they say so.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum WeightUnit {
+    #[default]
+    Kg,
+    Lb,
+}
+
+impl WeightUnit {
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Kg => "kg",
+            Self::Lb => "lb",
+        }
+    }
+
+    /// Factor to convert a weight in this unit to kilograms.
+    pub fn kilograms(self) -> f64 {
+        match self {
+            Self::Kg => 1.0,
+            Self::Lb => KILOGRAMS_PER_POUND,
+        }
+    }
+
+    /// Converts a weight in this unit to the given unit, rounded to whole units
+    /// as weights are stored as integers. Weights other than zero keep at
+    /// least one unit, e.g. 1 lb becomes 1 kg instead of 0 kg.
+    pub fn convert(self, weight: i64, to: WeightUnit) -> i64 {
+        if self == to {
+            return weight;
+        }
+
+        match (weight as f64 * self.kilograms() / to.kilograms()).round() as i64 {
+            0 => weight.signum(),
+            converted => converted,
+        }
+    }
+}
+
+impl FromStr for WeightUnit {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "kg" => Ok(Self::Kg),
+            "lb" => Ok(Self::Lb),
+            other => Err(format!(
+                r#"unknown weight unit "{other}", expected kg or lb"#
+            )),
+        }
+    }
+}
+
+/// Converts weights from the unit of the instance to the unit a client asked
+/// for.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct WeightConversion {
+    pub from: WeightUnit,
+    pub to: WeightUnit,
+}
+
+impl WeightConversion {
+    pub fn new(from: WeightUnit, to: WeightUnit) -> Self {
+        Self { from, to }
+    }
+
+    /// Converts weights the client sent back to the unit of the instance.
+    pub fn inverse(self) -> Self {
+        Self::new(self.to, self.from)
+    }
+
+    /// Converts a whole weight, rounded to whole units.
+    pub fn weight(self, weight: i64) -> i64 {
+        self.from.convert(weight, self.to)
+    }
+
+    /// Converts a derived value like an estimate or a tonnage without
+    /// rounding.
+    pub fn value(self, value: f64) -> f64 {
+        if self.from == self.to {
+            return value;
+        }
+
+        value * self.from.kilograms() / self.to.kilograms()
+    }
+}
+
+/// Unit and locale configuration of an instance, used by clients to format
+/// numbers consistently.
+#[derive(Debug, Clone)]
+pub struct UnitConfig {
+    pub weight_unit: WeightUnit,
+    pub locale: String,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn converts_between_units() {
+        assert_eq!(WeightUnit::Kg.convert(100, WeightUnit::Kg), 100);
+        assert_eq!(WeightUnit::Kg.convert(100, WeightUnit::Lb), 220);
+        assert_eq!(WeightUnit::Lb.convert(220, WeightUnit::Kg), 100);
+    }
+
+    #[test]
+    fn small_weights_stay_non_zero() {
+        assert_eq!(WeightUnit::Lb.convert(1, WeightUnit::Kg), 1);
+        assert_eq!(WeightUnit::Lb.convert(-1, WeightUnit::Kg), -1);
+        assert_eq!(WeightUnit::Lb.convert(0, WeightUnit::Kg), 0);
+    }
+
+    #[test]
+    fn conversion_of_weights_and_values() {
+        let conversion = WeightConversion::new(WeightUnit::Kg, WeightUnit::Lb);
+
+        assert_eq!(conversion.weight(100), 220);
+        assert_eq!(conversion.inverse().weight(220), 100);
+        assert!((conversion.value(1.0) - 1.0 / KILOGRAMS_PER_POUND).abs() < 1e-9);
+
+        let identity = WeightConversion::new(WeightUnit::Lb, WeightUnit::Lb);
+        assert_eq!(identity.value(2.5), 2.5);
+    }
+
+    #[test]
+    fn parses_units() {
+        assert_eq!("kg".parse(), Ok(WeightUnit::Kg));
+        assert_eq!("lb".parse(), Ok(WeightUnit::Lb));
+        assert!("stone".parse::<WeightUnit>().is_err());
+    }
+}
@@ -0,0 +1,430 @@
+use crate::{
+    dal::{
+        BodyWeightInput, ExerciseInput, ExerciseSetInput, GoalInput, GoalKind, GymEntity, GymInput,
+        NotificationEvent,
+    },
+    notifications::{self, Notification, Transport},
+    plates::PlateSelection,
+};
+
+const MAX_NOTE_LENGTH: usize = 2000;
+
+const MAX_EXERCISE_NAME_LENGTH: usize = 100;
+
+const MAX_GYM_NAME_LENGTH: usize = 100;
+
+/// Maximum length of a muscle group or a piece of equipment.
+const MAX_LIST_ITEM_LENGTH: usize = 50;
+
+pub const MAX_DESCRIPTION_LENGTH: usize = 500;
+
+const MAX_URL_LENGTH: usize = 2000;
+
+const MAX_TEMPLATE_LENGTH: usize = 4000;
+
+/// Maximum size of a workout draft in bytes, a set form is way smaller.
+const MAX_DRAFT_SIZE: usize = 16 * 1024;
+
+/// Maximum duration of a rest timer, longer breaks aren't rests anymore.
+const MAX_REST_S: i64 = 60 * 60;
+
+/// A rejected field of a written entity, `field` uses the name of the API
+/// and `code` is meant to be matched by clients.
+#[derive(Debug)]
+pub struct FieldError {
+    pub field: &'static str,
+    pub code: &'static str,
+    pub message: String,
+}
+
+/// All rejected fields of a written entity, never empty.
+#[derive(Debug)]
+pub struct ValidationError(pub Vec<FieldError>);
+
+pub fn validate_exercise(exercise: &ExerciseInput) -> Result<(), ValidationError> {
+    let mut errors = Vec::new();
+
+    errors.extend(invalid_name(&exercise.name, MAX_EXERCISE_NAME_LENGTH));
+
+    if let Some(description) = &exercise.description {
+        if description.chars().count() > MAX_DESCRIPTION_LENGTH {
+            errors.push(too_long("description", MAX_DESCRIPTION_LENGTH));
+        }
+    }
+
+    if let Some(instructions) = &exercise.instructions {
+        if instructions.chars().count() > MAX_NOTE_LENGTH {
+            errors.push(too_long("instructions", MAX_NOTE_LENGTH));
+        }
+    }
+
+    if let Some(video_url) = exercise.video_url.as_deref().filter(|url| !url.is_empty()) {
+        errors.extend(invalid_url("videoUrl", video_url));
+    }
+
+    let non_negative = [
+        ("defaultIncrement", exercise.default_increment),
+        ("minRepetitions", exercise.min_repetitions),
+        ("maxRepetitions", exercise.max_repetitions),
+    ];
+
+    for (field, value) in non_negative {
+        if matches!(value, Some(value) if value < 0) {
+            errors.push(FieldError {
+                field,
+                code: "negative",
+                message: format!("The {field} must not be negative."),
+            });
+        }
+    }
+
+    if let (Some(min), Some(max)) = (exercise.min_repetitions, exercise.max_repetitions) {
+        if min > 0 && max > 0 && min > max {
+            errors.push(FieldError {
+                field: "minRepetitions",
+                code: "invalid_range",
+                message: "The minRepetitions must not be greater than the maxRepetitions."
+                    .to_string(),
+            });
+        }
+    }
+
+    let lists = [
+        ("primaryMuscles", &exercise.primary_muscles),
+        ("secondaryMuscles", &exercise.secondary_muscles),
+        ("equipment", &exercise.equipment),
+        ("tags", &exercise.tags),
+    ];
+
+    for (field, items) in lists {
+        errors.extend(invalid_list(field, items.as_deref().unwrap_or_default()));
+    }
+
+    into_result(errors)
+}
+
+pub fn validate_gym(gym: &GymInput) -> Result<(), ValidationError> {
+    let mut errors = Vec::new();
+
+    errors.extend(invalid_name(&gym.name, MAX_GYM_NAME_LENGTH));
+    errors.extend(invalid_list("equipment", &gym.equipment));
+
+    if gym
+        .bar_weight
+        .map_or(false, |weight| !weight.is_finite() || weight < 0.0)
+    {
+        errors.push(FieldError {
+            field: "barWeight",
+            code: "negative",
+            message: "The bar weight must not be negative.".to_string(),
+        });
+    }
+
+    if gym
+        .plates
+        .iter()
+        .any(|plate| !plate.weight.is_finite() || plate.weight <= 0.0 || plate.count <= 0)
+    {
+        errors.push(FieldError {
+            field: "plates",
+            code: "not_positive",
+            message: "Every plate must weigh more than zero and be available at least once."
+                .to_string(),
+        });
+    }
+
+    into_result(errors)
+}
+
+pub fn validate_body_weight(body_weight: &BodyWeightInput) -> Result<(), ValidationError> {
+    if body_weight.weight <= 0 {
+        return Err(ValidationError(vec![FieldError {
+            field: "weight",
+            code: "not_positive",
+            message: "The weight must be greater than zero.".to_string(),
+        }]));
+    }
+
+    Ok(())
+}
+
+pub fn validate_goal(goal: &GoalInput) -> Result<(), ValidationError> {
+    let mut errors = Vec::new();
+
+    if goal.target <= 0 {
+        errors.push(FieldError {
+            field: "target",
+            code: "not_positive",
+            message: "The target must be greater than zero.".to_string(),
+        });
+    }
+
+    match (goal.kind, goal.exercise_id) {
+        (GoalKind::OneRepMax, None) => errors.push(FieldError {
+            field: "exerciseId",
+            code: "required",
+            message: "The exerciseId is required for one_rep_max goals.".to_string(),
+        }),
+        (GoalKind::WeeklyWorkouts | GoalKind::BodyWeight, Some(_)) => errors.push(FieldError {
+            field: "exerciseId",
+            code: "invalid",
+            message: "The exerciseId is only allowed for one_rep_max goals.".to_string(),
+        }),
+        _ => {}
+    }
+
+    into_result(errors)
+}
+
+/// Rejects values that can't be entered on purpose, bodyweight sets are
+/// written without a weight instead of a weight of zero. A negative added
+/// weight is fine as it marks assisted exercises.
+pub fn validate_exercise_set(exercise_set: &ExerciseSetInput) -> Result<(), ValidationError> {
+    let mut errors = Vec::new();
+
+    let non_negative = [
+        ("repetitions", Some(exercise_set.repetitions)),
+        ("durationSeconds", exercise_set.duration_s),
+        ("distanceMeters", exercise_set.distance_m),
+        ("targetRepetitions", exercise_set.target_repetitions),
+    ];
+
+    for (field, value) in non_negative {
+        if matches!(value, Some(value) if value < 0) {
+            errors.push(FieldError {
+                field,
+                code: "negative",
+                message: format!("The {field} must not be negative."),
+            });
+        }
+    }
+
+    let positive = [
+        ("weight", exercise_set.weight),
+        ("targetWeight", exercise_set.target_weight),
+    ];
+
+    for (field, value) in positive {
+        if matches!(value, Some(value) if value <= 0) {
+            errors.push(FieldError {
+                field,
+                code: "not_positive",
+                message: format!("The {field} must be greater than zero."),
+            });
+        }
+    }
+
+    if exercise_set.note.chars().count() > MAX_NOTE_LENGTH {
+        errors.push(too_long("note", MAX_NOTE_LENGTH));
+    }
+
+    if matches!(&exercise_set.client_id, Some(client_id) if !is_uuid(client_id)) {
+        errors.push(FieldError {
+            field: "clientId",
+            code: "invalid_uuid",
+            message: "The clientId must be a UUID.".to_string(),
+        });
+    }
+
+    into_result(errors)
+}
+
+/// Plates are only accepted if the gym has a bar and enough plates of every
+/// weight to load both sides.
+pub fn validate_plates(
+    plates: &PlateSelection,
+    gym: Option<&GymEntity>,
+) -> Result<(), ValidationError> {
+    let Some(gym) = gym else {
+        return Err(ValidationError(vec![FieldError {
+            field: "plates",
+            code: "unknown_gym",
+            message: format!("There is no gym with id {}.", plates.gym_id),
+        }]));
+    };
+
+    let mut errors = Vec::new();
+
+    if gym.bar_weight.is_none() {
+        errors.push(FieldError {
+            field: "plates",
+            code: "no_bar",
+            message: format!("The gym {} has no bar weight.", gym.name),
+        });
+    }
+
+    let missing = plates.missing_plates(&gym.plates());
+    if !missing.is_empty() {
+        let missing = missing
+            .iter()
+            .map(f64::to_string)
+            .collect::<Vec<_>>()
+            .join(", ");
+        errors.push(FieldError {
+            field: "plates",
+            code: "unavailable",
+            message: format!(
+                "The gym {} doesn't have enough plates of {missing}.",
+                gym.name
+            ),
+        });
+    }
+
+    into_result(errors)
+}
+
+/// Only accepts web links, so linked attachments can't point to local files.
+pub fn validate_attachment_url(url: &str) -> Result<(), ValidationError> {
+    into_result(invalid_url("url", url).into_iter().collect())
+}
+
+pub fn validate_workout_draft(data: &str) -> Result<(), ValidationError> {
+    if data.len() > MAX_DRAFT_SIZE {
+        return Err(ValidationError(vec![FieldError {
+            field: "draft",
+            code: "too_large",
+            message: format!("The draft must not be larger than {MAX_DRAFT_SIZE} bytes."),
+        }]));
+    }
+
+    Ok(())
+}
+
+pub fn validate_rest_timer(duration_s: i64) -> Result<(), ValidationError> {
+    let error = if duration_s <= 0 {
+        FieldError {
+            field: "durationSeconds",
+            code: "not_positive",
+            message: "The durationSeconds must be greater than zero.".to_string(),
+        }
+    } else if duration_s > MAX_REST_S {
+        FieldError {
+            field: "durationSeconds",
+            code: "too_large",
+            message: format!("The durationSeconds must not be greater than {MAX_REST_S}."),
+        }
+    } else {
+        return Ok(());
+    };
+
+    Err(ValidationError(vec![error]))
+}
+
+/// Webhook templates are rendered with a sample notification, so mistakes
+/// show up when the channel is created instead of when it is used.
+pub fn validate_transport(transport: &Transport) -> Result<(), ValidationError> {
+    let template = match transport {
+        Transport::Email { to } if !notifications::is_mail_address(to) => {
+            return Err(ValidationError(vec![FieldError {
+                field: "to",
+                code: "invalid",
+                message: "The recipient must be a single mail address.".to_string(),
+            }]));
+        }
+        Transport::Webhook {
+            template: Some(template),
+            ..
+        } => template,
+        _ => return Ok(()),
+    };
+
+    if template.chars().count() > MAX_TEMPLATE_LENGTH {
+        return Err(ValidationError(vec![too_long(
+            "template",
+            MAX_TEMPLATE_LENGTH,
+        )]));
+    }
+
+    let sample = Notification {
+        event: NotificationEvent::Test,
+        title: "Title".to_string(),
+        message: "Message".to_string(),
+    };
+
+    match notifications::render_template(template, &sample) {
+        Ok(_) => Ok(()),
+        Err(err) => Err(ValidationError(vec![FieldError {
+            field: "template",
+            code: "invalid_template",
+            message: format!("{err:#}."),
+        }])),
+    }
+}
+
+fn invalid_name(name: &str, max_length: usize) -> Option<FieldError> {
+    let name = name.trim();
+
+    if name.is_empty() {
+        Some(FieldError {
+            field: "name",
+            code: "required",
+            message: "The name must not be empty.".to_string(),
+        })
+    } else if name.chars().count() > max_length {
+        Some(too_long("name", max_length))
+    } else {
+        None
+    }
+}
+
+/// Items of lists are stored comma separated, so they can't contain commas.
+fn invalid_list(field: &'static str, items: &[String]) -> Option<FieldError> {
+    if items.iter().any(|item| item.is_empty()) {
+        Some(FieldError {
+            field,
+            code: "required",
+            message: format!("The {field} must not contain empty entries."),
+        })
+    } else if items
+        .iter()
+        .any(|item| item.chars().count() > MAX_LIST_ITEM_LENGTH || item.contains(','))
+    {
+        Some(FieldError {
+            field,
+            code: "invalid",
+            message: format!(
+                "The {field} entries must be at most {MAX_LIST_ITEM_LENGTH} characters without commas."
+            ),
+        })
+    } else {
+        None
+    }
+}
+
+fn invalid_url(field: &'static str, url: &str) -> Option<FieldError> {
+    if !url.starts_with("https://") && !url.starts_with("http://") {
+        Some(FieldError {
+            field,
+            code: "invalid_url",
+            message: format!("The {field} must be an http or https link."),
+        })
+    } else if url.chars().count() > MAX_URL_LENGTH {
+        Some(too_long(field, MAX_URL_LENGTH))
+    } else {
+        None
+    }
+}
+
+fn is_uuid(value: &str) -> bool {
+    value.len() == 36
+        && value.char_indices().all(|(i, c)| match i {
+            8 | 13 | 18 | 23 => c == '-',
+            _ => c.is_ascii_hexdigit(),
+        })
+}
+
+fn too_long(field: &'static str, max_length: usize) -> FieldError {
+    FieldError {
+        field,
+        code: "too_long",
+        message: format!("The {field} must not be longer than {max_length} characters."),
+    }
+}
+
+fn into_result(errors: Vec<FieldError>) -> Result<(), ValidationError> {
+    if errors.is_empty() {
+        Ok(())
+    } else {
+        Err(ValidationError(errors))
+    }
+}
@@ -0,0 +1,264 @@
+use std::collections::HashMap;
+
+use anyhow::{Context, Result};
+use serde::Deserialize;
+use sqlx::{Pool, Sqlite};
+use tracing::{debug, info};
+
+use crate::{
+    dal::{self, ExerciseCategory, ExerciseFilterInput, ExerciseInput, ExerciseModality},
+    validation,
+};
+
+/// Language id of English in wger.
+const LANGUAGE_ENGLISH: i64 = 2;
+
+const PAGE_SIZE: usize = 100;
+
+/// Category id of cardio exercises in wger.
+const CATEGORY_CARDIO: i64 = 15;
+
+/// Muscles of wger by id, mapped to the muscle groups of the built-in
+/// catalog.
+const MUSCLES: &[(i64, &str)] = &[
+    (1, "biceps"),
+    (2, "shoulders"),
+    (3, "chest"),
+    (4, "chest"),
+    (5, "triceps"),
+    (6, "abs"),
+    (7, "calves"),
+    (8, "glutes"),
+    (9, "traps"),
+    (10, "quads"),
+    (11, "hamstrings"),
+    (12, "back"),
+    (13, "biceps"),
+    (14, "abs"),
+    (15, "calves"),
+];
+
+/// Equipment id of wger for exercises without equipment.
+const EQUIPMENT_NONE: i64 = 7;
+
+#[derive(Debug, Deserialize)]
+struct Page {
+    next: Option<String>,
+    results: Vec<WgerExercise>,
+}
+
+#[derive(Debug, Deserialize)]
+struct WgerExercise {
+    id: i64,
+    category: WgerCategory,
+    muscles: Vec<WgerReference>,
+    muscles_secondary: Vec<WgerReference>,
+    equipment: Vec<WgerEquipment>,
+    /// Older versions of the API call them `exercises`.
+    #[serde(default, alias = "exercises")]
+    translations: Vec<WgerTranslation>,
+}
+
+#[derive(Debug, Deserialize)]
+struct WgerCategory {
+    id: i64,
+}
+
+#[derive(Debug, Deserialize)]
+struct WgerReference {
+    id: i64,
+}
+
+#[derive(Debug, Deserialize)]
+struct WgerEquipment {
+    id: i64,
+    name: String,
+}
+
+#[derive(Debug, Deserialize)]
+struct WgerTranslation {
+    name: String,
+    #[serde(default)]
+    description: String,
+    language: i64,
+}
+
+#[derive(Debug, Default)]
+pub struct ImportResult {
+    pub created: usize,
+    pub updated: usize,
+    pub skipped: usize,
+}
+
+/// Imports the exercises of a wger instance, e.g. `https://wger.de`, with
+/// their English names. Exercises are matched by name ignoring case, existing
+/// ones only get muscles and equipment if they have neither yet.
+pub async fn import(pool: &Pool<Sqlite>, base_url: &str) -> Result<ImportResult> {
+    let client = reqwest::Client::new();
+
+    let mut existing = dal::get_exercises(pool, &ExerciseFilterInput::default())
+        .await?
+        .into_iter()
+        .map(|exercise| (exercise.name.to_lowercase(), exercise))
+        .collect::<HashMap<_, _>>();
+
+    let mut result = ImportResult::default();
+    let mut url = Some(format!(
+        "{}/api/v2/exerciseinfo/?language={LANGUAGE_ENGLISH}&limit={PAGE_SIZE}",
+        base_url.trim_end_matches('/')
+    ));
+
+    while let Some(page_url) = url {
+        info!(url = page_url, "Fetching wger exercises.");
+
+        let page: Page = client
+            .get(&page_url)
+            .send()
+            .await
+            .context("Failed to fetch wger exercises")?
+            .error_for_status()
+            .context("wger rejected the request")?
+            .json()
+            .await
+            .context("Failed to parse wger exercises")?;
+
+        for exercise in page.results {
+            let Some(input) = into_input(&exercise) else {
+                result.skipped += 1;
+                continue;
+            };
+
+            if let Err(err) = validation::validate_exercise(&input) {
+                debug!(id = exercise.id, ?err, "Skipping invalid wger exercise.");
+                result.skipped += 1;
+                continue;
+            }
+
+            match existing.get(&input.name.to_lowercase()) {
+                None => {
+                    let created = dal::create_exercise(pool, &input).await?;
+                    existing.insert(created.name.to_lowercase(), created);
+                    result.created += 1;
+                }
+                Some(current)
+                    if current.primary_muscles.is_none() && current.equipment.is_none() =>
+                {
+                    let input = ExerciseInput {
+                        name: current.name.clone(),
+                        modality: None,
+                        category: current.category.or(input.category),
+                        description: None,
+                        instructions: None,
+                        video_url: None,
+                        default_increment: None,
+                        min_repetitions: None,
+                        max_repetitions: None,
+                        ..input
+                    };
+                    let updated = dal::update_exercise(pool, current.id, &input).await?;
+                    existing.insert(updated.name.to_lowercase(), updated);
+                    result.updated += 1;
+                }
+                Some(_) => result.skipped += 1,
+            }
+        }
+
+        url = page.next;
+    }
+
+    Ok(result)
+}
+
+/// Exercises without an English translation are skipped.
+fn into_input(exercise: &WgerExercise) -> Option<ExerciseInput> {
+    let translation = exercise
+        .translations
+        .iter()
+        .find(|translation| translation.language == LANGUAGE_ENGLISH)?;
+
+    let name = translation.name.trim().to_string();
+    if name.is_empty() {
+        return None;
+    }
+
+    let description = strip_html(&translation.description)
+        .chars()
+        .take(validation::MAX_DESCRIPTION_LENGTH)
+        .collect::<String>();
+
+    let equipment = exercise
+        .equipment
+        .iter()
+        .filter(|equipment| equipment.id != EQUIPMENT_NONE)
+        .map(|equipment| equipment.name.replace(',', "").trim().to_lowercase())
+        .collect::<Vec<_>>();
+
+    let category = if exercise.equipment.iter().any(|e| e.id == EQUIPMENT_NONE) {
+        Some(ExerciseCategory::Bodyweight)
+    } else if equipment.iter().any(|e| e == "barbell") {
+        Some(ExerciseCategory::Barbell)
+    } else if equipment.iter().any(|e| e == "dumbbell") {
+        Some(ExerciseCategory::Dumbbell)
+    } else {
+        None
+    };
+
+    let modality = if exercise.category.id == CATEGORY_CARDIO {
+        ExerciseModality::Cardio
+    } else {
+        ExerciseModality::Strength
+    };
+
+    // A muscle has a single role, primary wins.
+    let primary_muscles = map_muscles(&exercise.muscles);
+    let secondary_muscles = map_muscles(&exercise.muscles_secondary)
+        .into_iter()
+        .filter(|muscle| !primary_muscles.contains(muscle))
+        .collect();
+
+    Some(ExerciseInput {
+        name,
+        modality: Some(modality),
+        category,
+        description: Some(description),
+        instructions: None,
+        video_url: None,
+        default_increment: None,
+        min_repetitions: None,
+        max_repetitions: None,
+        primary_muscles: Some(primary_muscles),
+        secondary_muscles: Some(secondary_muscles),
+        equipment: Some(equipment),
+        tags: None,
+    })
+}
+
+fn map_muscles(muscles: &[WgerReference]) -> Vec<String> {
+    let mut mapped = muscles
+        .iter()
+        .filter_map(|muscle| MUSCLES.iter().find(|(id, _)| *id == muscle.id))
+        .map(|(_, name)| name.to_string())
+        .collect::<Vec<_>>();
+    mapped.sort();
+    mapped.dedup();
+    mapped
+}
+
+/// Descriptions of wger are HTML, only their text is kept.
+fn strip_html(html: &str) -> String {
+    let mut text = String::with_capacity(html.len());
+    let mut in_tag = false;
+    for c in html.chars() {
+        match c {
+            '<' => in_tag = true,
+            '>' if in_tag => {
+                in_tag = false;
+                text.push(' ');
+            }
+            c if !in_tag => text.push(c),
+            _ => {}
+        }
+    }
+
+    text.split_whitespace().collect::<Vec<_>>().join(" ")
+}